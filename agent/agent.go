@@ -3,14 +3,11 @@ package agent
 import (
 	"time"
 	"github.com/hansen1101/go_heating/system"
-	"github.com/hansen1101/go_heating/system/gpio"
 )
 
 var(
 	lastState system.SystemState
-	buffer_pump *system.Pump
-	radiator_pump *system.Pump
-	burner *gpio.Pin
+	actuators *system.ActuatorRegistry
 )
 
 type HeatingAgent interface {
@@ -130,16 +127,28 @@ func (p *Percept) checkValidity()(bool){
 	return true
 }
 */
-func SetPumpW(p *system.Pump)(){
-	buffer_pump = p
+func SetActuators(r *system.ActuatorRegistry)(){
+	actuators = r
 }
 
-func SetPumpH(p *system.Pump)(){
-	radiator_pump = p
+// Returns the last commanded state of the named actuator, zero if it is not registered
+func actuatorState(name string)(system.Setting){
+	if actuators != nil {
+		if a := actuators.Get(name); a != nil {
+			return a.State()
+		}
+	}
+	return system.Setting{}
 }
 
-func SetBurner(b *gpio.Pin)(){
-	burner = b
+// Returns the constraints of the named actuator, false if it is not registered
+func actuatorConstraints(name string)(system.Constraints, bool){
+	if actuators != nil {
+		if a := actuators.Get(name); a != nil {
+			return a.Constraints(), true
+		}
+	}
+	return system.Constraints{}, false
 }
 
 func GetLastState()(system.SystemState){
@@ -0,0 +1,36 @@
+package agent
+
+import (
+	"github.com/hansen1101/go_heating/system"
+)
+
+// The ChimneySweepAgent implements the emission measurement mode. The burner is
+// forced on at full load while both pumps circulate at their maximum frequency in
+// order to dump the produced heat into the radiators and the boiler.
+type ChimneySweepAgent struct {
+}
+
+func NewChimneySweepAgent()(a *ChimneySweepAgent){
+	a = &ChimneySweepAgent{}
+	return
+}
+
+// Implementation of HeatingAgent interface
+func (self *ChimneySweepAgent) GetAction(percept *system.Percept)(action *system.Action){
+	action = new(system.Action)
+
+	action.SetBurnerState(true)
+	action.SetWPumpState(true)
+	action.SetHPumpState(true)
+
+	// load the boiler until it is full, afterwards all heat goes to the radiators
+	action.SetTriangleState(percept.BoilerTopTemp.GetValue() < BOILER_MAX_TOP)
+
+	if c,ok := actuatorConstraints(system.ACTUATOR_BOILER_PUMP); ok {
+		action.SetWPumpThrottle(c.MaxFrequency)
+	}
+	if c,ok := actuatorConstraints(system.ACTUATOR_RADIATOR_PUMP); ok {
+		action.SetHPumpThrottle(c.MaxFrequency)
+	}
+	return
+}
@@ -0,0 +1,35 @@
+package agent
+
+import (
+	"github.com/hansen1101/go_heating/system"
+)
+
+// The DegradedAgent takes over if a sensor that is required to operate the burner
+// safely (kettle or boiler) is unhealthy. The burner is switched off while both pumps
+// keep circulating at their minimum frequency in order to dissipate the remaining heat
+// of the kettle into the radiators.
+type DegradedAgent struct {
+}
+
+func NewDegradedAgent()(a *DegradedAgent){
+	a = &DegradedAgent{}
+	return
+}
+
+// Implementation of HeatingAgent interface
+func (self *DegradedAgent) GetAction(percept *system.Percept)(action *system.Action){
+	action = new(system.Action)
+
+	action.SetBurnerState(false)
+	action.SetWPumpState(true)
+	action.SetHPumpState(true)
+	action.SetTriangleState(false)
+
+	if c,ok := actuatorConstraints(system.ACTUATOR_BOILER_PUMP); ok {
+		action.SetWPumpThrottle(c.MinFrequency)
+	}
+	if c,ok := actuatorConstraints(system.ACTUATOR_RADIATOR_PUMP); ok {
+		action.SetHPumpThrottle(c.MinFrequency)
+	}
+	return
+}
@@ -242,7 +242,7 @@ func (s *ADPState) getStateHash()(ReflexStateHash){
 	return ReflexStateHash(fmt.Sprintf("kd%d_cv%.2f_,frdd%.2f_rd%.2f_fd%.2f_hep%d_her%d_,bd%d_wep%d_wep%d_wlp%d",s.kettleLevel,s.circulationValue,s.hForeReverseDiffDelta,s.hReverseDelta,s.hForeRunTempDelta,s.hEnergyPotential,s.hEnergyRequirement,s.boilerDelta,s.wEnergyPotential,s.wEnergyRequirement,s.wLoadPotential))
 }
 func (s *ADPState) Terminal()(bool){
-	switch burner.GetValue() {
+	switch actuatorState(system.ACTUATOR_BURNER).On {
 	case true:
 	case false:
 	}
@@ -426,7 +426,7 @@ func (s *ADPState) calcCirculationValue(predecessor AiState, percept_time, prede
 	duration_base := 15.0
 	exp_sum_bound := 1.75
 	freq := transitionAction.GetHPumpThrottle()
-	freq_eff:=predecessor.getCirculationValue() * radiator_pump.GetMaxFreq()
+	freq_eff:=predecessor.getCirculationValue() * radiatorMaxFreq()
 	var divider, numerator float64
 	if freq_eff > transitionAction.GetHPumpThrottle() {
 		// frequency was decreased, freq_eff converges from above
@@ -439,13 +439,13 @@ func (s *ADPState) calcCirculationValue(predecessor AiState, percept_time, prede
 	d := math.Min(numerator / divider,exp_sum_bound)
 
 	approx_delta := math.Abs(freq_eff - freq)
-	b1 := math.Abs(predecessor.getCirculationValue() - freq / radiator_pump.GetMaxFreq()) / 2.0 + d
+	b1 := math.Abs(predecessor.getCirculationValue() - freq / radiatorMaxFreq()) / 2.0 + d
 	exp1 := math.Pow(b1,2.0) * -1.0
 	exp2 := math.Pow(2.0,exp1) * -1.0
 	exp3 := math.Pow(2.0,exp2)
 	h := math.Pow(exp3,approx_delta) // invariant 1>=h>0
 	duration := float64((*percept_time).Sub(*predecessor_time).Seconds())
-	exact_value := (duration / duration_base * h * freq / radiator_pump.GetMaxFreq() + predecessor.getCirculationValue() * gamma)/(duration / duration_base * h + gamma)
+	exact_value := (duration / duration_base * h * freq / radiatorMaxFreq() + predecessor.getCirculationValue() * gamma)/(duration / duration_base * h + gamma)
 	s.circulationValue = approxCirculationValue(exact_value)
 	return
 }
@@ -1055,9 +1055,10 @@ func (r *ReflexAgent) PrintSeq()(){
 }
 
 func (agent *ReflexAgent) initStateHistory(p *system.Percept)(){
-	wState,_ := buffer_pump.GetState()
-	hState,hFreq := radiator_pump.GetState()
-	bState := burner.GetValue()
+	wState := actuatorState(system.ACTUATOR_BOILER_PUMP).On
+	radiator := actuatorState(system.ACTUATOR_RADIATOR_PUMP)
+	hState,hFreq := radiator.On,radiator.Frequency
+	bState := actuatorState(system.ACTUATOR_BURNER).On
 	a:=&system.Action{
 		//RollOut:DefaultRollOut
 	}
@@ -1098,21 +1099,21 @@ func (agent *ReflexAgent) calcCirculationValueRunningAverage(actualState *Reflex
 	} else {
 		var ratio, func_value, throttle, history float64
 		if predecessor_state.action == nil {
-			_,throttle = radiator_pump.GetState()
+			throttle = actuatorState(system.ACTUATOR_RADIATOR_PUMP).Frequency
 		} else {
 			throttle = predecessor_state.action.GetHPumpThrottle()
 		}
 		history = predecessor_state.circulationValue
 		for time_diff := actualState.Percept.CurrentTime.Sub(predecessor_state.Percept.CurrentTime).Seconds(); time_diff >= intervall_length; time_diff -= intervall_length{
-			func_value = history * radiator_pump.GetMaxFreq() - throttle
+			func_value = history * radiatorMaxFreq() - throttle
 			if func_value < 0.0 {
 				func_value *= -1.0
 			}
 			ratio = math.Pow(base,func_value)
-			actualState.circulationValue = (ratio * throttle / radiator_pump.GetMaxFreq() + gamma * history) / (ratio+gamma)
+			actualState.circulationValue = (ratio * throttle / radiatorMaxFreq() + gamma * history) / (ratio+gamma)
 			history = actualState.circulationValue
 		}
-		actualState.circulationValue = (time_diff / intervall_length * ratio * throttle / radiator_pump.GetMaxFreq() + gamma * history) / (time_diff/intervall_length*ratio+gamma)
+		actualState.circulationValue = (time_diff / intervall_length * ratio * throttle / radiatorMaxFreq() + gamma * history) / (time_diff/intervall_length*ratio+gamma)
 	}
 }
 
@@ -1377,7 +1378,7 @@ func (agent *ReflexAgent) takeStateTransition(actualState *ReflexState)(transiti
 			transition = true
 		}
 
-		on,_ := buffer_pump.GetState()
+		on := actuatorState(system.ACTUATOR_BOILER_PUMP).On
 		switch on {
 		case true:
 			if 2 * actualState.Percept.BoilerMidTemp.GetValue() - actualState.Percept.WReverseRunTemp.GetValue() < actualState.Percept.KettleTemp.GetValue() {
@@ -1390,7 +1391,7 @@ func (agent *ReflexAgent) takeStateTransition(actualState *ReflexState)(transiti
 			}
 		}
 
-		_,freq := radiator_pump.GetState()
+		freq := actuatorState(system.ACTUATOR_RADIATOR_PUMP).Frequency
 		if actualState.circulationValue - freq < 0.005 && actualState.circulationValue - freq > -0.005 {
 			transition = true
 		}
@@ -1599,3 +1600,9 @@ func initAction()(*ReflexAction){
 		//RollOut:DefaultRollOut
 		}}
 }
+
+// Returns the max frequency of the radiator pump
+func radiatorMaxFreq()(float64){
+	c,_ := actuatorConstraints(system.ACTUATOR_RADIATOR_PUMP)
+	return c.MaxFrequency
+}
@@ -1,27 +1,45 @@
 package agent
 
 import (
+	"context"
 	"github.com/hansen1101/go_heating/system"
+	"github.com/hansen1101/go_heating/system/hardware"
 	"fmt"
+	"time"
 )
 
 const (
 	BOILER_TARGET_FALLBACK = 40000
 	BOILER_MAX_TOP = 45000
+	TARGET_REQUEST_TIMEOUT = time.Second * 10
+	FROST_OUTSIDE = 3000	// radiators run in frost protection below this outside temperature
 )
 
+var (
+	boiler_on_delta = 2000		// burner starts below target - boiler_on_delta
+	boiler_off_delta = 3500		// burner stops at target + boiler_off_delta
+	boiler_max_top = BOILER_MAX_TOP	// hot water is not loaded above this boiler top temperature
+
+	// sensor roles the decisions of the SimpleHeatingAgent are based on
+	SIMPLE_HEATING_ROLES = []string{hardware.ROLE_OUTSIDE,hardware.ROLE_TWO,hardware.ROLE_TPO,hardware.ROLE_TPU,hardware.ROLE_KETTLE}
+)
+
+// Sets the switching thresholds of the agents, see system.Hysteresis
+func SetHysteresis(h system.Hysteresis)(){
+	boiler_on_delta,boiler_off_delta,boiler_max_top = h.BoilerOn,h.BoilerOff,h.BoilerMaxTop
+	w_lower_bound,w_target_bound,h_deviation = h.WaterLowerBound,h.WaterTargetBound,h.RadiatorDeviation
+}
+
 type SimpleHeatingAgent struct {
-	config_chan chan int
-	config_request_generator system.ConfigRequester
+	targets system.TargetSource
 }
 
-func NewSimpleHeatingAgent(config_oracle_available bool)(a *SimpleHeatingAgent){
-	if config_oracle_available {
-		a = &SimpleHeatingAgent{make(chan int),system.MakeConfigRequest}
-	} else {
-		a = &SimpleHeatingAgent{}
-	}
-	return
+// Generates an agent that heats the boiler to the targets of the given source,
+// without source (nil) BOILER_TARGET_FALLBACK is used. A source that implements
+// system.SetpointSource also switches hot water and frost protection, a source that
+// implements system.HysteresisSource sets the switching thresholds.
+func NewSimpleHeatingAgent(targets system.TargetSource)(a *SimpleHeatingAgent){
+	return &SimpleHeatingAgent{targets}
 }
 
 //type Policy func(system.SystemState)(system.Action)
@@ -36,14 +54,36 @@ func (self *SimpleHeatingAgent) GetAction(percept *system.Percept)(action *syste
 	// requesting percepts reward
 
 	// request action from policy
-	boiler_target := BOILER_TARGET_FALLBACK
-	if self.config_chan != nil {
+	setpoint := system.Setpoint{Boiler:BOILER_TARGET_FALLBACK,HotWater:true}
+	if self.targets != nil {
 		fmt.Printf("[Agent]\ttry to get boiler target...")
-		self.config_request_generator(self.config_chan,percept)
-		boiler_target = <-self.config_chan
+		ctx,cancel := context.WithTimeout(context.Background(),TARGET_REQUEST_TIMEOUT)
+		var err error
+		if source,ok := self.targets.(system.SetpointSource); ok {
+			var received system.Setpoint
+			if received,err = source.Setpoint(ctx,percept); err == nil {
+				setpoint = received
+			}
+		} else {
+			var target int
+			if target,err = self.targets.Target(ctx,percept); err == nil {
+				setpoint.Boiler = target
+			}
+		}
+		if err != nil {
+			fmt.Printf(" failed: %v, using fallback...",err)
+		}
+		cancel()
+		if source,ok := self.targets.(system.HysteresisSource); ok {
+			SetHysteresis(source.Hysteresis())
+		}
+	}
+	fmt.Printf(" received: %d\n",setpoint.Boiler)
+	burnerOn,triangleOn := waterNeedsHeating(percept,getState().GetBurnerState(),setpoint.Boiler)
+	if !setpoint.HotWater {
+		// the boiler is only held at its (frost) target, the valve loads it while the burner runs for it
+		triangleOn = triangleOn && burnerOn
 	}
-	fmt.Printf(" received: %d\n",boiler_target)
-	burnerOn,triangleOn := waterNeedsHeating(percept,getState().GetBurnerState(),boiler_target)
 	timePassedSinceLastTransition := percept.CurrentTime.Sub(getState().Time)
 	action.SetBurnerState(burnerOn)
 	action.SetTriangleState(triangleOn)
@@ -52,7 +92,11 @@ func (self *SimpleHeatingAgent) GetAction(percept *system.Percept)(action *syste
 	} else {
 		action.SetWPumpState(energyIsAvailable(percept))
 	}
-	action.SetHPumpState(radiatorsNeedEnergy(percept))
+	if setpoint.Frost || setpoint.HeatingOff {
+		action.SetHPumpState(frostProtection(percept))
+	} else {
+		action.SetHPumpState(radiatorsNeedEnergy(percept))
+	}
 
 	// update internal fields
 
@@ -66,21 +110,34 @@ func getState()(state *system.ActorState){
 	return
 }
 
+// Decides on burner and triangle valve for loading the boiler.
+// Without boiler temperatures the boiler is not loaded.
 func waterNeedsHeating(percept *system.Percept, burnerIsOn bool, boilerTarget int)(burner bool,triangle bool){
 	triangle = true
 	burner = false
-	if percept.BoilerTopTemp.GetValue() >= 45000 {
+	if !system.Usable(percept.BoilerTopTemp) || !system.Usable(percept.BoilerMidTemp) {
+		return false,false
+	}
+	if percept.BoilerTopTemp.GetValue() >= boiler_max_top {
 		triangle = false
 	}
-	if percept.BoilerMidTemp.GetValue() < boilerTarget - 2000 && !burnerIsOn {
+	if percept.BoilerMidTemp.GetValue() < boilerTarget - boiler_on_delta && !burnerIsOn {
 		burner= true
-	} else if percept.BoilerMidTemp.GetValue() < boilerTarget + 3500 && burnerIsOn {
+	} else if percept.BoilerMidTemp.GetValue() < boilerTarget + boiler_off_delta && burnerIsOn {
 		burner= true
 	}
 	return
 }
 
+// Decides on the boiler pump. Without kettle temperature the pump runs in order to carry
+// off the heat, without boiler bottom temperature the kettle is compared to the boiler mid.
 func energyIsAvailable(percept *system.Percept)(bool){
+	if !system.Usable(percept.KettleTemp) {
+		return true
+	}
+	if !system.Usable(percept.WForeRunTemp) {
+		return system.Usable(percept.BoilerMidTemp) && percept.BoilerMidTemp.GetValue() < percept.KettleTemp.GetValue()
+	}
 	if percept.WForeRunTemp.GetValue() + 300 < percept.KettleTemp.GetValue() {
 	//if percept.BoilerMidTemp.GetValue() < percept.KettleTemp.GetValue() {
 		return true
@@ -89,10 +146,16 @@ func energyIsAvailable(percept *system.Percept)(bool){
 	}
 }
 
+// Decides on the radiator pump. Without outside temperature heating is assumed to be required.
 func radiatorsNeedEnergy(percept *system.Percept)(bool){
-	if percept.OutsideTemp.GetValue() < 19000 && (percept.CurrentTime.Hour() >= 5 && percept.CurrentTime.Minute() >= 30 || percept.CurrentTime.Hour() >= 6) && percept.CurrentTime.Hour() < 23 {
+	if (!system.Usable(percept.OutsideTemp) || percept.OutsideTemp.GetValue() < 19000) && (percept.CurrentTime.Hour() >= 5 && percept.CurrentTime.Minute() >= 30 || percept.CurrentTime.Hour() >= 6) && percept.CurrentTime.Hour() < 23 {
 		return true
 	} else {
 		return false
 	}
 }
+
+// Decides on the radiator pump in frost protection. Without outside temperature frost is assumed.
+func frostProtection(percept *system.Percept)(bool){
+	return !system.Usable(percept.OutsideTemp) || percept.OutsideTemp.GetValue() < FROST_OUTSIDE
+}
@@ -0,0 +1,185 @@
+package toml
+
+import(
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var(
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// Decodes a TOML document into the struct v points to. Keys are matched against
+// the `toml` tag of the struct fields (the lower case field name if no tag is set).
+// Keys without a matching field are reported as error. time.Duration fields are
+// decoded from strings like "20m".
+func Unmarshal(data []byte, v interface{})(error){
+	doc,err := Parse(data)
+	if err != nil {
+		return err
+	}
+	return Decode(doc,v)
+}
+
+// Decodes an already parsed Table into the struct v points to.
+func Decode(doc Table, v interface{})(error){
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("toml: decode target must be a non-nil pointer")
+	}
+	return decodeValue(map[string]interface{}(doc),rv.Elem(),"")
+}
+
+// Returns the key of a struct field and its options, key is "-" for skipped fields
+func fieldKey(f reflect.StructField)(key string, omitempty bool){
+	tag := f.Tag.Get("toml")
+	parts := strings.Split(tag,",")
+	key = parts[0]
+	if key == "" {
+		key = strings.ToLower(f.Name)
+	}
+	for _,option := range parts[1:] {
+		if option == "omitempty" {
+			omitempty = true
+		}
+	}
+	if f.PkgPath != "" {
+		key = "-"
+	}
+	return
+}
+
+func joinPath(path, key string)(string){
+	if path == "" {
+		return key
+	}
+	return path+"."+key
+}
+
+func decodeValue(value interface{}, target reflect.Value, path string)(error){
+	if target.Type() == durationType {
+		s,ok := value.(string)
+		if !ok {
+			return fmt.Errorf("toml: %s: expected duration string, got %v",path,value)
+		}
+		d,err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("toml: %s: %v",path,err)
+		}
+		target.SetInt(int64(d))
+		return nil
+	}
+
+	switch target.Kind() {
+	case reflect.Ptr:
+		if target.IsNil() {
+			target.Set(reflect.New(target.Type().Elem()))
+		}
+		return decodeValue(value,target.Elem(),path)
+	case reflect.Interface:
+		target.Set(reflect.ValueOf(value))
+		return nil
+	case reflect.Struct:
+		table,ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("toml: %s: expected table",path)
+		}
+		fields := make(map[string]int)
+		for i := 0; i < target.NumField(); i++ {
+			if key,_ := fieldKey(target.Type().Field(i)); key != "-" {
+				fields[key] = i
+			}
+		}
+		for key,v := range table {
+			i,ok := fields[key]
+			if !ok {
+				return fmt.Errorf("toml: unknown key %s",joinPath(path,key))
+			}
+			if err := decodeValue(v,target.Field(i),joinPath(path,key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		table,ok := value.(map[string]interface{})
+		if !ok || target.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("toml: %s: expected table",path)
+		}
+		if target.IsNil() {
+			target.Set(reflect.MakeMap(target.Type()))
+		}
+		for key,v := range table {
+			elem := reflect.New(target.Type().Elem()).Elem()
+			if err := decodeValue(v,elem,joinPath(path,key)); err != nil {
+				return err
+			}
+			target.SetMapIndex(reflect.ValueOf(key).Convert(target.Type().Key()),elem)
+		}
+		return nil
+	case reflect.Slice:
+		var items []interface{}
+		switch array := value.(type) {
+		case []interface{}:
+			items = array
+		case []map[string]interface{}:
+			for _,t := range array {
+				items = append(items,t)
+			}
+		default:
+			return fmt.Errorf("toml: %s: expected array",path)
+		}
+		slice := reflect.MakeSlice(target.Type(),len(items),len(items))
+		for i,item := range items {
+			if err := decodeValue(item,slice.Index(i),fmt.Sprintf("%s[%d]",path,i)); err != nil {
+				return err
+			}
+		}
+		target.Set(slice)
+		return nil
+	case reflect.String:
+		s,ok := value.(string)
+		if !ok {
+			return fmt.Errorf("toml: %s: expected string, got %v",path,value)
+		}
+		target.SetString(s)
+		return nil
+	case reflect.Bool:
+		b,ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("toml: %s: expected boolean, got %v",path,value)
+		}
+		target.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i,ok := value.(int64)
+		if !ok || target.OverflowInt(i) {
+			return fmt.Errorf("toml: %s: expected integer, got %v",path,value)
+		}
+		target.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i,ok := value.(int64)
+		if !ok || i < 0 || target.OverflowUint(uint64(i)) {
+			return fmt.Errorf("toml: %s: expected unsigned integer, got %v",path,value)
+		}
+		target.SetUint(uint64(i))
+		return nil
+	case reflect.Float32, reflect.Float64:
+		switch f := value.(type) {
+		case float64:
+			target.SetFloat(f)
+		case int64:
+			target.SetFloat(float64(f))
+		default:
+			return fmt.Errorf("toml: %s: expected number, got %v",path,value)
+		}
+		if math.IsInf(target.Float(),0) {
+			return fmt.Errorf("toml: %s: number out of range",path)
+		}
+		return nil
+	}
+	return fmt.Errorf("toml: %s: unsupported field type %s",path,target.Type())
+}
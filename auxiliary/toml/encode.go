@@ -0,0 +1,191 @@
+package toml
+
+import(
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Encodes the struct v as TOML document. Plain values are written first, nested
+// structs and maps as tables and slices of structs as arrays of tables. Fields
+// tagged omitempty are skipped if they hold the zero value.
+func Marshal(v interface{})([]byte, error){
+	var buffer bytes.Buffer
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("toml: encode source must be a struct")
+	}
+	if err := encodeTable(&buffer,rv,""); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Returns true if the value is encoded as (array of) table(s)
+func isTable(v reflect.Value)(bool){
+	v = reflect.Indirect(v)
+	switch v.Kind() {
+	case reflect.Struct:
+		return v.Type() != durationType
+	case reflect.Map:
+		return true
+	case reflect.Slice:
+		return reflect.Indirect(reflect.New(v.Type().Elem()).Elem()).Kind() == reflect.Struct
+	}
+	return false
+}
+
+func isZero(v reflect.Value)(bool){
+	return reflect.DeepEqual(v.Interface(),reflect.Zero(v.Type()).Interface())
+}
+
+func encodeTable(buffer *bytes.Buffer, v reflect.Value, path string)(error){
+	v = reflect.Indirect(v)
+	type entry struct {
+		key string
+		value reflect.Value
+	}
+	values := make([]entry,0)
+	tables := make([]entry,0)
+
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			key,omitempty := fieldKey(v.Type().Field(i))
+			f := v.Field(i)
+			if key == "-" || (omitempty && isZero(f)) || (f.Kind() == reflect.Ptr && f.IsNil()) {
+				continue
+			}
+			if isTable(f) {
+				tables = append(tables,entry{key,f})
+			} else {
+				values = append(values,entry{key,f})
+			}
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys,func(i, j int)(bool){
+			return keys[i].String() < keys[j].String()
+		})
+		for _,k := range keys {
+			f := v.MapIndex(k)
+			if isTable(f) {
+				tables = append(tables,entry{k.String(),f})
+			} else {
+				values = append(values,entry{k.String(),f})
+			}
+		}
+	}
+
+	for _,e := range values {
+		s,err := encodeValue(e.value)
+		if err != nil {
+			return fmt.Errorf("toml: %s: %v",joinPath(path,e.key),err)
+		}
+		buffer.WriteString(fmt.Sprintf("%s = %s\n",quoteKey(e.key),s))
+	}
+	for _,e := range tables {
+		name := joinPath(path,quoteKey(e.key))
+		f := reflect.Indirect(e.value)
+		if f.Kind() == reflect.Slice {
+			for i := 0; i < f.Len(); i++ {
+				buffer.WriteString(fmt.Sprintf("\n[[%s]]\n",name))
+				if err := encodeTable(buffer,f.Index(i),name); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		buffer.WriteString(fmt.Sprintf("\n[%s]\n",name))
+		if err := encodeTable(buffer,f,name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Quotes keys that can not be written as bare keys
+func quoteKey(key string)(string){
+	if parts,err := splitKey(key); err != nil || len(parts) != 1 {
+		return quote(key)
+	}
+	return key
+}
+
+// Returns s as basic TOML string, only the escapes of the specification are used
+func quote(s string)(string){
+	var buffer bytes.Buffer
+	buffer.WriteByte('"')
+	for _,r := range s {
+		switch r {
+		case '\b':
+			buffer.WriteString(`\b`)
+		case '\t':
+			buffer.WriteString(`\t`)
+		case '\n':
+			buffer.WriteString(`\n`)
+		case '\f':
+			buffer.WriteString(`\f`)
+		case '\r':
+			buffer.WriteString(`\r`)
+		case '"':
+			buffer.WriteString(`\"`)
+		case '\\':
+			buffer.WriteString(`\\`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				buffer.WriteString(fmt.Sprintf(`\u%04X`,r))
+			} else {
+				buffer.WriteRune(r)
+			}
+		}
+	}
+	buffer.WriteByte('"')
+	return buffer.String()
+}
+
+func encodeValue(v reflect.Value)(string, error){
+	if v.Type() == durationType {
+		return quote(time.Duration(v.Int()).String()), nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return "", fmt.Errorf("nil value")
+		}
+		return encodeValue(v.Elem())
+	case reflect.String:
+		return quote(v.String()), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(),10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(),10), nil
+	case reflect.Float32, reflect.Float64:
+		s := strconv.FormatFloat(v.Float(),'f',-1,64)
+		if _,err := strconv.ParseInt(s,10,64); err == nil {
+			s += ".0"
+		}
+		return s, nil
+	case reflect.Slice, reflect.Array:
+		var buffer bytes.Buffer
+		buffer.WriteString("[")
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				buffer.WriteString(", ")
+			}
+			s,err := encodeValue(v.Index(i))
+			if err != nil {
+				return "", err
+			}
+			buffer.WriteString(s)
+		}
+		buffer.WriteString("]")
+		return buffer.String(), nil
+	}
+	return "", fmt.Errorf("unsupported type %s",v.Type())
+}
@@ -0,0 +1,375 @@
+// The toml package implements the subset of TOML (https://toml.io) that is used by the
+// configuration files of the heating system: tables, arrays of tables, strings,
+// integers, floats, booleans and (multi-line) arrays of these values. Dates are
+// represented as strings. Documents can be decoded into structs via Unmarshal and
+// encoded via Marshal, keys are mapped to struct fields by the `toml` field tag.
+package toml
+
+import(
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A parsed table, values are either string, int64, float64, bool, []interface{},
+// map[string]interface{} (table) or []map[string]interface{} (array of tables).
+type Table map[string]interface{}
+
+// Error of a document that violates the supported syntax
+type ParseError struct {
+	Line int
+	Msg string
+}
+
+func (e *ParseError) Error()(string){
+	return fmt.Sprintf("toml: line %d: %s",e.Line,e.Msg)
+}
+
+// Parses the file at path
+func ParseFile(path string)(Table, error){
+	data,err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parses a TOML document into a Table.
+func Parse(data []byte)(doc Table, err error){
+	doc = make(Table)
+	current := map[string]interface{}(doc)
+	defined := make(map[string]bool)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := stripComment(scanner.Text())
+
+		// arrays may span several lines until all brackets are closed
+		start := line
+		for depth(text) > 0 && scanner.Scan() {
+			line++
+			text += " "+stripComment(scanner.Text())
+		}
+		text = strings.TrimSpace(text)
+
+		switch {
+		case text == "":
+			continue
+		case strings.HasPrefix(text,"[["):
+			if !strings.HasSuffix(text,"]]") {
+				return nil, &ParseError{start,"unterminated array of tables header"}
+			}
+			path,err := splitKey(text[2:len(text)-2])
+			if err != nil {
+				return nil, &ParseError{start,err.Error()}
+			}
+			parent,err := walk(doc,path[:len(path)-1])
+			if err != nil {
+				return nil, &ParseError{start,err.Error()}
+			}
+			name := path[len(path)-1]
+			array,ok := parent[name].([]map[string]interface{})
+			if _,exists := parent[name]; exists && !ok {
+				return nil, &ParseError{start,"key "+name+" is already defined"}
+			}
+			// sub-tables of the previous element may be defined again for the new one
+			prefix := strings.Join(path,".")+"."
+			for table := range defined {
+				if strings.HasPrefix(table,prefix) {
+					delete(defined,table)
+				}
+			}
+			current = make(map[string]interface{})
+			parent[name] = append(array,current)
+		case strings.HasPrefix(text,"["):
+			if !strings.HasSuffix(text,"]") {
+				return nil, &ParseError{start,"unterminated table header"}
+			}
+			path,err := splitKey(text[1:len(text)-1])
+			if err != nil {
+				return nil, &ParseError{start,err.Error()}
+			}
+			joined := strings.Join(path,".")
+			if defined[joined] {
+				return nil, &ParseError{start,"table "+joined+" is defined twice"}
+			}
+			defined[joined] = true
+			if current,err = walk(doc,path); err != nil {
+				return nil, &ParseError{start,err.Error()}
+			}
+		default:
+			eq := indexOutsideString(text,'=')
+			if eq < 0 {
+				return nil, &ParseError{start,"expected key = value"}
+			}
+			key,err := splitKey(text[:eq])
+			if err != nil || len(key) != 1 {
+				return nil, &ParseError{start,"invalid key "+strings.TrimSpace(text[:eq])}
+			}
+			if _,exists := current[key[0]]; exists {
+				return nil, &ParseError{start,"key "+key[0]+" is defined twice"}
+			}
+			value,rest,err := parseValue(strings.TrimSpace(text[eq+1:]))
+			if err != nil {
+				return nil, &ParseError{start,err.Error()}
+			}
+			if strings.TrimSpace(rest) != "" {
+				return nil, &ParseError{start,"unexpected "+rest+" after value"}
+			}
+			current[key[0]] = value
+		}
+	}
+	return doc, scanner.Err()
+}
+
+// Returns the table at path, missing tables are created. If the path points
+// to an array of tables the last table of the array is returned.
+func walk(doc Table, path []string)(table map[string]interface{}, err error){
+	table = doc
+	for _,name := range path {
+		switch next := table[name].(type) {
+		case nil:
+			t := make(map[string]interface{})
+			table[name] = t
+			table = t
+		case map[string]interface{}:
+			table = next
+		case []map[string]interface{}:
+			table = next[len(next)-1]
+		default:
+			return nil, fmt.Errorf("key %s is not a table",name)
+		}
+	}
+	return
+}
+
+// Removes a comment from the line, # within strings is kept
+func stripComment(line string)(string){
+	if i := indexOutsideString(line,'#'); i >= 0 {
+		return line[:i]
+	}
+	return line
+}
+
+// Returns the index of the first occurrence of c outside of a string, -1 if c does not occur
+func indexOutsideString(s string, c byte)(int){
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote != 0:
+			if s[i] == '\\' && quote == '"' {
+				i++
+			} else if s[i] == quote {
+				quote = 0
+			}
+		case s[i] == '"' || s[i] == '\'':
+			quote = s[i]
+		case s[i] == c:
+			return i
+		}
+	}
+	return -1
+}
+
+// Returns the number of open array brackets of a key = value line
+func depth(s string)(d int){
+	eq := indexOutsideString(s,'=')
+	if eq < 0 {
+		return 0
+	}
+	s = s[eq+1:]
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote != 0:
+			if s[i] == '\\' && quote == '"' {
+				i++
+			} else if s[i] == quote {
+				quote = 0
+			}
+		case s[i] == '"' || s[i] == '\'':
+			quote = s[i]
+		case s[i] == '[':
+			d++
+		case s[i] == ']':
+			d--
+		}
+	}
+	return
+}
+
+// Splits a (dotted) key into its parts
+func splitKey(s string)(parts []string, err error){
+	parts = make([]string,0)
+	for _,part := range strings.Split(s,".") {
+		part = strings.TrimSpace(part)
+		if len(part) >= 2 && part[0] == '"' && part[len(part)-1] == '"' {
+			if part,err = unescape(part[1:len(part)-1]); err != nil {
+				return nil, err
+			}
+		} else if part == "" || strings.IndexFunc(part,func(r rune)(bool){
+			return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-')
+		}) >= 0 {
+			return nil, fmt.Errorf("invalid key %q",s)
+		}
+		parts = append(parts,part)
+	}
+	return
+}
+
+// Parses the value at the beginning of s.
+// @return the value and the remaining input
+func parseValue(s string)(value interface{}, rest string, err error){
+	if s == "" {
+		return nil, "", fmt.Errorf("missing value")
+	}
+	switch s[0] {
+	case '"':
+		for i := 1; i < len(s); i++ {
+			if s[i] == '\\' {
+				i++
+			} else if s[i] == '"' {
+				value,err = unescape(s[1:i])
+				return value, s[i+1:], err
+			}
+		}
+		return nil, "", fmt.Errorf("unterminated string")
+	case '\'':
+		if i := strings.IndexByte(s[1:],'\''); i >= 0 {
+			return s[1:i+1], s[i+2:], nil
+		}
+		return nil, "", fmt.Errorf("unterminated string")
+	case '[':
+		array := make([]interface{},0)
+		rest = strings.TrimSpace(s[1:])
+		for {
+			if strings.HasPrefix(rest,"]") {
+				return array, rest[1:], nil
+			}
+			var v interface{}
+			if v,rest,err = parseValue(rest); err != nil {
+				return nil, "", err
+			}
+			array = append(array,v)
+			rest = strings.TrimSpace(rest)
+			if strings.HasPrefix(rest,",") {
+				rest = strings.TrimSpace(rest[1:])
+			} else if !strings.HasPrefix(rest,"]") {
+				return nil, "", fmt.Errorf("expected , or ] in array")
+			}
+		}
+	}
+
+	end := strings.IndexAny(s,",] \t")
+	if end < 0 {
+		end = len(s)
+	}
+	token := s[:end]
+	rest = s[end:]
+	switch token {
+	case "true":
+		return true, rest, nil
+	case "false":
+		return false, rest, nil
+	}
+	if value,err = parseNumber(token); err != nil {
+		return nil, "", err
+	}
+	return value, rest, nil
+}
+
+// Replaces the escapes of a basic string, only the escapes of the specification are accepted
+func unescape(s string)(string, error){
+	var buffer bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			if s[i] < 0x20 && s[i] != '\t' || s[i] == 0x7f {
+				return "", fmt.Errorf("control character 0x%02x in string",s[i])
+			}
+			buffer.WriteByte(s[i])
+			continue
+		}
+		if i++; i == len(s) {
+			return "", fmt.Errorf("unterminated escape in string")
+		}
+		switch s[i] {
+		case 'b':
+			buffer.WriteByte('\b')
+		case 't':
+			buffer.WriteByte('\t')
+		case 'n':
+			buffer.WriteByte('\n')
+		case 'f':
+			buffer.WriteByte('\f')
+		case 'r':
+			buffer.WriteByte('\r')
+		case '"', '\\':
+			buffer.WriteByte(s[i])
+		case 'u', 'U':
+			digits := 4
+			if s[i] == 'U' {
+				digits = 8
+			}
+			if i+digits >= len(s) {
+				return "", fmt.Errorf("invalid escape \\%s in string",s[i:])
+			}
+			code,err := strconv.ParseUint(s[i+1:i+1+digits],16,32)
+			if err != nil || !utf8.ValidRune(rune(code)) {
+				return "", fmt.Errorf("invalid escape \\%s in string",s[i:i+1+digits])
+			}
+			buffer.WriteRune(rune(code))
+			i += digits
+		default:
+			return "", fmt.Errorf("invalid escape \\%c in string",s[i])
+		}
+	}
+	return buffer.String(), nil
+}
+
+// Parses an integer or float token. Decimal numbers must not have leading zeros,
+// hexadecimal, octal and binary integers need their 0x, 0o or 0b prefix.
+func parseNumber(token string)(value interface{}, err error){
+	number := strings.Replace(token,"_","",-1)
+	if len(number) > 2 && number[0] == '0' {
+		base := 0
+		switch number[1] {
+		case 'x':
+			base = 16
+		case 'o':
+			base = 8
+		case 'b':
+			base = 2
+		}
+		if base != 0 {
+			if i,convert_err := strconv.ParseUint(number[2:],base,63); convert_err == nil {
+				return int64(i), nil
+			}
+			return nil, fmt.Errorf("invalid value %s",token)
+		}
+	}
+
+	digits := strings.TrimLeft(number,"+-")
+	if end := strings.IndexAny(digits,".eE"); end >= 0 {
+		digits = digits[:end]
+	}
+	if len(digits) > 1 && digits[0] == '0' {
+		return nil, fmt.Errorf("invalid value %s (leading zero)",token)
+	}
+	if i,convert_err := strconv.ParseInt(number,10,64); convert_err == nil {
+		return i, nil
+	}
+	if strings.IndexFunc(number,func(r rune)(bool){
+		return !(r >= '0' && r <= '9' || r == '.' || r == 'e' || r == 'E' || r == '+' || r == '-')
+	}) < 0 {
+		if f,convert_err := strconv.ParseFloat(number,64); convert_err == nil {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid value %s",token)
+}
@@ -0,0 +1,151 @@
+package toml
+
+import(
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testRelay struct {
+	Name string `toml:"name"`
+	Pin int `toml:"pin"`
+	ActiveLow bool `toml:"active_low"`
+}
+
+type testDocument struct {
+	Title string `toml:"title"`
+	Ratio float64 `toml:"ratio"`
+	Timeout time.Duration `toml:"timeout,omitempty"`
+	Hours []int `toml:"hours"`
+	Limits map[string]int `toml:"limits"`
+	Relays []testRelay `toml:"relay"`
+}
+
+var document = `
+# hardware topology
+title = "heating # not a comment"
+ratio = 1
+timeout = "20m"
+hours = [
+	5, 6, # morning
+	22,
+]
+
+[limits]
+kettle = 65_000
+"boiler top" = -1
+
+[[relay]]
+name = 'burner'
+pin = 18
+active_low = true
+
+[[relay]]
+name = "triangle"
+pin = 27
+`
+
+func TestUnmarshal(t *testing.T){
+	var doc testDocument
+	if err := Unmarshal([]byte(document),&doc); err != nil {
+		t.Fatal(err)
+	}
+	expected := testDocument{
+		Title:"heating # not a comment",
+		Ratio:1.0,
+		Timeout:20*time.Minute,
+		Hours:[]int{5,6,22},
+		Limits:map[string]int{"kettle":65000,"boiler top":-1},
+		Relays:[]testRelay{{"burner",18,true},{"triangle",27,false}},
+	}
+	if !reflect.DeepEqual(doc,expected) {
+		t.Error("For","document","expected",expected,"got",doc)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T){
+	var params = []struct{
+		document string
+		err string
+	} {
+		{"unknown = 1","unknown key unknown"},
+		{"title = 1","expected string"},
+		{"[[relay]]\npin = \"18\"","relay[0].pin"},
+		{"[[relay]]\nname = \"a\"\nname = \"b\"","line 3"},
+		{"hours = [1, 2","line 1"},
+		{"ratio = 1..2","invalid value"},
+		{"timeout = \"20 minutes\"","timeout"},
+		{"ratio = 010","leading zero"},
+		{"ratio = 01.5","leading zero"},
+		{"ratio = 0x1p3","invalid value"},
+		{"[limits]\nkettle = 0xg","invalid value"},
+		{"[[relay]]\n[relay.a]\n[relay.a]","defined twice"},
+		{"title = \"bell \\a\"","invalid escape"},
+		{"title = \"nul \\x00\"","invalid escape"},
+	}
+	for _,param := range params {
+		var doc testDocument
+		err := Unmarshal([]byte(param.document),&doc)
+		if err == nil || !strings.Contains(err.Error(),param.err) {
+			t.Error("For",param.document,"expected",param.err,"got",err)
+		}
+	}
+}
+
+func TestParse(t *testing.T){
+	var params = []struct{
+		document string
+		expected Table
+	} {
+		{"b = 0x10\nc = 0o17\nd = 0b101\ne = -0\nf = +1_000\ng = 0.5",
+			Table{"b":int64(16),"c":int64(15),"d":int64(5),"e":int64(0),"f":int64(1000),"g":0.5}},
+		{"[[a]]\n[a.b]\nx = 1\n[[a]]\n[a.b]\nx = 2",
+			Table{"a":[]map[string]interface{}{
+				{"b":map[string]interface{}{"x":int64(1)}},
+				{"b":map[string]interface{}{"x":int64(2)}},
+			}}},
+	}
+	for _,param := range params {
+		doc,err := Parse([]byte(param.document))
+		if err != nil || !reflect.DeepEqual(doc,param.expected) {
+			t.Error("For",param.document,"expected",param.expected,"got",doc,err)
+		}
+	}
+}
+
+func TestMarshal(t *testing.T){
+	var doc testDocument
+	if err := Unmarshal([]byte(document),&doc); err != nil {
+		t.Fatal(err)
+	}
+	data,err := Marshal(&doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded testDocument
+	if err = Unmarshal(data,&decoded); err != nil {
+		t.Fatal(err,string(data))
+	}
+	if !reflect.DeepEqual(doc,decoded) {
+		t.Error("For",string(data),"expected",doc,"got",decoded)
+	}
+}
+
+func TestMarshalControlCharacters(t *testing.T){
+	doc := testDocument{Title:"tab\tnul\x00 bell\a \"quoted\" \\ del\x7f ü",Hours:[]int{5},Limits:map[string]int{"line\nbreak":1}}
+	data,err := Marshal(&doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data),"\\x") || strings.Contains(string(data),"\\a") {
+		t.Error("For","control characters","expected","TOML escapes","got",string(data))
+	}
+	var decoded testDocument
+	if err = Unmarshal(data,&decoded); err != nil {
+		t.Fatal(err,string(data))
+	}
+	if !reflect.DeepEqual(doc,decoded) {
+		t.Error("For",string(data),"expected",doc,"got",decoded)
+	}
+}
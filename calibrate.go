@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"github.com/hansen1101/go_heating/system/hardware"
+	"github.com/hansen1101/go_heating/system/w1"
+)
+
+const(
+	CALIBRATION_COMMAND = "calibrate"
+	CALIBRATION_SAMPLES = 10
+	CALIBRATION_INTERVAL = time.Second * 5
+)
+
+var(
+	calibrationInterval = CALIBRATION_INTERVAL
+)
+
+// Calibrates the offsets of all sensors of the hardware topology. The routine must be
+// run while the whole system is at equilibrium (e.g. after a longer standstill in summer),
+// thus all sensors measure the same temperature. Each sensor is sampled several times,
+// the offset is chosen such that the mean of the samples matches the reference value.
+// Configured gains are kept.
+// @param path of the hardware topology the calibration is written to
+// @param reference the true temperature in °C as string, empty to use the median of all sensors
+// @return error if the topology can not be read or written or no sensor delivers valid data
+func calibrateSensors(path, reference string, out io.Writer)(err error){
+	topology,err := hardware.Load(path)
+	if err != nil {
+		return
+	}
+
+	// sample all sensors and average their raw values
+	var logDestination io.Writer = ioutil.Discard
+	var logMutex sync.Mutex
+	ids := topology.SensorIdList()
+	sums := make(map[string]int,len(ids))
+	counts := make(map[string]int,len(ids))
+	for i := 0; i < CALIBRATION_SAMPLES; i++ {
+		if i > 0 {
+			<-time.After(calibrationInterval)
+		}
+		for _,id := range ids {
+			t := w1.SensorTemperaturGenerator(id,"",&logDestination,&logMutex)
+			if t.IsValid() {
+				sums[id] += t.GetRawValue()
+				counts[id]++
+			}
+		}
+	}
+	means := make(map[string]int,len(ids))
+	values := make([]int,0,len(ids))
+	for _,id := range ids {
+		if counts[id] == 0 {
+			fmt.Fprintf(out,"[WARNING]\t%s delivered no valid data and is not calibrated\n",id)
+			continue
+		}
+		means[id] = int(math.Floor(float64(sums[id])/float64(counts[id]) + 0.5))
+		values = append(values,means[id])
+	}
+	if len(values) == 0 {
+		return fmt.Errorf("no sensor delivered valid data")
+	}
+
+	// reference temperature in millidegree
+	var target int
+	if reference == "" {
+		sort.Ints(values)
+		target = values[len(values)/2]
+		if len(values) % 2 == 0 {
+			target = (values[len(values)/2-1] + target) / 2
+		}
+	} else {
+		var celsius float64
+		if celsius,err = strconv.ParseFloat(strings.TrimSpace(reference),64); err != nil {
+			return fmt.Errorf("invalid reference temperature %s",reference)
+		}
+		target = int(math.Floor(celsius*1000 + 0.5))
+	}
+	fmt.Fprintf(out,"Reference temperature:\t%d\n",target)
+
+	calibrations := topology.Calibrations()
+	for _,id := range ids {
+		if _,ok := means[id]; !ok {
+			continue
+		}
+		c := calibrations[id]
+		old := c.Offset
+		c.Offset = 0
+		c.Offset = target - c.Apply(means[id])
+		topology.SetCalibration(id,c)
+		fmt.Fprintf(out,"%s\traw %d\toffset %d -> %d\n",id,means[id],old,c.Offset)
+	}
+
+	if err = topology.Save(path); err != nil {
+		return
+	}
+	fmt.Fprintf(out,"Calibration written to %s\n",path)
+	return
+}
@@ -0,0 +1,56 @@
+package main
+
+import(
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"github.com/hansen1101/go_heating/system/hardware"
+	"github.com/hansen1101/go_heating/system/w1"
+)
+
+func TestCalibrateSensors(t *testing.T){
+	dir,err := ioutil.TempDir("","calibrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(prefix string){ w1.SENSOR_PATH_PREFIX = prefix }(w1.SENSOR_PATH_PREFIX)
+	w1.SENSOR_PATH_PREFIX = dir+"/"
+	calibrationInterval = time.Millisecond
+
+	topology,err := hardware.Load(TEST_TOPOLOGY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := topology.SensorIdList()
+	for i,id := range ids {
+		writeSensor(t,dir,id,20000+100*(i-len(ids)/2))
+	}
+	topology.SetCalibration(ids[0],w1.Calibration{Gain:2})
+	path := filepath.Join(dir,"hardware.toml")
+	if err = topology.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err = calibrateSensors(path,"20.5",&out); err != nil {
+		t.Fatal(err,out.String())
+	}
+	calibrated,err := hardware.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calibrations := calibrated.Calibrations()
+	for i,id := range ids {
+		raw := 20000+100*(i-len(ids)/2)
+		if v := calibrations[id].Apply(raw); v != 20500 {
+			t.Error("For",id,"expected",20500,"got",v,calibrations[id])
+		}
+	}
+	if calibrations[ids[0]].Gain != 2 {
+		t.Error("For","gain","expected",2,"got",calibrations[ids[0]].Gain)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	"github.com/hansen1101/go_heating/agent"
+	"github.com/hansen1101/go_heating/system"
+	"github.com/hansen1101/go_heating/system/gpio"
+)
+
+const(
+	CHIMNEY_SWEEP_DURATION = time.Minute * 20
+	CHIMNEY_BUTTON_DEBOUNCE = time.Millisecond * 50
+	CHIMNEY_LED_BLINK = time.Millisecond * 500
+)
+
+// The chimney sweep mode is started by pressing the chimney button and overrides the
+// system's HeatingAgent for the emission measurement. The mode ends after a timeout,
+// by a second press of the button or if the kettle reaches its temperature limit.
+type chimneySweepMode struct {
+	button, led gpio.Pin
+	duration time.Duration
+	agent agent.HeatingAgent
+
+	lock sync.Mutex
+	active bool
+	deadline time.Time
+	restorePumps bool	// pumps must be brought back to normal operation after the mode ended
+	blinkStop chan bool
+}
+
+// Constructor for the chimney sweep mode.
+// @param button the input pin of the chimney button
+// @param led the output pin of the chimney LED
+// @param duration time after which the mode ends automatically
+func newChimneySweepMode(button, led gpio.Pin, duration time.Duration)(c *chimneySweepMode){
+	c = &chimneySweepMode{
+		button:button,
+		led:led,
+		duration:duration,
+		agent:agent.NewChimneySweepAgent(),
+	}
+	return
+}
+
+// Watches the chimney button in a separate go routine, each press toggles the mode.
+func (c *chimneySweepMode) watch()(){
+	events := c.button.WatchEdge(gpio.EDGE_RISING,CHIMNEY_BUTTON_DEBOUNCE)
+	if events == nil {
+		fmt.Println("[WARNING]\tChimney button can not be watched.")
+		return
+	}
+	go func(){
+		for range events {
+			c.lock.Lock()
+			active := c.active
+			c.lock.Unlock()
+			if active {
+				c.stop("button pressed")
+			} else {
+				c.start()
+			}
+		}
+	}()
+}
+
+// Starts the mode and the blinking of the LED
+func (c *chimneySweepMode) start()(){
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.active {
+		return
+	}
+	c.active = true
+	c.deadline = time.Now().Add(c.duration)
+	c.blinkStop = make(chan bool)
+	go blink(c.led,c.blinkStop)
+	fmt.Printf("[CHIMNEY]\tChimney sweep mode started until %s.\n",c.deadline.Format(time.Kitchen))
+}
+
+// Ends the mode and hands control back to the system's agent
+// @param reason logged reason for leaving the mode
+func (c *chimneySweepMode) stop(reason string)(){
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !c.active {
+		return
+	}
+	c.active = false
+	c.restorePumps = true
+	close(c.blinkStop)
+	fmt.Printf("[CHIMNEY]\tChimney sweep mode ended (%s).\n",reason)
+}
+
+// Checks whether the mode is active, the mode ends if its deadline passed.
+func (c *chimneySweepMode) isActive(now time.Time)(bool){
+	c.lock.Lock()
+	expired := c.active && now.After(c.deadline)
+	active := c.active
+	c.lock.Unlock()
+	if expired {
+		c.stop("timeout")
+		return false
+	}
+	return active
+}
+
+// Returns true once after the mode ended in order to reset the pumps
+func (c *chimneySweepMode) pumpsNeedRestore()(restore bool){
+	c.lock.Lock()
+	restore = c.restorePumps
+	c.restorePumps = false
+	c.lock.Unlock()
+	return
+}
+
+// Implementation of HeatingAgent interface
+func (c *chimneySweepMode) GetAction(percept *system.Percept)(*system.Action){
+	return c.agent.GetAction(percept)
+}
+
+// Toggles the led until stop is closed, the led is switched off afterwards.
+func blink(led gpio.Pin, stop chan bool)(){
+	on := false
+	for {
+		select {
+		case <-stop:
+			led.SetValue(false)
+			return
+		case <-time.After(CHIMNEY_LED_BLINK):
+			on = !on
+			led.SetValue(on)
+		}
+	}
+}
+
+// Moves the frequency of the active pumps to their maximum in the chimney sweep mode or back
+// to their minimum afterwards.
+func driveActivePumps(toMax bool)(){
+	if actuators == nil {
+		return
+	}
+	for _,name := range actuators.Names(system.ACTUATOR_PUMP) {
+		pump := actuators.Get(name)
+		if !pump.State().On {
+			continue
+		}
+		frequency := pump.Constraints().MinFrequency
+		if toMax {
+			frequency = pump.Constraints().MaxFrequency
+		}
+		pump.Set(system.Setting{On:true,Frequency:frequency})
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import(
+	"testing"
+	"time"
+	"github.com/hansen1101/go_heating/system/gpio"
+)
+
+var(
+	buttonIn,ledOut gpio.GpioId
+)
+
+func newTestChimneySweepMode(t *testing.T, duration time.Duration)(c *chimneySweepMode){
+	buttonIn,ledOut = testPin(t,CHIMNEY_BUTTON),testPin(t,CHIMNEY_LED)
+	gpio.SetInput(buttonIn,false)
+	button := gpio.NewSimPin()
+	button.PinMode(buttonIn,gpio.INPUT,true)
+	led := gpio.NewSimPin()
+	led.PinMode(ledOut,gpio.OUTPUT,true)
+	c = newChimneySweepMode(button,led,duration)
+	c.watch()
+	return
+}
+
+func press()(){
+	<-gpio.ScriptInput(buttonIn,
+		gpio.InputStep{After:0,Value:true},
+		gpio.InputStep{After:2*CHIMNEY_BUTTON_DEBOUNCE,Value:false},
+	)
+	<-time.After(2*CHIMNEY_BUTTON_DEBOUNCE)
+}
+
+func TestChimneySweepButton(t *testing.T){
+	c := newTestChimneySweepMode(t,time.Hour)
+	defer c.button.Unexport()
+
+	press()
+	if !c.isActive(time.Now()) {
+		t.Fatal("For","first press","expected",true,"got",false)
+	}
+	<-time.After(3*CHIMNEY_LED_BLINK)
+	if i,_ := gpio.SimulationJournal.Find(0,ledOut,gpio.OP_SET,true); i < 0 {
+		t.Error("For","led","expected","blinking","got",gpio.SimulationJournal.Filter(ledOut))
+	}
+
+	press()
+	if c.isActive(time.Now()) {
+		t.Error("For","second press","expected",false,"got",true)
+	}
+	if !c.pumpsNeedRestore() || c.pumpsNeedRestore() {
+		t.Error("For","pump restore","expected","single restore","got","none or repeated")
+	}
+}
+
+func TestChimneySweepTimeout(t *testing.T){
+	c := newTestChimneySweepMode(t,time.Minute)
+	defer c.button.Unexport()
+
+	c.start()
+	if !c.isActive(time.Now()) {
+		t.Fatal("For","start","expected",true,"got",false)
+	}
+	if c.isActive(time.Now().Add(2*time.Minute)) {
+		t.Error("For","timeout","expected",false,"got",true)
+	}
+	if c.led.GetValue() {
+		t.Error("For","led after timeout","expected",false,"got",true)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+	"github.com/hansen1101/go_heating/system/hardware"
+	"github.com/hansen1101/go_heating/system/w1"
+)
+
+const(
+	DISCOVERY_COMMAND = "discover"
+	DISCOVERY_WINDOW = time.Second * 60
+	DISCOVERY_INTERVAL = time.Second
+	DISCOVERY_MIN_RISE = 500	// minimal temperature rise of a touched sensor in millidegree
+)
+
+var(
+	discoveryWindow = DISCOVERY_WINDOW
+	discoveryInterval = DISCOVERY_INTERVAL
+)
+
+// Interactive wizard that maps the sensors found on the w1 bus to the logical roles
+// of the hardware topology. A sensor is identified by touching it: the sensor that
+// warms up while the user holds it is assigned to the requested role.
+type sensorWizard struct {
+	in *bufio.Scanner
+	out io.Writer
+	ids []string
+	logDestination io.Writer
+	logMutex sync.Mutex
+}
+
+// Reads the current temperatures of all sensors on the bus
+// @return map of sensor ids to temperatures of all sensors that returned a valid value
+func (w *sensorWizard) readAll()(temps map[string]int){
+	temps = make(map[string]int,len(w.ids))
+	for _,id := range w.ids {
+		t := w1.SensorTemperaturGenerator(id,"",&w.logDestination,&w.logMutex)
+		if t.IsValid() {
+			temps[id] = t.GetValue()
+		}
+	}
+	return
+}
+
+// Samples all sensors until one of them rises above the baseline by DISCOVERY_MIN_RISE
+// or the discovery window elapsed.
+// @return the id of the touched sensor and its temperature rise, empty id if no sensor warmed up
+func (w *sensorWizard) detectTouched(baseline map[string]int)(id string, rise int){
+	deadline := time.Now().Add(discoveryWindow)
+	for time.Now().Before(deadline) {
+		for sensor,temp := range w.readAll() {
+			if base,ok := baseline[sensor]; ok && temp-base > rise {
+				id,rise = sensor,temp-base
+			}
+		}
+		if rise >= DISCOVERY_MIN_RISE {
+			return
+		}
+		<-time.After(discoveryInterval)
+	}
+	return "",0
+}
+
+// Prints the prompt and reads the answer of the user
+// @return the trimmed answer, false if the input is exhausted
+func (w *sensorWizard) ask(format string, a ...interface{})(answer string, ok bool){
+	fmt.Fprintf(w.out,format,a...)
+	if !w.in.Scan() {
+		return "", false
+	}
+	return strings.TrimSpace(w.in.Text()), true
+}
+
+// Discovers the DS18B20 sensors on the w1 bus, reports configured sensors that are missing
+// and guides the user through assigning a sensor to each logical role. The resulting mapping
+// is written to the hardware topology at path.
+// @return error if no sensors are found, the topology can not be written or is still incomplete
+func discoverSensors(path string, in io.Reader, out io.Writer)(err error){
+	w := &sensorWizard{
+		in:bufio.NewScanner(in),
+		out:out,
+		logDestination:ioutil.Discard,
+	}
+	if w.ids,err = w1.DiscoverSensors(); err != nil {
+		return
+	}
+	if len(w.ids) == 0 {
+		return fmt.Errorf("no DS18B20 sensors found in %s",w1.SENSOR_PATH_PREFIX)
+	}
+
+	topology,err := hardware.Read(path)
+	if err != nil {
+		return
+	}
+
+	// report differences between the bus and the configuration
+	onBus := make(map[string]bool,len(w.ids))
+	for _,id := range w.ids {
+		onBus[id] = true
+	}
+	configured := make(map[string]bool,len(topology.Sensors))
+	comments := make(map[string]string,len(topology.Sensors))
+	related := make(map[string][]string,len(topology.Sensors))
+	calibrations := make(map[string]hardware.Sensor,len(topology.Sensors))
+	for _,s := range topology.Sensors {
+		configured[s.Id] = true
+		calibrations[s.Id] = s
+		comments[s.Role] = s.Comment
+		related[s.Role] = s.Related
+		if !onBus[s.Id] {
+			fmt.Fprintf(out,"[MISSING]\t%s\t%s is configured but not found on the bus\n",s.Id,s.Role)
+		}
+	}
+	temps := w.readAll()
+	for _,id := range w.ids {
+		status := "[FOUND]"
+		if !configured[id] {
+			status = "[NEW]"
+		}
+		if temp,ok := temps[id]; ok {
+			fmt.Fprintf(out,"%s\t%s\t%.3f°C\n",status,id,float64(temp)/1000)
+		} else {
+			fmt.Fprintf(out,"%s\t%s\tno valid reading\n",status,id)
+		}
+	}
+
+	// assign a sensor to each known role and each further role of the configuration
+	current := topology.SensorIds()
+	roles := append([]string{},hardware.Roles...)
+	for _,s := range topology.Sensors {
+		if !hardware.IsRole(s.Role) {
+			roles = append(roles,s.Role)
+		}
+	}
+	sensors := make([]hardware.Sensor,0,len(roles))
+	for _,role := range roles {
+		var id string
+		for id == "" {
+			baseline := w.readAll()
+			answer,ok := w.ask("Role %s (current: %s): hold the sensor and press enter, type a sensor id or 's' to skip: ",role,current[role])
+			if !ok {
+				return fmt.Errorf("discovery aborted, %s was not written",path)
+			}
+			switch {
+			case answer == "s":
+				if id = current[role]; id == "" {
+					fmt.Fprintf(out,"%s stays unassigned\n",role)
+				}
+			case answer == "":
+				var rise int
+				if id,rise = w.detectTouched(baseline); id == "" {
+					fmt.Fprintf(out,"No sensor warmed up by %.1f°C within %s, try again\n",float64(DISCOVERY_MIN_RISE)/1000,discoveryWindow)
+					continue
+				}
+				fmt.Fprintf(out,"%s warmed up by %.1f°C\n",id,float64(rise)/1000)
+			case onBus[answer]:
+				id = answer
+			default:
+				fmt.Fprintf(out,"%s is not on the bus\n",answer)
+				continue
+			}
+			break
+		}
+		if id != "" {
+			// the calibration belongs to the probe and moves with it to its new role
+			sensors = append(sensors,hardware.Sensor{
+				Id:id,
+				Role:role,
+				Comment:comments[role],
+				Related:related[role],
+				Offset:calibrations[id].Offset,
+				Gain:calibrations[id].Gain,
+			})
+		}
+	}
+
+	topology.Sensors = sensors
+	if err = topology.Save(path); err != nil {
+		return
+	}
+	fmt.Fprintf(out,"Sensor mapping written to %s\n",path)
+	return topology.Validate(requiredRoles()...)
+}
@@ -0,0 +1,114 @@
+package main
+
+import(
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"github.com/hansen1101/go_heating/system/hardware"
+	"github.com/hansen1101/go_heating/system/w1"
+)
+
+// Input of the discovery wizard, each read returns the next line after the
+// corresponding action (e.g. touching a sensor) has been carried out.
+type touchInput struct {
+	lines []string
+	actions []func()
+}
+
+func (t *touchInput) Read(p []byte)(n int, err error){
+	if len(t.lines) == 0 {
+		return 0, fmt.Errorf("no more input")
+	}
+	if t.actions[0] != nil {
+		t.actions[0]()
+	}
+	n = copy(p,t.lines[0]+"\n")
+	t.lines,t.actions = t.lines[1:],t.actions[1:]
+	return
+}
+
+func (t *touchInput) answer(line string, action func())(){
+	t.lines = append(t.lines,line)
+	t.actions = append(t.actions,action)
+}
+
+func writeSensor(t *testing.T, dir, id string, value int)(){
+	if err := os.MkdirAll(filepath.Join(dir,id),0755); err != nil {
+		t.Fatal(err)
+	}
+	data := fmt.Sprintf("5f 01 4b 46 7f ff 01 10 9b : crc=9b YES\n5f 01 4b 46 7f ff 01 10 9b t=%d\n",value)
+	if err := ioutil.WriteFile(filepath.Join(dir,id,"w1_slave"),[]byte(data),0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiscoverSensors(t *testing.T){
+	dir,err := ioutil.TempDir("","discover")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(prefix string){ w1.SENSOR_PATH_PREFIX = prefix }(w1.SENSOR_PATH_PREFIX)
+	w1.SENSOR_PATH_PREFIX = dir+"/"
+	discoveryWindow,discoveryInterval = time.Second,10*time.Millisecond
+
+	// the topology of the repository with all sensors on a new bus
+	topology,err := hardware.Load(TEST_TOPOLOGY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range topology.Sensors {
+		if topology.Sensors[i].Role == hardware.ROLE_ROOM {
+			topology.Sensors[i].Offset,topology.Sensors[i].Gain = -250,1.02
+		}
+	}
+	path := filepath.Join(dir,"hardware.toml")
+	if err = topology.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	bus := make([]string,len(hardware.Roles))
+	for i := range bus {
+		bus[i] = fmt.Sprintf("28-00000000000%d",i)
+		writeSensor(t,dir,bus[i],20000)
+	}
+
+	in := &touchInput{}
+	for i := range hardware.Roles[:len(hardware.Roles)-2] {
+		id := bus[i]
+		in.answer("",func(){ writeSensor(t,dir,id,21000) })
+	}
+	in.answer(bus[7],nil)	// typed sensor id
+	in.answer("s",nil)	// skipped role keeps the configured sensor
+
+	var out bytes.Buffer
+	if err = discoverSensors(path,in,&out); err != nil {
+		t.Fatal(err,out.String())
+	}
+	if !strings.Contains(out.String(),"[MISSING]\t28-000007c5cf02\tKettle") || !strings.Contains(out.String(),"[NEW]\t28-000000000000") {
+		t.Error("For","bus report","expected","missing and new sensors","got",out.String())
+	}
+
+	saved,err := hardware.Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := saved.SensorIds()
+	for i,role := range hardware.Roles[:len(hardware.Roles)-1] {
+		if ids[role] != bus[i] {
+			t.Error("For",role,"expected",bus[i],"got",ids[role])
+		}
+	}
+	if id := ids[hardware.ROLE_ROOM]; id != "28-0000075d9c18" {
+		t.Error("For","skipped role","expected","28-0000075d9c18","got",id)
+	}
+	for _,s := range saved.Sensors {
+		if s.Role == hardware.ROLE_ROOM && (s.Offset != -250 || s.Gain != 1.02) {
+			t.Error("For","calibration of",s.Id,"expected",-250,1.02,"got",s.Offset,s.Gain)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"os"
 	"os/signal"
 	"time"
@@ -20,51 +21,29 @@ import (
 	"github.com/hansen1101/go_heating/system/logger"
 	"github.com/hansen1101/go_heating/system/gpio"
 	"github.com/hansen1101/go_heating/system/w1"
+	"github.com/hansen1101/go_heating/system/hardware"
+	"github.com/hansen1101/go_heating/system/modbus"
 	"github.com/hansen1101/go_heating/agent"
 )
 
 const(
-	RELAIS_1 = gpio.GPIO17
-	RELAIS_2 = gpio.GPIO18
-	RELAIS_3 = gpio.GPIO22
-	RELAIS_4 = gpio.GPIO27
-	RELAIS_5 = gpio.GPIO5
-	RELAIS_6 = gpio.GPIO25
-	RELAIS_7 = gpio.GPIO23
-	RELAIS_8 = gpio.GPIO24
-	BUTTON_IN = gpio.GPIO6
-	CHIMNEY_LED = gpio.GPIO13
+	// names of the components in the hardware topology the system depends on
+	BURNER = system.ACTUATOR_BURNER
+	TRIANGLE = system.ACTUATOR_TRIANGLE
+	CHIMNEY_BUTTON = "chimney_button"
+	CHIMNEY_LED = "chimney_led"
+	BOILER_PUMP = system.ACTUATOR_BOILER_PUMP
+	RADIATOR_PUMP = system.ACTUATOR_RADIATOR_PUMP
+
 	W1_REPLICATION_LEVEL = 4
+	W1_CONSENSUS_REPLICAS = 3	// replica readings per sensor and percept
+	W1_CONSENSUS_QUORUM = 2		// agreeing readings required for a valid temperature
+	W1_CONSENSUS_WINDOW = time.Second * 15
+	W1_MAX_LOOKUP_ROUNDS = 3	// lookup rounds per sensor and percept before the sensor is given up
+	W1_MAX_SUBSTITUTE_AGE = time.Minute * 10	// max age of a value that substitutes a missing measurement
 	WORKER_MAX_REPLICATION_LEVEL = 50
-	W1_SENSOR_COUNT = 9
 	PERCEPT_HISTORY_LENGTH = 300
-	//OUTSIDE_SENSOR = "28-000006980f74"
-	OUTSIDE_SENSOR = "28-000007c5f668" // OK Outside Temperature
-	OUTSIDE = "OUTSIDE"
-	//BOILER_MID_SENSOR = "28-000005d8afbd"
-	BOILER_TOP_SENSOR = "28-0000030f9a99" // OK Boiler Top [TWO]
-	TWO = "TWO" // Boiler Top [TWO]
-	//BOILER_TOP_SENSOR =  "28-000006a335b2"
-	BOILER_MID_SENSOR=  "28-0000030f4ff5" // OK Boiler Mid [TPO]
-	TPO = "TPO"
-	//KETTLE_SENSOR = "28-000005d8f178"
-	H_REVERSERUN_SENSOR = "28-0000075c5fd6" // OK Ruecklauf Heizkreis
-	H_REV = "H_rev"
-	//H_FORERUN_SENSOR = "28-000006981117"
-	H_FORERUN_SENSOR = "28-000007c5f57f" // OK Vorlauf Heizkreis
-	H_FOR = "H_for"
-	//H_REVERSERUN_SENSOR = "28-00000698a022"
-	W_FORERUN_SENSOR= "28-0000030f64da" // OK Boiler Bottom [TPU]
-	TPU = "TPU"
-	//W_FORERUN_SENSOR = "28-00000697b512"
-	KETTLE_SENSOR = "28-000007c5cf02" // OK Kessel
-	KETTLE = "Kettle"
-	//W_REVERSERUN_SENSOR = "28-000007c61ca9"
-	W_REVERSERUN_SENSOR = "28-0000075d9c18" // OK Raum
-	W_REV = "W_rev"
-	//W_INTAKE_SENSOR = "28-000007c61ca9"
-	W_INTAKE_SENSOR = "28-0000075d9c18" // Raum
-	ROOM = "Room"
+	SNAPSHOT_INTERVAL = time.Minute * 10	// interval of the snapshots of oracle and learner
 
 	DATABASE_USER string = "heating_logger"
 	DATABASE_PASSWD string = "heating"
@@ -73,32 +52,22 @@ const(
 
 	DEBUG = false
 	DEFAULT_MIN_BOILER_TEMP int = 30000
+	KETTLE_MAX_TEMP int = 65000
 )
 
 var(
-	radiatorPump,
-	boilerPump *system.Pump
-
-	burner,
-	boilerPump_on,
-	boilerPump_inc,
-	boilerPump_dec,
-	radiatorPump_on,
-	radiatorPump_inc,
-	radiatorPump_dec,
-	triangle_switch,
+	// burner, pumps and valves by name, see initActors
+	actuators *system.ActuatorRegistry
+
+	// modbus lines and connections of the converters by address, shared by their pumps
+	modbusTransports map[string]modbus.Transport
+
 	chimney_button,
-	chimney_led *gpio.Pin
-
-	outsideSensor,
-	boilerMidSensor,
-	boilerTopSensor,
-	kettleSensor,
-	hForeRunSensor,
-	hReverseRunSensor,
-	wForeRunSensor,
-	wReverseRunSensor,
-	wIntakeSensor []w1.TemperatureLookup
+	chimney_led gpio.Pin
+
+	// hardware setup and all configured pins by name
+	topology *hardware.Topology
+	pins map[string]gpio.Pin
 
 	// map: logical sensor name -> sensor ids (e.g. 'kettle' => '28-00000123456')
 	sensorIds map[string]string
@@ -110,6 +79,15 @@ var(
 
 	systemAgent agent.HeatingAgent
 
+	// sliding window of the percepts, see pooledPerceptGenerator
+	perceptOracle *system.PerceptOracle
+
+	// boiler targets and manual overrides, nil if the configuration can not be read
+	configurationOracle *system.ConfigurationOracle
+
+	// takes over if a sensor of CRITICAL_ROLES is unhealthy
+	degradedAgent agent.HeatingAgent = agent.NewDegradedAgent()
+
 	// sState is logable
 	sState *system.ActorState
 
@@ -117,186 +95,265 @@ var(
 
 	applyAction system.RollOut
 
+	// sensors required to operate the burner safely
+	CRITICAL_ROLES = []string{hardware.ROLE_KETTLE,hardware.ROLE_TWO,hardware.ROLE_TPO}
+
+	// sensors the systemAgent reads
+	AGENT_ROLES = agent.SIMPLE_HEATING_ROLES
+
+	// actuators the agents depend on
+	REQUIRED_ACTUATORS = []string{BURNER,TRIANGLE,BOILER_PUMP,RADIATOR_PUMP}
+
+	// emission measurement mode, overrides systemAgent while active
+	chimney *chimneySweepMode
+	chimneySweepDuration = CHIMNEY_SWEEP_DURATION
+
 	config_path string = "/usr/local/share/heating_config/config.csv"
+	curve_path string = "/usr/local/share/heating_config/heating_curve.toml"
+	schedule_path string = "/usr/local/share/heating_config/schedule.toml"
+	configuration_path string = "/usr/local/share/heating_config/config.pb"
+	topology_path string = "/usr/local/share/heating_config/hardware.toml"
+	plausibility_path string = "/usr/local/share/heating_config/plausibility.toml"
 	log_path = "/var/log/go_heating.log"
-)
-
-// Initializes the GPIO pins used to control the systems actuators
-func initGPIO() {
-	burner = gpio.NewPin()
-	burner.PinMode(RELAIS_2,gpio.OUTPUT,true)
+	oracle_snapshot_path = "/var/lib/go_heating/oracle.snapshot"
+	learner_snapshot_path = "/var/lib/go_heating/learner.snapshot"
+	override_path = "/var/lib/go_heating/overrides.toml"
 
-	boilerPump_on = gpio.NewPin()
-	boilerPump_on.PinMode(RELAIS_1,gpio.OUTPUT,true)
+	// settle times of burner and triangle valve if the topology does not set them
+	triangleSettleTime = time.Second * 5
+	burnerIgnitionTime = time.Second * 15
+)
 
-	boilerPump_inc = gpio.NewPin()
-	boilerPump_inc.PinMode(RELAIS_8,gpio.OUTPUT,true)
+// Reads and validates the hardware topology from the given file, the sensors of
+// CRITICAL_ROLES and AGENT_ROLES are required
+func initTopology(path string)(err error){
+	topology,err = hardware.Load(path,requiredRoles()...)
+	return
+}
 
-	boilerPump_dec = gpio.NewPin()
-	boilerPump_dec.PinMode(RELAIS_5,gpio.OUTPUT,true)
+// Returns the sensor roles the topology must assign, see CRITICAL_ROLES and AGENT_ROLES
+func requiredRoles()(roles []string){
+	seen := make(map[string]bool)
+	for _,role := range append(append([]string{},CRITICAL_ROLES...),AGENT_ROLES...) {
+		if !seen[role] {
+			seen[role] = true
+			roles = append(roles,role)
+		}
+	}
+	return
+}
 
-	radiatorPump_on = gpio.NewPin()
-	radiatorPump_on.PinMode(RELAIS_3,gpio.OUTPUT,true)
+// Reads the plausibility rules percepts are validated against. Without rule file
+// every percept is accepted.
+// @return error if the rule file exists but can not be used
+func initPlausibility(path string)(err error){
+	rules,err := system.LoadRules(path)
+	if os.IsNotExist(err) {
+		fmt.Printf("[WARNING]\tno plausibility rules at %s, percepts are not checked\n",path)
+		system.SetPlausibility(nil)
+		return nil
+	} else if err != nil {
+		return
+	}
+	system.SetPlausibility(system.NewPlausibilityEngine(rules))
+	return
+}
 
-	radiatorPump_inc = gpio.NewPin()
-	radiatorPump_inc.PinMode(RELAIS_6,gpio.OUTPUT,true)
+// State that survives a restart, see system.WriteSnapshot
+type snapshotter interface {
+	Snapshot(path string)(error)
+	Restore(path string)(error)
+}
 
-	radiatorPump_dec = gpio.NewPin()
-	radiatorPump_dec.PinMode(RELAIS_7,gpio.OUTPUT,true)
+// Restores s from the snapshot at path, a missing, corrupted or stale snapshot is skipped
+func restoreSnapshot(s snapshotter, path string)(){
+	switch err := s.Restore(path); {
+	case err == nil:
+		fmt.Printf("Restored snapshot %s\n",path)
+	case os.IsNotExist(err):
+		fmt.Printf("No snapshot at %s, starting without history\n",path)
+	default:
+		fmt.Printf("[WARNING]\tsnapshot is ignored: %v\n",err)
+	}
+}
 
-	triangle_switch = gpio.NewPin()
-	triangle_switch.PinMode(RELAIS_4,gpio.OUTPUT,true)
+// Writes the snapshots by path
+func saveSnapshots(snapshots map[string]snapshotter)(){
+	for path,s := range snapshots {
+		if err := s.Snapshot(path); err != nil {
+			fmt.Printf("[WARNING]\tsnapshot %s failed: %v\n",path,err)
+		}
+	}
+}
 
-	chimney_button = gpio.NewPin()
-	chimney_button.PinMode(BUTTON_IN,gpio.INPUT,true)
+// Initializes the GPIO pins of all relays and inputs of the hardware topology
+// and assigns the pins the system depends on.
+// @return error if a required component is missing in the topology
+func initGPIO()(err error) {
+	pins = make(map[string]gpio.Pin)
+	for _,r := range topology.Relays {
+		pin := gpio.NewPin()
+		pin.PinMode(r.GpioId(),gpio.OUTPUT,r.ActiveLow)
+		pins[r.Name] = pin
+	}
+	for _,i := range topology.Inputs {
+		pin := gpio.NewPin()
+		pin.PinMode(i.GpioId(),gpio.INPUT,i.ActiveLow)
+		pin.SetBias(i.GetBias())
+		pins[i.Name] = pin
+	}
 
-	chimney_led = gpio.NewPin()
-	chimney_led.PinMode(CHIMNEY_LED,gpio.OUTPUT,true)
+	required := func(name string)(gpio.Pin){
+		if pins[name] == nil && err == nil {
+			err = fmt.Errorf("hardware topology: %s is not configured",name)
+		}
+		return pins[name]
+	}
+	chimney_button = required(CHIMNEY_BUTTON)
+	chimney_led = required(CHIMNEY_LED)
+	return
 }
 
 // Performs a cleanup and unexports the GPIO pins
 func cleanupGPIO(){
-	burner.Unexport()
-	boilerPump_on.Unexport()
-	boilerPump_inc.Unexport()
-	boilerPump_dec.Unexport()
-	radiatorPump_on.Unexport()
-	radiatorPump_inc.Unexport()
-	radiatorPump_dec.Unexport()
-	chimney_button.Unexport()
-	chimney_led.Unexport()
+	for _,pin := range pins {
+		pin.Unexport()
+	}
 	return
 }
 
 // Initializes the mapping of logical sensor names that are used during system routines to sensor ids
 func initW1()(){
-	sensorIds = make(map[string]string,9)
-	sensorIds[TPO] = BOILER_MID_SENSOR
-	sensorIds[TWO] = BOILER_TOP_SENSOR
-	sensorIds[KETTLE] = KETTLE_SENSOR
-	sensorIds[H_FOR] = H_FORERUN_SENSOR
-	sensorIds[H_REV] = H_REVERSERUN_SENSOR
-	sensorIds[TPU] = W_FORERUN_SENSOR
-	sensorIds[ROOM] = W_INTAKE_SENSOR
-	sensorIds[W_REV] = W_REVERSERUN_SENSOR
-	sensorIds[OUTSIDE] = OUTSIDE_SENSOR
+	sensorIds = topology.SensorIds()
+	for id,c := range topology.Calibrations() {
+		w1.SetCalibration(id,c)
+	}
+	for id,related := range topology.RelatedSensors() {
+		w1.SetRelatedSensors(id,related...)
+	}
 }
 
-// Maps a w1.Temperature pointer to the corresponding field for a given percept.
-// This function implements the logic of the hardware sensor setup and the
-// corresponding internal processing of the sensor data. Change the assignment
-// here if the system has another architecture.
-// @return pointer to a w1.temperature struct
-// @todo import the mapping from an external configuration file
-func SetTempPointerForSensor(percept *system.Percept, temp *w1.Temperature)(*w1.Temperature){
-	switch temp.GetSensorLogic() {
-	case OUTSIDE:
-		percept.OutsideTemp = temp
-		return percept.OutsideTemp
-	case TPO:
-		percept.BoilerMidTemp = temp
-		return percept.BoilerMidTemp
-	case TWO:
-		percept.BoilerTopTemp = temp
-		return percept.BoilerTopTemp
-	case KETTLE:
-		percept.KettleTemp = temp
-		return percept.KettleTemp
-	case H_FOR:
-		percept.HForeRunTemp = temp
-		return percept.HForeRunTemp
-	case H_REV:
-		percept.HReverseRunTemp = temp
-		return percept.HReverseRunTemp
-	case TPU:
-		percept.WForeRunTemp = temp
-		return percept.WForeRunTemp
-	case W_REV:
-		percept.WReverseRunTemp = temp
-		return percept.WReverseRunTemp
-	case ROOM:
-		percept.WIntakeTemp = temp
-		return percept.WIntakeTemp
-	default:
-		return nil
+// Returns the pump of a converter that is commanded via modbus, the transport to its address
+// is opened once and shared by all converters at the same line or gateway
+func newModbusPump(p hardware.Pump)(pump *system.ModbusPump, err error){
+	transport,ok := modbusTransports[p.Modbus]
+	if !ok {
+		serial := modbus.DefaultSerialConfig
+		if p.ModbusBaud != 0 {
+			serial.Baud = p.ModbusBaud
+		}
+		if p.ModbusParity != "" {
+			serial.Parity = p.ModbusParity
+		}
+		if transport,err = modbus.Open(p.Modbus,serial); err != nil {
+			return nil, fmt.Errorf("pump %s: %v",p.Name,err)
+		}
+		modbusTransports[p.Modbus] = transport
+	}
+	registers := system.DefaultModbusRegisters
+	if len(p.ModbusRegisters) == hardware.MODBUS_REGISTERS {
+		r := p.ModbusRegisters
+		registers.Control,registers.Setpoint,registers.Frequency,registers.Current,registers.Fault = uint16(r[0]),uint16(r[1]),uint16(r[2]),uint16(r[3]),uint16(r[4])
 	}
+	if p.ModbusScale != 0 {
+		registers.FrequencyScale = p.ModbusScale
+	}
+	return system.NewModbusPump(p.Max,p.Min,modbus.NewClient(transport,byte(p.ModbusUnit)),registers), nil
 }
 
-// Initializes the system's actuators
-func initActors() {
-	radiatorPump = system.NewPump(50.0,50.0,25.0,0.2,radiatorPump_on,radiatorPump_inc,radiatorPump_dec)
-	boilerPump = system.NewPump(50.0,15.0,25.0,0.2,boilerPump_on,boilerPump_inc,boilerPump_dec)
+// Closes the modbus transports of the converters
+func cleanupActors(){
+	for address,transport := range modbusTransports {
+		transport.Close()
+		delete(modbusTransports,address)
+	}
+}
+
+// Registers the system's actuators: the pumps of the hardware topology and the relays that
+// are actuators on their own. Burner and triangle valve are registered with the default settle
+// times if the topology does not declare them (topologies without actuators).
+// @return error if an actuator of REQUIRED_ACTUATORS is missing in the topology
+func initActors()(err error) {
+	actuators = system.NewActuatorRegistry()
+	register := func(name string, a system.Actuator)(){
+		if register_err := actuators.Register(name,a); register_err != nil && err == nil {
+			err = register_err
+		}
+	}
+	defaultSettle := map[string]time.Duration{BURNER:burnerIgnitionTime,TRIANGLE:triangleSettleTime}
+	cleanupActors()
+	modbusTransports = make(map[string]modbus.Transport)
+	for _,p := range topology.Pumps {
+		if p.IsModbus() {
+			pump,modbus_err := newModbusPump(p)
+			if modbus_err != nil {
+				if err == nil {
+					err = modbus_err
+				}
+				continue
+			}
+			register(p.Name,pump)
+			continue
+		}
+		pump := system.NewPump(p.Max,p.Min,p.Acceleration,p.Delta,pins[p.Power],pins[p.Inc],pins[p.Dec])
+		if p.Feedback != "" {
+			pump.SetFeedback(&system.AnalogFeedback{Path:p.Feedback,Scale:p.FeedbackScale,Offset:p.FeedbackOffset},p.Tolerance)
+		}
+		register(p.Name,pump)
+	}
+	for _,r := range topology.Relays {
+		settle := r.Settle
+		if settle == 0 {
+			settle = defaultSettle[r.Name]
+		}
+		switch r.Actuator {
+		case hardware.ACTUATOR_RELAY:
+			register(r.Name,system.NewRelayActuator(pins[r.Name],settle))
+		case hardware.ACTUATOR_VALVE:
+			register(r.Name,system.NewValveActuator(pins[r.Name],settle))
+		}
+	}
+	if actuators.Get(BURNER) == nil && pins[BURNER] != nil {
+		register(BURNER,system.NewRelayActuator(pins[BURNER],burnerIgnitionTime))
+	}
+	if actuators.Get(TRIANGLE) == nil && pins[TRIANGLE] != nil {
+		register(TRIANGLE,system.NewValveActuator(pins[TRIANGLE],triangleSettleTime))
+	}
+	if missing := actuators.Missing(REQUIRED_ACTUATORS...); len(missing) > 0 && err == nil {
+		err = fmt.Errorf("hardware topology: %s is not configured",strings.Join(missing,", "))
+	}
+	return
 }
 
 // Instance of PerceptGenerator, thus creates a new percept for a given timestamp.
-// Fetches data for all temperature sensors in a replicated fashion (pointer to
-// first valid temperature data is taken from each sensor).
-// Assigns each incoming temperature data to the corresponding percept field.
+// Fetches data for all configured temperature sensors in a replicated fashion (the
+// replicas of each sensor have to agree on the temperature, see w1.Consensus).
+// Adds each incoming temperature to the percept under its logical sensor name.
 // @param pointer to the timestamp the percept is generated for
 // @return pointer to the generated percept
-// @todo this is not elegant
 func fetchSensorData(timestamp *time.Time)(percept *system.Percept) {
-
-	outside := make(chan *w1.Temperature)
-	go func(){outside <- w1.First(outsideSensor...)}()
-
-	boilerM := make(chan *w1.Temperature)
-	go func(){boilerM <- w1.First(boilerMidSensor...)}()
-
-	boilerT:= make(chan *w1.Temperature)
-	go func(){boilerT <- w1.First(boilerTopSensor...)}()
-
-	kettle := make(chan *w1.Temperature)
-	go func(){kettle <- w1.First(kettleSensor...)}()
-
-	hfor := make(chan *w1.Temperature)
-	go func(){hfor<-w1.First(hForeRunSensor...)}()
-
-	hrev := make(chan *w1.Temperature)
-	go func(){hrev<-w1.First(hReverseRunSensor...)}()
-
-	wfor := make(chan *w1.Temperature)
-	go func(){wfor<-w1.First(wForeRunSensor...)}()
-
-	wrev := make(chan *w1.Temperature)
-	go func(){wrev<-w1.First(wReverseRunSensor...)}()
-
-	win := make(chan *w1.Temperature)
-	go func(){win<-w1.First(wIntakeSensor...)}()
+	results := make(chan *w1.Temperature,len(sensorIds))
+	for logic,sensorId := range sensorIds {
+		replicas := make([]w1.TemperatureLookup,W1_CONSENSUS_REPLICAS)
+		for i := range replicas {
+			replicas[i] = func(sensorId, logic string)(w1.TemperatureLookup){
+				return func()(w1.Temperature){
+					return w1.SensorTemperaturGenerator(sensorId,logic,&logfile,&logmutex)
+				}
+			}(sensorId,logic)
+		}
+		go func(replicas []w1.TemperatureLookup){
+			results <- w1.Consensus(W1_CONSENSUS_QUORUM,W1_CONSENSUS_WINDOW,replicas...)
+		}(replicas)
+	}
 
 	// generate percept
-	percept = new(system.Percept)
-	percept.Valid = true
-	percept.CurrentTime = *timestamp
-
-	var temp *w1.Temperature
-
-	for i:=0; i<W1_SENSOR_COUNT; i++{
-		select {
-		case temp = <-outside:
-			percept.OutsideTemp = temp
-		case temp = <-boilerM:
-			percept.BoilerMidTemp = temp
-		case temp = <-boilerT:
-			percept.BoilerTopTemp = temp
-		case temp = <-kettle:
-			percept.KettleTemp = temp
-		case temp = <-hfor:
-			percept.HForeRunTemp = temp
-		case temp = <-hrev:
-			percept.HReverseRunTemp = temp
-		case temp = <-wfor:
-			percept.WForeRunTemp = temp
-		case temp = <-wrev:
-			percept.WReverseRunTemp = temp
-		case temp = <-win:
-			percept.WIntakeTemp = temp
-		}
-		if !temp.IsValid() {
+	percept = system.NewPercept(*timestamp)
+	for range sensorIds {
+		if temp := percept.SetTemperature(<-results); !temp.IsValid() {
 			percept.Valid = false
 		}
 	}
-
 	return
 }
 
@@ -304,10 +361,11 @@ func fetchSensorData(timestamp *time.Time)(percept *system.Percept) {
 // updateChan channel. The functions throttles the required worker routines
 // that generate sensor data by itself. Starts an infinite loop where percepts
 // are generated and passed via updateChan. Workers are spawned or teminated
-// according to runtime stats.
+// according to runtime stats. Implements system.PerceptSource, thus the loop and
+// all workers terminate once ctx is done.
 // @info make sure initW1() is called before and global sensorIds variable is
 // set properly.
-func pooledPerceptGenerator(updateChan chan *system.Percept)(){
+func pooledPerceptGenerator(ctx context.Context, updateChan chan *system.Percept)(){
 
 	// channel through which TemperatureLookupJob are issued, all workers are sitting at
 	// the other side of the channel awaiting lookup jobs
@@ -324,6 +382,9 @@ func pooledPerceptGenerator(updateChan chan *system.Percept)(){
 	var pool []chan bool
 
 	var flags map[string]*w1.Temperature
+	var readings map[string][]w1.Temperature
+	var known map[string]*w1.Temperature
+	var rounds map[string]int
 	var percept *system.Percept
 	var currentWorkerPoolSize, benchPoolSize, benchPoolSize1 int
 	var wokerPoolsStats map[int]*struct{
@@ -335,7 +396,10 @@ func pooledPerceptGenerator(updateChan chan *system.Percept)(){
 
 	//pool = make([]chan bool,W1_REPLICATION_LEVEL,W1_REPLICATION_LEVEL)
 	pool = make([]chan bool,0)
-	flags = make(map[string]*w1.Temperature,W1_SENSOR_COUNT)
+	flags = make(map[string]*w1.Temperature,len(sensorIds))
+	readings = make(map[string][]w1.Temperature,len(sensorIds))
+	known = make(map[string]*w1.Temperature,len(sensorIds))
+	rounds = make(map[string]int,len(sensorIds))
 	currentWorkerPoolSize = W1_REPLICATION_LEVEL
 	benchPoolSize = 0
 	benchPoolSize1 = 0
@@ -368,7 +432,18 @@ func pooledPerceptGenerator(updateChan chan *system.Percept)(){
 
 	// issues a TemperatureLookupJob for the given parameters to the requestQueue chanel
 	lookup := func(sensorId,logic string){
-		requestQueue <- w1.TemperatureLookupJob{sensorId,logic}
+		select {
+		case requestQueue <- w1.TemperatureLookupJob{sensorId,logic}:
+		case <-ctx.Done():
+		}
+	}
+
+	// issues W1_CONSENSUS_REPLICAS lookup jobs for the given sensor and discards the previous readings
+	replicate := func(sensorId,logic string){
+		readings[logic] = make([]w1.Temperature,0,W1_CONSENSUS_REPLICAS)
+		for i := 0; i < W1_CONSENSUS_REPLICAS; i++ {
+			go lookup(sensorId,logic)
+		}
 	}
 
 	// initial spawn of TemperaturLookupWorker, starts currentWorkerPoolSize worker go routines
@@ -376,6 +451,25 @@ func pooledPerceptGenerator(updateChan chan *system.Percept)(){
 		spawn()
 	}
 
+	// on termination drain the responses of running lookups, thus all workers
+	// receive their termination signal
+	defer func(){
+		drained := make(chan bool)
+		go func(){
+			for {
+				select {
+				case <-responseQueue:
+				case <-drained:
+					return
+				}
+			}
+		}()
+		for len(pool) > 0 {
+			terminate()
+		}
+		close(drained)
+	}()
+
 	// main loop generates a new percept and sends pointer back to this methods callee through updateChan
 	for {
 		jobDone := false
@@ -383,35 +477,59 @@ func pooledPerceptGenerator(updateChan chan *system.Percept)(){
 		failures := 0
 
 		// generate a new pointer
-		percept = new(system.Percept)
+		percept = system.NewPercept(time.Now())
 		start := time.Now()
 
 		// queue up jobs temperature lookups
 		for logic,sensorId := range sensorIds {
 			flags[logic]=nil // set the current temperature pointer in flags map to nil for this sencor
+			rounds[logic]=0
 
-			// put a temperature lookup job for this sensor to the requestQueue
+			// put W1_CONSENSUS_REPLICAS temperature lookup jobs for this sensor to the requestQueue
 			//@todo use buffered requestQueue to prevent go routine spawning; drawback blocking if buffer is full
-			go lookup(sensorId,logic)
-			attempts++
+			replicate(sensorId,logic)
+			attempts += W1_CONSENSUS_REPLICAS
 		}
 
-		// invariant: either valid data collected or lookup job for sensor is still running
+		// invariant: either valid data collected or lookup jobs for sensor are still running
 		for !jobDone {
 			// collect next response from workers
-			temp := <-responseQueue
+			var temp w1.Temperature
+			select {
+			case temp = <-responseQueue:
+			case <-ctx.Done():
+				return
+			}
+			logic := temp.GetSensorLogic()
 
 			//@todo: make sure no old values are accepted
 			if temp.IsValid() {
-				// update percept, set flag to temperature pointer or nil and update counter
-				flags[temp.GetSensorLogic()]=SetTempPointerForSensor(percept,&temp)
 				w1.IncrementSuccessLookupCount()
 			} else {
-				// update fail counter and reschedule temperature lookup for the corresponding sensor
 				w1.IncrementFailLookupCount()
 				failures++
-				go lookup(temp.GetSensorId(),temp.GetSensorLogic())
-				attempts++
+			}
+			readings[logic] = append(readings[logic],temp)
+
+			// decide on the temperature once all replicas of the sensor answered
+			if len(readings[logic]) == W1_CONSENSUS_REPLICAS {
+				if agreed := w1.Agree(readings[logic],W1_CONSENSUS_QUORUM); agreed.IsValid() {
+					// update percept, set flag to temperature pointer
+					flags[logic]=percept.SetTemperature(agreed)
+					known[logic]=agreed
+				} else if rounds[logic]++; rounds[logic] >= W1_MAX_LOOKUP_ROUNDS || !w1.IsHealthy(temp.GetSensorId()) {
+					// give up the sensor for this percept, substitute the last known temperature if it
+					// is recent enough, otherwise the temperature is marked invalid
+					if last := known[logic]; last != nil && last.Age(time.Now()) <= W1_MAX_SUBSTITUTE_AGE {
+						flags[logic]=percept.SetTemperature(last.Substitute())
+					} else {
+						flags[logic]=percept.SetTemperature(w1.NewTemperature(temp.GetSensorId(),logic))
+					}
+				} else {
+					// no quorum, reschedule temperature lookups for the corresponding sensor
+					replicate(temp.GetSensorId(),logic)
+					attempts += W1_CONSENSUS_REPLICAS
+				}
 			}
 
 			// job is done if all pointer in flag map are non-nil
@@ -424,12 +542,19 @@ func pooledPerceptGenerator(updateChan chan *system.Percept)(){
 			}
 		}
 
-		// at this point all flags are set => all temperatures are valid
+		// at this point all flags are set => all temperatures are valid, substituted or marked invalid
 		finish := time.Now()
 		percept.SetTime(finish)
-		percept.Validate()
+		setPumpReadings(percept)
+		if percept.Validate(); len(percept.Violations) > 0 {
+			logViolations(percept)
+		}
 
-		updateChan <- percept
+		select {
+		case updateChan <- percept:
+		case <-ctx.Done():
+			return
+		}
 
 		jobDuration := finish.Sub(start)
 		//fmt.Printf("Lookup Job took %2.4f seconds\n",jobDuration.Seconds())
@@ -508,88 +633,126 @@ func pooledPerceptGenerator(updateChan chan *system.Percept)(){
 	}
 }
 
-func generateReward()(int){
-	return 1
+// Writes the disagreement statistics of the replicated sensor lookups and the health
+// of the sensors to the log file
+func logSensorStats()(){
+	logmutex.Lock()
+	defer logmutex.Unlock()
+	for logic,sensorId := range sensorIds {
+		fmt.Fprintf(logfile,"[CONSENSUS]\t%s\t[%s]\t%s\t%s\n",time.Now().String(),sensorId,logic,w1.GetConsensusStat(sensorId))
+		fmt.Fprintf(logfile,"[HEALTH]\t%s\t[%s]\t%s\t%s\n",time.Now().String(),sensorId,logic,w1.GetSensorHealth(sensorId))
+	}
+	if engine := system.GetPlausibility(); engine != nil {
+		stats := engine.GetRuleStats()
+		for _,name := range engine.RuleNames() {
+			fmt.Fprintf(logfile,"[PLAUSIBILITY]\t%s\t%s\t%s\n",time.Now().String(),name,stats[name])
+		}
+	}
 }
 
-// Implementation of a system.RollOut type.
-// Simply checks if burner and pumps are available
-// and performs activation/deactivation as specified
-// by the given system.Action parameter
-func DefaultRollOut(a *system.Action)(){
-	if burner == nil || boilerPump == nil || radiatorPump == nil {
-		fmt.Println("Rollout not possible.")
-		return
+// Logs the plausibility rules violated by the percept to the log file and database
+func logViolations(percept *system.Percept)(){
+	logmutex.Lock()
+	for _,v := range percept.Violations {
+		fmt.Fprintf(logfile,"[PLAUSIBILITY]\t%s\t%s\t(rejected %v)\n",percept.CurrentTime.String(),v,v.Rejected)
 	}
+	logmutex.Unlock()
+	(&system.ViolationLog{Engine:system.GetPlausibility()}).Insert(percept)
+}
 
-	if triangle_switch != nil {
-		triangle_switch.SetValue(a.GetTriangleState())
-		<-time.After(time.Second * 5) // wait a moment for switch to adjust position
+// Adds the states and frequencies of the pumps to the percept, thus plausibility rules
+// can depend on them
+func setPumpReadings(percept *system.Percept)(){
+	if actuators == nil {
+		return
 	}
-
-	burnerWasOn := burner.GetValue()
-	burnerIsOn := a.GetBurnerState()
-	burner.SetValue(burnerIsOn)
-	if !burnerWasOn && burnerIsOn {
-		<-time.After(time.Second * 15) // wait a moment for switch to adjust position
+	for _,name := range actuators.Names(system.ACTUATOR_PUMP) {
+		state := actuators.Get(name).State()
+		percept.Set(system.StateReading{Logic:name,State:state.On,Valid:true})
+		percept.Set(system.FrequencyReading{Logic:name+"_frequency",Hertz:state.Frequency,Valid:true})
 	}
+}
 
-	if a.GetWPumpState() {
-		boilerPump.Activate()
-	} else {
-		boilerPump.Deactivate()
+// Returns the roles whose sensors are flagged unhealthy
+func unhealthyRoles(roles ...string)(unhealthy []string){
+	for _,role := range roles {
+		if sensorId,ok := sensorIds[role]; ok && !w1.IsHealthy(sensorId) {
+			unhealthy = append(unhealthy,role)
+		}
 	}
+	return
+}
 
-	if a.GetHPumpState() {
-		radiatorPump.Activate()
-	} else {
-		radiatorPump.Deactivate()
-	}
+func generateReward()(int){
+	return 1
+}
 
+// Implementation of a system.RollOut type.
+// Simply checks if the required actuators are available
+// and rolls out the given system.Action parameter to
+// all registered actuators (see system.ActuatorRegistry)
+func DefaultRollOut(a *system.Action)(){
+	if actuators == nil || len(actuators.Missing(REQUIRED_ACTUATORS...)) > 0 {
+		fmt.Println("Rollout not possible.")
+		return
+	}
+	actuators.RollOut(a)
 	return
 }
 
 func simple_routine(systemAgent agent.HeatingAgent, lastLog *time.Time)(break_loop bool){
 
-	// generate percept; generate channel for receiving a percept pointer and hand it over to the oracle
-	c := make(chan *system.Percept)
-
-	// assume someone will wait on the Percept_request_chan in order to process this request
-	system.Percept_request_chan <- c
-
-	// wait for oracle to send percept back
-	// @todo: ensure invariant that oracle sends back valid percepts only
-	systemPercept := <- c
-	defer func(){
-		c = nil
-	}()
+	// request the current percept from the oracle, the oracle only hands over valid percepts
+	systemPercept,err := perceptOracle.Current(context.Background())
+	if err != nil {
+		fmt.Printf("[ERROR]\tno percept available: %v\n",err)
+		return true
+	}
+	if configurationOracle != nil {
+		// the percept is shared with the oracle window, the copy carries the active overrides
+		annotated := *systemPercept
+		annotated.Overrides = configurationOracle.Overrides(systemPercept.CurrentTime)
+		systemPercept = &annotated
+	}
 
 	//fmt.Println("Fresh percept received by simple routine...")
 	/*
-	q := system.MakeDataRequest(
-		make(chan []system.DataResponse),
-		[]system.DataQuery{system.REVERSE_DELTA},
-		[]struct{
-			Sec int
-			Weight float64
-		}{
-			{65,0.8},
-			{120,0.6},
-		},
-	)
-	system.Query_request_chan <- q
-	response_silce := <- q.Endpoint
+	response_silce,_ := perceptOracle.Query([]system.DataQuery{system.REVERSE_DELTA},system.Calculation_info{65,0.8},system.Calculation_info{120,0.6})
 	fmt.Printf("Response received: %v\n",response_silce)
 	fmt.Printf("Percept received: %s\n",systemPercept)
 	*/
 
 
 	var sPrimeState *system.ActorState
-	next_action := systemAgent.GetAction(systemPercept)
+	var next_action *system.Action
+
+	chimneySweep := chimney != nil && chimney.isActive(time.Now())
+	if unhealthy := unhealthyRoles(CRITICAL_ROLES...); len(unhealthy) > 0 {
+		// degraded mode, the burner can not be operated safely without these sensors
+		fmt.Printf("[WARNING]\tDegraded mode, unhealthy sensors: %s\n",strings.Join(unhealthy,", "))
+		if chimneySweep {
+			chimney.stop("unhealthy sensor")
+			chimneySweep = false
+		}
+		next_action = degradedAgent.GetAction(systemPercept)
+	} else if chimneySweep {
+		next_action = chimney.GetAction(systemPercept)
+	} else {
+		next_action = systemAgent.GetAction(systemPercept)
+	}
 
 	// security check
-	if systemPercept.KettleTemp.GetValue() > 65000 {
+	if !system.Usable(systemPercept.KettleTemp) {
+		// kettle temperature is unknown, the burner must not run blind
 		next_action.SetBurnerState(false)
+		if chimneySweep {
+			chimney.stop("kettle temperature missing")
+		}
+	} else if systemPercept.KettleTemp.GetValue() > KETTLE_MAX_TEMP {
+		next_action.SetBurnerState(false)
+		if chimneySweep {
+			chimney.stop("kettle over-temperature")
+		}
 		// return strong negative reward to agent
 	}
 
@@ -598,10 +761,20 @@ func simple_routine(systemAgent agent.HeatingAgent, lastLog *time.Time)(break_lo
 	if next_action != nil {
 		// roll out action
 		applyAction(next_action)
+		if chimneySweep {
+			driveActivePumps(true)
+		} else if chimney != nil && chimney.pumpsNeedRestore() {
+			driveActivePumps(false)
+		}
 
 		// sState transition
 		sPrimeState = sState.Successor(next_action).(*system.ActorState)
 		sPrimeState.SetTimeStamp(systemPercept.CurrentTime)
+		if actuators != nil {
+			// the pumps clamp the throttles to their frequency range
+			sPrimeState.SetPumpFrequencies(actuators.Get(BOILER_PUMP).State().Frequency,actuators.Get(RADIATOR_PUMP).State().Frequency)
+		}
+		sPrimeState.SetOverrides(systemPercept.Overrides)
 	}
 
 	// generate reward
@@ -636,6 +809,7 @@ func simple_routine(systemAgent agent.HeatingAgent, lastLog *time.Time)(break_lo
 	} else if now.Sub(*lastLog).Seconds() > 180 {
 		systemPercept.Insert()
 		sPrimeState.Insert()
+		logSensorStats()
 		*lastLog = now
 	}
 
@@ -657,17 +831,111 @@ func main(){
 			gpio.UNEXPORT_FILE = pair[1]+"/src/github.com/hansen1101/go_heating/filesystem/sys/class/gpio/unexport"
 			gpio.PATH_PREFIX = pair[1]+"/src/github.com/hansen1101/go_heating/filesystem/sys/class/gpio/gpio"
 			config_path = pair[1]+"/src/github.com/hansen1101/go_heating/filesystem/heating_config/config.csv"
+			curve_path = pair[1]+"/src/github.com/hansen1101/go_heating/filesystem/heating_config/heating_curve.toml"
+			schedule_path = pair[1]+"/src/github.com/hansen1101/go_heating/filesystem/heating_config/schedule.toml"
+			configuration_path = pair[1]+"/src/github.com/hansen1101/go_heating/filesystem/heating_config/config.pb"
+			topology_path = pair[1]+"/src/github.com/hansen1101/go_heating/filesystem/heating_config/hardware.toml"
+			plausibility_path = pair[1]+"/src/github.com/hansen1101/go_heating/filesystem/heating_config/plausibility.toml"
 			log_path = pair[1]+"/src/github.com/hansen1101/go_heating/log/go_heating.log"
+			oracle_snapshot_path = pair[1]+"/src/github.com/hansen1101/go_heating/filesystem/var/lib/go_heating/oracle.snapshot"
+			learner_snapshot_path = pair[1]+"/src/github.com/hansen1101/go_heating/filesystem/var/lib/go_heating/learner.snapshot"
+			override_path = pair[1]+"/src/github.com/hansen1101/go_heating/filesystem/var/lib/go_heating/overrides.toml"
+		}
+		if pair[0] == "GPIO_BACKEND" {
+			// select the gpio access mechanism (sysfs or chardev)
+			b,err := gpio.ParseBackend(pair[1])
+			if err != nil {
+				log.Fatal(err)
+			}
+			gpio.SetBackend(b)
+		}
+		if pair[0] == "CHIMNEY_SWEEP_DURATION" {
+			// duration of the chimney sweep mode, e.g. 20m
+			d,err := time.ParseDuration(pair[1])
+			if err != nil {
+				log.Fatal(err)
+			}
+			chimneySweepDuration = d
+		}
+		if pair[0] == "GPIO_CHIP" {
+			// character device of the gpiochip used by the chardev backend (e.g. a gpio-sim chip)
+			gpio.CHIP_PATH = pair[1]
+		}
+	}
+
+	// assign the sensors on the w1 bus to their roles instead of running the system
+	if len(os.Args) > 1 && os.Args[1] == DISCOVERY_COMMAND {
+		if err = discoverSensors(topology_path,os.Stdin,os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// convert the configuration table, curve or schedule into a structured configuration
+	if len(os.Args) > 1 && os.Args[1] == MIGRATION_COMMAND {
+		source := config_path
+		for _,path := range []string{curve_path,schedule_path} {
+			if _,err := os.Stat(path); err == nil {
+				source = path
+			}
+		}
+		if len(os.Args) > 2 {
+			source = os.Args[2]
+		}
+		if err = migrateConfiguration(source,configuration_path,os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// list, add or remove manual overrides, checked against the limits of the configuration
+	if len(os.Args) > 1 && os.Args[1] == OVERRIDE_COMMAND {
+		limits := system.DefaultConfiguration().Limits
+		if config,err := system.LoadConfiguration(configuration_path); err == nil {
+			limits = config.Limits
 		}
+		if err = manageOverrides(override_path,limits,os.Args[2:],time.Now(),os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// calibrate the sensor offsets against a reference (°C) or the median of all sensors
+	if len(os.Args) > 1 && os.Args[1] == CALIBRATION_COMMAND {
+		reference := ""
+		if len(os.Args) > 2 {
+			reference = os.Args[2]
+		}
+		if err = calibrateSensors(topology_path,reference,os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// read the hardware setup
+	if err = initTopology(topology_path); err != nil {
+		log.Fatal(err)
+	}
+
+	// read the rules percepts are checked against before they enter the sliding window
+	if err = initPlausibility(plausibility_path); err != nil {
+		log.Fatal(err)
 	}
 
 	// init gpio pins
-	initGPIO()
+	if err = initGPIO(); err != nil {
+		log.Fatal(err)
+	}
 
 	// push cleanup on defer stack
 	defer func(){
 		fmt.Println("Cleanup GPIO Pins.")
 		cleanupGPIO()
+		cleanupActors()
+		if gpio.GetBackend() == gpio.SIMULATED {
+			// dry run: report what would have been switched
+			gpio.SimulationJournal.WriteTo(os.Stdout)
+		}
 	}()
 
 	// create log file if not exists, otherwise open file for appending error logs
@@ -686,34 +954,69 @@ func main(){
 	// init w1 sensors and start temperature recording
 	initW1()
 
-	// Percept_Oracle starts pooledPerceptGenerator and two tight loops waiting for
-	// Percept requests and Query request and the system chanels
-	processChan := make(chan bool)
-	go system.Percept_Oracle(
-		pooledPerceptGenerator,
-		PERCEPT_HISTORY_LENGTH,
-		processChan,
-		)
-	<-processChan // wait for processing signal from system.Percept_Oracle routine
+	// the oracles run until main returns
+	ctx,cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	go system.Configuration_Oracle(
-		config_path,
-		processChan,
-		DEFAULT_MIN_BOILER_TEMP,
-		)
-	config_oracle_available := <-processChan // wait for processing signal from system.Percept_Oracle routine
+	// the percept oracle runs pooledPerceptGenerator and maintains the sliding window,
+	// learners query it through the channel API
+	perceptOracle = system.NewPerceptOracle(pooledPerceptGenerator,PERCEPT_HISTORY_LENGTH)
+	restoreSnapshot(perceptOracle,oracle_snapshot_path)
+	if err = perceptOracle.Start(ctx); err != nil {
+		log.Fatal(err)
+	}
+	defer perceptOracle.Stop()
+	perceptOracle.ServeChannels(ctx)
+
+	// the configuration oracle provides the boiler targets, agents fall back to a default without it
+	// a structured configuration, a schedule or a parametric heating curve replaces the
+	// configuration table if present (in reverse order of precedence)
+	var targets system.TargetSource
+	for _,path := range []string{curve_path,schedule_path,configuration_path} {
+		if _,err := os.Stat(path); err == nil {
+			config_path = path
+		}
+	}
+	oracle := system.NewConfigurationOracle(config_path,DEFAULT_MIN_BOILER_TEMP)
+	oracle.SetOverridePath(override_path)
+	if err = oracle.Start(ctx); err != nil {
+		fmt.Printf("[WARNING]\t%v\n",err)
+	} else {
+		configurationOracle = oracle
+		defer configurationOracle.Stop()
+		targets = configurationOracle
+
+		// a SIGHUP forces a reload of the configuration
+		hangups := make(chan os.Signal, 1)
+		signal.Notify(hangups, syscall.SIGHUP)
+		go func(){
+			defer signal.Stop(hangups)
+			for {
+				select {
+				case <-hangups:
+					configurationOracle.Reload()
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
 
 	// @TODO include oracle loop
 	//go system.Oracle_loop(fetchSensorData,PERCEPT_HISTORY_LENGTH )
 	//system.Oracle_loop(fetchSensorData,PERCEPT_HISTORY_LENGTH )
 
 	// init actors
-	initActors()
+	if err = initActors(); err != nil {
+		log.Fatal(err)
+	}
 
 	// introduce actuators to agent
-	agent.SetPumpW(boilerPump)
-	agent.SetPumpH(radiatorPump)
-	agent.SetBurner(burner)
+	agent.SetActuators(actuators)
+
+	// the chimney button starts the emission measurement mode
+	chimney = newChimneySweepMode(chimney_button,chimney_led,chimneySweepDuration)
+	chimney.watch()
 
 	sState = &system.ActorState{
 		Time:time.Now(),
@@ -746,6 +1049,10 @@ func main(){
 		relations := []logger.Logable{
 			sState,
 			&system.Percept{},
+			&system.ReadingLog{},
+			&system.ViolationLog{},
+			&system.ReloadLog{},
+			&system.OverrideLog{},
 		}
 
 		logger.InitDbRelations(&relations)
@@ -755,19 +1062,22 @@ func main(){
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGKILL)
 
 	// set initial action
+	state := func(name string)(system.Setting){
+		return actuators.Get(name).State()
+	}
 	sAction = system.NewAction(
-		boilerPump.GetCurrentFreq(),
-		radiatorPump.GetCurrentFreq(),
-		boilerPump.IsActive(),
-		radiatorPump.IsActive(),
-		burner.GetValue(),
-		triangle_switch.GetValue(),
+		state(BOILER_PUMP).Frequency,
+		state(RADIATOR_PUMP).Frequency,
+		state(BOILER_PUMP).On,
+		state(RADIATOR_PUMP).On,
+		state(BURNER).On,
+		state(TRIANGLE).On,
 		)
 
 	// set rollout method for performing action transitions
 	applyAction = DefaultRollOut
 
-	systemAgent = agent.NewSimpleHeatingAgent(config_oracle_available)
+	systemAgent = agent.NewSimpleHeatingAgent(targets)
 
 	streamLearner := learner.NewWaterConsumptionLearner(
 		5,
@@ -776,11 +1086,29 @@ func main(){
 		14, // sec
 		6, // overlap
 	)
+	restoreSnapshot(streamLearner,learner_snapshot_path)
 	go streamLearner.StreamClustering(
 		&logfile,
 		&logmutex,
 		)
 
+	// oracle and learner are saved periodically and on shutdown
+	snapshots := map[string]snapshotter{
+		oracle_snapshot_path:perceptOracle,
+		learner_snapshot_path:streamLearner,
+	}
+	go func(){
+		for {
+			select {
+			case <-time.After(SNAPSHOT_INTERVAL):
+				saveSnapshots(snapshots)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	defer saveSnapshots(snapshots)
+
 	var lastLogTs time.Time
 
 	//@debug deadlock bug fmt.Println("Starting the main Loop")
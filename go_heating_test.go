@@ -1,11 +1,149 @@
 package main
 
-import "testing"
+import(
+	"net"
+	"testing"
+	"time"
+	"github.com/hansen1101/go_heating/system"
+	"github.com/hansen1101/go_heating/system/gpio"
+	"github.com/hansen1101/go_heating/system/hardware"
+	"github.com/hansen1101/go_heating/system/modbus"
+)
+
+const(
+	TEST_TOPOLOGY = "filesystem/heating_config/hardware.toml"
+)
+
+// Loads the topology shipped with the repository
+// @return the gpio id of the named relay or input
+func testPin(t *testing.T, name string)(gpio.GpioId){
+	if topology == nil {
+		if err := initTopology(TEST_TOPOLOGY); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if r,err := topology.Relay(name); err == nil {
+		return r.GpioId()
+	}
+	i,err := topology.Input(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return i.GpioId()
+}
 
 func TestInitW1(t *testing.T){
+	testPin(t,BURNER)
 	initGPIO()
 	initW1()
 	t.Error(
 		"Test","failed",
 	)
 }
+
+func TestDefaultRollOut(t *testing.T){
+	gpio.SetBackend(gpio.SIMULATED)
+	defer gpio.SetBackend(gpio.SYSFS)
+	triangleSettleTime = 5 * time.Millisecond
+	burnerIgnitionTime = 15 * time.Millisecond
+
+	triangle,burnerPin := testPin(t,TRIANGLE),testPin(t,BURNER)
+	boilerPumpOn,radiatorPumpOn := testPin(t,"boiler_pump_on"),testPin(t,"radiator_pump_on")
+	if err := initGPIO(); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanupGPIO()
+	// registered out of rollout order, the valve is moved first nevertheless
+	actuators = system.NewActuatorRegistry()
+	actuators.Register(BURNER,system.NewRelayActuator(pins[BURNER],burnerIgnitionTime))
+	actuators.Register(BOILER_PUMP,system.NewPump(50.0,15.0,0.01,0.2,pins["boiler_pump_on"],pins["boiler_pump_inc"],pins["boiler_pump_dec"]))
+	actuators.Register(RADIATOR_PUMP,system.NewPump(50.0,50.0,0.01,0.2,pins["radiator_pump_on"],pins["radiator_pump_inc"],pins["radiator_pump_dec"]))
+	actuators.Register(TRIANGLE,system.NewValveActuator(pins[TRIANGLE],triangleSettleTime))
+	gpio.SimulationJournal.Reset()
+
+	// burner relay on, wait for ignition, boiler pump on
+	DefaultRollOut(system.NewAction(15.0,50.0,false,true,true,true))
+
+	j := gpio.SimulationJournal
+	i,triangleOn := j.Find(0,triangle,gpio.OP_SET,true)
+	i,burnerOn := j.Find(i,burnerPin,gpio.OP_SET,true)
+	i,pumpOn := j.Find(i,boilerPumpOn,gpio.OP_SET,true)
+	if i < 0 {
+		t.Fatal("For","rollout sequence","expected","triangle, burner, pump","got",j.Entries())
+	}
+	if d := burnerOn.Time.Sub(triangleOn.Time); d < triangleSettleTime {
+		t.Error("For","triangle settle time","expected",triangleSettleTime,"got",d)
+	}
+	if d := pumpOn.Time.Sub(burnerOn.Time); d < burnerIgnitionTime {
+		t.Error("For","burner ignition time","expected",burnerIgnitionTime,"got",d)
+	}
+	if len(j.Filter(radiatorPumpOn)) != 0 {
+		t.Error("For","radiator pump","expected","no transition","got",j.Filter(radiatorPumpOn))
+	}
+}
+
+func TestInitActors(t *testing.T){
+	gpio.SetBackend(gpio.SIMULATED)
+	defer gpio.SetBackend(gpio.SYSFS)
+	triangleSettleTime = 5 * time.Millisecond
+	burnerIgnitionTime = 15 * time.Millisecond
+	testPin(t,BURNER)
+	if err := initGPIO(); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanupGPIO()
+	if err := initActors(); err != nil {
+		t.Fatal(err)
+	}
+	names := actuators.Names()
+	if len(names) != 4 || names[0] != TRIANGLE || names[1] != BURNER {
+		t.Error("For","rollout order","expected","triangle, burner, pumps","got",names)
+	}
+	if settle := actuators.Get(BURNER).Constraints().Settle; settle != burnerIgnitionTime {
+		t.Error("For","burner settle time","expected",burnerIgnitionTime,"got",settle)
+	}
+	if c := actuators.Get(BOILER_PUMP).Constraints(); c.MinFrequency != 15.0 || c.MaxFrequency != 50.0 {
+		t.Error("For","boiler pump","expected","15-50","got",c)
+	}
+
+	// converters commanded via modbus share the connection to their gateway
+	r := system.DefaultModbusRegisters
+	converter := modbus.NewSimSlave(1,r.Control,r.Setpoint,r.Frequency,r.Current,r.Fault)
+	listener,err := net.Listen("tcp","127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go converter.ServeTCP(listener)
+	pumps := topology.Pumps
+	defer func(){ topology.Pumps = pumps }()
+	topology.Pumps = append([]hardware.Pump{},pumps...)
+	for _,name := range []string{"mixer_pump","solar_pump"} {
+		topology.Pumps = append(topology.Pumps,hardware.Pump{Name:name,Max:40,Min:20,Modbus:"tcp://"+listener.Addr().String(),ModbusUnit:1})
+	}
+	if err := initActors(); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanupActors()
+	if len(modbusTransports) != 1 || actuators.Get("solar_pump").Kind() != system.ACTUATOR_PUMP {
+		t.Error("For","modbus pumps","expected","one transport","got",modbusTransports,actuators.Names())
+	}
+	if err := actuators.Get("mixer_pump").Set(system.Setting{On:true,Frequency:30}); err != nil || converter.Register(r.Setpoint) != 3000 {
+		t.Error("For","mixer pump at 30 Hz","expected",3000,"got",converter.Register(r.Setpoint),err)
+	}
+	topology.Pumps = pumps
+
+	// a topology without burner can not operate the system
+	relays := topology.Relays
+	defer func(){ topology.Relays = relays }()
+	topology.Relays = nil
+	for _,r := range relays {
+		if r.Name != BURNER {
+			topology.Relays = append(topology.Relays,r)
+		}
+	}
+	delete(pins,BURNER)
+	if err := initActors(); err == nil {
+		t.Error("For","missing burner","expected","error","got",actuators.Names())
+	}
+}
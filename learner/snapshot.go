@@ -0,0 +1,126 @@
+package learner
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hansen1101/go_heating/auxiliary/clustering"
+	"github.com/hansen1101/go_heating/system"
+)
+
+const (
+	LEARNER_SNAPSHOT_KIND = "WCLR"
+)
+
+/**
+ * Writes the bucket collection to a snapshot file (see system.WriteSnapshot). The clusters of
+ * a bucket are stored by their member points, their parameters are recalculated on Restore.
+ * May be called while StreamClustering is running.
+ */
+func (learner *waterConsumptionLearner) Snapshot(path string)(error){
+	var e system.SnapshotEncoder
+	learner.bucketLock.Lock()
+	sizeKeys := make([]int,0,len(learner.bucketCollection))
+	for sizeKey,list := range learner.bucketCollection {
+		if list != nil {
+			sizeKeys = append(sizeKeys,sizeKey)
+		}
+	}
+	sort.Ints(sizeKeys)
+	e.PutUint32(uint32(len(sizeKeys)))
+	for _,sizeKey := range sizeKeys {
+		list := *learner.bucketCollection[sizeKey]
+		e.PutUint32(uint32(sizeKey))
+		e.PutUint32(uint32(len(list)))
+		for _,b := range list {
+			encodeBucket(&e,b)
+		}
+	}
+	learner.bucketLock.Unlock()
+	return system.WriteSnapshot(path,LEARNER_SNAPSHOT_KIND,time.Now(),e.Bytes())
+}
+
+/**
+ * Restores the bucket collection from a snapshot file, must be called before StreamClustering.
+ * Buckets that left the time horizon of the learner are dropped by the next merge.
+ * @return an error of system.ReadSnapshot if the file is corrupted or older than the time
+ * horizon of the learner
+ */
+func (learner *waterConsumptionLearner) Restore(path string)(error){
+	payload,_,err := system.ReadSnapshot(path,LEARNER_SNAPSHOT_KIND,time.Second * time.Duration(learner.windowTimeHorizonInSec))
+	if err != nil {
+		return err
+	}
+	d := system.NewSnapshotDecoder(payload)
+	collection := make(map[int]*[]*bucket)
+	for i,n := 0,d.Count(8); i < n; i++ {
+		sizeKey := int(d.Uint32())
+		list := make([]*bucket,d.Count(20))
+		for j := range list {
+			list[j] = decodeBucket(d)
+		}
+		collection[sizeKey] = &list
+	}
+	if err := d.Err(); err != nil {
+		return err
+	}
+	learner.bucketLock.Lock()
+	learner.bucketCollection = collection
+	learner.bucketLock.Unlock()
+	return nil
+}
+
+func encodeBucket(e *system.SnapshotEncoder, b *bucket)(){
+	e.PutInt64(b.timestamp)
+	e.PutUint32(uint32(b.itemcount))
+	e.PutUint32(uint32(len(b.record)))
+	for _,c := range b.record {
+		items := c.GetClusterItems()
+		e.PutUint32(uint32(len(items)))
+		for _,p := range items {
+			var timestamp int64
+			if v,ok := p.(*deltaPoint); ok {
+				timestamp = v.timestamp
+			}
+			e.PutInt64(timestamp)
+			e.PutUint32(uint32(p.Dimensions()))
+			for _,coordinate := range p.GetVector() {
+				value,ok := float64(0),false
+				if coordinate != nil {
+					value,ok = coordinate.GetValue().(float64)
+				}
+				e.PutBool(ok)
+				e.PutFloat64(value)
+			}
+		}
+	}
+}
+
+func decodeBucket(d *system.SnapshotDecoder)(b *bucket){
+	b = &bucket{timestamp:d.Int64(),itemcount:int(d.Uint32())}
+	for i,n := 0,d.Count(4); i < n; i++ {
+		members := clustering.NewCluster()
+		for j,m := 0,d.Count(12); j < m; j++ {
+			timestamp := d.Int64()
+			point := NewDeltaPoint(d.Count(9))
+			point.SetTimestamp(timestamp)
+			for dim := 0; dim < point.Dimensions(); dim++ {
+				if ok,value := d.Bool(),d.Float64(); ok {
+					point.SetCoordinate(dim,value)
+				}
+			}
+			members.AddItem(point)
+		}
+		if members.GetClusterSize() == 0 {
+			fmt.Println("[WARNING]\tsnapshot cluster without points is dropped")
+			continue
+		}
+		// recalculates centroid, diameter, radius and density of the members
+		b.record = append(b.record,newFullCluster(
+			members.CombineWithCluster(nil,clustering.WeightedEuclideanDistance),
+			clustering.WeightedEuclideanDistance,
+		))
+	}
+	return
+}
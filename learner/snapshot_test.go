@@ -0,0 +1,73 @@
+package learner
+
+import(
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"github.com/hansen1101/go_heating/auxiliary/clustering"
+)
+
+func TestSnapshotBuckets(t *testing.T){
+	dir,err := ioutil.TempDir("","learner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir,"learner.snapshot")
+
+	// two groups of deltas: around 0.1 and around 2.0
+	now := time.Now().Unix()
+	points := make([]*deltaPoint,0,16)
+	for i := 0; i < 16; i++ {
+		p := NewDeltaPoint(1)
+		p.SetTimestamp(now - int64(16-i))
+		p.SetDelta(float64(i % 2) * 2 + float64(i) / 100)
+		points = append(points,p)
+	}
+	next := new(bucket)
+	next.record,next.itemcount,next.timestamp = generateAgglomerativeBucket(
+		points,
+		5,
+		extractBestClusterLevelSilhouette,
+		clustering.WeightedEuclideanDistance,
+		clustering.SingleLink,
+	)
+
+	learner := NewWaterConsumptionLearner(5,0.01,16,14,6)
+	learner.bucketCollection[next.itemcount] = &[]*bucket{next}
+	if err := learner.Snapshot(path); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewWaterConsumptionLearner(5,0.01,16,14,6)
+	if err := restored.Restore(path); err != nil {
+		t.Fatal(err)
+	}
+	list := restored.bucketCollection[16]
+	if list == nil || len(*list) != 1 {
+		t.Fatal("For","restored collection","expected","one bucket of size 16","got",restored.bucketCollection)
+	}
+	b := (*list)[0]
+	if b.timestamp != next.timestamp || b.itemcount != 16 || len(b.record) != len(next.record) {
+		t.Error("For","restored bucket","expected",next.timestamp,16,len(next.record),"got",b.timestamp,b.itemcount,len(b.record))
+	}
+	for i,c := range b.record {
+		expected := next.record[i].GetCentroid()
+		if c.GetClusterSize() != next.record[i].GetClusterSize() || clustering.EuclideanDistance(c.GetCentroid(),expected) > 1e-9 {
+			t.Error("For","cluster",i,"expected",expected,"got",c.GetCentroid())
+		}
+		if c.GetCentroid().(*deltaPoint).timestamp != expected.(*deltaPoint).timestamp {
+			t.Error("For","centroid timestamp of cluster",i,"expected",expected.(*deltaPoint).timestamp,"got",c.GetCentroid().(*deltaPoint).timestamp)
+		}
+	}
+
+	// a corrupted snapshot leaves the collection untouched
+	data,_ := ioutil.ReadFile(path)
+	data[len(data)/2] ^= 0xff
+	ioutil.WriteFile(path,data,0644)
+	if err := restored.Restore(path); err == nil || len(restored.bucketCollection) != 1 {
+		t.Error("For","corrupted snapshot","expected","error and the restored collection","got",err,restored.bucketCollection)
+	}
+}
@@ -33,6 +33,8 @@ type waterConsumptionLearner struct{
 	overlap int
 	windowTimeHorizonInSec int64
 	clusteringPointList []*bucketPoint
+	bucketCollection map[int]*[]*bucket	// buckets by size key, see Snapshot
+	bucketLock sync.Mutex
 
 }
 type bucket struct {
@@ -54,6 +56,10 @@ func NewWaterConsumptionLearner(k int, epsilon float64,p,sec,overlap int)(learne
 	learner = &waterConsumptionLearner{k:k,epsilon:epsilon,p:p,sec:sec,overlap:overlap}
 	learner.clusteringPointList = make([]*bucketPoint,0,CLUSTERING_DECAY_HORIZON)
 	learner.windowTimeHorizonInSec = calcTimeHorizonInSec(p,sec,overlap)
+	learner.bucketCollection = make(
+		map[int]*[]*bucket,
+		math.Ilogb(float64(MAX_BUCKET_SIZE))-math.Ilogb(float64(p)),
+	)
 	return
 }
 
@@ -635,11 +641,6 @@ func fetchClusterLevel(k int, levels *[][]clustering.Cluster,pointDistAlgo clust
 // Tigh loop that sends DataQuery for WaterBufferDelta to an Oracle and
 // processes the response
 func (learner *waterConsumptionLearner) StreamClustering(logDestination *io.Writer, logMutex *sync.Mutex)(){
-	bucketCollection := make(
-		map[int]*[]*bucket,
-		math.Ilogb(float64(MAX_BUCKET_SIZE))-math.Ilogb(float64(learner.p)),
-	)
-
 	// list d_i holds up to p points
 	// that represent p successive (1D) temperature deltas
 	incomingPoints := make([]*deltaPoint,0,learner.p)
@@ -772,14 +773,16 @@ func (learner *waterConsumptionLearner) StreamClustering(logDestination *io.Writ
 					*/
 					// add bucket to bucketCollection
 					sizeKey := next.itemcount
+					learner.bucketLock.Lock()
 					learner.addAndMergeBuckets(
 						next,
-						&bucketCollection,
+						&learner.bucketCollection,
 						sizeKey,
 						next.timestamp,
 						clustering.CentroidDistance,
 						clustering.WeightedEuclideanDistance,
 					)
+					learner.bucketLock.Unlock()
 				}
 
 				// init new point collection
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"github.com/hansen1101/go_heating/system"
+)
+
+const(
+	MIGRATION_COMMAND = "migrate-config"
+)
+
+// Converts the configuration table, parametric curve or schedule at source into a structured
+// configuration with the default hysteresis and safety limits and writes it to target.
+// Every invalid row or setting fails the migration, the target is not touched then.
+// @return error if the source can not be read or the configuration is invalid
+func migrateConfiguration(source, target string, out io.Writer)(err error){
+	config,err := system.MigrateConfiguration(source)
+	if err != nil {
+		return
+	}
+	if err = system.SaveConfiguration(target,config); err != nil {
+		return
+	}
+	kind := "heating curve"
+	switch {
+	case config.Schedule != nil:
+		kind = fmt.Sprintf("schedule with %d profiles",len(config.Schedule.Profiles))
+	case len(config.Curve.Table) > 0:
+		kind = fmt.Sprintf("configuration table with %d rows",len(config.Curve.Table))
+	}
+	fmt.Fprintf(out,"%s (%s) migrated to %s (schema version %d)\n",source,kind,target,config.Version)
+	return
+}
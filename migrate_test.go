@@ -0,0 +1,43 @@
+package main
+
+import(
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"github.com/hansen1101/go_heating/system"
+)
+
+func TestMigrateConfiguration(t *testing.T){
+	dir,err := ioutil.TempDir("","migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	target := filepath.Join(dir,"config.pb")
+
+	var out bytes.Buffer
+	if err = migrateConfiguration("filesystem/heating_config/config.csv",target,&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(),"configuration table with 35 rows") {
+		t.Error("For","summary","expected","configuration table with 35 rows","got",out.String())
+	}
+	config,err := system.LoadConfiguration(target)
+	if err != nil || config.Curve == nil || config.Hysteresis != system.DefaultConfiguration().Hysteresis {
+		t.Error("For","migrated configuration","expected","table with default settings","got",config,err)
+	}
+
+	// an invalid source leaves the target untouched
+	invalid := filepath.Join(dir,"invalid.csv")
+	ioutil.WriteFile(invalid,[]byte("Temp/h,0\n-10,x\n"),0644)
+	before,_ := ioutil.ReadFile(target)
+	if err = migrateConfiguration(invalid,target,&out); err == nil {
+		t.Error("For","invalid source","expected","error","got",nil)
+	}
+	if after,_ := ioutil.ReadFile(target); !bytes.Equal(before,after) {
+		t.Error("For","invalid source","expected","target unchanged","got",len(after),"bytes")
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+	"github.com/hansen1101/go_heating/system"
+)
+
+const(
+	OVERRIDE_COMMAND = "override"
+	OVERRIDE_USAGE = "usage: override [list | boiler <°C> <until> | heating-off <until> | boost [°C] | remove <id>]\n" +
+		"\t<until> is a duration (2h), a time of the day (06:00) or a time (2006-01-02 15:04)"
+)
+
+// Parses a temperature in °C into m°C
+func parseCelsius(value string)(target int, err error){
+	celsius,err := strconv.ParseFloat(value,64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is no temperature in °C",value)
+	}
+	return int(math.Round(celsius * 1000)), nil
+}
+
+// Lists, adds or removes the manual overrides saved at path. A running system picks up the
+// changes at once since the configuration oracle watches the file.
+// @param limits safety limits of the configuration the overrides are checked against
+// @param args the arguments of the command, see OVERRIDE_USAGE
+// @return error if the arguments are invalid or the overrides can not be read or written
+func manageOverrides(path string, limits system.SafetyLimits, args []string, now time.Time, out io.Writer)(err error){
+	overrides,err := system.LoadOverrides(path)
+	if err != nil {
+		return
+	}
+	if len(args) == 0 || args[0] == "list" {
+		active := overrides.Active(now)
+		if len(active) == 0 {
+			fmt.Fprintln(out,"no active overrides")
+		}
+		for _,o := range active {
+			fmt.Fprintln(out,o)
+		}
+		return
+	}
+
+	if args[0] == "remove" {
+		if len(args) != 2 {
+			return fmt.Errorf(OVERRIDE_USAGE)
+		}
+		id,err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("%q is no override id",args[1])
+		}
+		if overrides,err = overrides.Remove(id); err != nil {
+			return fmt.Errorf("override #%d: %v",id,err)
+		}
+		if err = system.SaveOverrides(path,overrides); err == nil {
+			fmt.Fprintf(out,"override #%d removed\n",id)
+		}
+		return err
+	}
+
+	override := system.Override{Created:now}
+	switch {
+	case args[0] == "boiler" && len(args) == 3:
+		override.Kind = system.OVERRIDE_BOILER
+		if override.Target,err = parseCelsius(args[1]); err == nil {
+			override.Expires,err = system.ParseExpiry(args[2],now)
+		}
+	case args[0] == "heating-off" && len(args) == 2:
+		override.Kind = system.OVERRIDE_HEATING_OFF
+		override.Expires,err = system.ParseExpiry(args[1],now)
+	case args[0] == "boost" && len(args) <= 2:
+		override.Kind = system.OVERRIDE_HOT_WATER_BOOST
+		override.Target,override.Expires = system.HOT_WATER_BOOST_TARGET,now.Add(system.HOT_WATER_BOOST_DURATION)
+		if len(args) == 2 {
+			override.Target,err = parseCelsius(args[1])
+		}
+	default:
+		return fmt.Errorf(OVERRIDE_USAGE)
+	}
+	if err != nil {
+		return
+	}
+	if overrides,override,err = overrides.Add(override,limits); err != nil {
+		return
+	}
+	if err = system.SaveOverrides(path,overrides); err == nil {
+		fmt.Fprintf(out,"override %s added\n",override)
+	}
+	return
+}
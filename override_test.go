@@ -0,0 +1,59 @@
+package main
+
+import(
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"github.com/hansen1101/go_heating/system"
+)
+
+func TestManageOverrides(t *testing.T){
+	dir,err := ioutil.TempDir("","override")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir,"overrides.toml")
+	limits := system.DefaultConfiguration().Limits
+	now := time.Now().Truncate(time.Minute)
+
+	var out bytes.Buffer
+	commands := [][]string{
+		{"boiler","55","2h"},
+		{"heating-off","06:00"},
+		{"boost"},
+	}
+	for _,args := range commands {
+		if err = manageOverrides(path,limits,args,now,&out); err != nil {
+			t.Error("For",args,"expected",nil,"got",err)
+		}
+	}
+	overrides,err := system.LoadOverrides(path)
+	if err != nil || len(overrides) != 3 {
+		t.Fatal("For","saved overrides","expected",3,"got",overrides,err)
+	}
+	if o := overrides[0]; o.Kind != system.OVERRIDE_BOILER || o.Target != 55000 || !o.Expires.Equal(now.Add(time.Hour * 2)) {
+		t.Error("For","boiler 55 2h","expected","boiler override","got",o)
+	}
+	if o := overrides[2]; o.Kind != system.OVERRIDE_HOT_WATER_BOOST || o.Target != system.HOT_WATER_BOOST_TARGET {
+		t.Error("For","boost","expected","boost to",system.HOT_WATER_BOOST_TARGET,"got",o)
+	}
+
+	for _,args := range [][]string{{"boiler","90","2h"},{"boiler","55"},{"heating-off","yesterday"},{"remove","9"},{"cool"}} {
+		if err = manageOverrides(path,limits,args,now,&out); err == nil {
+			t.Error("For",args,"expected","error","got",nil)
+		}
+	}
+
+	if err = manageOverrides(path,limits,[]string{"remove","2"},now,&out); err != nil {
+		t.Error("For","remove 2","expected",nil,"got",err)
+	}
+	out.Reset()
+	if err = manageOverrides(path,limits,nil,now,&out); err != nil || strings.Count(out.String(),"\n") != 2 || strings.Contains(out.String(),system.OVERRIDE_HEATING_OFF) {
+		t.Error("For","list","expected","boiler and boost","got",out.String(),err)
+	}
+}
@@ -17,6 +17,7 @@ type Action struct {
 	//logger.Logable
 	hPumpThrottle, wPumpThrottle float64
 	hPumpState, wPumpState, burnerState, triangleState bool
+	settings map[string]Setting	// settings of further actuators by name, see CommandFor
 }
 
 func NewAction(wFreq, hFreq float64, h,w,b,t bool)(a *Action){
@@ -33,7 +34,7 @@ func NewAction(wFreq, hFreq float64, h,w,b,t bool)(a *Action){
 
 func (self *Action) String()(string){
 	var buffer bytes.Buffer
-	buffer.WriteString(fmt.Sprintf("\n[ACTION]\tBurner:%v | HeatPump:%v | BoilerPump:%v | Triangle:%v\n",self.burnerState,self.hPumpState,self.wPumpState,self.triangleState))
+	buffer.WriteString(fmt.Sprintf("\n[ACTION]\tBurner:%v | HeatPump:%v (%.1f) | BoilerPump:%v (%.1f) | Triangle:%v\n",self.burnerState,self.hPumpState,self.hPumpThrottle,self.wPumpState,self.wPumpThrottle,self.triangleState))
 	buffer.WriteString(fmt.Sprintln())
 	return buffer.String()
 }
@@ -139,3 +140,17 @@ func (a *Action) SetWPumpThrottle(v float64)(){
 func (a *Action) SetTriangleState(v bool)(){
 	a.triangleState = v
 }
+
+// Returns the setting of the named actuator, false if the action does not demand one
+func (a *Action) GetSetting(name string)(Setting, bool){
+	s,ok := a.settings[name]
+	return s, ok
+}
+
+// Sets the setting of an actuator that is not covered by the fields of the action
+func (a *Action) SetSetting(name string, s Setting)(){
+	if a.settings == nil {
+		a.settings = make(map[string]Setting)
+	}
+	a.settings[name] = s
+}
@@ -0,0 +1,239 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+	"github.com/hansen1101/go_heating/system/gpio"
+	"github.com/hansen1101/go_heating/system/hardware"
+)
+
+// Kinds of actuators, the rollout moves valves first, then switches relays and pumps
+const (
+	ACTUATOR_VALVE = hardware.ACTUATOR_VALVE
+	ACTUATOR_RELAY = hardware.ACTUATOR_RELAY
+	ACTUATOR_PUMP = "pump"
+)
+
+// Names of the actuators the fields of an Action are rolled out to, see ActionCommand
+const (
+	ACTUATOR_BURNER = "burner"
+	ACTUATOR_TRIANGLE = "triangle"
+	ACTUATOR_BOILER_PUMP = "boiler"
+	ACTUATOR_RADIATOR_PUMP = "radiator"
+)
+
+var (
+	ErrNoFeedback = errors.New("actuator has no feedback")
+	rolloutOrder = map[string]int{ACTUATOR_VALVE:0,ACTUATOR_RELAY:1,ACTUATOR_PUMP:2}
+)
+
+// State of an actuator: switched on (a valve in position B) and the frequency of a
+// variable-frequency pump
+type Setting struct {
+	On bool
+	Frequency float64
+}
+
+// Constraints of an actuator
+type Constraints struct {
+	MinFrequency, MaxFrequency float64	// range of a variable-frequency pump, 0 otherwise
+	Settle time.Duration			// time to take effect after the actuator was switched on (valves: moved)
+}
+
+// An Actuator is a component of the heating system that is commanded by the rollout
+type Actuator interface {
+	Kind()(string)
+	State()(Setting)		// last commanded state
+	Set(setting Setting)(error)
+	ReadBack()(Setting, error)	// state reported by the hardware, ErrNoFeedback if it can not be read
+	Constraints()(Constraints)
+}
+
+// A binary component switched by a relay, e.g. the burner
+// implements Actuator interface
+type RelayActuator struct {
+	pin gpio.Pin
+	settle time.Duration
+	state Setting
+}
+
+// @param settle time the component needs after it was switched on (e.g. ignition of a burner)
+func NewRelayActuator(pin gpio.Pin, settle time.Duration)(r *RelayActuator){
+	r = &RelayActuator{pin:pin,settle:settle}
+	if pin != nil {
+		r.state.On = pin.GetValue()
+	}
+	return
+}
+
+func (r *RelayActuator) Kind()(string){
+	return ACTUATOR_RELAY
+}
+
+func (r *RelayActuator) State()(Setting){
+	return r.state
+}
+
+func (r *RelayActuator) Set(setting Setting)(error){
+	if r.pin == nil {
+		return fmt.Errorf("relay has no pin")
+	}
+	r.pin.SetValue(setting.On)
+	r.state = Setting{On:setting.On}
+	return nil
+}
+
+func (r *RelayActuator) ReadBack()(Setting, error){
+	if r.pin == nil {
+		return Setting{}, ErrNoFeedback
+	}
+	return Setting{On:r.pin.GetValue()}, nil
+}
+
+func (r *RelayActuator) Constraints()(Constraints){
+	return Constraints{Settle:r.settle}
+}
+
+// A three-way valve moved by a relay, On selects position B (e.g. the triangle valve that
+// leads the kettle water to the boiler instead of the radiators)
+// implements Actuator interface
+type ValveActuator struct {
+	RelayActuator
+}
+
+// @param settle time the valve needs to change its position
+func NewValveActuator(pin gpio.Pin, settle time.Duration)(v *ValveActuator){
+	return &ValveActuator{*NewRelayActuator(pin,settle)}
+}
+
+func (v *ValveActuator) Kind()(string){
+	return ACTUATOR_VALVE
+}
+
+// Returns the setting of the named actuator that is demanded by an action
+type ActionCommand func(a *Action)(setting Setting, ok bool)
+
+// Returns the command for the field of the action the named actuator executes. Other
+// actuators get the setting of their name (see Action.SetSetting).
+func CommandFor(name string)(command ActionCommand){
+	switch name {
+	case ACTUATOR_BURNER:
+		return func(a *Action)(Setting, bool){ return Setting{On:a.GetBurnerState()}, true }
+	case ACTUATOR_TRIANGLE:
+		return func(a *Action)(Setting, bool){ return Setting{On:a.GetTriangleState()}, true }
+	case ACTUATOR_BOILER_PUMP:
+		return func(a *Action)(Setting, bool){ return Setting{On:a.GetWPumpState(),Frequency:a.GetWPumpThrottle()}, true }
+	case ACTUATOR_RADIATOR_PUMP:
+		return func(a *Action)(Setting, bool){ return Setting{On:a.GetHPumpState(),Frequency:a.GetHPumpThrottle()}, true }
+	}
+	return func(a *Action)(Setting, bool){ return a.GetSetting(name) }
+}
+
+type actuatorBinding struct {
+	name string
+	actuator Actuator
+	command ActionCommand
+}
+
+/**
+ * The ActuatorRegistry holds the actuators of the system by name and rolls out actions to
+ * them, thus new circuits and actuators are added by registering them.
+ */
+type ActuatorRegistry struct {
+	bindings []actuatorBinding	// in rollout order
+	lock sync.Mutex
+}
+
+func NewActuatorRegistry()(r *ActuatorRegistry){
+	return &ActuatorRegistry{}
+}
+
+/**
+ * Registers the actuator under name, actions are rolled out to it by the command of
+ * CommandFor. Valves are moved before relays are switched and pumps are driven.
+ * @return error if the name is already registered
+ */
+func (r *ActuatorRegistry) Register(name string, actuator Actuator)(error){
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _,b := range r.bindings {
+		if b.name == name {
+			return fmt.Errorf("actuator %s is already registered",name)
+		}
+	}
+	r.bindings = append(r.bindings,actuatorBinding{name,actuator,CommandFor(name)})
+	sort.SliceStable(r.bindings,func(i, j int)(bool){
+		return rolloutOrder[r.bindings[i].actuator.Kind()] < rolloutOrder[r.bindings[j].actuator.Kind()]
+	})
+	return nil
+}
+
+// Returns the named actuator, nil if it is not registered
+func (r *ActuatorRegistry) Get(name string)(Actuator){
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _,b := range r.bindings {
+		if b.name == name {
+			return b.actuator
+		}
+	}
+	return nil
+}
+
+// Returns the names of the actuators in rollout order, only those of the given kinds if set
+func (r *ActuatorRegistry) Names(kinds ...string)(names []string){
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _,b := range r.bindings {
+		matches := len(kinds) == 0
+		for _,kind := range kinds {
+			matches = matches || b.actuator.Kind() == kind
+		}
+		if matches {
+			names = append(names,b.name)
+		}
+	}
+	return
+}
+
+// Returns the names of the given actuators that are not registered
+func (r *ActuatorRegistry) Missing(names ...string)(missing []string){
+	for _,name := range names {
+		if r.Get(name) == nil {
+			missing = append(missing,name)
+		}
+	}
+	return
+}
+
+/**
+ * Rolls out the action to all actuators whose setting the action demands, in the order of
+ * Register. After an actuator was switched on (a valve was moved) the rollout waits for its
+ * settle time. Failures and read-backs that differ from the setting are printed.
+ * implements RollOut type
+ */
+func (r *ActuatorRegistry) RollOut(a *Action)(){
+	r.lock.Lock()
+	bindings := append([]actuatorBinding{},r.bindings...)
+	r.lock.Unlock()
+	for _,b := range bindings {
+		setting,ok := b.command(a)
+		if !ok {
+			continue
+		}
+		before := b.actuator.State()
+		if err := b.actuator.Set(setting); err != nil {
+			fmt.Printf("[WARNING]\tactuator %s: %v\n",b.name,err)
+			continue
+		}
+		if feedback,err := b.actuator.ReadBack(); err == nil && feedback.On != setting.On {
+			fmt.Printf("[WARNING]\tactuator %s: read-back %v differs from setting %v\n",b.name,feedback.On,setting.On)
+		}
+		if after := b.actuator.State(); after.On != before.On && (after.On || b.actuator.Kind() == ACTUATOR_VALVE) {
+			<-time.After(b.actuator.Constraints().Settle)
+		}
+	}
+}
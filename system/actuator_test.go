@@ -0,0 +1,242 @@
+package system
+
+import(
+	"io/ioutil"
+	"math"
+	"os"
+	"testing"
+	"time"
+	"github.com/hansen1101/go_heating/system/gpio"
+	"github.com/hansen1101/go_heating/system/modbus"
+)
+
+func testActuatorPin(id gpio.GpioId)(gpio.Pin){
+	pin := gpio.NewSimPin()
+	pin.PinMode(id,gpio.OUTPUT)
+	return pin
+}
+
+func TestActuatorRegistry(t *testing.T){
+	burner := NewRelayActuator(testActuatorPin(gpio.GPIO18),time.Millisecond * 30)
+	triangle := NewValveActuator(testActuatorPin(gpio.GPIO27),time.Millisecond * 20)
+	pump := NewPump(50.0,15.0,0.01,0.2,testActuatorPin(gpio.GPIO17),testActuatorPin(gpio.GPIO24),testActuatorPin(gpio.GPIO5))
+	mixer := NewRelayActuator(testActuatorPin(gpio.GPIO22),0)
+
+	r := NewActuatorRegistry()
+	for name,a := range map[string]Actuator{ACTUATOR_BOILER_PUMP:pump,ACTUATOR_BURNER:burner,"mixer":mixer} {
+		if err := r.Register(name,a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r.Register(ACTUATOR_TRIANGLE,triangle)
+	if err := r.Register(ACTUATOR_BURNER,burner); err == nil {
+		t.Error("For","registered twice","expected","error","got",nil)
+	}
+	if names := r.Names(ACTUATOR_VALVE,ACTUATOR_PUMP); len(names) != 2 || names[0] != ACTUATOR_TRIANGLE || names[1] != ACTUATOR_BOILER_PUMP {
+		t.Error("For","valves and pumps","expected",[]string{ACTUATOR_TRIANGLE,ACTUATOR_BOILER_PUMP},"got",names)
+	}
+	if missing := r.Missing(ACTUATOR_BURNER,ACTUATOR_RADIATOR_PUMP); len(missing) != 1 || missing[0] != ACTUATOR_RADIATOR_PUMP {
+		t.Error("For","missing","expected",ACTUATOR_RADIATOR_PUMP,"got",missing)
+	}
+
+	// valve and burner settle, the mixer is not demanded by the action and kept
+	mixer.Set(Setting{On:true})
+	start := time.Now()
+	r.RollOut(NewAction(15.0,0.0,false,true,true,true))
+	if d := time.Since(start); d < time.Millisecond * 50 {
+		t.Error("For","settle times","expected",time.Millisecond * 50,"got",d)
+	}
+	for name,expected := range map[string]bool{ACTUATOR_BURNER:true,ACTUATOR_TRIANGLE:true,ACTUATOR_BOILER_PUMP:true,"mixer":true} {
+		if state := r.Get(name).State(); state.On != expected {
+			t.Error("For",name,"expected",expected,"got",state)
+		}
+		if feedback,err := r.Get(name).ReadBack(); err != nil || feedback.On != expected {
+			t.Error("For",name,"read-back","expected",expected,"got",feedback,err)
+		}
+	}
+	if c := pump.Constraints(); c.MinFrequency != 15.0 || c.MaxFrequency != 50.0 || pump.State().Frequency != 15.0 {
+		t.Error("For","pump","expected","15-50 at 15","got",c,pump.State())
+	}
+
+	// the burner only settles after it was switched on, the valve after every move
+	action := NewAction(15.0,0.0,false,true,true,false)
+	action.SetSetting("mixer",Setting{On:false})
+	start = time.Now()
+	r.RollOut(action)
+	if d := time.Since(start); d < time.Millisecond * 20 || d >= time.Millisecond * 50 {
+		t.Error("For","valve moved","expected",time.Millisecond * 20,"got",d)
+	}
+	if mixer.State().On || triangle.State().On {
+		t.Error("For","named setting","expected","mixer and valve off","got",mixer.State(),triangle.State())
+	}
+	if _,err := NewPump(50.0,15.0,0.01,0.2,nil,nil,nil).ReadBack(); err != ErrNoFeedback {
+		t.Error("For","pump without pins","expected",ErrNoFeedback,"got",err)
+	}
+}
+
+func TestPumpFrequency(t *testing.T){
+	power,inc,dec := testActuatorPin(gpio.GPIO17),testActuatorPin(gpio.GPIO24),testActuatorPin(gpio.GPIO5)
+	pump := NewPump(50.0,15.0,0.01,0.2,power,inc,dec)
+	gpio.SimulationJournal.Reset()
+
+	settings := []struct{
+		setting Setting
+		expected float64
+		relay gpio.Pin
+	}{
+		{Setting{On:true,Frequency:30.0},30.0,inc},
+		{Setting{On:true,Frequency:80.0},50.0,inc},
+		{Setting{On:true},15.0,dec},
+		{Setting{On:true,Frequency:10.0},15.0,nil},
+		{Setting{On:false,Frequency:40.0},OFF_FREQ,nil},
+	}
+	for _,s := range settings {
+		gpio.SimulationJournal.Reset()
+		pump.Set(s.setting)
+		if state := pump.State(); state.On != s.setting.On || state.Frequency != s.expected {
+			t.Error("For",s.setting,"expected",s.expected,"got",state)
+		}
+		for _,relay := range []gpio.Pin{inc,dec} {
+			pulsed := len(gpio.SimulationJournal.Filter(relay.GetGpioId())) > 0
+			if pulsed != (relay == s.relay) {
+				t.Error("For",s.setting,"relay",relay.GetGpioId(),"expected pulse",relay == s.relay,"got",pulsed)
+			}
+		}
+	}
+}
+
+// Feedback of a fixed frequency
+type testFeedback struct {
+	frequency float64
+	err error
+}
+
+func (f *testFeedback) Frequency()(float64, error){
+	return f.frequency, f.err
+}
+
+func TestPumpRecalibration(t *testing.T){
+	power,inc,dec := testActuatorPin(gpio.GPIO17),testActuatorPin(gpio.GPIO24),testActuatorPin(gpio.GPIO5)
+	pump := NewPump(50.0,15.0,0.01,0.2,power,inc,dec)
+	pump.Set(Setting{On:true})
+	if pump.Uncertainty() != 0 {
+		t.Error("For","switched on","expected",0,"got",pump.Uncertainty())
+	}
+
+	// pulses add to the uncertainty until the pump is driven to an endstop
+	for _,frequency := range []float64{50.0,15.0,50.0} {
+		pump.Set(Setting{On:true,Frequency:frequency})
+	}
+	if u,expected := pump.Uncertainty(),3 * 35.0 * PUMP_PULSE_UNCERTAINTY; u != expected || u <= PUMP_MAX_UNCERTAINTY {
+		t.Error("For","uncertainty","expected",expected,"got",u)
+	}
+	gpio.SimulationJournal.Reset()
+	pump.Set(Setting{On:true,Frequency:20.0})
+	if u,expected := pump.Uncertainty(),5.0 * PUMP_PULSE_UNCERTAINTY; u != expected || len(gpio.SimulationJournal.Filter(dec.GetGpioId())) == 0 {
+		t.Error("For","recalibration at min","expected",expected,"got",u,gpio.SimulationJournal.Entries())
+	}
+
+	// a calibration that is too old drives the pump to the endstop nearest to the target
+	pump.calibrated = time.Now().Add(-PUMP_RECALIBRATION_INTERVAL * 2)
+	gpio.SimulationJournal.Reset()
+	pump.Set(Setting{On:true,Frequency:45.0})
+	incPulses,decPulses := gpio.SimulationJournal.Filter(inc.GetGpioId()),gpio.SimulationJournal.Filter(dec.GetGpioId())
+	if len(incPulses) == 0 || len(decPulses) == 0 || decPulses[0].Time.Before(incPulses[0].Time) {
+		t.Error("For","recalibration at max","expected","inc before dec","got",gpio.SimulationJournal.Entries())
+	}
+	if pump.Uncertainty() != 5.0 * PUMP_PULSE_UNCERTAINTY || pump.State().Frequency != 45.0 {
+		t.Error("For","recalibrated pump","expected",45.0,"got",pump.State(),pump.Uncertainty())
+	}
+
+	// the feedback corrects the estimation and reports the deviation
+	feedback := &testFeedback{frequency:30.0}
+	pump.SetFeedback(feedback,0)
+	err := pump.Set(Setting{On:true,Frequency:30.0})
+	if err != nil || pump.Faults() != 0 || pump.Uncertainty() != 0 {
+		t.Error("For","matching feedback","expected","no fault","got",err,pump.Faults(),pump.Uncertainty())
+	}
+	feedback.frequency = 20.0
+	err = pump.Set(Setting{On:true,Frequency:35.0})
+	fault,ok := err.(*PumpFault)
+	if !ok || fault.Measured != 20.0 || fault.Estimated != 35.0 || pump.Faults() != 1 || pump.State().Frequency != 35.0 {
+		t.Error("For","deviating feedback","expected","fault","got",err,pump.Faults(),pump.State())
+	}
+	if setting,err := pump.ReadBack(); err != nil || setting.Frequency != 20.0 || !setting.On {
+		t.Error("For","read-back","expected",20.0,"got",setting,err)
+	}
+	feedback.err = ErrNoFeedback
+	if err = pump.Set(Setting{On:true,Frequency:30.0}); err == nil {
+		t.Error("For","failing feedback","expected","error","got",nil)
+	}
+}
+
+func TestAnalogFeedback(t *testing.T){
+	file,err := ioutil.TempFile("","in_voltage0_raw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("512\n")
+	file.Close()
+	feedback := &AnalogFeedback{Path:file.Name(),Scale:0.1,Offset:-1.2}
+	if frequency,err := feedback.Frequency(); err != nil || math.Abs(frequency - 50.0) > 1e-9 {
+		t.Error("For","raw 512","expected",50.0,"got",frequency,err)
+	}
+	ioutil.WriteFile(file.Name(),[]byte("x"),0644)
+	if _,err := feedback.Frequency(); err == nil {
+		t.Error("For","invalid raw value","expected","error","got",nil)
+	}
+}
+
+// Simulates a converter of the default register map: the output follows the set-point while
+// the run command is set
+func testConverter(unit byte)(converter *modbus.SimSlave){
+	r := DefaultModbusRegisters
+	converter = modbus.NewSimSlave(unit,r.Control,r.Setpoint,r.Frequency,r.Current,r.Fault)
+	converter.OnWrite(func(address, value uint16){
+		output := uint16(0)
+		if converter.Register(r.Control) == r.Run {
+			output = converter.Register(r.Setpoint)
+		}
+		converter.SetRegister(r.Frequency,output)
+		converter.SetRegister(r.Current,output / 200)
+	})
+	return
+}
+
+func TestModbusPump(t *testing.T){
+	converter := testConverter(3)
+	pump := NewModbusPump(50.0,15.0,modbus.NewClient(converter,3),DefaultModbusRegisters)
+	r := NewActuatorRegistry()
+	r.Register(ACTUATOR_RADIATOR_PUMP,pump)
+
+	r.RollOut(NewAction(0.0,42.5,true,false,false,false))
+	if setting,err := pump.ReadBack(); err != nil || !setting.On || math.Abs(setting.Frequency - 42.5) > 1e-9 || pump.State() != setting {
+		t.Error("For","rollout","expected",42.5,"got",setting,pump.State(),err)
+	}
+	if status,err := pump.Status(); err != nil || !status.Running || math.Abs(status.Current - 2.1) > 1e-9 || status.Fault != 0 {
+		t.Error("For","status","expected","running at 2.1 A","got",status,err)
+	}
+	for setting,expected := range map[Setting]float64{{On:true,Frequency:80.0}:50.0,{On:true}:15.0,{On:false,Frequency:40.0}:OFF_FREQ} {
+		if err := pump.Set(setting); err != nil {
+			t.Fatal(err)
+		}
+		if frequency,err := pump.Frequency(); err != nil || math.Abs(frequency - expected) > 1e-9 || pump.State().On != setting.On {
+			t.Error("For",setting,"expected",expected,"got",frequency,pump.State(),err)
+		}
+	}
+
+	// faults and missing converters are reported
+	converter.SetRegister(DefaultModbusRegisters.Fault,12)
+	err := pump.Set(Setting{On:true,Frequency:30.0})
+	if fault,ok := err.(*ConverterFault); !ok || fault.Code != 12 || pump.Faults() != 1 {
+		t.Error("For","fault code 12","expected","converter fault","got",err,pump.Faults())
+	}
+	missing := NewModbusPump(50.0,15.0,modbus.NewClient(converter,4),DefaultModbusRegisters)
+	if err = missing.Set(Setting{On:true}); err == nil || missing.State().On {
+		t.Error("For","unit 4","expected","error","got",err,missing.State())
+	}
+	if _,err = missing.ReadBack(); err == nil {
+		t.Error("For","read-back of unit 4","expected","error","got",nil)
+	}
+}
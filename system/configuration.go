@@ -0,0 +1,676 @@
+package system
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	CONFIGURATION_SCHEMA_VERSION = 1
+	CONFIGURATION_EXTENSION = ".pb"	// files with this extension hold an encoded Configuration
+)
+
+var (
+	// migrations of older schema versions, each lifts a configuration by one version
+	configurationMigrations = map[uint32]func(*Configuration)(error){}
+)
+
+// Switching thresholds of the agents in m°C
+type Hysteresis struct {
+	BoilerOn int		// burner starts below target - BoilerOn
+	BoilerOff int		// burner stops at target + BoilerOff
+	BoilerMaxTop int	// hot water is not loaded above this boiler top temperature
+	WaterLowerBound int	// reflex agent: boiler mid temperature that requests the burner
+	WaterTargetBound int	// reflex agent: boiler mid temperature that satisfies the request
+	RadiatorDeviation int	// reflex agent: tolerated deviation of the radiator fore run
+}
+
+// Safety limits every configuration is checked against, temperatures in m°C
+type SafetyLimits struct {
+	MaxTarget int		// no boiler target exceeds this temperature
+	MinFrostTarget int	// lowest boiler target in vacation mode
+}
+
+type BoilerTargets struct {
+	Default int		// answer until a target was determined
+}
+
+// Row of a configuration table: outside temperature in °C and one target per hour
+type CurveRow struct {
+	Outside int
+	Targets []int
+}
+
+// A heating curve of the configuration, either Table or Parametric is set
+type CurveConfig struct {
+	Table []CurveRow
+	Parametric *ParametricCurve
+}
+
+type ProfileConfig struct {
+	Name string
+	Curve CurveConfig
+	Shift int
+}
+
+// A schedule of the configuration, see Schedule
+type ScheduleConfig struct {
+	Profiles []ProfileConfig
+	Week Week
+	Holidays []Holiday
+	Vacation *Vacation
+}
+
+/**
+ * Structured configuration of the heating as defined by configuration.proto: the heating curve
+ * or a schedule of curves, the hysteresis of the agents, safety limits and boiler targets.
+ * A configuration is not modified after it was loaded.
+ */
+type Configuration struct {
+	Version uint32
+	Curve *CurveConfig		// exclusive with Schedule
+	Schedule *ScheduleConfig
+	Hysteresis Hysteresis
+	Limits SafetyLimits
+	Boiler BoilerTargets
+}
+
+// Errors of a configuration, each names the offending field
+type ConfigurationError []string
+
+func (e ConfigurationError) Error()(string){
+	return "invalid configuration:\n\t"+strings.Join(e,"\n\t")
+}
+
+// Returns a configuration without curve that holds the defaults of all settings, which are
+// the values the agents used before they became configurable
+func DefaultConfiguration()(*Configuration){
+	return &Configuration{
+		Version:CONFIGURATION_SCHEMA_VERSION,
+		Hysteresis:Hysteresis{
+			BoilerOn:2000,
+			BoilerOff:3500,
+			BoilerMaxTop:45000,
+			WaterLowerBound:49000,
+			WaterTargetBound:52000,
+			RadiatorDeviation:11000,
+		},
+		Limits:SafetyLimits{MaxTarget:75000,MinFrostTarget:10000},
+		Boiler:BoilerTargets{Default:30000},
+	}
+}
+
+/**
+ * Checks the configuration against its schema and the safety limits
+ * @return ConfigurationError with all violations or nil
+ */
+func (c *Configuration) Validate()(error){
+	var errs ConfigurationError
+	add := func(field, format string, a ...interface{})(){
+		errs = append(errs,field+": "+fmt.Sprintf(format,a...))
+	}
+
+	switch {
+	case c.Version == 0:
+		add("schema_version","is not set")
+	case c.Version != CONFIGURATION_SCHEMA_VERSION:
+		add("schema_version","%d is not the supported version %d",c.Version,CONFIGURATION_SCHEMA_VERSION)
+	}
+
+	limits := c.Limits
+	if limits.MaxTarget <= 0 {
+		add("limits.max_target","%d is not positive",limits.MaxTarget)
+	}
+	if limits.MinFrostTarget < 0 || limits.MinFrostTarget > limits.MaxTarget {
+		add("limits.min_frost_target","%d is not within 0 and limits.max_target %d",limits.MinFrostTarget,limits.MaxTarget)
+	}
+
+	h := c.Hysteresis
+	for _,setting := range []struct{
+		field string
+		value int
+	}{
+		{"boiler_on",h.BoilerOn},
+		{"boiler_off",h.BoilerOff},
+		{"boiler_max_top",h.BoilerMaxTop},
+		{"water_lower_bound",h.WaterLowerBound},
+		{"water_target_bound",h.WaterTargetBound},
+		{"radiator_deviation",h.RadiatorDeviation},
+	} {
+		if setting.value <= 0 {
+			add("hysteresis."+setting.field,"%d is not positive",setting.value)
+		}
+	}
+	if h.BoilerMaxTop > limits.MaxTarget {
+		add("hysteresis.boiler_max_top","%d exceeds limits.max_target %d",h.BoilerMaxTop,limits.MaxTarget)
+	}
+	if h.WaterLowerBound >= h.WaterTargetBound {
+		add("hysteresis.water_lower_bound","%d is not below water_target_bound %d",h.WaterLowerBound,h.WaterTargetBound)
+	}
+
+	if c.Boiler.Default < 0 || c.Boiler.Default > limits.MaxTarget {
+		add("boiler.default","%d is not within 0 and limits.max_target %d",c.Boiler.Default,limits.MaxTarget)
+	}
+
+	switch {
+	case c.Curve == nil && c.Schedule == nil:
+		add("curve","neither curve nor schedule is set")
+	case c.Curve != nil && c.Schedule != nil:
+		add("schedule","curve and schedule are exclusive")
+	}
+	if c.Curve != nil {
+		c.Curve.validate("curve",limits,add)
+	}
+	if s := c.Schedule; s != nil {
+		names := make(map[string]bool)
+		valid := len(errs)
+		for i,p := range s.Profiles {
+			field := fmt.Sprintf("schedule.profiles[%d]",i)
+			switch {
+			case p.Name == "":
+				add(field+".name","is not set")
+			case p.Name == VACATION_PROFILE:
+				add(field+".name","%s is reserved for the vacation mode",p.Name)
+			case names[p.Name]:
+				add(field+".name","%s is defined twice",p.Name)
+			}
+			names[p.Name] = true
+			p.Curve.validate(field+".curve",limits,add)
+		}
+		// the curves are built only if they are valid
+		if len(errs) == valid {
+			if _,err := s.schedule(); err != nil {
+				add("schedule","%v",err)
+			}
+		}
+		if v := s.Vacation; v != nil && (v.FrostTarget < limits.MinFrostTarget || v.FrostTarget > limits.MaxTarget) {
+			add("schedule.vacation.frost_target","%d is not within limits.min_frost_target %d and limits.max_target %d",v.FrostTarget,limits.MinFrostTarget,limits.MaxTarget)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Checks the curve, add is called for each violation
+func (c *CurveConfig) validate(field string, limits SafetyLimits, add func(field, format string, a ...interface{}))(){
+	switch {
+	case c.Parametric != nil && len(c.Table) > 0:
+		add(field,"table and parametric are exclusive")
+	case c.Parametric != nil:
+		p := c.Parametric
+		if err := p.Validate(); err != nil {
+			add(field+".parametric","%v",err)
+		}
+		if p.Max <= 0 || p.Max > limits.MaxTarget {
+			add(field+".parametric.max","%d is not within 1 and limits.max_target %d",p.Max,limits.MaxTarget)
+		}
+	case len(c.Table) == 0:
+		add(field,"has neither table rows nor a parametric curve")
+	default:
+		rows := make(map[int]bool)
+		for i,row := range c.Table {
+			rowField := fmt.Sprintf("%s.table.rows[%d]",field,i)
+			if rows[row.Outside] {
+				add(rowField+".outside","%d °C is defined twice",row.Outside)
+			}
+			rows[row.Outside] = true
+			if len(row.Targets) != CURVE_HOURS {
+				add(rowField+".targets","%d instead of %d targets",len(row.Targets),CURVE_HOURS)
+			}
+			for hour,target := range row.Targets {
+				if target < 0 || target > limits.MaxTarget {
+					add(fmt.Sprintf("%s.targets[%d]",rowField,hour),"%d is not within 0 and limits.max_target %d",target,limits.MaxTarget)
+				}
+			}
+		}
+	}
+}
+
+// Returns the heating curve of a validated curve and the issues of its table
+func (c *CurveConfig) heatingCurve()(HeatingCurve, CurveReport){
+	if c.Parametric != nil {
+		return *c.Parametric, CurveReport{}
+	}
+	rows := make(map[int][]int)
+	for _,row := range c.Table {
+		rows[row.Outside] = row.Targets
+	}
+	t := &CurveTable{}
+	t.setRows(rows)
+	return t, t.report
+}
+
+// Returns the schedule of the profiles, the references and periods are checked
+func (s *ScheduleConfig) schedule()(schedule *Schedule, err error){
+	schedule = &Schedule{
+		Profiles:make(map[string]*Profile),
+		Week:s.Week,
+		Holidays:append([]Holiday(nil),s.Holidays...),
+	}
+	if s.Vacation != nil {
+		vacation := *s.Vacation
+		schedule.Vacation = &vacation
+	}
+	for _,p := range s.Profiles {
+		profile := &Profile{Shift:p.Shift}
+		if p.Curve.Parametric != nil || len(p.Curve.Table) > 0 {
+			var report CurveReport
+			profile.curve,report = p.Curve.heatingCurve()
+			schedule.addReport(p.Name,report)
+		}
+		schedule.Profiles[p.Name] = profile
+	}
+	if len(schedule.Profiles) == 0 {
+		return nil, fmt.Errorf("no profile defined")
+	}
+	return schedule, schedule.check()
+}
+
+// Returns the heating curve of a validated configuration and the issues of its tables
+func (c *Configuration) HeatingCurve()(HeatingCurve, CurveReport){
+	if c.Schedule != nil {
+		schedule,_ := c.Schedule.schedule()
+		return schedule, schedule.Report()
+	}
+	return c.Curve.heatingCurve()
+}
+
+// Converts a curve read by LoadHeatingCurve
+func newCurveConfig(curve HeatingCurve)(c CurveConfig, err error){
+	switch curve := curve.(type) {
+	case *CurveTable:
+		for i,outside := range curve.temperatures {
+			c.Table = append(c.Table,CurveRow{Outside:outside,Targets:append([]int(nil),curve.targets[i]...)})
+		}
+	case ParametricCurve:
+		c.Parametric = &curve
+	default:
+		err = fmt.Errorf("%T can not be converted",curve)
+	}
+	return
+}
+
+// Returns a configuration of the curve read by LoadHeatingCurve with the default settings,
+// the configuration is not validated
+func newConfiguration(curve HeatingCurve)(c *Configuration, err error){
+	c = DefaultConfiguration()
+	if schedule,ok := curve.(*Schedule); ok {
+		s := &ScheduleConfig{Week:schedule.Week}
+		for _,h := range schedule.Holidays {
+			s.Holidays = append(s.Holidays,Holiday{Name:h.Name,From:h.From,To:h.To,Profile:h.Profile})
+		}
+		if v := schedule.Vacation; v != nil {
+			s.Vacation = &Vacation{From:v.From,Until:v.Until,FrostTarget:v.FrostTarget,Preheat:v.Preheat}
+		}
+		names := make([]string,0,len(schedule.Profiles))
+		for name := range schedule.Profiles {
+			names = append(names,name)
+		}
+		sort.Strings(names)
+		for _,name := range names {
+			p := schedule.Profiles[name]
+			profile := ProfileConfig{Name:name,Shift:p.Shift}
+			if profile.Curve,err = newCurveConfig(p.curve); err != nil {
+				return nil, fmt.Errorf("profile %s: %v",name,err)
+			}
+			s.Profiles = append(s.Profiles,profile)
+		}
+		c.Schedule = s
+	} else {
+		var config CurveConfig
+		if config,err = newCurveConfig(curve); err != nil {
+			return nil, err
+		}
+		c.Curve = &config
+	}
+	return
+}
+
+/**
+ * Migrates a configuration table (csv), a parametric curve or a schedule (toml) into a
+ * configuration with the default settings. Other than the oracle, which drops rows that can
+ * not be read, the migration fails on every invalid row.
+ * @return error if the file can not be read or the configuration is invalid
+ */
+func MigrateConfiguration(path string)(c *Configuration, err error){
+	curve,report,err := LoadHeatingCurve(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(report.Invalid) > 0 {
+		errs := ConfigurationError{}
+		for _,issue := range report.Invalid {
+			errs = append(errs,path+": "+issue)
+		}
+		return nil, errs
+	}
+
+	if c,err = newConfiguration(curve); err != nil {
+		return nil, err
+	}
+	if err = c.Validate(); err != nil {
+		return nil, err
+	}
+	return
+}
+
+/**
+ * Reads an encoded configuration, migrates older schema versions and validates it
+ * @return error if the file can not be read or decoded, its schema version is not supported or
+ * it is invalid (ConfigurationError)
+ */
+func LoadConfiguration(path string)(c *Configuration, err error){
+	data,err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+	if c,err = UnmarshalConfiguration(data); err != nil {
+		return nil, fmt.Errorf("%s: %v",path,err)
+	}
+	switch {
+	case c.Version == 0:
+		return nil, fmt.Errorf("%s: schema_version is not set",path)
+	case c.Version > CONFIGURATION_SCHEMA_VERSION:
+		return nil, fmt.Errorf("%s: schema_version %d is newer than the supported version %d",path,c.Version,CONFIGURATION_SCHEMA_VERSION)
+	}
+	for c.Version < CONFIGURATION_SCHEMA_VERSION {
+		migrate,ok := configurationMigrations[c.Version]
+		if !ok {
+			return nil, fmt.Errorf("%s: schema_version %d can not be migrated",path,c.Version)
+		}
+		if err = migrate(c); err != nil {
+			return nil, fmt.Errorf("%s: migration of schema_version %d: %v",path,c.Version,err)
+		}
+		c.Version++
+	}
+	if err = c.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v",path,err)
+	}
+	return
+}
+
+// Validates the configuration and replaces the file at path atomically
+func SaveConfiguration(path string, c *Configuration)(err error){
+	if err = c.Validate(); err != nil {
+		return
+	}
+	if err = os.MkdirAll(filepath.Dir(path),0755); err != nil {
+		return
+	}
+	tmp := path + ".tmp"
+	if err = ioutil.WriteFile(tmp,c.Marshal(),0644); err != nil {
+		return
+	}
+	return os.Rename(tmp,path)
+}
+
+// Returns the protocol buffer encoding of the configuration
+func (c *Configuration) Marshal()([]byte){
+	var e protoEncoder
+	e.putUint(1,uint64(c.Version))
+	if c.Curve != nil {
+		e.putMessage(2,c.Curve.encode)
+	}
+	if s := c.Schedule; s != nil {
+		e.putMessage(3,func(e *protoEncoder)(){
+			for i := range s.Profiles {
+				p := &s.Profiles[i]
+				e.putMessage(1,func(e *protoEncoder)(){
+					e.putString(1,p.Name)
+					e.putMessage(2,p.Curve.encode)
+					e.putSint(3,int64(p.Shift))
+				})
+			}
+			e.putMessage(2,func(e *protoEncoder)(){
+				w := s.Week
+				for i,day := range []string{w.Default,w.Monday,w.Tuesday,w.Wednesday,w.Thursday,w.Friday,w.Saturday,w.Sunday} {
+					e.putString(i+1,day)
+				}
+			})
+			for _,h := range s.Holidays {
+				e.putMessage(3,func(e *protoEncoder)(){
+					e.putString(1,h.Name)
+					e.putString(2,h.From)
+					e.putString(3,h.To)
+					e.putString(4,h.Profile)
+				})
+			}
+			if v := s.Vacation; v != nil {
+				e.putMessage(4,func(e *protoEncoder)(){
+					e.putString(1,v.From)
+					e.putString(2,v.Until)
+					e.putSint(3,int64(v.FrostTarget))
+					e.putUint(4,uint64(v.Preheat / time.Second))
+				})
+			}
+		})
+	}
+	e.putMessage(4,func(e *protoEncoder)(){
+		h := c.Hysteresis
+		for i,v := range []int{h.BoilerOn,h.BoilerOff,h.BoilerMaxTop,h.WaterLowerBound,h.WaterTargetBound,h.RadiatorDeviation} {
+			e.putSint(i+1,int64(v))
+		}
+	})
+	e.putMessage(5,func(e *protoEncoder)(){
+		e.putSint(1,int64(c.Limits.MaxTarget))
+		e.putSint(2,int64(c.Limits.MinFrostTarget))
+	})
+	e.putMessage(6,func(e *protoEncoder)(){
+		e.putSint(1,int64(c.Boiler.Default))
+	})
+	return e.buffer
+}
+
+func (c *CurveConfig) encode(e *protoEncoder)(){
+	if p := c.Parametric; p != nil {
+		e.putMessage(2,func(e *protoEncoder)(){
+			e.putSint(1,int64(p.Room))
+			e.putSint(2,int64(p.DesignOutside))
+			e.putSint(3,int64(p.DesignTarget))
+			e.putDouble(4,p.Slope)
+			e.putSint(5,int64(p.Shift))
+			e.putSint(6,int64(p.Min))
+			e.putSint(7,int64(p.Max))
+		})
+		return
+	}
+	e.putMessage(1,func(e *protoEncoder)(){
+		for _,row := range c.Table {
+			e.putMessage(1,func(e *protoEncoder)(){
+				e.putSint(1,int64(row.Outside))
+				e.putPackedSint(2,row.Targets)
+			})
+		}
+	})
+}
+
+/**
+ * Decodes a configuration, settings that are not set keep the values of DefaultConfiguration.
+ * The configuration is neither migrated nor validated, see LoadConfiguration.
+ */
+func UnmarshalConfiguration(data []byte)(c *Configuration, err error){
+	c = DefaultConfiguration()
+	c.Version = 0
+	d := &protoDecoder{data:data}
+	for d.more() {
+		switch field,wire := d.next(); field {
+		case 1:
+			c.Version = uint32(d.uint(wire))
+		case 2:
+			c.Curve = &CurveConfig{}
+			d.embedded(wire,c.Curve.decode)
+		case 3:
+			c.Schedule = &ScheduleConfig{}
+			d.embedded(wire,c.Schedule.decode)
+		case 4:
+			d.embedded(wire,func(m *protoDecoder)(){
+				h := &c.Hysteresis
+				settings := []*int{&h.BoilerOn,&h.BoilerOff,&h.BoilerMaxTop,&h.WaterLowerBound,&h.WaterTargetBound,&h.RadiatorDeviation}
+				for m.more() {
+					if field,wire := m.next(); field > 0 && field <= len(settings) {
+						*settings[field-1] = m.sint32(wire)
+					} else {
+						m.skip(wire)
+					}
+				}
+			})
+		case 5:
+			d.embedded(wire,func(m *protoDecoder)(){
+				for m.more() {
+					switch field,wire := m.next(); field {
+					case 1:
+						c.Limits.MaxTarget = m.sint32(wire)
+					case 2:
+						c.Limits.MinFrostTarget = m.sint32(wire)
+					default:
+						m.skip(wire)
+					}
+				}
+			})
+		case 6:
+			d.embedded(wire,func(m *protoDecoder)(){
+				for m.more() {
+					if field,wire := m.next(); field == 1 {
+						c.Boiler.Default = m.sint32(wire)
+					} else {
+						m.skip(wire)
+					}
+				}
+			})
+		default:
+			d.skip(wire)
+		}
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return
+}
+
+func (c *CurveConfig) decode(d *protoDecoder)(){
+	for d.more() {
+		switch field,wire := d.next(); field {
+		case 1:
+			c.Parametric = nil
+			d.embedded(wire,func(m *protoDecoder)(){
+				for m.more() {
+					if field,wire := m.next(); field == 1 {
+						var row CurveRow
+						m.embedded(wire,func(r *protoDecoder)(){
+							for r.more() {
+								switch field,wire := r.next(); field {
+								case 1:
+									row.Outside = r.sint32(wire)
+								case 2:
+									row.Targets = r.appendSint32(row.Targets,wire)
+								default:
+									r.skip(wire)
+								}
+							}
+						})
+						c.Table = append(c.Table,row)
+					} else {
+						m.skip(wire)
+					}
+				}
+			})
+		case 2:
+			c.Table = nil
+			p := &ParametricCurve{}
+			d.embedded(wire,func(m *protoDecoder)(){
+				ints := []*int{&p.Room,&p.DesignOutside,&p.DesignTarget,nil,&p.Shift,&p.Min,&p.Max}
+				for m.more() {
+					switch field,wire := m.next(); {
+					case field == 4:
+						p.Slope = m.double(wire)
+					case field > 0 && field <= len(ints):
+						*ints[field-1] = m.sint32(wire)
+					default:
+						m.skip(wire)
+					}
+				}
+			})
+			c.Parametric = p
+		default:
+			d.skip(wire)
+		}
+	}
+}
+
+func (s *ScheduleConfig) decode(d *protoDecoder)(){
+	for d.more() {
+		switch field,wire := d.next(); field {
+		case 1:
+			var p ProfileConfig
+			d.embedded(wire,func(m *protoDecoder)(){
+				for m.more() {
+					switch field,wire := m.next(); field {
+					case 1:
+						p.Name = m.string(wire)
+					case 2:
+						m.embedded(wire,p.Curve.decode)
+					case 3:
+						p.Shift = m.sint32(wire)
+					default:
+						m.skip(wire)
+					}
+				}
+			})
+			s.Profiles = append(s.Profiles,p)
+		case 2:
+			d.embedded(wire,func(m *protoDecoder)(){
+				w := &s.Week
+				days := []*string{&w.Default,&w.Monday,&w.Tuesday,&w.Wednesday,&w.Thursday,&w.Friday,&w.Saturday,&w.Sunday}
+				for m.more() {
+					if field,wire := m.next(); field > 0 && field <= len(days) {
+						*days[field-1] = m.string(wire)
+					} else {
+						m.skip(wire)
+					}
+				}
+			})
+		case 3:
+			var h Holiday
+			d.embedded(wire,func(m *protoDecoder)(){
+				values := []*string{&h.Name,&h.From,&h.To,&h.Profile}
+				for m.more() {
+					if field,wire := m.next(); field > 0 && field <= len(values) {
+						*values[field-1] = m.string(wire)
+					} else {
+						m.skip(wire)
+					}
+				}
+			})
+			s.Holidays = append(s.Holidays,h)
+		case 4:
+			v := &Vacation{}
+			d.embedded(wire,func(m *protoDecoder)(){
+				for m.more() {
+					switch field,wire := m.next(); field {
+					case 1:
+						v.From = m.string(wire)
+					case 2:
+						v.Until = m.string(wire)
+					case 3:
+						v.FrostTarget = m.sint32(wire)
+					case 4:
+						v.Preheat = time.Duration(m.uint(wire)) * time.Second
+					default:
+						m.skip(wire)
+					}
+				}
+			})
+			s.Vacation = v
+		default:
+			d.skip(wire)
+		}
+	}
+}
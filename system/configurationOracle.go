@@ -0,0 +1,422 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"github.com/hansen1101/go_heating/system/hardware"
+)
+
+const (
+	CONFIGURATION_RELOAD_INTERVAL = time.Minute * 5	// polling if the files can not be watched
+	CONFIGURATION_DEBOUNCE = time.Second * 2		// quiet time after the last change before a reload
+)
+
+// A TargetSource provides the boiler target temperature for a percept
+type TargetSource interface {
+	Target(ctx context.Context, percept *Percept)(int, error)
+}
+
+// A SetpointSource provides the boiler target and the operating mode for a percept
+type SetpointSource interface {
+	Setpoint(ctx context.Context, percept *Percept)(Setpoint, error)
+}
+
+// A HysteresisSource provides the switching thresholds of the agents
+type HysteresisSource interface {
+	Hysteresis()(Hysteresis)
+}
+
+// The ConfigurationOracle answers requests for the boiler target temperature according to
+// the heating curve provided by the user: a configuration table (outside temperature x hour
+// of the day), a parametric curve or a schedule of curves with holidays and vacation mode
+// (see LoadHeatingCurve) or a structured configuration (.pb, see LoadConfiguration), which
+// also provides the hysteresis and the safety limits. While the oracle is running the
+// configuration is reloaded when its files change (see Reload). Manual overrides apply on
+// top of the configuration until they expire, they are saved to the override file (see
+// SetOverridePath), thus they survive restarts and can be edited by other processes.
+// implements TargetSource, SetpointSource and HysteresisSource interface
+type ConfigurationOracle struct {
+	path string
+	reloadInterval time.Duration
+	debounce time.Duration
+	reloadLock sync.Mutex		// serializes reloads
+	lastReload ConfigurationReload
+
+	config *Configuration		// settings, defaults unless a structured configuration is read
+	curve HeatingCurve
+	report CurveReport		// issues of the configuration table
+	setpoint Setpoint		// last setpoint, answer to invalid percepts
+	determined bool			// a setpoint was determined from a percept
+	overridePath string		// file of the overrides, empty if they are not saved
+	overrides Overrides
+	configurationLock sync.Mutex
+
+	ctx context.Context		// done when the oracle is stopped
+	cancel context.CancelFunc
+	routines sync.WaitGroup
+	stateLock sync.Mutex
+}
+
+// Generates an oracle for the heating curve at path
+// @param defaultTarget answer to invalid percepts until a target was determined
+func NewConfigurationOracle(path string, defaultTarget int)(o *ConfigurationOracle){
+	o = &ConfigurationOracle{
+		path:path,
+		config:DefaultConfiguration(),
+		setpoint:Setpoint{Boiler:defaultTarget,HotWater:true},
+		reloadInterval:CONFIGURATION_RELOAD_INTERVAL,
+		debounce:CONFIGURATION_DEBOUNCE,
+	}
+	return
+}
+
+// Reads the configuration at the path of the oracle. Curves and schedules get the default
+// settings, a structured configuration is validated.
+func (o *ConfigurationOracle) read()(config *Configuration, curve HeatingCurve, report CurveReport, err error){
+	if filepath.Ext(o.path) == CONFIGURATION_EXTENSION {
+		if config,err = LoadConfiguration(o.path); err == nil {
+			curve,report = config.HeatingCurve()
+		}
+		return
+	}
+	if curve,report,err = LoadHeatingCurve(o.path); err != nil {
+		return
+	}
+	config,err = newConfiguration(curve)
+	return
+}
+
+// Replaces the configuration at once and prints the issues of a configuration table if they
+// changed, the caller holds configurationLock
+func (o *ConfigurationOracle) swap(config *Configuration, curve HeatingCurve, report CurveReport)(){
+	if !report.IsEmpty() && report.String() != o.report.String() {
+		fmt.Printf("[WARNING]	configuration %s:\n%s",o.path,report)
+	}
+	if !o.determined && filepath.Ext(o.path) == CONFIGURATION_EXTENSION {
+		o.setpoint.Boiler = config.Boiler.Default
+	}
+	o.config,o.curve,o.report = config,curve,report
+}
+
+/**
+ * Reads the configuration again and swaps it if it is valid: other than on start, rows of a
+ * configuration table that can not be read (e.g. of a half-written file) and violations of
+ * the safety limits reject the file. A rejected file keeps the last valid configuration.
+ * The outcome and the changed settings are printed and logged to the database (ReloadLog).
+ * @return error if the configuration was rejected
+ */
+func (o *ConfigurationOracle) Reload()(err error){
+	o.reloadLock.Lock()
+	defer o.reloadLock.Unlock()
+	reload := ConfigurationReload{Time:time.Now(),Path:o.path}
+	config,curve,report,err := o.read()
+	if err == nil && len(report.Invalid) > 0 {
+		err = ConfigurationError(report.Invalid)
+	}
+	if err == nil {
+		err = config.Validate()
+	}
+	if err != nil {
+		reload.Error = err.Error()
+		fmt.Printf("[WARNING]	%s\n",reload)
+	} else {
+		o.configurationLock.Lock()
+		reload.Accepted = true
+		reload.Changes = DiffConfigurations(o.config,config)
+		o.swap(config,curve,report)
+		o.configurationLock.Unlock()
+		fmt.Printf("[CONFIG]	%s\n",reload)
+	}
+	(&ReloadLog{}).Insert(&reload)
+	o.lastReload = reload
+	return
+}
+
+// Returns the outcome of the last reload
+func (o *ConfigurationOracle) LastReload()(ConfigurationReload){
+	o.reloadLock.Lock()
+	defer o.reloadLock.Unlock()
+	return o.lastReload
+}
+
+// Returns the names of the files the configuration is read from
+func (o *ConfigurationOracle) files()(files map[string]bool){
+	files = map[string]bool{filepath.Base(o.path):true}
+	o.configurationLock.Lock()
+	defer o.configurationLock.Unlock()
+	if schedule,ok := o.curve.(*Schedule); ok {
+		for _,path := range schedule.Files() {
+			if filepath.Dir(path) == filepath.Dir(o.path) {
+				files[filepath.Base(path)] = true
+			}
+		}
+	}
+	return
+}
+
+// Sets the file the overrides are read from and saved to, must be called before Start
+func (o *ConfigurationOracle) SetOverridePath(path string)(){
+	o.overridePath = path
+}
+
+// Reads the override file again, overrides that violate the safety limits are dropped
+func (o *ConfigurationOracle) loadOverrides()(){
+	if o.overridePath == "" {
+		return
+	}
+	overrides,err := LoadOverrides(o.overridePath)
+	if err != nil {
+		fmt.Printf("[WARNING]	overrides can not be read, the previous ones are kept: %v\n",err)
+		return
+	}
+	o.configurationLock.Lock()
+	defer o.configurationLock.Unlock()
+	var valid Overrides
+	for _,override := range overrides {
+		if err := override.validate(o.config.Limits); err != nil {
+			fmt.Printf("[WARNING]	%s: %v\n",o.overridePath,err)
+			continue
+		}
+		valid = append(valid,override)
+	}
+	if fmt.Sprint(valid.Strings()) != fmt.Sprint(o.overrides.Strings()) {
+		fmt.Printf("[CONFIG]	overrides %s: %v\n",o.overridePath,valid.Strings())
+	}
+	o.overrides = valid
+}
+
+// Saves the overrides, the caller holds configurationLock
+func (o *ConfigurationOracle) saveOverrides()(err error){
+	if o.overridePath == "" {
+		return
+	}
+	if err = SaveOverrides(o.overridePath,o.overrides); err != nil {
+		fmt.Printf("[WARNING]	overrides can not be saved to %s: %v\n",o.overridePath,err)
+	}
+	return
+}
+
+/**
+ * Adds a manual override and saves the overrides
+ * @param override see Override, Id and Created are set by the oracle
+ * @return the override as added, error if it is invalid or can not be saved (it applies then
+ * until the oracle is stopped)
+ */
+func (o *ConfigurationOracle) AddOverride(override Override)(added Override, err error){
+	o.configurationLock.Lock()
+	defer o.configurationLock.Unlock()
+	override.Created = time.Now()
+	if o.overrides,added,err = o.overrides.Add(override,o.config.Limits); err != nil {
+		return
+	}
+	fmt.Printf("[CONFIG]	override %s added\n",added)
+	err = o.saveOverrides()
+	return
+}
+
+// Removes the override with the given Id and saves the overrides
+// @return ErrOverrideUnknown if there is no such override, error if they can not be saved
+func (o *ConfigurationOracle) RemoveOverride(id int)(err error){
+	o.configurationLock.Lock()
+	defer o.configurationLock.Unlock()
+	if o.overrides,err = o.overrides.Remove(id); err != nil {
+		return
+	}
+	fmt.Printf("[CONFIG]	override #%d removed\n",id)
+	return o.saveOverrides()
+}
+
+// Returns the overrides that apply at the given time
+func (o *ConfigurationOracle) Overrides(at time.Time)(Overrides){
+	o.configurationLock.Lock()
+	defer o.configurationLock.Unlock()
+	return o.overrides.Active(at)
+}
+
+// Returns the current configuration, which must not be modified
+func (o *ConfigurationOracle) Configuration()(*Configuration){
+	o.configurationLock.Lock()
+	defer o.configurationLock.Unlock()
+	return o.config
+}
+
+func (o *ConfigurationOracle) Hysteresis()(Hysteresis){
+	return o.Configuration().Hysteresis
+}
+
+// Returns the issues of the configuration table
+func (o *ConfigurationOracle) Report()(CurveReport){
+	o.configurationLock.Lock()
+	defer o.configurationLock.Unlock()
+	return o.report
+}
+
+// Reads the configuration and the overrides and starts the reload routine, which runs until
+// ctx is done or Stop is called. The routine watches the directory of the configuration and
+// reloads it once its files (including the curves of a schedule in the same directory) did
+// not change for the debounce time, the override file is read again at once. If a directory
+// can not be watched the files are polled instead.
+// @return error if the oracle was already started or the configuration can not be read
+func (o *ConfigurationOracle) Start(ctx context.Context)(error){
+	o.stateLock.Lock()
+	defer o.stateLock.Unlock()
+	if o.ctx != nil && o.ctx.Err() == nil {
+		return ErrOracleRunning
+	}
+	config,curve,report,err := o.read()
+	if err != nil {
+		fmt.Printf("[WARNING]	configuration %s can not be read: %v\n",o.path,err)
+		return fmt.Errorf("configuration %s can not be read",o.path)
+	}
+	o.configurationLock.Lock()
+	o.swap(config,curve,report)
+	o.configurationLock.Unlock()
+	o.loadOverrides()
+
+	o.ctx,o.cancel = context.WithCancel(ctx)
+	changes,err := watchDirectory(o.ctx,filepath.Dir(o.path))
+	var poll *time.Ticker
+	if err != nil {
+		fmt.Printf("[WARNING]	configuration %s is polled every %s: %v\n",o.path,o.reloadInterval,err)
+		poll = time.NewTicker(o.reloadInterval)
+	}
+	// the override file is watched with the configuration if it is in the same directory
+	overrideName := filepath.Base(o.overridePath)
+	separate := o.overridePath != "" && filepath.Dir(o.overridePath) != filepath.Dir(o.path)
+	var overrideChanges <-chan string
+	if separate {
+		if err = os.MkdirAll(filepath.Dir(o.overridePath),0755); err == nil {
+			overrideChanges,err = watchDirectory(o.ctx,filepath.Dir(o.overridePath))
+		}
+		if err != nil && poll == nil {
+			fmt.Printf("[WARNING]	overrides %s are polled every %s: %v\n",o.overridePath,o.reloadInterval,err)
+			poll = time.NewTicker(o.reloadInterval)
+		}
+	}
+	o.routines.Add(1)
+	go func(ctx context.Context)(){
+		defer o.routines.Done()
+		var polled <-chan time.Time
+		if poll != nil {
+			defer poll.Stop()
+			polled = poll.C
+		}
+		var debounce <-chan time.Time
+		for {
+			select {
+			case name,ok := <-changes:
+				if !ok {
+					changes = nil
+					continue
+				}
+				if name == "" || o.files()[name] {
+					debounce = time.After(o.debounce)
+				}
+				if !separate && (name == "" || name == overrideName) {
+					o.loadOverrides()
+				}
+			case name,ok := <-overrideChanges:
+				if !ok {
+					overrideChanges = nil
+				} else if name == "" || name == overrideName {
+					o.loadOverrides()
+				}
+			case <-debounce:
+				debounce = nil
+				o.Reload()
+			case <-polled:
+				o.Reload()
+				o.loadOverrides()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}(o.ctx)
+	return nil
+}
+
+// Stops the oracle and waits until its reload routine terminated
+func (o *ConfigurationOracle) Stop()(){
+	o.stateLock.Lock()
+	ctx,cancel := o.ctx,o.cancel
+	o.stateLock.Unlock()
+	if ctx == nil {
+		return
+	}
+	cancel()
+	o.routines.Wait()
+}
+
+// Returns the boiler target of the setpoint for the percept, see Setpoint
+// @return ErrOracleStopped if the oracle is not running
+func (o *ConfigurationOracle) Target(ctx context.Context, percept *Percept)(int, error){
+	setpoint,err := o.Setpoint(ctx,percept)
+	return setpoint.Boiler, err
+}
+
+// Returns the setpoint for the outside temperature and time of the percept. If the
+// outside temperature is missing the target of COLDEST_OUTSIDE is used, an invalid percept
+// gets the last setpoint. Curves without schedule always heat hot water. The overrides that
+// apply at the time of the percept modify the setpoint, a boost whose target is reached by
+// the percept is removed. Targets are clamped to the safety limit.
+// @return ErrOracleStopped if the oracle is not running
+func (o *ConfigurationOracle) Setpoint(ctx context.Context, percept *Percept)(Setpoint, error){
+	o.stateLock.Lock()
+	running := o.ctx != nil && o.ctx.Err() == nil
+	o.stateLock.Unlock()
+	if !running {
+		return Setpoint{}, ErrOracleStopped
+	}
+	if err := ctx.Err(); err != nil {
+		return Setpoint{}, err
+	}
+
+	o.configurationLock.Lock()
+	defer o.configurationLock.Unlock()
+	if percept != nil && percept.IsValid() {
+		// the curve clamps to its coldest target if the outside temperature is missing
+		outside := COLDEST_OUTSIDE
+		if temperature := percept.Temperature(hardware.ROLE_OUTSIDE); Usable(temperature) {
+			outside = temperature.GetValue()
+		}
+		if schedule,ok := o.curve.(*Schedule); ok {
+			o.setpoint = schedule.Setpoint(outside,percept.CurrentTime)
+		} else {
+			o.setpoint = Setpoint{Boiler:o.curve.Target(outside,percept.CurrentTime),HotWater:true}
+		}
+		for _,override := range o.overrides.Active(percept.CurrentTime) {
+			if override.completed(percept) {
+				o.overrides,_ = o.overrides.Remove(override.Id)
+				fmt.Printf("[CONFIG]	override %s completed\n",override)
+				o.saveOverrides()
+			}
+		}
+		o.setpoint = o.overrides.Apply(o.setpoint,percept.CurrentTime)
+		if limit := o.config.Limits.MaxTarget; o.setpoint.Boiler > limit {
+			o.setpoint.Boiler = limit
+		}
+		o.determined = true
+	}
+	return o.setpoint, nil
+}
+
+// Serves the package channel Configuration_request_chan by the oracle until ctx is done,
+// thus clients of the channel API (see MakeConfigRequest) keep working.
+func (o *ConfigurationOracle) ServeChannels(ctx context.Context)(){
+	Configuration_request_chan = make(chan *configRequest)
+	go func(requests chan *configRequest)(){
+		for {
+			select {
+			case request := <-requests:
+				target,_ := o.Target(ctx,request.percept)
+				request.Endpoint <- target
+			case <-ctx.Done():
+				return
+			}
+		}
+	}(Configuration_request_chan)
+}
@@ -0,0 +1,180 @@
+package system
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/hansen1101/go_heating/system/logger"
+)
+
+const (
+	RELOAD_TABLE = "configuration_reloads"
+)
+
+// Outcome of a reload of the configuration oracle
+type ConfigurationReload struct {
+	Time time.Time
+	Path string
+	Accepted bool		// the configuration was swapped, otherwise the last valid one is kept
+	Error string		// reason of the rejection
+	Changes []string	// see DiffConfigurations
+}
+
+func (r ConfigurationReload) String()(string){
+	if !r.Accepted {
+		return fmt.Sprintf("configuration %s rejected, the last valid configuration is kept: %s",r.Path,r.Error)
+	}
+	if len(r.Changes) == 0 {
+		return fmt.Sprintf("configuration %s reloaded without changes",r.Path)
+	}
+	return fmt.Sprintf("configuration %s reloaded, %d changes:\n\t%s",r.Path,len(r.Changes),strings.Join(r.Changes,"\n\t"))
+}
+
+// Returns the settings of the configuration by their field names
+func (c *Configuration) settings()(settings map[string]string){
+	h := c.Hysteresis
+	settings = map[string]string{
+		"schema_version":fmt.Sprint(c.Version),
+		"hysteresis.boiler_on":fmt.Sprint(h.BoilerOn),
+		"hysteresis.boiler_off":fmt.Sprint(h.BoilerOff),
+		"hysteresis.boiler_max_top":fmt.Sprint(h.BoilerMaxTop),
+		"hysteresis.water_lower_bound":fmt.Sprint(h.WaterLowerBound),
+		"hysteresis.water_target_bound":fmt.Sprint(h.WaterTargetBound),
+		"hysteresis.radiator_deviation":fmt.Sprint(h.RadiatorDeviation),
+		"limits.max_target":fmt.Sprint(c.Limits.MaxTarget),
+		"limits.min_frost_target":fmt.Sprint(c.Limits.MinFrostTarget),
+		"boiler.default":fmt.Sprint(c.Boiler.Default),
+	}
+	if c.Curve != nil {
+		c.Curve.settings("curve",settings)
+	}
+	if s := c.Schedule; s != nil {
+		for _,p := range s.Profiles {
+			prefix := "schedule.profiles."+p.Name
+			settings[prefix+".shift"] = fmt.Sprint(p.Shift)
+			p.Curve.settings(prefix+".curve",settings)
+		}
+		w := s.Week
+		for i,day := range []string{w.Default,w.Sunday,w.Monday,w.Tuesday,w.Wednesday,w.Thursday,w.Friday,w.Saturday} {
+			if day == "" {
+				continue
+			}
+			key := "default"
+			if i > 0 {
+				key = weekdayKeys[i-1]
+			}
+			settings["schedule.week."+key] = day
+		}
+		for _,h := range s.Holidays {
+			settings["schedule.holidays."+h.Name] = fmt.Sprintf("%s .. %s %s",h.From,h.To,h.Profile)
+		}
+		if v := s.Vacation; v != nil {
+			settings["schedule.vacation"] = fmt.Sprintf("%s .. %s frost_target %d preheat %s",v.From,v.Until,v.FrostTarget,v.Preheat)
+		}
+	}
+	return
+}
+
+func (c *CurveConfig) settings(prefix string, settings map[string]string)(){
+	if p := c.Parametric; p != nil {
+		prefix += ".parametric."
+		settings[prefix+"room"] = fmt.Sprint(p.Room)
+		settings[prefix+"design_outside"] = fmt.Sprint(p.DesignOutside)
+		settings[prefix+"design_target"] = fmt.Sprint(p.DesignTarget)
+		settings[prefix+"slope"] = strconv.FormatFloat(p.Slope,'g',-1,64)
+		settings[prefix+"shift"] = fmt.Sprint(p.Shift)
+		settings[prefix+"min"] = fmt.Sprint(p.Min)
+		settings[prefix+"max"] = fmt.Sprint(p.Max)
+	}
+	for _,row := range c.Table {
+		for hour,target := range row.Targets {
+			settings[fmt.Sprintf("%s.table[%d °C][%02d:00]",prefix,row.Outside,hour)] = fmt.Sprint(target)
+		}
+	}
+}
+
+/**
+ * Compares two configurations setting by setting
+ * @param previous configuration, nil if there is none
+ * @return sorted changes: "field: old -> new", "field: + new" or "field: - old"
+ */
+func DiffConfigurations(previous, current *Configuration)(changes []string){
+	before,after := make(map[string]string),current.settings()
+	if previous != nil {
+		before = previous.settings()
+	}
+	for key,old := range before {
+		if value,ok := after[key]; !ok {
+			changes = append(changes,fmt.Sprintf("%s: - %s",key,old))
+		} else if value != old {
+			changes = append(changes,fmt.Sprintf("%s: %s -> %s",key,old,value))
+		}
+	}
+	for key,value := range after {
+		if _,ok := before[key]; !ok {
+			changes = append(changes,fmt.Sprintf("%s: + %s",key,value))
+		}
+	}
+	sort.Strings(changes)
+	return
+}
+
+// Logs the reloads of the configuration oracle.
+// implements logger.Logable interface
+type ReloadLog struct{}
+
+func (l *ReloadLog) GetRelationName()(string){
+	return RELOAD_TABLE
+}
+func (l *ReloadLog) CreateRelation()(){
+	var stmnt_string string
+
+	stmnt_string = fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s(" +
+			"r_id INT NOT NULL AUTO_INCREMENT," +
+			"time INT NOT NULL DEFAULT 0," +
+			"path VARCHAR(255) NOT NULL," +
+			"accepted BIT(1) NOT NULL DEFAULT b'1'," +
+			"error VARCHAR(1024) NOT NULL DEFAULT ''," +
+			"changes TEXT," +
+			"PRIMARY KEY(r_id)" +
+			")ENGINE=InnoDB DEFAULT CHARSET=latin1",
+		l.GetRelationName())
+
+	logger.StatementExecute(stmnt_string)
+}
+
+// Inserts the given reloads
+// @param val *ConfigurationReload values
+func (l *ReloadLog) Insert(val ...interface{})(){
+	escape := func(s string)(string){ return strings.Replace(s,"'","''",-1) }
+	for _,v := range val {
+		r,ok := v.(*ConfigurationReload)
+		if !ok || r == nil {
+			continue
+		}
+		accepted := 0
+		if r.Accepted {
+			accepted = 1
+		}
+		message := r.Error
+		if len(message) > 1024 {
+			message = message[:1024]
+		}
+		stmnt_string := fmt.Sprintf(
+			"INSERT INTO %s" +
+				"(time,path,accepted,error,changes)" +
+				" VALUES " +
+				"(%d,'%s',b'%d','%s','%s')",
+			l.GetRelationName(),r.Time.Unix(),escape(r.Path),accepted,escape(message),escape(strings.Join(r.Changes,"\n")))
+		go logger.StatementExecute(stmnt_string)
+	}
+}
+func (l *ReloadLog) Delete(val ...interface{})(){
+	//@todo
+}
+func (l *ReloadLog) Update(val ...interface{})(){
+	//@todo
+}
@@ -0,0 +1,132 @@
+package system
+
+import(
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiffConfigurations(t *testing.T){
+	previous := DefaultConfiguration()
+	previous.Curve = &CurveConfig{Table:[]CurveRow{
+		{Outside:0,Targets:make([]int,CURVE_HOURS)},
+		{Outside:10,Targets:make([]int,CURVE_HOURS)},
+	}}
+	current := DefaultConfiguration()
+	current.Curve = &CurveConfig{Table:[]CurveRow{
+		{Outside:0,Targets:make([]int,CURVE_HOURS)},
+		{Outside:20,Targets:make([]int,CURVE_HOURS)},
+	}}
+	current.Curve.Table[0].Targets[6] = 50000
+	current.Hysteresis.BoilerOn = 1000
+
+	changes := DiffConfigurations(previous,current)
+	expected := []string{
+		"curve.table[0 °C][06:00]: 0 -> 50000",
+		"curve.table[10 °C][00:00]: - 0",
+		"curve.table[20 °C][00:00]: + 0",
+		"hysteresis.boiler_on: 2000 -> 1000",
+	}
+	if len(changes) != 2 + 2*CURVE_HOURS {
+		t.Error("For","changes","expected",2 + 2*CURVE_HOURS,"got",len(changes),changes)
+	}
+	for _,change := range expected {
+		found := false
+		for _,c := range changes {
+			found = found || c == change
+		}
+		if !found {
+			t.Error("For","changes","expected",change,"got",changes)
+		}
+	}
+	if changes := DiffConfigurations(current,current); len(changes) != 0 {
+		t.Error("For","same configuration","expected","no changes","got",changes)
+	}
+}
+
+// Replaces the file at path atomically like an editor
+func replaceFile(t *testing.T, path, content string)(){
+	if err := ioutil.WriteFile(path+".swp",[]byte(content),0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(path+".swp",path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Waits until the reload at or after since
+func awaitReload(t *testing.T, o *ConfigurationOracle, since time.Time)(ConfigurationReload){
+	for deadline := time.Now().Add(time.Second * 5); time.Now().Before(deadline); time.Sleep(time.Millisecond * 10) {
+		if reload := o.LastReload(); !reload.Time.Before(since) {
+			return reload
+		}
+	}
+	t.Fatal("no reload since",since)
+	return ConfigurationReload{}
+}
+
+func TestConfigurationReload(t *testing.T){
+	dir,err := ioutil.TempDir("","reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir,"config.csv")
+	table := func(target int)(string){
+		return "Temp/h\n"+curveRow(0,func(hour int)(int){ return target })+"\n"+curveRow(10,func(hour int)(int){ return target - 10000 })+"\n"
+	}
+	replaceFile(t,path,table(50000))
+
+	o := NewConfigurationOracle(path,30000)
+	o.debounce = time.Millisecond * 50
+	if err := o.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer o.Stop()
+
+	// a change is reloaded once the file is quiet
+	start := time.Now()
+	replaceFile(t,path,table(55000))
+	reload := awaitReload(t,o,start)
+	if !reload.Accepted || len(reload.Changes) != 2*CURVE_HOURS || !strings.Contains(reload.String(),"curve.table[0 °C][00:00]: 50000 -> 55000") {
+		t.Error("For","changed table","expected",2*CURVE_HOURS,"changes","got",reload)
+	}
+	if o.Configuration().Curve.Table[0].Targets[0] != 55000 {
+		t.Error("For","swapped configuration","expected",55000,"got",o.Configuration().Curve)
+	}
+
+	// a half-written file is rejected, the last valid configuration is kept
+	start = time.Now()
+	previous := o.Configuration()
+	content := table(60000)
+	replaceFile(t,path,content[:len(content)-20])
+	if reload = awaitReload(t,o,start); reload.Accepted || reload.Error == "" {
+		t.Error("For","half-written file","expected","rejection","got",reload)
+	}
+	if o.Configuration() != previous {
+		t.Error("For","rejected file","expected","last valid configuration","got",o.Configuration())
+	}
+
+	// other files of the directory are ignored
+	start = time.Now()
+	replaceFile(t,filepath.Join(dir,"hardware.toml"),"")
+	time.Sleep(o.debounce * 4)
+	if reload := o.LastReload(); !reload.Time.Before(start) {
+		t.Error("For","unrelated file","expected","no reload","got",reload)
+	}
+
+	// a forced reload reports the rejection
+	if err := o.Reload(); err == nil {
+		t.Error("For","forced reload of the half-written file","expected","error","got",nil)
+	}
+	replaceFile(t,path,content)
+	time.Sleep(o.debounce * 4)
+	if err := o.Reload(); err != nil || !reflect.DeepEqual(o.LastReload().Changes,[]string(nil)) {
+		t.Error("For","forced reload without changes","expected","no changes","got",err,o.LastReload())
+	}
+}
@@ -0,0 +1,160 @@
+package system
+
+import(
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"github.com/hansen1101/go_heating/system/hardware"
+	"github.com/hansen1101/go_heating/system/w1"
+)
+
+func TestConfigurationCodec(t *testing.T){
+	config,err := MigrateConfiguration(TEST_CONFIGURATION)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Hysteresis.BoilerOn = 1500
+	config.Limits.MinFrostTarget = 0
+	decoded,err := UnmarshalConfiguration(config.Marshal())
+	if err != nil || !reflect.DeepEqual(config,decoded) {
+		t.Error("For","configuration table","expected",config,"got",decoded,err)
+	}
+
+	dir,err := ioutil.TempDir("","configuration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	schedule,err := MigrateConfiguration(writeSchedule(t,dir,testSchedule))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded,err = UnmarshalConfiguration(schedule.Marshal()); err != nil || !reflect.DeepEqual(schedule.Schedule,decoded.Schedule) {
+		t.Error("For","schedule","expected",schedule.Schedule,"got",decoded,err)
+	}
+	curve,_ := decoded.HeatingCurve()
+	if setpoint := curve.(*Schedule).Setpoint(0,time.Date(2027,2,10,8,0,0,0,time.Local)); setpoint.Boiler != 30000 || !setpoint.Frost {
+		t.Error("For","decoded vacation","expected",30000,"got",setpoint)
+	}
+
+	// unknown fields of newer schemas are skipped
+	var e protoEncoder
+	e.putString(99,"unknown")
+	e.putDouble(98,1)
+	if decoded,err = UnmarshalConfiguration(append(config.Marshal(),e.buffer...)); err != nil || !reflect.DeepEqual(config,decoded) {
+		t.Error("For","unknown fields","expected",config,"got",decoded,err)
+	}
+	data := config.Marshal()
+	if _,err = UnmarshalConfiguration(data[:len(data)-3]); err == nil {
+		t.Error("For","truncated configuration","expected","error","got",nil)
+	}
+}
+
+func TestConfigurationValidation(t *testing.T){
+	tests := []struct{
+		name string
+		modify func(c *Configuration)()
+		field string
+	}{
+		{"version",func(c *Configuration)(){ c.Version = 0 },"schema_version"},
+		{"no curve",func(c *Configuration)(){ c.Curve = nil },"curve"},
+		{"target above limit",func(c *Configuration)(){ c.Curve.Table[3].Targets[5] = 90000 },"curve.table.rows[3].targets[5]"},
+		{"short row",func(c *Configuration)(){ c.Curve.Table[0].Targets = c.Curve.Table[0].Targets[:23] },"curve.table.rows[0].targets"},
+		{"duplicate row",func(c *Configuration)(){ c.Curve.Table[1].Outside = c.Curve.Table[0].Outside },"curve.table.rows[1].outside"},
+		{"unbounded curve",func(c *Configuration)(){ c.Curve = &CurveConfig{Parametric:&ParametricCurve{Room:20000,Slope:1}} },"curve.parametric.max"},
+		{"hysteresis",func(c *Configuration)(){ c.Hysteresis.BoilerOff = 0 },"hysteresis.boiler_off"},
+		{"water bounds",func(c *Configuration)(){ c.Hysteresis.WaterLowerBound = 60000 },"hysteresis.water_lower_bound"},
+		{"default target",func(c *Configuration)(){ c.Boiler.Default = 80000 },"boiler.default"},
+		{"undefined profile",func(c *Configuration)(){
+			c.Schedule = &ScheduleConfig{Profiles:[]ProfileConfig{{Name:"workday",Curve:*c.Curve}},Week:Week{Default:"weekend"}}
+			c.Curve = nil
+		},"schedule: week: sunday uses the undefined profile weekend"},
+		{"frost target",func(c *Configuration)(){
+			c.Schedule = &ScheduleConfig{Profiles:[]ProfileConfig{{Name:"workday",Curve:*c.Curve}},Week:Week{Default:"workday"},Vacation:&Vacation{Until:"2027-02-14",FrostTarget:5000}}
+			c.Curve = nil
+		},"schedule.vacation.frost_target"},
+	}
+	for _,test := range tests {
+		config,err := MigrateConfiguration(TEST_CONFIGURATION)
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.modify(config)
+		err = config.Validate()
+		if errs,ok := err.(ConfigurationError); !ok || len(errs) != 1 || !strings.HasPrefix(errs[0],test.field) {
+			t.Error("For",test.name,"expected",test.field,"got",err)
+		}
+	}
+
+	dir,err := ioutil.TempDir("","configuration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	invalid := filepath.Join(dir,"config.csv")
+	ioutil.WriteFile(invalid,[]byte("Temp/h\n"+curveRow(0,func(hour int)(int){ return 40000 })+"\n1,x\n"),0644)
+	if _,err := MigrateConfiguration(invalid); err == nil {
+		t.Error("For","csv with invalid row","expected","error","got",nil)
+	}
+	if _,err := MigrateConfiguration(filepath.Join(dir,"missing.csv")); err == nil {
+		t.Error("For","missing csv","expected","error","got",nil)
+	}
+}
+
+func TestLoadConfiguration(t *testing.T){
+	dir,err := ioutil.TempDir("","configuration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir,"config.pb")
+
+	config := DefaultConfiguration()
+	config.Curve = &CurveConfig{Parametric:&ParametricCurve{Room:20000,DesignOutside:-15000,DesignTarget:95000,Max:75000}}
+	config.Limits.MaxTarget = 60000
+	if err := SaveConfiguration(path,config); err == nil {
+		t.Error("For","curve above the safety limit","expected","error","got",nil)
+	}
+	config.Limits.MaxTarget = 75000
+	config.Boiler.Default = 35000
+	if err := SaveConfiguration(path,config); err != nil {
+		t.Fatal(err)
+	}
+	if loaded,err := LoadConfiguration(path); err != nil || !reflect.DeepEqual(config,loaded) {
+		t.Error("For","saved configuration","expected",config,"got",loaded,err)
+	}
+
+	o := NewConfigurationOracle(path,30000)
+	if err := o.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer o.Stop()
+	if target,err := o.Target(context.Background(),nil); err != nil || target != 35000 {
+		t.Error("For","default target","expected",35000,"got",target,err)
+	}
+	percept := testPercept(time.Now(),nil)
+	percept.SetTemperature(w1.NewMeasuredTemperature("28-000000000001",hardware.ROLE_OUTSIDE,-20000,percept.CurrentTime))
+	percept.Valid = true
+	if target,err := o.Target(context.Background(),percept); err != nil || target != 75000 {
+		t.Error("For","target at -20 °C","expected",75000,"got",target,err)
+	}
+	if o.Hysteresis() != config.Hysteresis {
+		t.Error("For","hysteresis","expected",config.Hysteresis,"got",o.Hysteresis())
+	}
+
+	config.Version = CONFIGURATION_SCHEMA_VERSION + 1
+	ioutil.WriteFile(path,config.Marshal(),0644)
+	if _,err := LoadConfiguration(path); err == nil || !strings.Contains(err.Error(),"newer") {
+		t.Error("For","newer schema version","expected","error","got",err)
+	}
+	config.Version = 0
+	ioutil.WriteFile(path,config.Marshal(),0644)
+	if _,err := LoadConfiguration(path); err == nil {
+		t.Error("For","missing schema version","expected","error","got",nil)
+	}
+}
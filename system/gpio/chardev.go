@@ -0,0 +1,343 @@
+//go:build linux
+// +build linux
+
+package gpio
+
+import(
+	"os"
+	"log"
+	"syscall"
+	"time"
+	"unsafe"
+	"encoding/binary"
+)
+
+// Constants of the gpio character device uAPI v2 (linux/gpio.h)
+const(
+	lines_max = 64
+	max_name_size = 32
+	line_num_attrs_max = 10
+
+	line_flag_active_low uint64 = 1<<1
+	line_flag_input uint64 = 1<<2
+	line_flag_output uint64 = 1<<3
+	line_flag_edge_rising uint64 = 1<<4
+	line_flag_edge_falling uint64 = 1<<5
+	line_flag_bias_pull_up uint64 = 1<<8
+	line_flag_bias_pull_down uint64 = 1<<9
+	line_flag_bias_disabled uint64 = 1<<10
+
+	line_attr_id_flags = 1
+	line_attr_id_output_values = 2
+	line_attr_id_debounce = 3
+
+	line_event_size = 48
+	line_event_rising_edge = 1
+
+	// _IOWR(0xB4, nr, size)
+	get_line_ioctl = 0xC250B407
+	line_set_config_ioctl = 0xC110B40D
+	line_get_values_ioctl = 0xC010B40E
+	line_set_values_ioctl = 0xC010B40F
+
+	CONSUMER_LABEL = "go_heating"
+)
+
+var(
+	CHIP_PATH = "/dev/gpiochip0"	// this needs to be variable in order to enable the main programm to select the chip (e.g. gpio-sim)
+
+	// performs the ioctl system call, replaceable in order to test against a fake character device
+	ioctl = func(fd uintptr, request uintptr, arg unsafe.Pointer)(error){
+		_,_,errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, uintptr(arg))
+		if errno != 0 {
+			return errno
+		}
+		return nil
+	}
+)
+
+// struct gpio_v2_line_attribute, the union is represented by the 64 bit value field
+type lineAttribute struct {
+	id uint32
+	padding uint32
+	value uint64
+}
+
+// struct gpio_v2_line_config_attribute
+type lineConfigAttribute struct {
+	attr lineAttribute
+	mask uint64
+}
+
+// struct gpio_v2_line_config
+type lineConfig struct {
+	flags uint64
+	numAttrs uint32
+	padding [5]uint32
+	attrs [line_num_attrs_max]lineConfigAttribute
+}
+
+// struct gpio_v2_line_request
+type lineRequest struct {
+	offsets [lines_max]uint32
+	consumer [max_name_size]byte
+	config lineConfig
+	numLines uint32
+	eventBufferSize uint32
+	padding [5]uint32
+	fd int32
+}
+
+// struct gpio_v2_line_values
+type lineValues struct {
+	bits uint64
+	mask uint64
+}
+
+// struct representing a gpio pin that is accessed via a line request on the gpiochip character device
+type ChardevPin struct {
+	pin GpioId
+	mode PinMode
+	value bool
+	activeLow bool
+	bias Bias
+	edges bool
+	lineFd int
+	stopWatch func()()	// terminates the running edge watch, nil if the pin is not watched
+}
+
+// Constructor method for the ChardevPin struct
+// mode set to INPUT by default
+// value set to FALSE by default
+// @return pointer to ChardevPin struct
+func NewChardevPin()(p *ChardevPin){
+	p = &ChardevPin{mode:INPUT,value:false,lineFd:-1}
+	return
+}
+
+// Configures the internal fields of a ChardevPin struct and requests the line
+// with the given configuration from the gpiochip at CHIP_PATH.
+// @param: pin the Pin's id on the raspberry board (equals the line offset on the chip)
+// @param: mode weather read or write access is required
+// @param: activeLow (optional) true if the pin should be run in active_low mode (raspberry default is false)
+func (g *ChardevPin) PinMode(pin GpioId, mode PinMode, activeLow ...bool){
+	g.pin = pin
+	g.mode = mode
+
+	if len(activeLow) > 0 {
+		g.activeLow = activeLow[0]
+	}
+
+	g.export()
+	return
+}
+
+// Builds the line configuration according to the internal fields of the pin.
+// If the pin's direction is OUT, the initial value is set to LOW.
+func (g *ChardevPin) config()(c lineConfig){
+	if g.activeLow {
+		c.flags |= line_flag_active_low
+	}
+	switch g.mode {
+	case OUTPUT:
+		c.flags |= line_flag_output
+		var level uint64
+		if valueLevel(g.value,g.activeLow) {
+			level = 1
+		}
+		c.attrs[0] = lineConfigAttribute{
+			attr:lineAttribute{id:line_attr_id_output_values,value:level},
+			mask:1,
+		}
+		c.numAttrs = 1
+	default:
+		c.flags |= line_flag_input
+		if g.edges {
+			c.flags |= line_flag_edge_rising | line_flag_edge_falling
+		}
+		switch g.bias {
+		case BIAS_DISABLED:
+			c.flags |= line_flag_bias_disabled
+		case BIAS_PULL_UP:
+			c.flags |= line_flag_bias_pull_up
+		case BIAS_PULL_DOWN:
+			c.flags |= line_flag_bias_pull_down
+		}
+	}
+	return
+}
+
+// Requests the line from the chip. The chip is only opened during the request,
+// the line itself is held by the returned line file descriptor.
+func (g *ChardevPin) export() {
+	chip,err := os.OpenFile(CHIP_PATH,os.O_RDWR,0)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer chip.Close()
+
+	req := lineRequest{numLines:1,config:g.config()}
+	req.offsets[0] = uint32(g.pin)
+	copy(req.consumer[:max_name_size-1],CONSUMER_LABEL)
+
+	if err = ioctl(chip.Fd(),get_line_ioctl,unsafe.Pointer(&req)); err != nil {
+		log.Fatal(err)
+	}
+	g.lineFd = int(req.fd)
+	return
+}
+
+// Releases the line which deactivates it for further use.
+func (g *ChardevPin) Unexport() {
+	g.StopWatch()
+	if g.lineFd < 0 {
+		return
+	}
+	syscall.Close(g.lineFd)
+	g.lineFd = -1
+	return
+}
+
+// Sets the bias of an input line. If the line is already requested the new
+// configuration is applied immediately.
+// @param bias one of the BIAS_* constants
+func (g *ChardevPin) SetBias(bias Bias)(){
+	g.bias = bias
+	g.reconfigure()
+	return
+}
+
+// Applies the current configuration to an already requested line.
+func (g *ChardevPin) reconfigure()(){
+	if g.lineFd < 0 {
+		return
+	}
+	c := g.config()
+	if err := ioctl(uintptr(g.lineFd),line_set_config_ioctl,unsafe.Pointer(&c)); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Watches the pin for transitions by enabling edge detection on the line and
+// reading the line events. Only applicable to INPUT pins.
+// @param edge transitions that should be delivered
+// @param period debounce period, changes shorter than period are ignored
+// @return channel through which the debounced events are delivered, nil if the pin can not be watched
+func (g *ChardevPin) WatchEdge(edge Edge, period time.Duration)(<-chan Event){
+	g.StopWatch()
+	if g.mode != INPUT || edge == EDGE_NONE || g.lineFd < 0 {
+		return nil
+	}
+
+	// always detect both edges in order to track the level for debouncing
+	g.edges = true
+	g.reconfigure()
+
+	stopR,stopW,err := os.Pipe()
+	if err != nil {
+		log.Print(err)
+		return nil
+	}
+	g.stopWatch = func(){
+		stopW.Write([]byte{0})
+		stopW.Close()
+		g.edges = false
+		g.reconfigure()
+	}
+
+	raw := make(chan Event,EVENT_BUFFER_SIZE)
+	out := make(chan Event,EVENT_BUFFER_SIZE)
+	go debounce(raw,out,edge,period,g.GetValue())
+
+	go func(fd int){
+		defer func(){
+			stopR.Close()
+			close(raw)
+		}()
+		buf := make([]byte,line_event_size,line_event_size)
+		err := pollFd(fd,syscall.EPOLLIN,stopR,func(){
+			// struct gpio_v2_line_event: the event id follows the 64 bit timestamp
+			if n,_ := syscall.Read(fd,buf); n == line_event_size {
+				level := binary.LittleEndian.Uint32(buf[8:12]) == line_event_rising_edge
+				raw <- newEvent(g.pin,levelValue(level,g.activeLow))
+			}
+		})
+		if err != nil {
+			log.Print(err)
+		}
+	}(g.lineFd)
+
+	return out
+}
+
+// Stops watching the pin, the event channel is closed afterwards.
+func (g *ChardevPin) StopWatch()(){
+	if g.stopWatch != nil {
+		g.stopWatch()
+		g.stopWatch = nil
+	}
+}
+
+// Sets the value for a gpio pin. This method is only applicable
+// to OUT direction pins and as no effect if Pin.mode is INPUT.
+// The function is active_low safe.
+// @param val if true the pin is set to mode HIGH else to mode LOW
+func (g *ChardevPin) SetValue(val bool){
+	if g.mode == INPUT || g.value == val {
+		return
+	}
+	g.value = val
+
+	v := lineValues{mask:1}
+	if valueLevel(g.value,g.activeLow) {
+		v.bits = 1
+	}
+
+	attempts := 0
+	for success:=false; success != true; {
+		err := ioctl(uintptr(g.lineFd),line_set_values_ioctl,unsafe.Pointer(&v))
+		attempts++
+		if err != nil {
+			if attempts > FILE_ACCESS_BOUND {
+				log.Fatal(err)
+			}
+		} else {
+			success = true
+		}
+	}
+	return
+}
+
+// Reads and returns the current value from any kind of pins. If the pin's direction
+// is set to OUT the internal value is returned directly. For INPUT pins the actual
+// value is read from the line each time the function is called.
+// This function is active_low safe.
+// @return true if signal at pin is HIGH, false if signal is LOW
+func (g *ChardevPin) GetValue()(val bool){
+	if g.mode == OUTPUT {
+		return g.value
+	}
+
+	v := lineValues{mask:1}
+	attempts := 0
+	for success:=false; success != true; {
+		err := ioctl(uintptr(g.lineFd),line_get_values_ioctl,unsafe.Pointer(&v))
+		attempts++
+		if err != nil {
+			if attempts > FILE_ACCESS_BOUND {
+				log.Fatal(err)
+			}
+		} else {
+			success = true
+		}
+	}
+
+	val = levelValue(v.bits&1 == 1,g.activeLow)
+	g.value = val
+	return val
+}
+
+// Getter for the GPIO board id of the pin
+func (g *ChardevPin) GetGpioId()(GpioId){
+	return g.pin
+}
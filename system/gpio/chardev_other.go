@@ -0,0 +1,22 @@
+//go:build !linux
+// +build !linux
+
+package gpio
+
+import(
+	"log"
+)
+
+var(
+	CHIP_PATH = "/dev/gpiochip0"
+)
+
+// The gpiochip character device is only available on linux.
+type ChardevPin struct {
+	SysfsPin
+}
+
+func NewChardevPin()(p *ChardevPin){
+	log.Fatal("gpio character device backend is only supported on linux")
+	return
+}
@@ -0,0 +1,206 @@
+//go:build linux
+// +build linux
+
+package gpio
+
+import(
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+// fakeChip emulates the ioctl interface of a gpiochip character device with a
+// single requested line
+type fakeChip struct {
+	request lineRequest
+	config lineConfig
+	level bool
+	line *os.File	// read end is handed out as line fd
+	events *os.File	// write end for line events
+}
+
+func (c *fakeChip) ioctl(fd uintptr, request uintptr, arg unsafe.Pointer)(error){
+	switch request {
+	case get_line_ioctl:
+		req := (*lineRequest)(arg)
+		c.request = *req
+		c.config = req.config
+		if req.config.numAttrs > 0 && req.config.attrs[0].attr.id == line_attr_id_output_values {
+			c.level = req.config.attrs[0].attr.value&1 == 1
+		}
+		req.fd = int32(c.line.Fd())
+	case line_set_config_ioctl:
+		c.config = *(*lineConfig)(arg)
+	case line_set_values_ioctl:
+		v := (*lineValues)(arg)
+		c.level = v.bits&v.mask == 1
+	case line_get_values_ioctl:
+		v := (*lineValues)(arg)
+		v.bits = 0
+		if c.level {
+			v.bits = 1
+		}
+	default:
+		return syscall.EINVAL
+	}
+	return nil
+}
+
+func withFakeChip(t *testing.T)(chip *fakeChip, cleanup func()){
+	chipFile,err := ioutil.TempFile("","gpiochip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	line,events,err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chip = &fakeChip{line:line,events:events}
+
+	oldPath, oldIoctl := CHIP_PATH, ioctl
+	CHIP_PATH = chipFile.Name()
+	ioctl = chip.ioctl
+
+	cleanup = func(){
+		CHIP_PATH, ioctl = oldPath, oldIoctl
+		chipFile.Close()
+		os.Remove(chipFile.Name())
+		events.Close()
+	}
+	return
+}
+
+func TestUapiStructSizes(t *testing.T){
+	if s := unsafe.Sizeof(lineRequest{}); s != 592 {
+		t.Error("For","gpio_v2_line_request","expected",592,"got",s)
+	}
+	if s := unsafe.Sizeof(lineConfig{}); s != 272 {
+		t.Error("For","gpio_v2_line_config","expected",272,"got",s)
+	}
+	if s := unsafe.Sizeof(lineValues{}); s != 16 {
+		t.Error("For","gpio_v2_line_values","expected",16,"got",s)
+	}
+}
+
+func TestChardevOutput(t *testing.T){
+	chip,cleanup := withFakeChip(t)
+	defer cleanup()
+
+	p := NewChardevPin()
+	p.PinMode(GPIO17,OUTPUT,true)
+
+	if chip.request.offsets[0] != uint32(GPIO17) || chip.request.numLines != 1 {
+		t.Error("For","offsets","expected",GPIO17,"got",chip.request.offsets[0],chip.request.numLines)
+	}
+	if chip.config.flags != line_flag_output|line_flag_active_low {
+		t.Errorf("For flags expected %b got %b",line_flag_output|line_flag_active_low,chip.config.flags)
+	}
+
+	// active low output lines are driven like the sysfs backend: the physical
+	// signal equals the logical value
+	var params = []struct{
+		value bool
+		level bool
+	} {
+		{false,true},
+		{true,false},
+		{true,false},
+		{false,true},
+	}
+	for _,param := range params {
+		p.SetValue(param.value)
+		if chip.level != param.level || p.GetValue() != param.value {
+			t.Error("For",param.value,"expected level",param.level,"got",chip.level,p.GetValue())
+		}
+	}
+	p.Unexport()
+	if p.lineFd != -1 {
+		t.Error("For","Unexport","expected",-1,"got",p.lineFd)
+	}
+}
+
+func TestChardevInput(t *testing.T){
+	chip,cleanup := withFakeChip(t)
+	defer cleanup()
+
+	p := NewChardevPin()
+	p.PinMode(GPIO6,INPUT,true)
+	if chip.config.flags != line_flag_input|line_flag_active_low {
+		t.Errorf("For flags expected %b got %b",line_flag_input|line_flag_active_low,chip.config.flags)
+	}
+
+	p.SetBias(BIAS_PULL_UP)
+	if chip.config.flags & line_flag_bias_pull_up == 0 {
+		t.Errorf("For bias expected %b got %b",line_flag_bias_pull_up,chip.config.flags)
+	}
+
+	for _,level := range []bool{true,false,true} {
+		chip.level = level
+		if p.GetValue() != level {
+			t.Error("For level",level,"expected",level,"got",p.GetValue())
+		}
+	}
+	p.Unexport()
+}
+
+// Writes a struct gpio_v2_line_event for the given edge id to the line
+func (c *fakeChip) edge(id uint32)(){
+	buf := make([]byte,line_event_size)
+	binary.LittleEndian.PutUint64(buf[0:8],uint64(time.Now().UnixNano()))
+	binary.LittleEndian.PutUint32(buf[8:12],id)
+	c.level = id == line_event_rising_edge
+	c.events.Write(buf)
+}
+
+func TestChardevWatchEdge(t *testing.T){
+	chip,cleanup := withFakeChip(t)
+	defer cleanup()
+
+	p := NewChardevPin()
+	p.PinMode(GPIO6,INPUT,false)
+	chip.level = true // logical value false
+
+	events := p.WatchEdge(EDGE_BOTH,5*time.Millisecond)
+	if chip.config.flags & (line_flag_edge_rising|line_flag_edge_falling) == 0 {
+		t.Errorf("For edge flags expected %b got %b",line_flag_edge_rising|line_flag_edge_falling,chip.config.flags)
+	}
+
+	chip.edge(2) // falling level -> logical true
+	select {
+	case e := <-events:
+		if !e.Value || e.Edge != EDGE_RISING || e.Pin != GPIO6 {
+			t.Error("For","falling level","expected",EDGE_RISING,"got",e)
+		}
+	case <-time.After(time.Second):
+		t.Error("For","falling level","expected",EDGE_RISING,"got","timeout")
+	}
+
+	p.Unexport()
+	if _,ok := <-events; ok {
+		t.Error("For","Unexport","expected","closed channel","got","open channel")
+	}
+}
+
+// Runs against a gpio-sim chip if GPIO_SIM_CHIP points to its character device
+// (e.g. /dev/gpiochip1), the simulated lines must not be in use.
+func TestChardevGpioSim(t *testing.T){
+	path := os.Getenv("GPIO_SIM_CHIP")
+	if path == "" {
+		t.Skip("GPIO_SIM_CHIP not set")
+	}
+	oldPath := CHIP_PATH
+	CHIP_PATH = path
+	defer func(){ CHIP_PATH = oldPath }()
+
+	p := NewChardevPin()
+	p.PinMode(GPIO2,OUTPUT,true)
+	defer p.Unexport()
+	p.SetValue(true)
+	if !p.GetValue() {
+		t.Error("For","gpio-sim","expected",true,"got",false)
+	}
+}
@@ -0,0 +1,102 @@
+package gpio
+
+import(
+	"time"
+)
+
+// Type for the signal transitions an INPUT pin can be watched for
+type Edge uint8
+
+const(
+	EDGE_NONE Edge = iota
+	EDGE_RISING
+	EDGE_FALLING
+	EDGE_BOTH
+
+	EDGE_FILE_NAME = "edge"
+	EVENT_BUFFER_SIZE = 16
+)
+
+// An Event is delivered each time the logical value of a watched pin changed
+// and stayed stable for the debounce period.
+type Event struct {
+	Pin GpioId
+	Edge Edge	// EDGE_RISING if Value changed to true, EDGE_FALLING otherwise
+	Value bool
+	Time time.Time	// time of the transition (begin of the stable period)
+}
+
+// String representation of an edge
+func (e Edge) String()(string){
+	switch e {
+	case EDGE_RISING:
+		return "rising"
+	case EDGE_FALLING:
+		return "falling"
+	case EDGE_BOTH:
+		return "both"
+	}
+	return "none"
+}
+
+// Checks whether an event with the given value is requested by the watched edge
+func (e Edge) matches(value bool)(bool){
+	switch e {
+	case EDGE_BOTH:
+		return true
+	case EDGE_RISING:
+		return value
+	case EDGE_FALLING:
+		return !value
+	}
+	return false
+}
+
+// Generates a raw (not debounced) event for the given pin and logical value
+func newEvent(pin GpioId, value bool)(e Event){
+	e = Event{Pin:pin,Value:value,Time:time.Now(),Edge:EDGE_FALLING}
+	if value {
+		e.Edge = EDGE_RISING
+	}
+	return
+}
+
+// Debounces the raw level changes received through raw and sends events for the
+// requested edge through out. A change is only accepted if the value stayed stable
+// for the debounce period. If the receiver of out is too slow, events are dropped
+// once the channel buffer is full. The function returns and closes out after raw
+// was closed.
+// @param raw channel of raw level changes produced by the backend
+// @param out channel through which debounced events are delivered
+// @param edge transitions that should be delivered
+// @param period the time a value must be stable to be accepted
+// @param value the logical value of the pin when watching started
+func debounce(raw <-chan Event, out chan<- Event, edge Edge, period time.Duration, value bool)(){
+	var pending Event
+	var stable <-chan time.Time
+
+	defer close(out)
+
+	for {
+		select {
+		case e,ok := <-raw:
+			if !ok {
+				return
+			}
+			// every change restarts the debounce period
+			pending = e
+			stable = time.After(period)
+		case <-stable:
+			stable = nil
+			if pending.Value != value {
+				value = pending.Value
+				if edge.matches(value) {
+					select {
+					case out <- pending:
+					default:
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+//go:build linux
+// +build linux
+
+package gpio
+
+import(
+	"os"
+	"log"
+	"syscall"
+	"time"
+)
+
+// Waits for the given epoll events on fd and calls handle each time fd becomes
+// ready. The function returns as soon as stop becomes readable.
+// @param fd the file descriptor to watch
+// @param events epoll event mask for fd
+// @param stop read end of a pipe, writing to the other end terminates the wait
+// @param handle function that consumes the event on fd
+// @return error if the epoll instance could not be set up
+func pollFd(fd int, events uint32, stop *os.File, handle func()())(error){
+	epfd,err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(epfd)
+
+	stopFd := int(stop.Fd())
+	if err = syscall.EpollCtl(epfd,syscall.EPOLL_CTL_ADD,fd,&syscall.EpollEvent{Events:events,Fd:int32(fd)}); err != nil {
+		return err
+	}
+	if err = syscall.EpollCtl(epfd,syscall.EPOLL_CTL_ADD,stopFd,&syscall.EpollEvent{Events:syscall.EPOLLIN,Fd:int32(stopFd)}); err != nil {
+		return err
+	}
+
+	ready := make([]syscall.EpollEvent,2)
+	for {
+		n,err := syscall.EpollWait(epfd,ready,-1)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			if ready[i].Fd == int32(stopFd) {
+				return nil
+			}
+		}
+		handle()
+	}
+}
+
+// Watches the pin for transitions by configuring the edge attribute and waiting for
+// priority events on the value file. Only applicable to INPUT pins.
+// @param edge transitions that should be delivered
+// @param period debounce period, changes shorter than period are ignored
+// @return channel through which the debounced events are delivered, nil if the pin can not be watched
+func (g *SysfsPin) WatchEdge(edge Edge, period time.Duration)(<-chan Event){
+	g.StopWatch()
+	if g.mode != INPUT || edge == EDGE_NONE {
+		return nil
+	}
+
+	// always watch both edges in order to track the level for debouncing
+	attempts := 0
+	for success:=false; success != true; {
+		file,err := os.OpenFile(g.path + EDGE_FILE_NAME,os.O_WRONLY,os.ModeExclusive)
+		attempts++
+		if err != nil {
+			if attempts > FILE_ACCESS_BOUND {
+				log.Fatal(err)
+			}
+		} else {
+			file.WriteString(EDGE_BOTH.String())
+			file.Close()
+			success = true
+		}
+	}
+
+	value,err := os.OpenFile(g.path + VALUE_FILE_NAME,os.O_RDONLY,os.ModeTemporary)
+	if err != nil {
+		log.Print(err)
+		return nil
+	}
+	stopR,stopW,err := os.Pipe()
+	if err != nil {
+		value.Close()
+		log.Print(err)
+		return nil
+	}
+	g.stopWatch = func(){
+		stopW.Write([]byte{0})
+		stopW.Close()
+	}
+
+	raw := make(chan Event,EVENT_BUFFER_SIZE)
+	out := make(chan Event,EVENT_BUFFER_SIZE)
+	go debounce(raw,out,edge,period,g.GetValue())
+
+	go func(){
+		defer func(){
+			value.Close()
+			stopR.Close()
+			close(raw)
+		}()
+		fd := int(value.Fd())
+		buf := make([]byte,1,1)
+		err := pollFd(fd,syscall.EPOLLPRI|syscall.EPOLLERR,stopR,func(){
+			// the value file must be read from the beginning after each event
+			syscall.Seek(fd,0,0)
+			if n,_ := syscall.Read(fd,buf); n > 0 {
+				raw <- newEvent(g.pin,levelValue(buf[0] == '1',g.activeLow))
+			}
+		})
+		if err != nil {
+			log.Print(err)
+		}
+	}()
+
+	return out
+}
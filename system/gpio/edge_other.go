@@ -0,0 +1,48 @@
+//go:build !linux
+// +build !linux
+
+package gpio
+
+import(
+	"time"
+)
+
+const(
+	POLL_INTERVAL = 10 * time.Millisecond
+)
+
+// Watches the pin for transitions by polling its value, epoll is only available on linux.
+// @param edge transitions that should be delivered
+// @param period debounce period, changes shorter than period are ignored
+// @return channel through which the debounced events are delivered, nil if the pin can not be watched
+func (g *SysfsPin) WatchEdge(edge Edge, period time.Duration)(<-chan Event){
+	g.StopWatch()
+	if g.mode != INPUT || edge == EDGE_NONE {
+		return nil
+	}
+	stop := make(chan bool)
+	g.stopWatch = func(){
+		close(stop)
+	}
+
+	raw := make(chan Event,EVENT_BUFFER_SIZE)
+	out := make(chan Event,EVENT_BUFFER_SIZE)
+	value := g.GetValue()
+	go debounce(raw,out,edge,period,value)
+
+	go func(){
+		defer close(raw)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(POLL_INTERVAL):
+				if v := g.GetValue(); v != value {
+					value = v
+					raw <- newEvent(g.pin,value)
+				}
+			}
+		}
+	}()
+	return out
+}
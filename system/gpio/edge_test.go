@@ -0,0 +1,75 @@
+package gpio
+
+import(
+	"testing"
+	"time"
+)
+
+func TestDebounce(t *testing.T){
+	var params = []struct{
+		edge Edge
+		values []bool
+		expected []bool
+	} {
+		{EDGE_BOTH,[]bool{true},[]bool{true}},
+		{EDGE_BOTH,[]bool{true,false,true,false},[]bool{}},
+		{EDGE_RISING,[]bool{true,false,true},[]bool{true}},
+		{EDGE_FALLING,[]bool{true},[]bool{}},
+	}
+
+	for _,param := range params {
+		raw := make(chan Event)
+		out := make(chan Event,EVENT_BUFFER_SIZE)
+		go debounce(raw,out,param.edge,5*time.Millisecond,false)
+
+		// bouncing contacts: all changes happen within the debounce period
+		for _,v := range param.values {
+			raw <- newEvent(GPIO6,v)
+		}
+		<-time.After(20*time.Millisecond)
+		close(raw)
+
+		received := make([]bool,0)
+		for e := range out {
+			received = append(received,e.Value)
+		}
+		if len(received) != len(param.expected) {
+			t.Error("For",param.edge,param.values,"expected",param.expected,"got",received)
+			continue
+		}
+		for i := range received {
+			if received[i] != param.expected[i] {
+				t.Error("For",param.edge,param.values,"expected",param.expected,"got",received)
+			}
+		}
+	}
+}
+
+func TestSimPinWatchEdge(t *testing.T){
+	SetInput(GPIO6,false)
+	p := NewSimPin()
+	p.PinMode(GPIO6,INPUT,true)
+	events := p.WatchEdge(EDGE_BOTH,5*time.Millisecond)
+
+	// button press with contact bounce, then release
+	<-ScriptInput(GPIO6,
+		InputStep{After:0,Value:true},
+		InputStep{After:time.Millisecond,Value:false},
+		InputStep{After:time.Millisecond,Value:true},
+		InputStep{After:20*time.Millisecond,Value:false},
+	)
+	<-time.After(20*time.Millisecond)
+	p.StopWatch()
+
+	expected := []Edge{EDGE_RISING,EDGE_FALLING}
+	i := 0
+	for e := range events {
+		if i >= len(expected) || e.Edge != expected[i] {
+			t.Error("For event",i,"expected",expected,"got",e)
+		}
+		i++
+	}
+	if i != len(expected) {
+		t.Error("For","button press","expected",len(expected),"events","got",i)
+	}
+}
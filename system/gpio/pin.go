@@ -1,16 +1,17 @@
 // The gpio package can be used to setup and access the raspberrie's gpio pins.
+// Pins are accessed through the Pin interface which is implemented by different backends:
+// the legacy sysfs interface (/sys/class/gpio), the gpiochip character device (/dev/gpiochipN)
+// which replaces sysfs on current kernels and an in-memory simulation for tests and dry runs.
+// The backend used by NewPin is selected via SetBackend.
 // If the configuration and access happens via file access an internal loop is implemented in
 // the corresponding method that ensures the file access is repeated until a fixed bound of repeats is reached
 // or the file access was successfully.
 package gpio
 
 import(
-	"os"
-	"os/exec"
-	"log"
+	"errors"
 	"strings"
-	"strconv"
-//	"fmt"
+	"time"
 )
 
 // Type for the mode of a gpio pin
@@ -19,6 +20,12 @@ type PinMode uint8
 // Type for the ID of gpio pins on raspberry board
 type GpioId uint8
 
+// Type for the gpio access mechanism used by NewPin
+type Backend uint8
+
+// Type for the bias (internal pull resistor) setting of an input pin
+type Bias uint8
+
 // Constants declatation
 const(
 	OUTPUT PinMode = 1+iota
@@ -60,245 +67,108 @@ const(
 	FILE_ACCESS_BOUND = 1000
 )
 
-var(
-	EXPORT_FILE = "/sys/class/gpio/export"
-	UNEXPORT_FILE = "/sys/class/gpio/unexport"
-	PATH_PREFIX = "/sys/class/gpio/gpio"
+const(
+	SYSFS Backend = iota
+	CHARDEV
+	SIMULATED
 )
 
-// struct representing a gpio pin internally
-type Pin struct {
-	pin GpioId
-	mode PinMode
-	value bool
-	activeLow bool
-	path string
-}
-
-// Constructor method for the Pin struct
-// mode set to INPUT by default
-// value set to FALSE by default
-// @return pointer to Pin struct with
-func NewPin()(p *Pin){
-	p = &Pin{mode:INPUT,value:false}
-	return
-}
-
-// Configures the internal fields of a Pin struct and calls the export function,
-// which introduces the pin to the os with the given configuration.
-// @param: pin the Pin's id on the raspberry board
-// @param: mode weather read or write access is required
-// @param: activeLow (optional) true if the pin should be run in active_low mode (raspberry default is false)
-func (g *Pin) PinMode(pin GpioId, mode PinMode, activeLow ...bool){
-	g.pin = pin
-	g.mode = mode
-
-	s := []string{PATH_PREFIX,strconv.Itoa(int(g.pin)),"/"}
-    	g.path = strings.Join(s,"")
+const(
+	BIAS_AS_IS Bias = iota
+	BIAS_DISABLED
+	BIAS_PULL_UP
+	BIAS_PULL_DOWN
+)
 
-	if len(activeLow) > 0 {
-		g.activeLow = activeLow[0]
-	}
+var(
+	backend = SYSFS
+)
 
-	g.export()
-	return
+// Interface to a single gpio pin. Values handed over to and returned from a Pin
+// are logical values, the mapping to the physical signal is done by the backend
+// according to the pin's active_low setting.
+// INPUT pins can be watched for edges, the debounced events are delivered through
+// the returned channel until StopWatch is called.
+type Pin interface {
+	PinMode(pin GpioId, mode PinMode, activeLow ...bool)()
+	SetBias(bias Bias)()
+	Unexport()()
+	SetValue(val bool)()
+	GetValue()(bool)
+	GetGpioId()(GpioId)
+	WatchEdge(edge Edge, debounce time.Duration)(<-chan Event)
+	StopWatch()()
 }
 
-// Introduces the pin configuration to the raspberry using the WiringPi framework
-// @internal: method is in ALPHA mode. WiringPi framework must be installed.
-func (g *Pin) exportWiringPi() {
-	var cmd *exec.Cmd
-	switch g.mode {
-		case OUTPUT:
-			cmd = exec.Command("gpio", "export", strconv.Itoa(int(g.pin)), "out")
-		default:
-			cmd = exec.Command("gpio", "export", strconv.Itoa(int(g.pin)), "in")
-	}
-	err := cmd.Run()
-	if err != nil {
-		log.Fatal(err) //os.Exit(1)
-	}
-
-	g.setActiveLowConfig()
-
-	return
+// Selects the backend that is used for pins created by successive NewPin calls.
+// Must be called before any pin is configured.
+func SetBackend(b Backend)(){
+	backend = b
 }
 
-// Introduces the pin's configuration to the raspberry via the configuration files.
-// If the pin's direction is OUT, the initial value is set to LOW.
-func (g *Pin) export() {
-	info,_ := os.Stat(g.path)
-	if info == nil {
-		file,err := os.OpenFile(EXPORT_FILE,os.O_WRONLY,os.ModeExclusive)
-		defer file.Close()
-		if err != nil {
-			log.Fatal(err)
-		}
-		file.WriteString(strconv.Itoa(int(g.pin)))
-	}
-
-	g.setActiveLowConfig()
-	g.setDirectionConfig()
-	g.SetValue(false)
-
-	return
+// Getter for the backend that is used by NewPin
+func GetBackend()(Backend){
+	return backend
 }
 
-// Unexports the pin which deactivates it for further use.
-func (g *Pin) Unexport() {
-	file,err := os.OpenFile(UNEXPORT_FILE,os.O_WRONLY,os.ModeExclusive)
-	defer file.Close()
-	if err != nil {
-		log.Fatal(err)
+// Maps a backend name (e.g. from the environment) to the Backend constant.
+// @param name either "sysfs", "chardev" or "simulated"
+// @return the corresponding Backend or an error if the name is unknown
+func ParseBackend(name string)(Backend, error){
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "sysfs":
+		return SYSFS, nil
+	case "chardev", "gpiochip", "cdev":
+		return CHARDEV, nil
+	case "sim", "simulated":
+		return SIMULATED, nil
 	}
-	file.WriteString(strconv.Itoa(int(g.pin)))
-
-	return
+	return SYSFS, errors.New("unknown gpio backend: "+name)
 }
 
-// Sets the value for a gpio pin. This method is only applicable
-// to OUT direction pins and as no effect if Pin.mode is INPUT.
-// The method sets the associated gpio value at the raspberry via file
-// access to the corresponding value file. The function is active_low safe.
-// @param val if true the pin is set to mode HIGH else to mode LOW
-func (g *Pin) SetValue(val bool){
-	if g.mode == INPUT || g.value == val {
-		return
-	}
-	g.value = val
-	var setTo string
-	if g.value {
-		if g.activeLow {
-			setTo = "0"
-		} else {
-			setTo = "1"
-		}
-	} else {
-		if g.activeLow {
-			setTo = "1"
-		} else {
-			setTo = "0"
-		}
+// String representation of a backend as accepted by ParseBackend
+func (b Backend) String()(string){
+	switch b {
+	case CHARDEV:
+		return "chardev"
+	case SIMULATED:
+		return "simulated"
+	default:
+		return "sysfs"
 	}
+}
 
-	attempts := 0
-	for success:=false; success != true; {
-		file,err := os.OpenFile(g.path+VALUE_FILE_NAME,os.O_WRONLY,os.ModeExclusive)
-		defer file.Close()
-		attempts++
-		if err != nil {
-			if attempts > FILE_ACCESS_BOUND {
-				log.Fatal(err)
-			}
-		} else {
-			file.WriteString(setTo)
-			success = true
-		}
+// Constructor method for the Pin interface, returns a pin of the selected backend
+// mode set to INPUT by default
+// value set to FALSE by default
+// @return Pin of the backend set by SetBackend
+func NewPin()(p Pin){
+	switch backend {
+	case CHARDEV:
+		p = NewChardevPin()
+	case SIMULATED:
+		p = NewSimPin()
+	default:
+		p = NewSysfsPin()
 	}
 	return
 }
 
-// Reads and returns the current value from any kind of pins. If the pin's direction
-// is set to OUT the internal value is returned directly. For INPUT pins the actual
-// value is read from the file each time the function is called.
-// This function is active_low safe which means, if the returned value is TRUE the pin's
-// physical signal is HIGH.
-// @return true if signal at pin is HIGH, false if signal is LOW
-func (g *Pin) GetValue()(val bool){
-	if g.mode == OUTPUT {
-		return g.value
-	}
-	buf := make([]byte,1,1)
-	attempts := 0
-	for success:=false; success != true; {
-		file,err := os.OpenFile(g.path+VALUE_FILE_NAME,os.O_RDONLY,os.ModeTemporary)
-		defer file.Close()
-		attempts++
-		if err != nil {
-			if attempts > FILE_ACCESS_BOUND {
-				log.Fatal(err)
-			}
-		} else {
-			file.Read(buf)
-			success = true
-		}
-	}
-	file_val,_ := strconv.Atoi(string(buf[0]))
-
-	if g.activeLow {
-		if file_val == 1 {
-			val = true
-		} else {
-			val = false
-		}
-	} else {
-		if file_val == 1 {
-			val = false
-		} else {
-			val = true
-		}
+// Returns the level that has to be written to the value attribute of a line
+// for the given logical value. Since the line itself is configured active_low
+// as well, the physical signal of an OUTPUT pin always equals the logical value.
+func valueLevel(val, activeLow bool)(level bool){
+	if activeLow {
+		return !val
 	}
-
-	g.value = val
 	return val
 }
 
-// Getter for the GPIO board id of the pin
-func (g *Pin) GetGpioId()(GpioId){
-	return g.pin
-}
-
-// Configures the pin's direction value at the raspberry according to the internal mode value
-// of the Pin struct.
-func (g *Pin) setDirectionConfig(){
-	var setTo string
-	switch g.mode {
-		case OUTPUT:
-			setTo = OUT
-		default:
-			setTo = IN
-	}
-
-	attempts := 0
-	for success:=false; success != true; {
-		file, err := os.OpenFile(g.path + DIRECTION_FILE_NAME, os.O_WRONLY, os.ModeExclusive)
-		defer file.Close()
-		attempts++
-		if err != nil {
-			if attempts > FILE_ACCESS_BOUND {
-				log.Fatal(err)
-			}
-		} else {
-			file.WriteString(setTo)
-			success = true
-		}
-	}
-
-	return
-}
-
-// Configures the pin's active_low value at the raspberry according to the internal value
-// of the Pin struct.
-func (g *Pin) setActiveLowConfig(){
-	var setTo string
-	if g.activeLow {
-		setTo = ALTRUE
-	} else {
-		setTo = ALFALSE
-	}
-	attempts := 0
-	for success:=false; success != true; {
-		file,err := os.OpenFile(g.path + ACTIVE_LOW_FILE_NAME,os.O_WRONLY,os.ModeExclusive)
-		defer file.Close()
-		attempts++
-		if err != nil {
-			if attempts > FILE_ACCESS_BOUND {
-				log.Fatal(err)
-			}
-		} else {
-			file.WriteString(setTo)
-			success = true
-		}
+// Maps the level that was read from the value attribute of an INPUT line to the
+// logical value of the pin.
+func levelValue(level, activeLow bool)(val bool){
+	if activeLow {
+		return level
 	}
-	return
+	return !level
 }
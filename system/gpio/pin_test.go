@@ -5,5 +5,39 @@ import(
 )
 
 func TestNewPin(t *testing.T){
-	return nil
-}
\ No newline at end of file
+	defer SetBackend(GetBackend())
+
+	SetBackend(SYSFS)
+	if _,ok := NewPin().(*SysfsPin); !ok {
+		t.Error("For",SYSFS,"expected",&SysfsPin{},"got",NewPin())
+	}
+
+	SetBackend(CHARDEV)
+	if _,ok := NewPin().(*ChardevPin); !ok {
+		t.Error("For",CHARDEV,"expected",&ChardevPin{},"got",NewPin())
+	}
+}
+
+func TestParseBackend(t *testing.T){
+	var params = []struct{
+		name string
+		backend Backend
+		fails bool
+	} {
+		{"sysfs",SYSFS,false},
+		{"chardev",CHARDEV,false},
+		{" Chardev\n",CHARDEV,false},
+		{"wiringpi",SYSFS,true},
+	}
+
+	for _,param := range params {
+		b,err := ParseBackend(param.name)
+		if b != param.backend || (err != nil) != param.fails {
+			t.Error(
+				"For", param.name,
+				"expected", param.backend,
+				"got", b, err,
+			)
+		}
+	}
+}
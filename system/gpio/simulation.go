@@ -0,0 +1,290 @@
+package gpio
+
+import(
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Type for the operations that are recorded in a Journal
+type JournalOp uint8
+
+const(
+	OP_EXPORT JournalOp = iota
+	OP_SET
+	OP_INPUT
+	OP_UNEXPORT
+)
+
+var(
+	// journal where all simulated pins record their operations by default
+	SimulationJournal = NewJournal()
+
+	// scripted logical values of simulated INPUT pins and the raw event channels of watched pins
+	simInputs = make(map[GpioId]bool)
+	simWatchers = make(map[GpioId][]chan Event)
+	simInputsLock sync.RWMutex
+)
+
+// An entry of the simulation journal. For OP_SET and OP_INPUT entries Value holds
+// the new logical value of the pin.
+type JournalEntry struct {
+	Time time.Time
+	Pin GpioId
+	Op JournalOp
+	Value bool
+}
+
+// A step of a scripted input sequence, the value is applied After the given
+// duration counted from the previous step.
+type InputStep struct {
+	After time.Duration
+	Value bool
+}
+
+// Journal of pin operations, safe for concurrent use
+type Journal struct {
+	lock sync.Mutex
+	entries []JournalEntry
+}
+
+// struct representing a simulated gpio pin, all operations are kept in memory
+// and recorded in the associated journal
+type SimPin struct {
+	pin GpioId
+	mode PinMode
+	value bool
+	activeLow bool
+	bias Bias
+	journal *Journal
+	stopWatch func()()	// terminates the running edge watch, nil if the pin is not watched
+}
+
+// String representation of a journal operation
+func (op JournalOp) String()(string){
+	switch op {
+	case OP_EXPORT:
+		return "export"
+	case OP_SET:
+		return "set"
+	case OP_INPUT:
+		return "input"
+	case OP_UNEXPORT:
+		return "unexport"
+	}
+	return "unknown"
+}
+
+// JournalEntry implements the Stringer interface.
+func (e JournalEntry) String()(string){
+	return fmt.Sprintf("[GPIO]\t%s\t[%d]\t%s\t%v",e.Time.Format(time.StampMilli),e.Pin,e.Op,e.Value)
+}
+
+// Constructor for an empty Journal
+func NewJournal()(j *Journal){
+	j = &Journal{entries:make([]JournalEntry,0)}
+	return
+}
+
+func (j *Journal) record(pin GpioId, op JournalOp, value bool)(){
+	j.lock.Lock()
+	j.entries = append(j.entries,JournalEntry{Time:time.Now(),Pin:pin,Op:op,Value:value})
+	j.lock.Unlock()
+}
+
+// Returns a copy of all recorded entries in chronological order
+func (j *Journal) Entries()(entries []JournalEntry){
+	j.lock.Lock()
+	entries = make([]JournalEntry,len(j.entries))
+	copy(entries,j.entries)
+	j.lock.Unlock()
+	return
+}
+
+// Returns all recorded entries for the given pin in chronological order
+func (j *Journal) Filter(pin GpioId)(entries []JournalEntry){
+	entries = make([]JournalEntry,0)
+	for _,e := range j.Entries() {
+		if e.Pin == pin {
+			entries = append(entries,e)
+		}
+	}
+	return
+}
+
+// Searches the first entry for the given pin, operation and value starting at index from.
+// @return index of the matching entry and the entry itself, index is -1 if no entry matches
+func (j *Journal) Find(from int, pin GpioId, op JournalOp, value bool)(index int, entry JournalEntry){
+	entries := j.Entries()
+	if from < 0 {
+		from = 0
+	}
+	for i := from; i < len(entries); i++ {
+		if e := entries[i]; e.Pin == pin && e.Op == op && e.Value == value {
+			return i, e
+		}
+	}
+	return -1, JournalEntry{}
+}
+
+// Removes all recorded entries
+func (j *Journal) Reset()(){
+	j.lock.Lock()
+	j.entries = make([]JournalEntry,0)
+	j.lock.Unlock()
+}
+
+// Writes all recorded entries line by line to w.
+func (j *Journal) WriteTo(w io.Writer)(n int64, err error){
+	var buffer bytes.Buffer
+	for _,e := range j.Entries() {
+		buffer.WriteString(e.String()+"\n")
+	}
+	return io.Copy(w,strings.NewReader(buffer.String()))
+}
+
+// Sets the logical value that simulated INPUT pins with the given id return.
+// Watchers of the pin are notified about the change.
+// @param pin the Pin's id on the raspberry board
+// @param val the logical value returned by GetValue
+func SetInput(pin GpioId, val bool)(){
+	simInputsLock.Lock()
+	if old,_ := simInputs[pin]; old != val {
+		for _,raw := range simWatchers[pin] {
+			select {
+			case raw <- newEvent(pin,val):
+			default:
+			}
+		}
+	}
+	simInputs[pin] = val
+	simInputsLock.Unlock()
+	SimulationJournal.record(pin,OP_INPUT,val)
+}
+
+// Applies the scripted input sequence to the given pin in a separate go routine.
+// @return channel that is closed after the last step was applied
+func ScriptInput(pin GpioId, steps ...InputStep)(done chan bool){
+	done = make(chan bool)
+	go func(){
+		for _,step := range steps {
+			<-time.After(step.After)
+			SetInput(pin,step.Value)
+		}
+		close(done)
+	}()
+	return
+}
+
+// Constructor method for the SimPin struct, the pin records to the SimulationJournal
+// mode set to INPUT by default
+// value set to FALSE by default
+// @return pointer to SimPin struct
+func NewSimPin()(p *SimPin){
+	p = &SimPin{mode:INPUT,value:false,journal:SimulationJournal}
+	return
+}
+
+// Configures the internal fields of a SimPin struct.
+// @param: pin the Pin's id on the raspberry board
+// @param: mode weather read or write access is required
+// @param: activeLow (optional) true if the pin should be run in active_low mode (raspberry default is false)
+func (g *SimPin) PinMode(pin GpioId, mode PinMode, activeLow ...bool){
+	g.pin = pin
+	g.mode = mode
+
+	if len(activeLow) > 0 {
+		g.activeLow = activeLow[0]
+	}
+
+	g.journal.record(g.pin,OP_EXPORT,g.value)
+	return
+}
+
+// Sets the bias of the simulated pin, has no effect on the simulation.
+func (g *SimPin) SetBias(bias Bias)(){
+	g.bias = bias
+	return
+}
+
+// Records the unexport of the pin.
+func (g *SimPin) Unexport() {
+	g.StopWatch()
+	g.journal.record(g.pin,OP_UNEXPORT,g.value)
+	return
+}
+
+// Sets and records the value for a simulated pin. This method is only applicable
+// to OUT direction pins and as no effect if Pin.mode is INPUT.
+// @param val if true the pin is set to mode HIGH else to mode LOW
+func (g *SimPin) SetValue(val bool){
+	if g.mode == INPUT || g.value == val {
+		return
+	}
+	g.value = val
+	g.journal.record(g.pin,OP_SET,g.value)
+	return
+}
+
+// Returns the current value of the pin. For INPUT pins the value set by SetInput
+// or ScriptInput is returned.
+// @return true if signal at pin is HIGH, false if signal is LOW
+func (g *SimPin) GetValue()(val bool){
+	if g.mode == OUTPUT {
+		return g.value
+	}
+	simInputsLock.RLock()
+	g.value = simInputs[g.pin]
+	simInputsLock.RUnlock()
+	return g.value
+}
+
+// Watches the simulated pin for transitions caused by SetInput or ScriptInput.
+// Only applicable to INPUT pins.
+// @param edge transitions that should be delivered
+// @param period debounce period, changes shorter than period are ignored
+// @return channel through which the debounced events are delivered, nil if the pin can not be watched
+func (g *SimPin) WatchEdge(edge Edge, period time.Duration)(<-chan Event){
+	g.StopWatch()
+	if g.mode != INPUT || edge == EDGE_NONE {
+		return nil
+	}
+
+	raw := make(chan Event,EVENT_BUFFER_SIZE)
+	out := make(chan Event,EVENT_BUFFER_SIZE)
+	go debounce(raw,out,edge,period,g.GetValue())
+
+	simInputsLock.Lock()
+	simWatchers[g.pin] = append(simWatchers[g.pin],raw)
+	simInputsLock.Unlock()
+
+	g.stopWatch = func(){
+		simInputsLock.Lock()
+		watchers := make([]chan Event,0)
+		for _,w := range simWatchers[g.pin] {
+			if w != raw {
+				watchers = append(watchers,w)
+			}
+		}
+		simWatchers[g.pin] = watchers
+		close(raw)
+		simInputsLock.Unlock()
+	}
+	return out
+}
+
+// Stops watching the pin, the event channel is closed afterwards.
+func (g *SimPin) StopWatch()(){
+	if g.stopWatch != nil {
+		g.stopWatch()
+		g.stopWatch = nil
+	}
+}
+
+// Getter for the GPIO board id of the pin
+func (g *SimPin) GetGpioId()(GpioId){
+	return g.pin
+}
@@ -0,0 +1,66 @@
+package gpio
+
+import(
+	"testing"
+	"time"
+)
+
+func TestSimPinJournal(t *testing.T){
+	SimulationJournal.Reset()
+
+	p := NewSimPin()
+	p.PinMode(GPIO18,OUTPUT,true)
+	p.SetValue(true)
+	p.SetValue(true) // no transition, must not be recorded
+	p.SetValue(false)
+	p.Unexport()
+
+	var expected = []struct{
+		op JournalOp
+		value bool
+	} {
+		{OP_EXPORT,false},
+		{OP_SET,true},
+		{OP_SET,false},
+		{OP_UNEXPORT,false},
+	}
+
+	entries := SimulationJournal.Filter(GPIO18)
+	if len(entries) != len(expected) {
+		t.Fatal("For","journal","expected",len(expected),"entries","got",entries)
+	}
+	for i,e := range expected {
+		if entries[i].Op != e.op || entries[i].Value != e.value {
+			t.Error("For entry",i,"expected",e,"got",entries[i])
+		}
+	}
+	if i,_ := SimulationJournal.Find(2,GPIO18,OP_SET,true); i != -1 {
+		t.Error("For","Find from 2","expected",-1,"got",i)
+	}
+}
+
+func TestSimPinScriptedInput(t *testing.T){
+	SimulationJournal.Reset()
+	SetInput(GPIO6,false)
+
+	p := NewSimPin()
+	p.PinMode(GPIO6,INPUT,true)
+	p.SetValue(true) // input pins can not be set
+	if p.GetValue() {
+		t.Error("For","initial input","expected",false,"got",true)
+	}
+
+	<-ScriptInput(GPIO6,
+		InputStep{After:time.Millisecond,Value:true},
+		InputStep{After:10*time.Millisecond,Value:false},
+	)
+	if p.GetValue() {
+		t.Error("For","scripted input","expected",false,"got",true)
+	}
+
+	i,pressed := SimulationJournal.Find(0,GPIO6,OP_INPUT,true)
+	_,released := SimulationJournal.Find(i,GPIO6,OP_INPUT,false)
+	if i < 0 || released.Time.Sub(pressed.Time) < 10*time.Millisecond {
+		t.Error("For","script timing","expected",10*time.Millisecond,"got",released.Time.Sub(pressed.Time))
+	}
+}
@@ -0,0 +1,245 @@
+package gpio
+
+import(
+	"os"
+	"os/exec"
+	"log"
+	"strings"
+	"strconv"
+)
+
+var(
+	EXPORT_FILE = "/sys/class/gpio/export"
+	UNEXPORT_FILE = "/sys/class/gpio/unexport"
+	PATH_PREFIX = "/sys/class/gpio/gpio"
+)
+
+// struct representing a gpio pin that is accessed via the sysfs interface
+type SysfsPin struct {
+	pin GpioId
+	mode PinMode
+	value bool
+	activeLow bool
+	path string
+	stopWatch func()()	// terminates the running edge watch, nil if the pin is not watched
+}
+
+// Constructor method for the SysfsPin struct
+// mode set to INPUT by default
+// value set to FALSE by default
+// @return pointer to SysfsPin struct
+func NewSysfsPin()(p *SysfsPin){
+	p = &SysfsPin{mode:INPUT,value:false}
+	return
+}
+
+// Configures the internal fields of a SysfsPin struct and calls the export function,
+// which introduces the pin to the os with the given configuration.
+// @param: pin the Pin's id on the raspberry board
+// @param: mode weather read or write access is required
+// @param: activeLow (optional) true if the pin should be run in active_low mode (raspberry default is false)
+func (g *SysfsPin) PinMode(pin GpioId, mode PinMode, activeLow ...bool){
+	g.pin = pin
+	g.mode = mode
+
+	s := []string{PATH_PREFIX,strconv.Itoa(int(g.pin)),"/"}
+    	g.path = strings.Join(s,"")
+
+	if len(activeLow) > 0 {
+		g.activeLow = activeLow[0]
+	}
+
+	g.export()
+	return
+}
+
+// Introduces the pin configuration to the raspberry using the WiringPi framework
+// @internal: method is in ALPHA mode. WiringPi framework must be installed.
+func (g *SysfsPin) exportWiringPi() {
+	var cmd *exec.Cmd
+	switch g.mode {
+		case OUTPUT:
+			cmd = exec.Command("gpio", "export", strconv.Itoa(int(g.pin)), "out")
+		default:
+			cmd = exec.Command("gpio", "export", strconv.Itoa(int(g.pin)), "in")
+	}
+	err := cmd.Run()
+	if err != nil {
+		log.Fatal(err) //os.Exit(1)
+	}
+
+	g.setActiveLowConfig()
+
+	return
+}
+
+// Introduces the pin's configuration to the raspberry via the configuration files.
+// If the pin's direction is OUT, the initial value is set to LOW.
+func (g *SysfsPin) export() {
+	info,_ := os.Stat(g.path)
+	if info == nil {
+		file,err := os.OpenFile(EXPORT_FILE,os.O_WRONLY,os.ModeExclusive)
+		defer file.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		file.WriteString(strconv.Itoa(int(g.pin)))
+	}
+
+	g.setActiveLowConfig()
+	g.setDirectionConfig()
+	g.SetValue(false)
+
+	return
+}
+
+// Unexports the pin which deactivates it for further use.
+func (g *SysfsPin) Unexport() {
+	g.StopWatch()
+	file,err := os.OpenFile(UNEXPORT_FILE,os.O_WRONLY,os.ModeExclusive)
+	defer file.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+	file.WriteString(strconv.Itoa(int(g.pin)))
+
+	return
+}
+
+// Sets the value for a gpio pin. This method is only applicable
+// to OUT direction pins and as no effect if Pin.mode is INPUT.
+// The method sets the associated gpio value at the raspberry via file
+// access to the corresponding value file. The function is active_low safe.
+// @param val if true the pin is set to mode HIGH else to mode LOW
+func (g *SysfsPin) SetValue(val bool){
+	if g.mode == INPUT || g.value == val {
+		return
+	}
+	g.value = val
+	setTo := "0"
+	if valueLevel(g.value,g.activeLow) {
+		setTo = "1"
+	}
+
+	attempts := 0
+	for success:=false; success != true; {
+		file,err := os.OpenFile(g.path+VALUE_FILE_NAME,os.O_WRONLY,os.ModeExclusive)
+		defer file.Close()
+		attempts++
+		if err != nil {
+			if attempts > FILE_ACCESS_BOUND {
+				log.Fatal(err)
+			}
+		} else {
+			file.WriteString(setTo)
+			success = true
+		}
+	}
+	return
+}
+
+// Reads and returns the current value from any kind of pins. If the pin's direction
+// is set to OUT the internal value is returned directly. For INPUT pins the actual
+// value is read from the file each time the function is called.
+// This function is active_low safe which means, if the returned value is TRUE the pin's
+// physical signal is HIGH.
+// @return true if signal at pin is HIGH, false if signal is LOW
+func (g *SysfsPin) GetValue()(val bool){
+	if g.mode == OUTPUT {
+		return g.value
+	}
+	buf := make([]byte,1,1)
+	attempts := 0
+	for success:=false; success != true; {
+		file,err := os.OpenFile(g.path+VALUE_FILE_NAME,os.O_RDONLY,os.ModeTemporary)
+		defer file.Close()
+		attempts++
+		if err != nil {
+			if attempts > FILE_ACCESS_BOUND {
+				log.Fatal(err)
+			}
+		} else {
+			file.Read(buf)
+			success = true
+		}
+	}
+	file_val,_ := strconv.Atoi(string(buf[0]))
+	val = levelValue(file_val == 1,g.activeLow)
+
+	g.value = val
+	return val
+}
+
+// The sysfs interface provides no access to the bias configuration of a line,
+// thus the bias is left as configured by the device tree.
+func (g *SysfsPin) SetBias(bias Bias)(){
+	return
+}
+
+// Stops watching the pin, the event channel is closed afterwards.
+func (g *SysfsPin) StopWatch()(){
+	if g.stopWatch != nil {
+		g.stopWatch()
+		g.stopWatch = nil
+	}
+}
+
+// Getter for the GPIO board id of the pin
+func (g *SysfsPin) GetGpioId()(GpioId){
+	return g.pin
+}
+
+// Configures the pin's direction value at the raspberry according to the internal mode value
+// of the Pin struct.
+func (g *SysfsPin) setDirectionConfig(){
+	var setTo string
+	switch g.mode {
+		case OUTPUT:
+			setTo = OUT
+		default:
+			setTo = IN
+	}
+
+	attempts := 0
+	for success:=false; success != true; {
+		file, err := os.OpenFile(g.path + DIRECTION_FILE_NAME, os.O_WRONLY, os.ModeExclusive)
+		defer file.Close()
+		attempts++
+		if err != nil {
+			if attempts > FILE_ACCESS_BOUND {
+				log.Fatal(err)
+			}
+		} else {
+			file.WriteString(setTo)
+			success = true
+		}
+	}
+
+	return
+}
+
+// Configures the pin's active_low value at the raspberry according to the internal value
+// of the Pin struct.
+func (g *SysfsPin) setActiveLowConfig(){
+	var setTo string
+	if g.activeLow {
+		setTo = ALTRUE
+	} else {
+		setTo = ALFALSE
+	}
+	attempts := 0
+	for success:=false; success != true; {
+		file,err := os.OpenFile(g.path + ACTIVE_LOW_FILE_NAME,os.O_WRONLY,os.ModeExclusive)
+		defer file.Close()
+		attempts++
+		if err != nil {
+			if attempts > FILE_ACCESS_BOUND {
+				log.Fatal(err)
+			}
+		} else {
+			file.WriteString(setTo)
+			success = true
+		}
+	}
+	return
+}
@@ -0,0 +1,447 @@
+// The hardware package describes the physical setup of the heating system: the relays
+// and inputs connected to the gpio pins, the pumps driven by these relays and the w1
+// temperature sensors with their logical role. The topology is read from a TOML file
+// and validated before the system components are built from it.
+package hardware
+
+import(
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"github.com/hansen1101/go_heating/auxiliary/toml"
+	"github.com/hansen1101/go_heating/system/gpio"
+	"github.com/hansen1101/go_heating/system/w1"
+)
+
+// Logical sensor roles that have a typed accessor in the percepts, a sensor may
+// have any other role as well
+const(
+	ROLE_OUTSIDE = "OUTSIDE"	// outside temperature
+	ROLE_TWO = "TWO"		// boiler top
+	ROLE_TPO = "TPO"		// boiler mid
+	ROLE_TPU = "TPU"		// boiler bottom
+	ROLE_KETTLE = "Kettle"		// kettle
+	ROLE_H_FOR = "H_for"		// fore run of the radiator circuit
+	ROLE_H_REV = "H_rev"		// reverse run of the radiator circuit
+	ROLE_W_REV = "W_rev"		// reverse run of the boiler circuit
+	ROLE_ROOM = "Room"		// room temperature
+
+	MAX_ROLE_LENGTH = 32		// roles are logged as logic of the percept readings
+
+		MODBUS_TCP_PREFIX = "tcp://"
+	MODBUS_REGISTERS = 5		// control, set-point, frequency, current, fault
+	MAX_MODBUS_UNIT = 247
+
+	MIN_PIN = int(gpio.GPIO2)
+	MAX_PIN = int(gpio.GPIO27)
+
+	// kinds of actuators a relay can be
+	ACTUATOR_RELAY = "relay"	// binary component, e.g. the burner
+	ACTUATOR_VALVE = "valve"	// three-way valve
+)
+
+var(
+	Roles = []string{ROLE_OUTSIDE,ROLE_TWO,ROLE_TPO,ROLE_TPU,ROLE_KETTLE,ROLE_H_FOR,ROLE_H_REV,ROLE_W_REV,ROLE_ROOM}
+	sensorIdPattern = regexp.MustCompile("^28-[0-9a-f]{12}$")
+	biasNames = map[string]gpio.Bias{
+		"":gpio.BIAS_AS_IS,
+		"as-is":gpio.BIAS_AS_IS,
+		"disabled":gpio.BIAS_DISABLED,
+		"pull-up":gpio.BIAS_PULL_UP,
+		"pull-down":gpio.BIAS_PULL_DOWN,
+	}
+	parityNames = map[string]bool{"":true,"none":true,"even":true,"odd":true}
+)
+
+// An output pin, usually a channel of the relay board. A relay that switches a component on
+// its own is an actuator (relay or valve), Settle is the time the component needs after it
+// was switched on (a valve: moved).
+type Relay struct {
+	Name string `toml:"name"`
+	Pin int `toml:"pin"`
+	ActiveLow bool `toml:"active_low"`
+	Actuator string `toml:"actuator,omitempty"`
+	Settle time.Duration `toml:"settle,omitempty"`
+}
+
+// An input pin like a push button
+type Input struct {
+	Name string `toml:"name"`
+	Pin int `toml:"pin"`
+	ActiveLow bool `toml:"active_low"`
+	Bias string `toml:"bias,omitempty"`
+}
+
+// A pump behind a frequency converter, power/inc/dec name the relays that switch
+// the pump and increase or decrease its frequency. Feedback optionally names the analog
+// input (e.g. an IIO channel) of the measured frequency: FeedbackScale * raw + FeedbackOffset
+// Hz, Tolerance is the max deviation from the estimated frequency.
+// A converter that speaks modbus is commanded without relays: Modbus is the serial device
+// (e.g. /dev/ttyUSB0) or tcp://host[:port] of the converter, ModbusRegisters optionally
+// lists the addresses of its control, set-point, frequency, current and fault registers and
+// ModbusScale the Hz per unit of set-point and frequency.
+type Pump struct {
+	Name string `toml:"name"`
+	Max float64 `toml:"max"`
+	Min float64 `toml:"min"`
+	Acceleration float64 `toml:"acceleration"`
+	Delta float64 `toml:"delta"`
+	Power string `toml:"power"`
+	Inc string `toml:"inc"`
+	Dec string `toml:"dec"`
+	Feedback string `toml:"feedback,omitempty"`
+	FeedbackScale float64 `toml:"feedback_scale,omitempty"`
+	FeedbackOffset float64 `toml:"feedback_offset,omitempty"`
+	Tolerance float64 `toml:"tolerance,omitempty"`
+	Modbus string `toml:"modbus,omitempty"`
+	ModbusUnit int `toml:"modbus_unit,omitempty"`
+	ModbusBaud int `toml:"modbus_baud,omitempty"`
+	ModbusParity string `toml:"modbus_parity,omitempty"`
+	ModbusRegisters []int `toml:"modbus_registers,omitempty"`
+	ModbusScale float64 `toml:"modbus_scale,omitempty"`
+}
+
+// A w1 temperature sensor and the logical role of its measurement. Offset (millidegree)
+// and gain correct the raw sensor value, see w1.Calibration. Related lists the roles whose
+// changes are expected to change the value of the sensor as well, only then the sensor is
+// checked for being stuck (see w1.SetRelatedSensors).
+type Sensor struct {
+	Id string `toml:"id"`
+	Role string `toml:"role"`
+	Comment string `toml:"comment,omitempty"`
+	Offset int `toml:"offset,omitempty"`
+	Gain float64 `toml:"gain,omitempty"`
+	Related []string `toml:"related,omitempty"`
+}
+
+// The hardware topology of the heating system
+type Topology struct {
+	Relays []Relay `toml:"relay"`
+	Inputs []Input `toml:"input"`
+	Pumps []Pump `toml:"pump"`
+	Sensors []Sensor `toml:"sensor"`
+}
+
+// Error returned by Validate, lists all problems found in the topology
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error()(string){
+	return "invalid hardware topology:\n\t"+strings.Join(e.Problems,"\n\t")
+}
+
+// Reads and validates the topology file at path, there must be a sensor for each of
+// the required roles
+// @return the topology or an error describing why the file can not be used
+func Load(path string, required ...string)(t *Topology, err error){
+	if t,err = Read(path); err != nil {
+		return nil, err
+	}
+	if err = t.Validate(required...); err != nil {
+		return nil, fmt.Errorf("%s: %v",path,err)
+	}
+	return
+}
+
+// Reads the topology file at path without validating it, e.g. in order to complete
+// an unfinished setup
+func Read(path string)(t *Topology, err error){
+	var data []byte
+	if data,err = ioutil.ReadFile(path); err != nil {
+		return nil, err
+	}
+	t = &Topology{}
+	if err = toml.Unmarshal(data,t); err != nil {
+		return nil, fmt.Errorf("%s: %v",path,err)
+	}
+	return
+}
+
+// Writes the topology to the file at path
+func (t *Topology) Save(path string)(error){
+	data,err := toml.Marshal(t)
+	if err != nil {
+		return err
+	}
+	header := "# hardware topology of the heating system\n"
+	return ioutil.WriteFile(path,append([]byte(header),data...),0644)
+}
+
+// Checks the topology for consistency: unique names and pins, existing relay
+// references, sane pump parameters and a sensor for every required role.
+// @return nil or a ValidationError listing all problems
+func (t *Topology) Validate(required ...string)(error){
+	problems := make([]string,0)
+	report := func(format string, a ...interface{}){
+		problems = append(problems,fmt.Sprintf(format,a...))
+	}
+
+	names := make(map[string]bool)
+	pins := make(map[int]string)
+	checkPin := func(kind, name string, pin int){
+		if name == "" {
+			report("%s with pin %d has no name",kind,pin)
+		} else if names[name] {
+			report("%s %s: name is used twice",kind,name)
+		}
+		names[name] = true
+		if pin < MIN_PIN || pin > MAX_PIN {
+			report("%s %s: pin %d is not in range %d-%d",kind,name,pin,MIN_PIN,MAX_PIN)
+		} else if other,used := pins[pin]; used {
+			report("%s %s: pin %d is already used by %s",kind,name,pin,other)
+		}
+		pins[pin] = name
+	}
+	relays := make(map[string]bool)
+	for _,r := range t.Relays {
+		checkPin("relay",r.Name,r.Pin)
+		relays[r.Name] = true
+		if r.Actuator != "" && r.Actuator != ACTUATOR_RELAY && r.Actuator != ACTUATOR_VALVE {
+			report("relay %s: unknown actuator %q (%s, %s)",r.Name,r.Actuator,ACTUATOR_RELAY,ACTUATOR_VALVE)
+		}
+		if r.Settle < 0 {
+			report("relay %s: settle time must not be negative",r.Name)
+		}
+	}
+	for _,i := range t.Inputs {
+		checkPin("input",i.Name,i.Pin)
+		if _,ok := biasNames[i.Bias]; !ok {
+			report("input %s: unknown bias %q (as-is, disabled, pull-up, pull-down)",i.Name,i.Bias)
+		}
+	}
+
+	pumps := make(map[string]bool)
+	for _,p := range t.Pumps {
+		if p.Name == "" || pumps[p.Name] {
+			report("pump %q: name is empty or used twice",p.Name)
+		}
+		pumps[p.Name] = true
+		if p.Min <= 0 || p.Min > p.Max {
+			report("pump %s: frequency range %.1f-%.1f is invalid",p.Name,p.Min,p.Max)
+		}
+		if p.Acceleration <= 0 {
+			report("pump %s: acceleration must be positive",p.Name)
+		}
+		if p.Delta < 0 {
+			report("pump %s: delta must not be negative",p.Name)
+		}
+		if p.Feedback != "" && p.FeedbackScale == 0 {
+			report("pump %s: feedback %s has no feedback_scale",p.Name,p.Feedback)
+		}
+		if p.Tolerance < 0 {
+			report("pump %s: tolerance must not be negative",p.Name)
+		}
+		if p.IsModbus() {
+			for _,problem := range p.validateModbus() {
+				report("pump %s: %s",p.Name,problem)
+			}
+			continue
+		}
+		used := make(map[string]bool)
+		for _,ref := range []struct{ kind, name string }{{"power",p.Power},{"inc",p.Inc},{"dec",p.Dec}} {
+			if !relays[ref.name] {
+				report("pump %s: %s relay %q does not exist",p.Name,ref.kind,ref.name)
+			} else if r,_ := t.Relay(ref.name); r.Actuator != "" {
+				report("pump %s: %s relay %s is an actuator itself",p.Name,ref.kind,ref.name)
+			} else if used[ref.name] {
+				report("pump %s: relay %s is used twice",p.Name,ref.name)
+			}
+			used[ref.name] = true
+		}
+	}
+
+	roles := make(map[string]string)
+	calibrations := make(map[string]w1.Calibration)
+	for _,s := range t.Sensors {
+		if c,ok := calibrations[s.Id]; ok && c != s.Calibration() {
+			report("sensor %s: calibration differs between its roles",s.Id)
+		}
+		calibrations[s.Id] = s.Calibration()
+		if s.Gain < 0 {
+			report("sensor %s: gain must not be negative",s.Id)
+		}
+		if !sensorIdPattern.MatchString(s.Id) {
+			report("sensor %q: id is not a DS18B20 id (28-xxxxxxxxxxxx)",s.Id)
+		}
+		if strings.TrimSpace(s.Role) == "" {
+			report("sensor %s: role is empty",s.Id)
+		} else if len(s.Role) > MAX_ROLE_LENGTH {
+			report("sensor %s: role %q is longer than %d characters",s.Id,s.Role,MAX_ROLE_LENGTH)
+		} else if other,ok := roles[s.Role]; ok {
+			report("sensor %s: role %s is already assigned to %s",s.Id,s.Role,other)
+		}
+		roles[s.Role] = s.Id
+	}
+	for _,role := range required {
+		if _,ok := roles[role]; !ok {
+			report("no sensor for role %s",role)
+		}
+	}
+	for _,s := range t.Sensors {
+		for _,role := range s.Related {
+			if _,ok := roles[role]; !ok || role == s.Role {
+				report("sensor %s: related role %q is not another configured role",s.Id,role)
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{problems}
+	}
+	return nil
+}
+
+// Returns the problems of the modbus settings of the pump
+func (p Pump) validateModbus()(problems []string){
+	if p.Power != "" || p.Inc != "" || p.Dec != "" {
+		problems = append(problems,"a modbus pump is switched without power/inc/dec relays")
+	}
+	if p.Feedback != "" {
+		problems = append(problems,"the frequency of a modbus pump is read from the converter, feedback is not supported")
+	}
+	serial := !strings.HasPrefix(p.Modbus,MODBUS_TCP_PREFIX)
+	if p.ModbusUnit < 0 || p.ModbusUnit > MAX_MODBUS_UNIT || (serial && p.ModbusUnit == 0) {
+		problems = append(problems,fmt.Sprintf("modbus_unit %d is not in range 1-%d",p.ModbusUnit,MAX_MODBUS_UNIT))
+	}
+	if p.ModbusBaud < 0 || !parityNames[p.ModbusParity] {
+		problems = append(problems,fmt.Sprintf("invalid serial settings (baud %d, parity %q)",p.ModbusBaud,p.ModbusParity))
+	}
+	if len(p.ModbusRegisters) != 0 && len(p.ModbusRegisters) != MODBUS_REGISTERS {
+		problems = append(problems,fmt.Sprintf("modbus_registers lists %d addresses (control, setpoint, frequency, current, fault)",len(p.ModbusRegisters)))
+	}
+	for _,address := range p.ModbusRegisters {
+		if address < 0 || address > 0xFFFF {
+			problems = append(problems,fmt.Sprintf("register address %d is not in range 0-65535",address))
+		}
+	}
+	if p.ModbusScale < 0 {
+		problems = append(problems,"modbus_scale must not be negative")
+	}
+	return
+}
+
+// Checks whether the pump is commanded via modbus
+func (p Pump) IsModbus()(bool){
+	return p.Modbus != ""
+}
+
+// Checks whether role is one of the logical sensor roles known to the system
+func IsRole(role string)(bool){
+	for _,r := range Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the relay with the given name
+func (t *Topology) Relay(name string)(Relay, error){
+	for _,r := range t.Relays {
+		if r.Name == name {
+			return r, nil
+		}
+	}
+	return Relay{}, fmt.Errorf("hardware topology: relay %s is not configured",name)
+}
+
+// Returns the input with the given name
+func (t *Topology) Input(name string)(Input, error){
+	for _,i := range t.Inputs {
+		if i.Name == name {
+			return i, nil
+		}
+	}
+	return Input{}, fmt.Errorf("hardware topology: input %s is not configured",name)
+}
+
+// Returns the pump with the given name
+func (t *Topology) Pump(name string)(Pump, error){
+	for _,p := range t.Pumps {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return Pump{}, fmt.Errorf("hardware topology: pump %s is not configured",name)
+}
+
+// Returns the mapping of logical sensor roles to sensor ids (e.g. 'Kettle' => '28-00000123456')
+func (t *Topology) SensorIds()(ids map[string]string){
+	ids = make(map[string]string,len(t.Sensors))
+	for _,s := range t.Sensors {
+		ids[s.Role] = s.Id
+	}
+	return
+}
+
+// Returns the ids of all configured sensors in ascending order, sensors serving
+// several roles are listed once
+func (t *Topology) SensorIdList()(ids []string){
+	unique := make(map[string]bool)
+	ids = make([]string,0)
+	for _,s := range t.Sensors {
+		if !unique[s.Id] {
+			unique[s.Id] = true
+			ids = append(ids,s.Id)
+		}
+	}
+	sort.Strings(ids)
+	return
+}
+
+// Returns the calibrations of all sensors by sensor id
+func (t *Topology) Calibrations()(calibrations map[string]w1.Calibration){
+	calibrations = make(map[string]w1.Calibration,len(t.Sensors))
+	for _,s := range t.Sensors {
+		calibrations[s.Id] = s.Calibration()
+	}
+	return
+}
+
+// Returns the ids of the related sensors of all sensors with related roles by sensor id
+func (t *Topology) RelatedSensors()(related map[string][]string){
+	ids := t.SensorIds()
+	related = make(map[string][]string)
+	for _,s := range t.Sensors {
+		for _,role := range s.Related {
+			if id,ok := ids[role]; ok && id != s.Id {
+				related[s.Id] = append(related[s.Id],id)
+			}
+		}
+	}
+	return
+}
+
+// Sets the calibration of all entries of the sensor with the given id
+func (t *Topology) SetCalibration(id string, c w1.Calibration)(){
+	for i := range t.Sensors {
+		if t.Sensors[i].Id == id {
+			t.Sensors[i].Offset = c.Offset
+			t.Sensors[i].Gain = c.Gain
+		}
+	}
+}
+
+// Getter for the calibration of the sensor
+func (s Sensor) Calibration()(w1.Calibration){
+	return w1.Calibration{Offset:s.Offset,Gain:s.Gain}
+}
+
+// Getter for the gpio id of the relay
+func (r Relay) GpioId()(gpio.GpioId){
+	return gpio.GpioId(r.Pin)
+}
+
+// Getter for the gpio id of the input
+func (i Input) GpioId()(gpio.GpioId){
+	return gpio.GpioId(i.Pin)
+}
+
+// Getter for the bias of the input
+func (i Input) GetBias()(gpio.Bias){
+	return biasNames[i.Bias]
+}
@@ -0,0 +1,122 @@
+package hardware
+
+import(
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const(
+	TOPOLOGY_FILE = "../../filesystem/heating_config/hardware.toml"
+)
+
+func TestLoad(t *testing.T){
+	topology,err := Load(TOPOLOGY_FILE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r,err := topology.Relay("burner"); err != nil || r.Pin != 18 || !r.ActiveLow {
+		t.Error("For","burner","expected","pin 18 active low","got",r,err)
+	}
+	if p,err := topology.Pump("boiler"); err != nil || p.Min != 15.0 || p.Power != "boiler_pump_on" {
+		t.Error("For","boiler pump","expected","min 15 on boiler_pump_on","got",p,err)
+	}
+	ids := topology.SensorIds()
+	if ids[ROLE_KETTLE] != "28-000007c5cf02" {
+		t.Error("For","kettle sensor","expected","28-000007c5cf02","got",ids[ROLE_KETTLE])
+	}
+	if n := len(topology.SensorIdList()); n != 8 {
+		t.Error("For","unique sensors","expected",8,"got",n)
+	}
+	// only the radiator reverse run is checked for being stuck, against the fore run
+	related := topology.RelatedSensors()
+	if len(related) != 1 || len(related[ids[ROLE_H_REV]]) != 1 || related[ids[ROLE_H_REV]][0] != ids[ROLE_H_FOR] {
+		t.Error("For","related sensors","expected",ids[ROLE_H_FOR],"got",related)
+	}
+}
+
+func TestSaveLoad(t *testing.T){
+	topology,err := Load(TOPOLOGY_FILE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir,err := ioutil.TempDir("","hardware")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir,"hardware.toml")
+	if err = topology.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	saved,err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.Relays) != len(topology.Relays) || len(saved.Sensors) != len(topology.Sensors) || !reflect.DeepEqual(saved.Pumps[1],topology.Pumps[1]) {
+		t.Error("For","saved topology","expected",topology,"got",saved)
+	}
+}
+
+func TestValidate(t *testing.T){
+	topology,err := Load(TOPOLOGY_FILE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	topology.Relays[1].Pin = topology.Relays[0].Pin
+	topology.Inputs[0].Bias = "floating"
+	topology.Pumps[0].Inc = "missing"
+	topology.Pumps[1].Feedback = "/sys/bus/iio/devices/iio:device0/in_voltage0_raw"
+	topology.Pumps[1].Tolerance = -1
+	topology.Pumps = append(topology.Pumps,Pump{Name:"mixer_pump",Max:50,Min:15,Acceleration:0.01,Modbus:"/dev/ttyUSB0",Inc:"boiler_pump_inc",ModbusRegisters:[]int{0,1}})
+	topology.Sensors = topology.Sensors[1:]
+	topology.Sensors[0].Role = ""
+	topology.Sensors[1].Related = []string{"Attic"}
+	topology.Sensors[2].Role = strings.Repeat("x",MAX_ROLE_LENGTH+1)
+
+	err = topology.Validate(ROLE_OUTSIDE,ROLE_KETTLE)
+	v,ok := err.(*ValidationError)
+	if !ok {
+		t.Fatal("For","invalid topology","expected","ValidationError","got",err)
+	}
+	for _,problem := range []string{"already used","unknown bias","does not exist","no feedback_scale","tolerance must not be negative","without power/inc/dec relays","modbus_unit 0","lists 2 addresses","no sensor for role OUTSIDE","role is empty","related role \"Attic\"","longer than 32"} {
+		if !strings.Contains(v.Error(),problem) {
+			t.Error("For",problem,"expected","reported","got",v.Problems)
+		}
+	}
+}
+
+func TestValidateRoles(t *testing.T){
+	topology,err := Load(TOPOLOGY_FILE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// any role is accepted, only the required ones must be present
+	topology.Sensors = append(topology.Sensors[:len(topology.Sensors)-1],Sensor{Id:"28-0000075d9c18",Role:"Attic"})
+	if err = topology.Validate(ROLE_KETTLE,ROLE_TWO); err != nil {
+		t.Error("For","custom role without room sensor","expected",nil,"got",err)
+	}
+	if err = topology.Validate(ROLE_ROOM); err == nil || !strings.Contains(err.Error(),"no sensor for role Room") {
+		t.Error("For","required room sensor","expected","no sensor for role Room","got",err)
+	}
+}
+
+func TestValidateModbus(t *testing.T){
+	topology,err := Load(TOPOLOGY_FILE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	topology.Pumps = append(topology.Pumps,
+		Pump{Name:"mixer_pump",Max:50,Min:15,Acceleration:0.01,Modbus:"/dev/ttyUSB0",ModbusUnit:2,ModbusParity:"none",ModbusRegisters:[]int{8192,8193,12289,12290,32768}},
+		Pump{Name:"solar_pump",Max:50,Min:15,Acceleration:0.01,Modbus:"tcp://192.168.1.20"})
+	if err = topology.Validate(); err != nil {
+		t.Error("For","modbus pumps","expected",nil,"got",err)
+	}
+	if p,_ := topology.Pump("solar_pump"); !p.IsModbus() {
+		t.Error("For",p.Name,"expected","modbus pump","got",p)
+	}
+}
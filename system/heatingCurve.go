@@ -0,0 +1,288 @@
+package system
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/hansen1101/go_heating/auxiliary/toml"
+)
+
+const (
+	COLDEST_OUTSIDE = -100000	// outside temperature assumed if the sensor is missing, curves clamp to their coldest target
+	CURVE_HOURS = 24
+)
+
+// A HeatingCurve maps the outside temperature and the time of day to a boiler target,
+// temperatures in m°C. A target of 0 switches the heating off.
+type HeatingCurve interface {
+	Target(outside int, at time.Time)(int)
+}
+
+// Issues of a configuration table, the table remains usable: missing rows are interpolated,
+// invalid rows are dropped
+type CurveReport struct {
+	Missing []int		// temperatures in °C without a row between the coldest and the warmest row
+	NonMonotonic []string	// targets that rise with the outside temperature
+	Invalid []string	// rows that can not be read
+}
+
+func (r CurveReport) IsEmpty()(bool){
+	return len(r.Missing) == 0 && len(r.NonMonotonic) == 0 && len(r.Invalid) == 0
+}
+
+func (r CurveReport) String()(string){
+	var buffer bytes.Buffer
+	if len(r.Missing) > 0 {
+		buffer.WriteString(fmt.Sprintf("missing rows (interpolated): %v\n",r.Missing))
+	}
+	for _,s := range r.NonMonotonic {
+		buffer.WriteString(fmt.Sprintf("non-monotonic: %s\n",s))
+	}
+	for _,s := range r.Invalid {
+		buffer.WriteString(fmt.Sprintf("invalid: %s\n",s))
+	}
+	return buffer.String()
+}
+
+/**
+ * Configuration table of boiler targets by outside temperature (rows in whole °C) and hour of
+ * the day (columns). Targets are interpolated bilinearly between the adjacent rows and hours
+ * and clamped at the coldest and warmest row. Next to a target of 0 (heating off) the target
+ * of the nearest cell is used, thus the heating is never switched on at a fraction of a target.
+ * implements HeatingCurve interface
+ */
+type CurveTable struct {
+	temperatures []int	// row keys in °C ascending
+	targets [][]int		// targets[row][hour] in m°C
+	report CurveReport
+}
+
+// Reads a configuration table from a csv file: a header row followed by one row per outside
+// temperature with the temperature in °C and 24 targets in m°C
+// @return error if the file can not be read or holds no valid row
+func LoadCurveTable(path string)(t *CurveTable, err error){
+	file,err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	return ParseCurveTable(file)
+}
+
+// Parses a configuration table, see LoadCurveTable
+func ParseCurveTable(r io.Reader)(t *CurveTable, err error){
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	records,err := reader.ReadAll()
+	if err != nil {
+		return
+	}
+	t = &CurveTable{}
+	rows := make(map[int][]int)
+	for row_id,record := range records {
+		if row_id == 0 {
+			// header
+			continue
+		}
+		if len(record) != CURVE_HOURS + 1 {
+			t.report.Invalid = append(t.report.Invalid,fmt.Sprintf("row %d has %d instead of %d columns",row_id+1,len(record),CURVE_HOURS+1))
+			continue
+		}
+		key,err := strconv.Atoi(strings.TrimSpace(record[0]))
+		if err != nil {
+			t.report.Invalid = append(t.report.Invalid,fmt.Sprintf("row %d: temperature %q",row_id+1,record[0]))
+			continue
+		}
+		targets := make([]int,CURVE_HOURS)
+		for hour := range targets {
+			if targets[hour],err = strconv.Atoi(strings.TrimSpace(record[hour+1])); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			t.report.Invalid = append(t.report.Invalid,fmt.Sprintf("row %d (%d °C): target %v",row_id+1,key,err))
+			continue
+		}
+		if _,duplicate := rows[key]; duplicate {
+			t.report.Invalid = append(t.report.Invalid,fmt.Sprintf("row %d: %d °C is configured twice, the last row is used",row_id+1,key))
+		}
+		rows[key] = targets
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("configuration has no valid row")
+	}
+	t.setRows(rows)
+	return
+}
+
+// Sorts the rows (targets by temperature in °C) into the table and reports missing rows and
+// targets that rise with the outside temperature
+func (t *CurveTable) setRows(rows map[int][]int)(){
+	for key := range rows {
+		t.temperatures = append(t.temperatures,key)
+	}
+	sort.Ints(t.temperatures)
+	for i,key := range t.temperatures {
+		t.targets = append(t.targets,rows[key])
+		if i == 0 {
+			continue
+		}
+		colder := t.temperatures[i-1]
+		for missing := colder + 1; missing < key; missing++ {
+			t.report.Missing = append(t.report.Missing,missing)
+		}
+		for hour := 0; hour < CURVE_HOURS; hour++ {
+			if rows[key][hour] > rows[colder][hour] {
+				t.report.NonMonotonic = append(t.report.NonMonotonic,fmt.Sprintf(
+					"%02d:00 %d at %d °C exceeds %d at %d °C",hour,rows[key][hour],key,rows[colder][hour],colder))
+			}
+		}
+	}
+}
+
+// Returns the issues found while reading the table
+func (t *CurveTable) Report()(CurveReport){
+	return t.report
+}
+
+func (t *CurveTable) Target(outside int, at time.Time)(int){
+	// row below the outside temperature and the share of the next row
+	celsius := float64(outside) / 1000
+	row,fx := 0,0.0
+	switch last := len(t.temperatures) - 1; {
+	case celsius <= float64(t.temperatures[0]):
+	case celsius >= float64(t.temperatures[last]):
+		row = last
+	default:
+		row = sort.Search(len(t.temperatures),func(i int)(bool){ return float64(t.temperatures[i]) > celsius }) - 1
+		fx = (celsius - float64(t.temperatures[row])) / float64(t.temperatures[row+1] - t.temperatures[row])
+	}
+	nextRow := row
+	if fx > 0 {
+		nextRow = row + 1
+	}
+
+	// hour of the day and the share of the next hour
+	hour := at.Hour()
+	fy := float64(at.Minute() * 60 + at.Second()) / 3600
+	nextHour := (hour + 1) % CURVE_HOURS
+
+	corners := [2][2]int{
+		{t.targets[row][hour],t.targets[row][nextHour]},
+		{t.targets[nextRow][hour],t.targets[nextRow][nextHour]},
+	}
+	for _,c := range []int{corners[0][0],corners[0][1],corners[1][0],corners[1][1]} {
+		if c == 0 {
+			return corners[int(math.Round(fx))][int(math.Round(fy))]
+		}
+	}
+	target := (1 - fx) * (1 - fy) * float64(corners[0][0]) +
+		(1 - fx) * fy * float64(corners[0][1]) +
+		fx * (1 - fy) * float64(corners[1][0]) +
+		fx * fy * float64(corners[1][1])
+	return int(math.Round(target))
+}
+
+/**
+ * Linear heating curve through the room temperature and the design point (the coldest outside
+ * temperature the heating is designed for and its boiler target), all temperatures in m°C:
+ * target = Room + Shift + Slope * (Room - outside). The target is clamped to Max, targets
+ * below Min switch the heating off.
+ * implements HeatingCurve interface
+ */
+type ParametricCurve struct {
+	Room int `toml:"room"`
+	DesignOutside int `toml:"design_outside"`
+	DesignTarget int `toml:"design_target"`
+	Slope float64 `toml:"slope"`	// overrides the slope of the design point if > 0
+	Shift int `toml:"shift"`		// parallel shift of the curve
+	Min int `toml:"min"`
+	Max int `toml:"max"`			// 0 disables the clamping
+}
+
+// Returns the slope of the curve
+func (c ParametricCurve) GetSlope()(float64){
+	if c.Slope > 0 || c.Room <= c.DesignOutside {
+		return c.Slope
+	}
+	return float64(c.DesignTarget - c.Room) / float64(c.Room - c.DesignOutside)
+}
+
+// Checks the parameters of the curve
+func (c ParametricCurve) Validate()(error){
+	switch {
+	case c.Slope < 0:
+		return fmt.Errorf("slope %g is negative",c.Slope)
+	case c.Slope == 0 && c.Room <= c.DesignOutside:
+		return fmt.Errorf("design outside temperature %d is not below the room temperature %d",c.DesignOutside,c.Room)
+	case c.Slope == 0 && c.DesignTarget <= c.Room:
+		return fmt.Errorf("design target %d is not above the room temperature %d",c.DesignTarget,c.Room)
+	case c.Max != 0 && c.Max < c.Min:
+		return fmt.Errorf("max %d is below min %d",c.Max,c.Min)
+	}
+	return nil
+}
+
+func (c ParametricCurve) Target(outside int, at time.Time)(int){
+	target := int(math.Round(float64(c.Room + c.Shift) + c.GetSlope() * float64(c.Room - outside)))
+	if c.Max != 0 && target > c.Max {
+		target = c.Max
+	}
+	if target < c.Min {
+		target = 0
+	}
+	return target
+}
+
+// Reads a parametric curve from the [curve] table of a toml file
+func LoadParametricCurve(path string)(c ParametricCurve, err error){
+	data,err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+	var file struct {
+		Curve ParametricCurve `toml:"curve"`
+	}
+	if err = toml.Unmarshal(data,&file); err != nil {
+		return c, fmt.Errorf("%s: %v",path,err)
+	}
+	if err = file.Curve.Validate(); err != nil {
+		return c, fmt.Errorf("%s: %v",path,err)
+	}
+	return file.Curve, nil
+}
+
+// Reads a heating curve: a schedule (see LoadSchedule) or a parametric curve from a .toml file
+// or a configuration table otherwise
+// @return the curve and the issues of the configuration tables
+func LoadHeatingCurve(path string)(curve HeatingCurve, report CurveReport, err error){
+	if filepath.Ext(path) == ".toml" {
+		doc,err := toml.ParseFile(path)
+		if err != nil {
+			return nil, report, err
+		}
+		if _,ok := doc["profiles"]; ok {
+			schedule,err := decodeSchedule(doc,path)
+			if err != nil {
+				return nil, report, err
+			}
+			return schedule, schedule.Report(), nil
+		}
+		curve,err = LoadParametricCurve(path)
+		return curve, report, err
+	}
+	table,err := LoadCurveTable(path)
+	if err != nil {
+		return nil, report, err
+	}
+	return table, table.Report(), nil
+}
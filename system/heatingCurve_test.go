@@ -0,0 +1,151 @@
+package system
+
+import(
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Returns a csv row of the configuration table with the targets of target(hour)
+func curveRow(temperature int, target func(hour int)(int))(string){
+	cells := []string{fmt.Sprint(temperature)}
+	for hour := 0; hour < CURVE_HOURS; hour++ {
+		cells = append(cells,fmt.Sprint(target(hour)))
+	}
+	return strings.Join(cells,",")
+}
+
+func TestCurveTable(t *testing.T){
+	rows := []string{
+		"Temp/h,0,1,2,...",
+		curveRow(-10,func(hour int)(int){
+			if hour == 6 {
+				return 64000
+			}
+			return 60000
+		}),
+		curveRow(0,func(hour int)(int){ return 50000 }),
+		curveRow(10,func(hour int)(int){
+			switch {
+			case hour < 6:
+				return 0
+			case hour == 12:
+				return 55000
+			}
+			return 40000
+		}),
+		"20,1,2,3",
+		curveRow(30,func(hour int)(int){ return 0 })+"x",
+	}
+	table,err := ParseCurveTable(strings.NewReader(strings.Join(rows,"\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	day := time.Date(2026,1,1,0,0,0,0,time.Local)
+	at := func(hour, minute int)(time.Time){ return day.Add(time.Hour * time.Duration(hour) + time.Minute * time.Duration(minute)) }
+	tests := []struct{
+		name string
+		outside int
+		at time.Time
+		expected int
+	}{
+		{"row",0,at(2,0),50000},
+		{"between rows",-5000,at(2,0),55000},
+		{"below coldest row",-25000,at(2,0),60000},
+		{"missing outside temperature",COLDEST_OUTSIDE,at(6,0),64000},
+		{"above warmest row",25000,at(8,0),40000},
+		{"between hours",-10000,at(5,30),62000},
+		{"bilinear",-5000,at(5,30),56000},
+		{"wrap around midnight",0,at(23,30),50000},
+		{"next to heating off",4000,at(2,0),50000},
+		{"heating off",6000,at(2,0),0},
+		{"heating off at 5:40",10000,at(5,40),40000},
+	}
+	for _,test := range tests {
+		if target := table.Target(test.outside,test.at); target != test.expected {
+			t.Error("For",test.name,"expected",test.expected,"got",target)
+		}
+	}
+
+	report := table.Report()
+	if len(report.Missing) != 18 || report.Missing[0] != -9 || report.Missing[17] != 9 {
+		t.Error("For","missing rows","expected","-9 ... 9 except 0","got",report.Missing)
+	}
+	if len(report.NonMonotonic) != 1 || !strings.Contains(report.NonMonotonic[0],"12:00 55000 at 10 °C") {
+		t.Error("For","non-monotonic targets","expected","12:00 at 10 °C","got",report.NonMonotonic)
+	}
+	if len(report.Invalid) != 2 || report.IsEmpty() {
+		t.Error("For","invalid rows","expected","rows 5 and 6","got",report.Invalid)
+	}
+
+	if _,err := ParseCurveTable(strings.NewReader("Temp/h\n1,2\n")); err == nil {
+		t.Error("For","table without valid rows","expected","error","got",nil)
+	}
+}
+
+func TestParametricCurve(t *testing.T){
+	curve := ParametricCurve{Room:20000,DesignOutside:-15000,DesignTarget:55000}
+	if curve.GetSlope() != 1 || curve.Target(0,time.Now()) != 40000 {
+		t.Error("For","design point","expected","slope 1 and 40000 at 0 °C","got",curve.GetSlope(),curve.Target(0,time.Now()))
+	}
+	curve.Shift,curve.Min,curve.Max = 2000,25000,50000
+	tests := map[int]int{
+		0:42000,
+		-15000:50000,
+		18000:0,
+		15000:27000,
+	}
+	for outside,expected := range tests {
+		if target := curve.Target(outside,time.Now()); target != expected {
+			t.Error("For","outside",outside,"expected",expected,"got",target)
+		}
+	}
+	curve.Slope = 1.5
+	if target := curve.Target(0,time.Now()); target != 50000 {
+		t.Error("For","slope 1.5","expected",50000,"got",target)
+	}
+
+	invalid := []ParametricCurve{
+		{Room:20000,DesignOutside:25000,DesignTarget:55000},
+		{Room:20000,DesignOutside:-15000,DesignTarget:15000},
+		{Room:20000,Slope:-1},
+		{Room:20000,Slope:1,Min:30000,Max:20000},
+	}
+	for _,c := range invalid {
+		if c.Validate() == nil {
+			t.Error("For",c,"expected","error","got",nil)
+		}
+	}
+}
+
+func TestLoadHeatingCurve(t *testing.T){
+	dir,err := ioutil.TempDir("","curve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir,"heating_curve.toml")
+	ioutil.WriteFile(path,[]byte("[curve]\nroom = 20000\ndesign_outside = -15000\ndesign_target = 55000\nmax = 60000\n"),0644)
+	curve,report,err := LoadHeatingCurve(path)
+	if err != nil || !report.IsEmpty() || curve.Target(-5000,time.Now()) != 45000 {
+		t.Error("For","parametric curve","expected",45000,"got",curve,report,err)
+	}
+	ioutil.WriteFile(path,[]byte("[curve]\nroom = 20000\n"),0644)
+	if _,_,err := LoadHeatingCurve(path); err == nil {
+		t.Error("For","curve without design point","expected","error","got",nil)
+	}
+
+	curve,report,err = LoadHeatingCurve(TEST_CONFIGURATION)
+	if _,ok := curve.(*CurveTable); err != nil || !ok {
+		t.Error("For","configuration table","expected","table","got",curve,err)
+	}
+	if len(report.Missing) > 0 || len(report.Invalid) > 0 {
+		t.Error("For","shipped configuration","expected","no missing or invalid rows","got",report)
+	}
+}
@@ -0,0 +1,207 @@
+package system
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+const (
+	HISTORY_READINGS = 24	// default max number of readings tracked by the history
+)
+
+var (
+	// aggregated tiers beyond the sliding window of the percept oracle (1 s for 5 minutes)
+	DefaultTiers = []Tier{
+		{Resolution:time.Second * 10,Length:2160},	// 6 hours
+		{Resolution:time.Minute,Length:10080},		// 7 days
+	}
+)
+
+// A tier of the history: Length buckets of Resolution each
+type Tier struct {
+	Resolution time.Duration
+	Length int
+}
+
+// Span of the tier
+func (t Tier) Span()(time.Duration){
+	return t.Resolution * time.Duration(t.Length)
+}
+
+// min/mean/max of the values of a reading within a bucket
+type aggregate struct {
+	min, mean, max float32
+	count uint16
+}
+
+func (a *aggregate) add(v float64)(){
+	value := float32(v)
+	if a.count == 0 {
+		a.min,a.mean,a.max = value,value,value
+	} else {
+		if value < a.min {
+			a.min = value
+		}
+		if value > a.max {
+			a.max = value
+		}
+		a.mean += (value - a.mean) / float32(a.count+1)
+	}
+	if a.count < ^uint16(0) {
+		a.count++
+	}
+}
+
+// Ring buffer of a tier, the aggregates of all readings of a bucket are stored en bloc
+type ring struct {
+	Tier
+	starts []int64		// unix time of the bucket start, 0 if the bucket is empty
+	aggregates []aggregate	// Length x readings
+}
+
+/**
+ * The History aggregates the readings of the percepts into tiers of ring buffers with
+ * decreasing resolution. All buffers are allocated on creation, thus the memory use does
+ * not grow (see MemorySize). Only the first readings (by order of appearance) up to the
+ * configured maximum are tracked.
+ */
+type History struct {
+	rings []ring
+	readings int			// max number of readings
+	logics map[string]int		// index of the tracked readings
+	ignored map[string]bool		// readings that exceeded the maximum
+	lock sync.RWMutex
+}
+
+// Generates a history with the given tiers ordered by resolution
+// @param readings max number of readings that are tracked
+func NewHistory(readings int, tiers ...Tier)(h *History){
+	h = &History{readings:readings,logics:make(map[string]int),ignored:make(map[string]bool)}
+	tiers = append([]Tier(nil),tiers...)
+	sort.Slice(tiers,func(i, j int)(bool){ return tiers[i].Resolution < tiers[j].Resolution })
+	for _,t := range tiers {
+		h.rings = append(h.rings,ring{
+			Tier:t,
+			starts:make([]int64,t.Length),
+			aggregates:make([]aggregate,t.Length*readings),
+		})
+	}
+	return
+}
+
+// Returns the tiers of the history ordered by resolution
+func (h *History) Tiers()(tiers []Tier){
+	for _,r := range h.rings {
+		tiers = append(tiers,r.Tier)
+	}
+	return
+}
+
+// Returns the memory allocated by the buffers of the history in bytes
+func (h *History) MemorySize()(size int){
+	for _,r := range h.rings {
+		size += len(r.starts) * int(unsafe.Sizeof(int64(0))) + len(r.aggregates) * int(unsafe.Sizeof(aggregate{}))
+	}
+	return
+}
+
+// Returns the index of the reading, registers unknown readings while the maximum is not reached
+func (h *History) index(logic string)(int, bool){
+	if i,ok := h.logics[logic]; ok {
+		return i, true
+	}
+	if len(h.logics) >= h.readings {
+		if !h.ignored[logic] {
+			h.ignored[logic] = true
+			fmt.Printf("[WARNING]\thistory tracks %d readings at most, %s is ignored\n",h.readings,logic)
+		}
+		return 0, false
+	}
+	h.logics[logic] = len(h.logics)
+	return h.logics[logic], true
+}
+
+// Adds the measured readings of the percept to the buckets of all tiers
+func (h *History) Add(p *Percept)(){
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for _,logic := range p.Logics() {
+		reading := p.Readings[logic]
+		if !reading.IsValid() || isSubstitute(reading) {
+			continue
+		}
+		i,ok := h.index(logic)
+		if !ok {
+			continue
+		}
+		for n := range h.rings {
+			r := &h.rings[n]
+			bucket := r.bucket(p.CurrentTime)
+			r.aggregates[bucket*h.readings+i].add(reading.GetNumeric())
+		}
+	}
+}
+
+// Returns the index of the bucket of the time, a bucket of an earlier period is cleared
+func (r *ring) bucket(t time.Time)(int){
+	resolution := int64(r.Resolution / time.Second)
+	start := t.Unix() - t.Unix() % resolution
+	bucket := int((start / resolution) % int64(r.Length))
+	if r.starts[bucket] != start {
+		r.starts[bucket] = start
+		readings := len(r.aggregates) / r.Length
+		for i := bucket*readings; i < (bucket+1)*readings; i++ {
+			r.aggregates[i] = aggregate{}
+		}
+	}
+	return bucket
+}
+
+// Returns the tier that answers a query over the span: the tier of the given resolution or
+// the finest tier that covers the span if resolution is 0
+func (h *History) tier(span, resolution time.Duration)(*ring, error){
+	for n := range h.rings {
+		if r := &h.rings[n]; resolution == r.Resolution || resolution == 0 && span <= r.Span() {
+			return r, nil
+		}
+	}
+	if resolution == 0 && len(h.rings) > 0 {
+		return &h.rings[len(h.rings)-1], nil
+	}
+	return nil, fmt.Errorf("%v: no tier with resolution %s",ErrInvalidQuery,resolution)
+}
+
+// Returns the samples of the reading within (to-span,to] of the tier ordered by time.
+// The sample of a bucket is its min for MIN, its max for MAX and its mean otherwise,
+// the time of a sample is the start of its bucket.
+func (h *History) samples(r *ring, q Query, to time.Time)(samples []Sample){
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	i,ok := h.logics[q.Logic]
+	if !ok {
+		return
+	}
+	from := to.Add(-q.Range).Unix()
+	for bucket,start := range r.starts {
+		if start == 0 || start <= from - int64(r.Resolution / time.Second) || start > to.Unix() {
+			continue
+		}
+		a := r.aggregates[bucket*h.readings+i]
+		if a.count == 0 {
+			continue
+		}
+		value := a.mean
+		switch q.Aggregation {
+		case MIN:
+			value = a.min
+		case MAX:
+			value = a.max
+		}
+		samples = append(samples,Sample{time.Unix(start,0),float64(value)})
+	}
+	sort.Slice(samples,func(i, j int)(bool){ return samples[i].Time.Before(samples[j].Time) })
+	return
+}
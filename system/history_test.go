@@ -0,0 +1,124 @@
+package system
+
+import(
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestHistoryMemory(t *testing.T){
+	h := NewHistory(HISTORY_READINGS,DefaultTiers...)
+	tiers := h.Tiers()
+	if len(tiers) != 2 || tiers[0].Span() != time.Hour * 6 || tiers[1].Span() != time.Hour * 24 * 7 {
+		t.Error("For","default tiers","expected","6 hours and 7 days","got",tiers)
+	}
+	// 12240 buckets of 24 readings (16 bytes each) and their start times
+	if size := h.MemorySize(); size != 12240 * (24 * 16 + 8) {
+		t.Error("For","memory size","expected",12240 * (24 * 16 + 8),"got",size)
+	}
+
+	// memory does not grow with percepts or readings
+	start := time.Date(2026,1,1,0,0,0,0,time.UTC)
+	for i := 0; i < 100; i++ {
+		readings := make(map[string]float64)
+		for r := 0; r < 30; r++ {
+			readings[string(rune('A'+r))] = float64(i)
+		}
+		h.Add(testPercept(start.Add(time.Minute * time.Duration(i)),readings))
+	}
+	if size := h.MemorySize(); size != 12240 * (24 * 16 + 8) || len(h.logics) != HISTORY_READINGS {
+		t.Error("For","30 readings","expected",HISTORY_READINGS,"tracked readings","got",len(h.logics),size)
+	}
+}
+
+func TestHistoryTiers(t *testing.T){
+	// 10 s for 1 minute, 30 s for 3 minutes
+	h := NewHistory(4,Tier{time.Second * 30,6},Tier{time.Second * 10,6})
+	start := time.Date(2026,1,1,0,0,0,0,time.UTC)
+	for i := 0; i < 60; i++ {
+		h.Add(testPercept(start.Add(time.Second * time.Duration(i)),map[string]float64{"x":float64(i)}))
+	}
+	to := start.Add(time.Second * 59)
+
+	fine,err := h.tier(time.Minute,0)
+	if err != nil || fine.Resolution != time.Second * 10 {
+		t.Fatal("For","1 minute","expected","10 s tier","got",fine,err)
+	}
+	samples := h.samples(fine,Query{Logic:"x",Range:time.Minute,Aggregation:MEAN},to)
+	if len(samples) != 6 || samples[0].Value != 4.5 || samples[5].Value != 54.5 || !samples[5].Time.Equal(start.Add(time.Second * 50)) {
+		t.Error("For","means of 10 s buckets","expected","4.5 ... 54.5","got",samples)
+	}
+	samples = h.samples(fine,Query{Logic:"x",Range:time.Second * 20,Aggregation:MAX},to)
+	// the bucket of 30 s overlaps the range (39 s,59 s]
+	if len(samples) != 3 || samples[0].Value != 39 || samples[2].Value != 59 {
+		t.Error("For","max of the last 20 s","expected","39, 49, 59","got",samples)
+	}
+
+	coarse,err := h.tier(time.Minute * 2,0)
+	if err != nil || coarse.Resolution != time.Second * 30 {
+		t.Fatal("For","2 minutes","expected","30 s tier","got",coarse,err)
+	}
+	samples = h.samples(coarse,Query{Logic:"x",Range:time.Minute * 2,Aggregation:MIN},to)
+	if len(samples) != 2 || samples[0].Value != 0 || samples[1].Value != 30 {
+		t.Error("For","min of 30 s buckets","expected","0, 30","got",samples)
+	}
+	if _,err := h.tier(time.Minute,time.Second * 20); err == nil {
+		t.Error("For","unknown resolution","expected","error","got",nil)
+	}
+
+	// the ring wraps around, old buckets are replaced
+	h.Add(testPercept(start.Add(time.Second * 65),map[string]float64{"x":100}))
+	samples = h.samples(fine,Query{Logic:"x",Range:time.Minute,Aggregation:MEAN},start.Add(time.Second * 65))
+	if len(samples) != 6 || samples[0].Value != 14.5 || samples[5].Value != 100 {
+		t.Error("For","wrapped ring","expected","14.5 ... 100","got",samples)
+	}
+}
+
+func TestAskHistory(t *testing.T){
+	start := time.Now().Truncate(time.Hour)
+	percepts := make([]*Percept,0)
+	// the kettle heats up by 1 K per minute for two hours
+	for i := 0; i < 120; i++ {
+		percepts = append(percepts,testPercept(start.Add(time.Minute * time.Duration(i)),map[string]float64{"Kettle":float64(20000 + i*1000)}))
+	}
+	o := NewPerceptOracle(func(ctx context.Context, updates chan *Percept)(){
+		for _,p := range percepts {
+			p.Valid = true
+			updates <- p
+		}
+		<-ctx.Done()
+	},300)
+	o.SetHistory(NewHistory(4,Tier{time.Minute,180},Tier{time.Minute * 10,144}))
+	o.Start(context.Background())
+	defer o.Stop()
+	for p,_ := o.Current(context.Background()); !p.CurrentTime.Equal(percepts[119].CurrentTime); p,_ = o.Current(context.Background()) {
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	results,err := o.Ask(
+		Query{Logic:"Kettle",Range:time.Hour,Aggregation:SLOPE},
+		Query{Logic:"Kettle",Range:time.Hour * 2,Aggregation:MIN,Resolution:time.Minute * 10},
+		Query{Logic:"Kettle",Range:time.Hour * 24,Aggregation:MAX},
+		Query{Logic:"Kettle",Range:time.Minute * 2,Aggregation:MEAN},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the bucket of minute 59 overlaps the range (59 min,119 min]
+	if r := results[0]; r.Resolution != time.Minute || r.Samples != 61 || math.Abs(r.Value - 1000.0/60) > 1e-6 || r.Coverage != 1 {
+		t.Error("For","slope over an hour","expected","1 K/min of 61 samples at 1 min","got",r)
+	}
+	if r := results[1]; r.Resolution != time.Minute * 10 || r.Samples != 12 || r.Value != 20000 {
+		t.Error("For","min over 2 hours","expected",20000,"got",r)
+	}
+	if r := results[2]; r.Resolution != time.Minute * 10 || r.Value != 139000 || r.Coverage > 0.1 {
+		t.Error("For","max over a day","expected","139000 with low coverage","got",r)
+	}
+	if r := results[3]; r.Resolution != time.Second || r.Samples != 2 || r.Value != 138500 {
+		t.Error("For","mean of the window","expected",138500,"got",r)
+	}
+	if _,err := o.Ask(Query{Logic:"Kettle",Range:time.Hour,Aggregation:MEAN,Resolution:time.Second * 10}); err == nil {
+		t.Error("For","unknown resolution","expected","error","got",nil)
+	}
+}
@@ -0,0 +1,218 @@
+// The modbus package implements a Modbus client for the frequency converters of the pumps:
+// RTU over a serial line (RS-485) and TCP. Only the register functions are supported, the
+// converters are commanded by writing and monitored by reading their registers.
+package modbus
+
+import(
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Function codes of the register access
+const(
+	READ_HOLDING_REGISTERS byte = 0x03
+	READ_INPUT_REGISTERS byte = 0x04
+	WRITE_SINGLE_REGISTER byte = 0x06
+	WRITE_MULTIPLE_REGISTERS byte = 0x10
+
+	// exception codes of a slave
+	ILLEGAL_FUNCTION byte = 0x01
+	ILLEGAL_DATA_ADDRESS byte = 0x02
+	ILLEGAL_DATA_VALUE byte = 0x03
+	SLAVE_DEVICE_FAILURE byte = 0x04
+
+	MAX_REGISTERS = 125			// registers of a single read
+	TIMEOUT = time.Second			// max time of a request
+	TCP_PREFIX = "tcp://"
+	DEFAULT_TCP_PORT = "502"
+)
+
+// Exception response of a slave
+type Exception struct {
+	Function byte
+	Code byte
+}
+
+func (e *Exception) Error()(string){
+	names := map[byte]string{
+		ILLEGAL_FUNCTION:"illegal function",
+		ILLEGAL_DATA_ADDRESS:"illegal data address",
+		ILLEGAL_DATA_VALUE:"illegal data value",
+		SLAVE_DEVICE_FAILURE:"slave device failure",
+	}
+	name,ok := names[e.Code]
+	if !ok {
+		name = fmt.Sprintf("code %d",e.Code)
+	}
+	return fmt.Sprintf("modbus exception of function 0x%02x: %s",e.Function,name)
+}
+
+// A Transport sends the protocol data unit (function code and data) of a request to a slave
+// and returns the pdu of its response. Transports may be shared by the clients of several
+// slaves (e.g. converters on the same RS-485 bus) and must be safe for concurrent use.
+type Transport interface {
+	Send(unit byte, pdu []byte)(response []byte, err error)
+	Close()(error)
+}
+
+// Settings of the serial line of an RTU transport
+type SerialConfig struct {
+	Baud int
+	Parity string		// none, even or odd
+}
+
+// Default of the modbus specification: 19200 baud, even parity
+var DefaultSerialConfig = SerialConfig{Baud:19200,Parity:"even"}
+
+/**
+ * Opens the transport to the address: tcp://host[:port] connects via TCP (port 502 by
+ * default), anything else is the device of a serial line (e.g. /dev/ttyUSB0) that is opened
+ * with the settings of serial.
+ */
+func Open(address string, serial SerialConfig)(Transport, error){
+	if strings.HasPrefix(address,TCP_PREFIX) {
+		host := strings.TrimPrefix(address,TCP_PREFIX)
+		if !strings.Contains(host,":") {
+			host += ":"+DEFAULT_TCP_PORT
+		}
+		return NewTCPTransport(host), nil
+	}
+	port,err := OpenSerial(address,serial)
+	if err != nil {
+		return nil, err
+	}
+	t := NewRTUTransport(port)
+	t.SetSilence(InterFrameSilence(serial.Baud))
+	return t, nil
+}
+
+// A client of a single slave
+type Client struct {
+	transport Transport
+	unit byte
+}
+
+// @param unit address of the slave (1-247, TCP gateways usually ignore it)
+func NewClient(transport Transport, unit byte)(c *Client){
+	return &Client{transport:transport,unit:unit}
+}
+
+// Getter for the address of the slave
+func (c *Client) Unit()(byte){
+	return c.unit
+}
+
+// Sends the request and checks the response for an exception and the function code
+func (c *Client) request(pdu []byte)(response []byte, err error){
+	if response,err = c.transport.Send(c.unit,pdu); err != nil {
+		return nil, err
+	}
+	if len(response) == 0 {
+		return nil, fmt.Errorf("modbus: empty response")
+	}
+	if response[0] == pdu[0] | 0x80 {
+		if len(response) < 2 {
+			return nil, fmt.Errorf("modbus: truncated exception")
+		}
+		return nil, &Exception{pdu[0],response[1]}
+	}
+	if response[0] != pdu[0] {
+		return nil, fmt.Errorf("modbus: response of function 0x%02x to request 0x%02x",response[0],pdu[0])
+	}
+	return
+}
+
+func (c *Client) read(function byte, address, count uint16)(values []uint16, err error){
+	if count == 0 || count > MAX_REGISTERS {
+		return nil, fmt.Errorf("modbus: can not read %d registers",count)
+	}
+	response,err := c.request(appendUint16([]byte{function},address,count))
+	if err != nil {
+		return nil, err
+	}
+	if len(response) < 2 || int(response[1]) != 2 * int(count) || len(response) != 2 + int(response[1]) {
+		return nil, fmt.Errorf("modbus: response of %d bytes does not hold %d registers",len(response),count)
+	}
+	values = make([]uint16,count)
+	for i := range values {
+		values[i] = binary.BigEndian.Uint16(response[2+2*i:])
+	}
+	return
+}
+
+// Reads count holding registers starting at address
+func (c *Client) ReadHoldingRegisters(address, count uint16)([]uint16, error){
+	return c.read(READ_HOLDING_REGISTERS,address,count)
+}
+
+// Reads count input registers starting at address
+func (c *Client) ReadInputRegisters(address, count uint16)([]uint16, error){
+	return c.read(READ_INPUT_REGISTERS,address,count)
+}
+
+// Writes value to the holding register at address
+func (c *Client) WriteRegister(address, value uint16)(error){
+	pdu := appendUint16([]byte{WRITE_SINGLE_REGISTER},address,value)
+	response,err := c.request(pdu)
+	if err == nil && string(response) != string(pdu) {
+		err = fmt.Errorf("modbus: write of register %d was not confirmed",address)
+	}
+	return err
+}
+
+// Writes the values to the holding registers starting at address
+func (c *Client) WriteRegisters(address uint16, values ...uint16)(error){
+	if len(values) == 0 || len(values) > MAX_REGISTERS - 2 {
+		return fmt.Errorf("modbus: can not write %d registers",len(values))
+	}
+	pdu := appendUint16([]byte{WRITE_MULTIPLE_REGISTERS},address,uint16(len(values)))
+	pdu = appendUint16(append(pdu,byte(2 * len(values))),values...)
+	response,err := c.request(pdu)
+	if err == nil && string(response) != string(pdu[:5]) {
+		err = fmt.Errorf("modbus: write of registers %d-%d was not confirmed",address,int(address)+len(values)-1)
+	}
+	return err
+}
+
+// Closes the transport of the client
+func (c *Client) Close()(error){
+	return c.transport.Close()
+}
+
+// Appends the values in big endian order
+func appendUint16(b []byte, values ...uint16)([]byte){
+	for _,v := range values {
+		b = append(b,byte(v >> 8),byte(v))
+	}
+	return b
+}
+
+// Returns the length of the pdu of a request or response that starts with head, -1 if
+// more bytes are needed to determine it and 0 if the function is not supported
+func pduLength(head []byte, request bool)(int){
+	if len(head) < 1 {
+		return -1
+	}
+	function := head[0]
+	switch {
+	case function & 0x80 != 0:
+		return 2
+	case function == WRITE_SINGLE_REGISTER || (request && (function == READ_HOLDING_REGISTERS || function == READ_INPUT_REGISTERS)):
+		return 5
+	case function == READ_HOLDING_REGISTERS || function == READ_INPUT_REGISTERS:
+		if len(head) < 2 {
+			return -1
+		}
+		return 2 + int(head[1])
+	case function == WRITE_MULTIPLE_REGISTERS && !request:
+		return 5
+	case function == WRITE_MULTIPLE_REGISTERS:
+		if len(head) < 6 {
+			return -1
+		}
+		return 6 + int(head[5])
+	}
+	return 0
+}
@@ -0,0 +1,160 @@
+package modbus
+
+import(
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCRC16(t *testing.T){
+	// read 10 holding registers of unit 1 starting at 0, example of the specification
+	frame := rtuFrame(0x01,[]byte{READ_HOLDING_REGISTERS,0x00,0x00,0x00,0x0A})
+	expected := []byte{0x01,0x03,0x00,0x00,0x00,0x0A,0xC5,0xCD}
+	if string(frame) != string(expected) {
+		t.Error("For","read request","expected",expected,"got",frame)
+	}
+	if crc := CRC16([]byte("123456789")); crc != 0x4B37 {
+		t.Error("For","check value","expected",0x4B37,"got",crc)
+	}
+}
+
+// Runs the request/response cycle of a client against the slave
+func testClient(t *testing.T, transport string, c *Client, slave *SimSlave){
+	if err := c.WriteRegister(1,5000); err != nil || slave.Register(1) != 5000 {
+		t.Error("For",transport,"write register","expected",5000,"got",slave.Register(1),err)
+	}
+	if err := c.WriteRegisters(0,1,4000); err != nil || slave.Register(0) != 1 || slave.Register(1) != 4000 {
+		t.Error("For",transport,"write registers","expected",[]uint16{1,4000},"got",slave.Register(0),slave.Register(1),err)
+	}
+	slave.SetRegister(2,3990)
+	if values,err := c.ReadHoldingRegisters(0,3); err != nil || len(values) != 3 || values[1] != 4000 || values[2] != 3990 {
+		t.Error("For",transport,"read holding registers","expected",[]uint16{1,4000,3990},"got",values,err)
+	}
+	if values,err := c.ReadInputRegisters(2,1); err != nil || len(values) != 1 || values[0] != 3990 {
+		t.Error("For",transport,"read input register","expected",3990,"got",values,err)
+	}
+	_,err := c.ReadHoldingRegisters(2,5)
+	if e,ok := err.(*Exception); !ok || e.Code != ILLEGAL_DATA_ADDRESS || e.Function != READ_HOLDING_REGISTERS {
+		t.Error("For",transport,"unknown register","expected",ILLEGAL_DATA_ADDRESS,"got",err)
+	}
+	if err = c.WriteRegister(9,1); err == nil {
+		t.Error("For",transport,"write of unknown register","expected","exception","got",nil)
+	}
+}
+
+func TestRTU(t *testing.T){
+	slave := NewSimSlave(7,0,1,2)
+	master,line := net.Pipe()
+	go slave.ServeRTU(line)
+	defer master.Close()
+	transport := NewRTUTransport(master)
+	transport.SetTimeout(time.Millisecond * 200)
+	testClient(t,"rtu",NewClient(transport,7),slave)
+
+	// a slave that is not on the line does not answer
+	requests := slave.Requests()
+	if _,err := NewClient(transport,8).ReadHoldingRegisters(0,1); err == nil || slave.Requests() != requests {
+		t.Error("For","unit 8","expected","timeout","got",err)
+	}
+}
+
+// A serial line of two os pipes, bytes that are not read stay buffered like in the
+// input buffer of a serial port
+type bufferedLine struct {
+	r, w *os.File
+}
+
+func (l bufferedLine) Read(p []byte)(int, error){ return l.r.Read(p) }
+func (l bufferedLine) Write(p []byte)(int, error){ return l.w.Write(p) }
+func (l bufferedLine) SetReadDeadline(t time.Time)(error){ return l.r.SetReadDeadline(t) }
+func (l bufferedLine) Close()(error){
+	l.r.Close()
+	return l.w.Close()
+}
+
+func TestRTUResync(t *testing.T){
+	masterR,slaveW,err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	slaveR,masterW,err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	master,line := bufferedLine{masterR,masterW},bufferedLine{slaveR,slaveW}
+	defer master.Close()
+	defer line.Close()
+
+	// answers a corrupted frame followed by the start of another one, then a late
+	// response and finally the correct value
+	go func(){
+		response := func(value byte)([]byte){ return rtuFrame(7,[]byte{READ_HOLDING_REGISTERS,2,0,value}) }
+		corrupted := response(1)
+		corrupted[len(corrupted)-1] ^= 0xff
+		for i,answer := range [][]byte{append(corrupted,7,READ_HOLDING_REGISTERS,2),response(2),response(42)} {
+			if _,_,err := readRTUFrame(line,true); err != nil {
+				return
+			}
+			if i == 1 {
+				time.Sleep(time.Millisecond * 150)
+			}
+			line.Write(answer)
+		}
+	}()
+
+	transport := NewRTUTransport(master)
+	transport.SetTimeout(time.Millisecond * 100)
+	c := NewClient(transport,7)
+	if _,err = c.ReadHoldingRegisters(0,1); !errors.Is(err,ErrFrame) {
+		t.Error("For","corrupted frame","expected",ErrFrame,"got",err)
+	}
+	if _,err = c.ReadHoldingRegisters(0,1); err == nil {
+		t.Error("For","late response","expected","timeout","got",nil)
+	}
+	time.Sleep(time.Millisecond * 100)
+	if values,err := c.ReadHoldingRegisters(0,1); err != nil || len(values) != 1 || values[0] != 42 {
+		t.Error("For","request after the late response","expected",42,"got",values,err)
+	}
+}
+
+func TestTCP(t *testing.T){
+	slave := NewSimSlave(1,0,1,2)
+	listener,err := net.Listen("tcp","127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go slave.ServeTCP(listener)
+	transport,err := Open(TCP_PREFIX+listener.Addr().String(),DefaultSerialConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.Close()
+	c := NewClient(transport,1)
+	testClient(t,"tcp",c,slave)
+
+	// the connection is established again after the slave was unreachable
+	listener.Close()
+	transport.Close()
+	if _,err = c.ReadHoldingRegisters(0,1); err == nil {
+		t.Error("For","closed listener","expected","error","got",nil)
+	}
+	if listener,err = net.Listen("tcp",listener.Addr().String()); err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go slave.ServeTCP(listener)
+	if values,err := c.ReadHoldingRegisters(1,1); err != nil || values[0] != 4000 {
+		t.Error("For","reconnect","expected",4000,"got",values,err)
+	}
+}
+
+func TestOpenSerial(t *testing.T){
+	if _,err := Open("/dev/null",SerialConfig{Baud:1234}); err == nil {
+		t.Error("For","baud 1234","expected","error","got",nil)
+	}
+	if _,err := Open("/dev/ttyMissing",DefaultSerialConfig); err == nil {
+		t.Error("For","missing device","expected","error","got",nil)
+	}
+}
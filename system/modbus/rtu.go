@@ -0,0 +1,158 @@
+package modbus
+
+import(
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const(
+	MIN_SILENCE = time.Microsecond * 1750	// inter-frame silence above 19200 baud
+)
+
+// Error of a frame that was received completely but can not be used
+var ErrFrame = errors.New("modbus: invalid frame")
+
+// Returns the silence that separates two RTU frames at the baud rate: 3.5 characters of
+// 11 bits, at least MIN_SILENCE
+func InterFrameSilence(baud int)(time.Duration){
+	if baud <= 0 {
+		baud = DefaultSerialConfig.Baud
+	}
+	if silence := time.Duration(float64(time.Second) * 3.5 * 11 / float64(baud)); silence > MIN_SILENCE {
+		return silence
+	}
+	return MIN_SILENCE
+}
+
+// A port whose reads can time out, e.g. a serial line or a pipe
+type deadliner interface {
+	SetReadDeadline(t time.Time)(error)
+}
+
+// Returns the CRC-16/MODBUS of the data (polynomial 0xA001 reflected, initial value 0xFFFF)
+func CRC16(data []byte)(crc uint16){
+	crc = 0xFFFF
+	for _,b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc & 1 != 0 {
+				crc = crc >> 1 ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return
+}
+
+// Returns the RTU frame of the pdu: unit, pdu and the CRC (low byte first)
+func rtuFrame(unit byte, pdu []byte)(frame []byte){
+	frame = append([]byte{unit},pdu...)
+	crc := CRC16(frame)
+	return append(frame,byte(crc),byte(crc >> 8))
+}
+
+// Reads an RTU frame from r and checks its CRC
+// @param request true if the frame is a request (a slave reads), false for a response
+func readRTUFrame(r io.Reader, request bool)(unit byte, pdu []byte, err error){
+	frame := make([]byte,2,256)
+	if _,err = io.ReadFull(r,frame); err != nil {
+		return
+	}
+	length := pduLength(frame[1:],request)
+	for length < 0 {
+		frame = append(frame,0)
+		if _,err = io.ReadFull(r,frame[len(frame)-1:]); err != nil {
+			return
+		}
+		length = pduLength(frame[1:],request)
+	}
+	if length == 0 {
+		return frame[0], nil, fmt.Errorf("%w: function 0x%02x is not supported",ErrFrame,frame[1])
+	}
+	rest := make([]byte,1 + length + 2 - len(frame))
+	if _,err = io.ReadFull(r,rest); err != nil {
+		return
+	}
+	frame = append(frame,rest...)
+	crc := CRC16(frame[:len(frame)-2])
+	if frame[len(frame)-2] != byte(crc) || frame[len(frame)-1] != byte(crc >> 8) {
+		return frame[0], nil, fmt.Errorf("%w: CRC mismatch of % x",ErrFrame,frame)
+	}
+	return frame[0], frame[1:len(frame)-2], nil
+}
+
+/**
+ * Modbus RTU over a serial line (usually RS-485). The frames of several slaves share the
+ * line, a request is answered before the next one is sent. Bytes of late or invalid frames
+ * are discarded until the line is silent before each request, thus a request never reads
+ * the rest of an earlier frame.
+ * implements Transport interface
+ */
+type RTUTransport struct {
+	port io.ReadWriteCloser
+	timeout time.Duration
+	silence time.Duration
+	lock sync.Mutex
+}
+
+// @param port the serial line, see OpenSerial
+func NewRTUTransport(port io.ReadWriteCloser)(t *RTUTransport){
+	return &RTUTransport{port:port,timeout:TIMEOUT,silence:InterFrameSilence(DefaultSerialConfig.Baud)}
+}
+
+// Sets the max time of a request, reads only time out if the port supports deadlines
+func (t *RTUTransport) SetTimeout(timeout time.Duration)(){
+	t.timeout = timeout
+}
+
+// Sets the silence that separates two frames on the line, see InterFrameSilence
+func (t *RTUTransport) SetSilence(silence time.Duration)(){
+	t.silence = silence
+}
+
+func (t *RTUTransport) Send(unit byte, pdu []byte)(response []byte, err error){
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	d,deadlines := t.port.(deadliner)
+	if deadlines {
+		t.drain(d)
+		d.SetReadDeadline(time.Now().Add(t.timeout))
+	}
+	if _,err = t.port.Write(rtuFrame(unit,pdu)); err != nil {
+		return nil, err
+	}
+	for {
+		var from byte
+		if from,response,err = readRTUFrame(t.port,false); err != nil {
+			if deadlines && errors.Is(err,ErrFrame) {
+				// the rest of the invalid frame is not the start of the next one
+				t.drain(d)
+			}
+			return nil, err
+		}
+		// frames of other slaves on the bus are skipped
+		if from == unit {
+			return
+		}
+	}
+}
+
+// Discards the input until the line was silent for the inter-frame silence, at most for
+// the timeout of a request
+func (t *RTUTransport) drain(d deadliner)(){
+	buffer := make([]byte,256)
+	for end := time.Now().Add(t.timeout); time.Now().Before(end); {
+		d.SetReadDeadline(time.Now().Add(t.silence))
+		if _,err := t.port.Read(buffer); err != nil {
+			return
+		}
+	}
+}
+
+func (t *RTUTransport) Close()(error){
+	return t.port.Close()
+}
@@ -0,0 +1,60 @@
+//go:build linux
+// +build linux
+
+package modbus
+
+import(
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var baudRates = map[int]uint32{
+	9600:syscall.B9600,
+	19200:syscall.B19200,
+	38400:syscall.B38400,
+	57600:syscall.B57600,
+	115200:syscall.B115200,
+}
+
+/**
+ * Opens the serial line at device in raw mode with 8 data bits. Without parity 2 stop bits
+ * are used as the modbus specification demands. The line is opened non-blocking thus reads
+ * time out (see RTUTransport.SetTimeout).
+ */
+func OpenSerial(device string, config SerialConfig)(port *os.File, err error){
+	baud,ok := baudRates[config.Baud]
+	if !ok {
+		return nil, fmt.Errorf("modbus: %s: unsupported baud rate %d",device,config.Baud)
+	}
+	cflag := syscall.CS8 | syscall.CREAD | syscall.CLOCAL | baud
+	switch config.Parity {
+	case "even":
+		cflag |= syscall.PARENB
+	case "odd":
+		cflag |= syscall.PARENB | syscall.PARODD
+	case "none", "":
+		cflag |= syscall.CSTOPB
+	default:
+		return nil, fmt.Errorf("modbus: %s: unknown parity %q (none, even, odd)",device,config.Parity)
+	}
+	if port,err = os.OpenFile(device,os.O_RDWR | syscall.O_NOCTTY | syscall.O_NONBLOCK,0); err != nil {
+		return nil, err
+	}
+	termios := syscall.Termios{Cflag:cflag,Ispeed:baud,Ospeed:baud}
+	termios.Cc[syscall.VMIN] = 1
+	raw,err := port.SyscallConn()
+	if err == nil {
+		raw.Control(func(fd uintptr){
+			if _,_,errno := syscall.Syscall(syscall.SYS_IOCTL,fd,syscall.TCSETS,uintptr(unsafe.Pointer(&termios))); errno != 0 {
+				err = errno
+			}
+		})
+	}
+	if err != nil {
+		port.Close()
+		return nil, fmt.Errorf("modbus: %s: %v",device,err)
+	}
+	return
+}
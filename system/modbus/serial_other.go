@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package modbus
+
+import(
+	"fmt"
+	"os"
+)
+
+// Serial lines are only supported on linux, use a TCP gateway otherwise.
+func OpenSerial(device string, config SerialConfig)(port *os.File, err error){
+	return nil, fmt.Errorf("modbus: %s: serial lines are only supported on linux",device)
+}
@@ -0,0 +1,192 @@
+package modbus
+
+import(
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+)
+
+// struct representing a simulated modbus slave, e.g. a frequency converter, its registers
+// are kept in memory. Holding and input registers share the same address space. The slave
+// answers requests of a Client directly (see Transport) or served on an RTU line or a TCP
+// listener.
+// implements Transport interface
+type SimSlave struct {
+	unit byte
+	registers map[uint16]uint16
+	onWrite func(address, value uint16)
+	requests int
+	lock sync.Mutex
+}
+
+// @param unit address of the slave
+// @param registers addresses of the registers the slave provides, requests of other
+// addresses are answered with ILLEGAL_DATA_ADDRESS
+func NewSimSlave(unit byte, registers ...uint16)(s *SimSlave){
+	s = &SimSlave{unit:unit,registers:make(map[uint16]uint16,len(registers))}
+	for _,address := range registers {
+		s.registers[address] = 0
+	}
+	return
+}
+
+// Sets the function that is called after a master wrote a register, e.g. in order to
+// simulate the reaction of a converter by SetRegister
+func (s *SimSlave) OnWrite(f func(address, value uint16))(){
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.onWrite = f
+}
+
+// Sets the value of a register, creates the register if it does not exist
+func (s *SimSlave) SetRegister(address, value uint16)(){
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.registers[address] = value
+}
+
+// Returns the value of a register
+func (s *SimSlave) Register(address uint16)(uint16){
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.registers[address]
+}
+
+// Returns the number of requests the slave answered
+func (s *SimSlave) Requests()(int){
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.requests
+}
+
+// Returns the response pdu of the request pdu
+func (s *SimSlave) Handle(pdu []byte)(response []byte){
+	s.lock.Lock()
+	s.requests++
+	response,written := s.handle(pdu)
+	onWrite := s.onWrite
+	s.lock.Unlock()
+	if onWrite != nil {
+		for _,address := range written {
+			onWrite(address,s.Register(address))
+		}
+	}
+	return
+}
+
+func (s *SimSlave) handle(pdu []byte)(response []byte, written []uint16){
+	exception := func(code byte)([]byte, []uint16){
+		return []byte{pdu[0] | 0x80,code}, nil
+	}
+	if len(pdu) == 0 {
+		return []byte{0x80,ILLEGAL_FUNCTION}, nil
+	}
+	if length := pduLength(pdu,true); length == 0 {
+		return exception(ILLEGAL_FUNCTION)
+	} else if len(pdu) != length {
+		return exception(ILLEGAL_DATA_VALUE)
+	}
+	address,count := binary.BigEndian.Uint16(pdu[1:]),binary.BigEndian.Uint16(pdu[3:])
+	switch pdu[0] {
+	case READ_HOLDING_REGISTERS, READ_INPUT_REGISTERS:
+		if count == 0 || count > MAX_REGISTERS {
+			return exception(ILLEGAL_DATA_VALUE)
+		}
+		response = []byte{pdu[0],byte(2 * count)}
+		for a := address; a < address + count; a++ {
+			value,ok := s.registers[a]
+			if !ok {
+				return exception(ILLEGAL_DATA_ADDRESS)
+			}
+			response = appendUint16(response,value)
+		}
+	case WRITE_SINGLE_REGISTER:
+		if _,ok := s.registers[address]; !ok {
+			return exception(ILLEGAL_DATA_ADDRESS)
+		}
+		s.registers[address] = count
+		response,written = pdu,[]uint16{address}
+	case WRITE_MULTIPLE_REGISTERS:
+		if count == 0 || int(pdu[5]) != 2 * int(count) {
+			return exception(ILLEGAL_DATA_VALUE)
+		}
+		for a := address; a < address + count; a++ {
+			if _,ok := s.registers[a]; !ok {
+				return exception(ILLEGAL_DATA_ADDRESS)
+			}
+		}
+		for i := uint16(0); i < count; i++ {
+			s.registers[address + i] = binary.BigEndian.Uint16(pdu[6+2*i:])
+			written = append(written,address + i)
+		}
+		response = pdu[:5]
+	}
+	return
+}
+
+// Answers a request of a Client without a line, requests of other units time out
+func (s *SimSlave) Send(unit byte, pdu []byte)(response []byte, err error){
+	if unit != s.unit {
+		return nil, timeoutError{}
+	}
+	return s.Handle(pdu), nil
+}
+
+func (s *SimSlave) Close()(error){
+	return nil
+}
+
+// Serves the RTU frames of the line until it is closed, requests of other units and
+// frames with a wrong CRC are not answered
+func (s *SimSlave) ServeRTU(line io.ReadWriter)(error){
+	for {
+		unit,pdu,err := readRTUFrame(line,true)
+		switch {
+		case errors.Is(err,ErrFrame) || (err == nil && unit != s.unit):
+			continue
+		case err == io.EOF || err == io.ErrClosedPipe:
+			return nil
+		case err != nil:
+			return err
+		}
+		if _,err = line.Write(rtuFrame(unit,s.Handle(pdu))); err != nil {
+			return err
+		}
+	}
+}
+
+// Serves the TCP connections of the listener until it is closed, the unit of a request
+// is ignored like a converter with a built-in TCP interface does
+func (s *SimSlave) ServeTCP(listener net.Listener)(){
+	for {
+		conn,err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func(){
+			defer conn.Close()
+			for {
+				transaction,unit,pdu,err := readTCPFrame(conn)
+				if err != nil {
+					return
+				}
+				if _,err = conn.Write(tcpFrame(transaction,unit,s.Handle(pdu))); err != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+// Error of a request no slave answered
+type timeoutError struct {}
+
+func (e timeoutError) Error()(string){
+	return "i/o timeout"
+}
+
+func (e timeoutError) Timeout()(bool){
+	return true
+}
@@ -0,0 +1,105 @@
+package modbus
+
+import(
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const(
+	MBAP_LENGTH = 7		// transaction, protocol, length and unit
+	MAX_PDU_LENGTH = 253
+)
+
+// Returns the TCP frame of the pdu: MBAP header (transaction id, protocol 0, length, unit)
+// and pdu
+func tcpFrame(transaction uint16, unit byte, pdu []byte)(frame []byte){
+	frame = appendUint16(nil,transaction,0,uint16(len(pdu)+1))
+	return append(append(frame,unit),pdu...)
+}
+
+// Reads a TCP frame from r
+func readTCPFrame(r io.Reader)(transaction uint16, unit byte, pdu []byte, err error){
+	header := make([]byte,MBAP_LENGTH)
+	if _,err = io.ReadFull(r,header); err != nil {
+		return
+	}
+	transaction = binary.BigEndian.Uint16(header)
+	length := int(binary.BigEndian.Uint16(header[4:]))
+	if protocol := binary.BigEndian.Uint16(header[2:]); protocol != 0 || length < 2 || length > MAX_PDU_LENGTH + 1 {
+		return transaction, 0, nil, fmt.Errorf("modbus: invalid MBAP header % x",header)
+	}
+	pdu = make([]byte,length - 1)
+	_,err = io.ReadFull(r,pdu)
+	return transaction, header[6], pdu, err
+}
+
+/**
+ * Modbus TCP, the connection is established on the first request and again after a
+ * request failed, thus a converter that is temporarily unreachable does not prevent
+ * the start of the system.
+ * implements Transport interface
+ */
+type TCPTransport struct {
+	address string
+	timeout time.Duration
+	conn net.Conn
+	transaction uint16
+	lock sync.Mutex
+}
+
+// @param address host:port of the slave or gateway
+func NewTCPTransport(address string)(t *TCPTransport){
+	return &TCPTransport{address:address,timeout:TIMEOUT}
+}
+
+// Sets the max time of a request including the connect
+func (t *TCPTransport) SetTimeout(timeout time.Duration)(){
+	t.timeout = timeout
+}
+
+func (t *TCPTransport) Send(unit byte, pdu []byte)(response []byte, err error){
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.conn == nil {
+		if t.conn,err = net.DialTimeout("tcp",t.address,t.timeout); err != nil {
+			t.conn = nil
+			return nil, err
+		}
+	}
+	defer func(){
+		if err != nil {
+			t.conn.Close()
+			t.conn = nil
+		}
+	}()
+	t.conn.SetDeadline(time.Now().Add(t.timeout))
+	t.transaction++
+	if _,err = t.conn.Write(tcpFrame(t.transaction,unit,pdu)); err != nil {
+		return nil, err
+	}
+	for {
+		var transaction uint16
+		if transaction,_,response,err = readTCPFrame(t.conn); err != nil {
+			return nil, err
+		}
+		// late responses of requests that timed out are skipped
+		if transaction == t.transaction {
+			return
+		}
+	}
+}
+
+func (t *TCPTransport) Close()(error){
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.conn == nil {
+		return nil
+	}
+	err := t.conn.Close()
+	t.conn = nil
+	return err
+}
@@ -0,0 +1,158 @@
+package system
+
+import (
+	"fmt"
+	"math"
+	"github.com/hansen1101/go_heating/system/modbus"
+)
+
+// Register map of a frequency converter that is commanded via modbus
+type ModbusRegisters struct {
+	Control, Setpoint, Frequency, Current, Fault uint16	// addresses of the holding registers
+	Run, Stop uint16					// values of the control register
+	FrequencyScale float64					// Hz per unit of setpoint and frequency
+	CurrentScale float64					// A per unit of the current
+}
+
+// Register map of the usual converter profile: control word at 0, set-point and output
+// frequency in 0.01 Hz, motor current in 0.1 A and the fault code (0: no fault)
+var DefaultModbusRegisters = ModbusRegisters{
+	Control:0,
+	Setpoint:1,
+	Frequency:2,
+	Current:3,
+	Fault:4,
+	Run:1,
+	Stop:0,
+	FrequencyScale:0.01,
+	CurrentScale:0.1,
+}
+
+// Fault code reported by a frequency converter
+type ConverterFault struct {
+	Code uint16
+}
+
+func (f *ConverterFault) Error()(string){
+	return fmt.Sprintf("converter fault code %d",f.Code)
+}
+
+// State of a frequency converter read from its registers
+type ConverterStatus struct {
+	Running bool
+	Frequency float64	// output frequency (Hz)
+	Current float64		// motor current (A)
+	Fault uint16		// 0 if the converter runs without fault
+}
+
+/**
+ * A pump behind a frequency converter that is commanded via modbus, the frequency is
+ * written as exact set-point and read back from the converter.
+ * implements Actuator and FrequencyFeedback interface
+ */
+type ModbusPump struct {
+	client *modbus.Client
+	registers ModbusRegisters
+	max_freq, min_freq float64
+	state Setting
+	faults int
+}
+
+func NewModbusPump(max,min float64, client *modbus.Client, registers ModbusRegisters)(p *ModbusPump){
+	return &ModbusPump{
+		client:client,
+		registers:registers,
+		max_freq:max,
+		min_freq:min,
+		state:Setting{On:false,Frequency:OFF_FREQ}}
+}
+
+func (p *ModbusPump) Kind()(string){
+	return ACTUATOR_PUMP
+}
+
+func (p *ModbusPump) State()(Setting){
+	return p.state
+}
+
+// Writes the set-point and the run command of the setting, the frequency is clamped to the
+// range min_freq..max_freq like the one of a relay pump. The control register is written on
+// every call thus a converter that was reset runs again.
+// @return *ConverterFault if the converter reports a fault
+func (p *ModbusPump) Set(setting Setting)(err error){
+	if !setting.On {
+		if err = p.client.WriteRegister(p.registers.Control,p.registers.Stop); err != nil {
+			return
+		}
+		p.state = Setting{On:false,Frequency:OFF_FREQ}
+		return
+	}
+	target := clampFrequency(setting.Frequency,p.min_freq,p.max_freq)
+	if err = p.client.WriteRegister(p.registers.Setpoint,uint16(math.Round(target / p.registers.FrequencyScale))); err != nil {
+		return
+	}
+	if err = p.client.WriteRegister(p.registers.Control,p.registers.Run); err != nil {
+		return
+	}
+	p.state = Setting{On:true,Frequency:target}
+	status,err := p.Status()
+	if err == nil && status.Fault != 0 {
+		p.faults++
+		err = &ConverterFault{status.Fault}
+	}
+	return
+}
+
+// Reads the state of the converter
+func (p *ModbusPump) Status()(status ConverterStatus, err error){
+	read := func(address uint16)(uint16){
+		if err != nil {
+			return 0
+		}
+		var values []uint16
+		if values,err = p.client.ReadHoldingRegisters(address,1); err != nil {
+			return 0
+		}
+		return values[0]
+	}
+	status.Running = read(p.registers.Control) == p.registers.Run
+	status.Frequency = float64(read(p.registers.Frequency)) * p.registers.FrequencyScale
+	status.Current = float64(read(p.registers.Current)) * p.registers.CurrentScale
+	status.Fault = read(p.registers.Fault)
+	return
+}
+
+// Reads the run command and the output frequency of the converter
+func (p *ModbusPump) ReadBack()(setting Setting, err error){
+	status,err := p.Status()
+	if err != nil {
+		return Setting{}, err
+	}
+	return Setting{On:status.Running,Frequency:status.Frequency}, nil
+}
+
+// Reads the output frequency of the converter
+func (p *ModbusPump) Frequency()(float64, error){
+	values,err := p.client.ReadHoldingRegisters(p.registers.Frequency,1)
+	if err != nil {
+		return 0, err
+	}
+	return float64(values[0]) * p.registers.FrequencyScale, nil
+}
+
+func (p *ModbusPump) Constraints()(Constraints){
+	return Constraints{MinFrequency:p.min_freq,MaxFrequency:p.max_freq}
+}
+
+// Returns the number of faults the converter reported
+func (p *ModbusPump) Faults()(int){
+	return p.faults
+}
+
+func (p *ModbusPump) String()(string){
+	return fmt.Sprintf("Pump State [active:%v frequency:%.2f faults:%d]\tModbus unit %d\n",
+		p.state.On,
+		p.state.Frequency,
+		p.faults,
+		p.client.Unit())
+}
@@ -1,15 +1,12 @@
 package system
 
 import (
+	"context"
 	"time"
 	"fmt"
 	"math"
-	"sync"
 	"errors"
-	"os"
-	"encoding/csv"
-	"bufio"
-	"strconv"
+	"github.com/hansen1101/go_heating/system/hardware"
 )
 
 const (
@@ -19,6 +16,13 @@ const (
 )
 
 type DataQuery int
+
+// Logical name of the sensor reading each DataQuery is calculated on
+var QueryLogic = map[DataQuery]string{
+	BOILER_DELTA:hardware.ROLE_TPO,
+	REVERSE_DELTA:hardware.ROLE_H_REV,
+	WATER_BUFFER_DELTA:hardware.ROLE_TWO,
+}
 //type PerceptGenerator func(timestamp *time.Time) (percept *Percept)
 
 type DataResponse struct {
@@ -70,7 +74,7 @@ func MakeConfigRequest(endpoint chan int, percept *Percept)(){
 	if endpoint != nil && Configuration_request_chan != nil {
 		Configuration_request_chan <- &configRequest{endpoint,percept}
 	} else {
-		fmt.Println("[ERROR]\teither channel endpoint %v or request %v does not exists",endpoint,Configuration_request_chan)
+		fmt.Printf("[ERROR]\teither channel endpoint %v or request %v does not exists\n",endpoint,Configuration_request_chan)
 	}
 }
 
@@ -232,20 +236,13 @@ func Oracle_loop(generator PerceptGenerator, windowLength int) () {
 			for _,req := range result_chan.request {
 				for _, j := range result_chan.calc_info {
 					if j.Sec > 0 {
-						var data func(i int)(int,int64,error)
-						switch req {
-						case BOILER_DELTA:
-							data = func(i int)(int,int64,error){
-								return window[i].BoilerMidTemp.GetValue(),window[i].CurrentTime.Unix(),nil
-							}
-						case REVERSE_DELTA:
-							data = func(i int)(int,int64,error){
-								return window[i].HReverseRunTemp.GetValue(),window[i].CurrentTime.Unix(),nil
-							}
-						default:
-							data = func(i int)(int,int64,error){
-								return window[i].HReverseRunTemp.GetValue(),window[i].CurrentTime.Unix(),nil
-							}
+						logic,known := QueryLogic[req]
+						if !known {
+							fmt.Printf("[ERROR]\t%v %d\n",ErrUnknownQuery,req)
+							continue
+						}
+						data := func(i int)(int,int64,error){
+							return windowTemperature(window,i,logic)
 						}
 						res = append(
 							res,
@@ -275,231 +272,45 @@ func Oracle_loop(generator PerceptGenerator, windowLength int) () {
 
 // A service routine that establishes a percept sliding window and processes
 // incoming requests from clients (either percept request by main routine or
-// query requests from learners).
-// The service listens at two distinct channels for either type of request.
-// In order to establish the sliding window the service starts a generator and
-// updates the sliding window each time the generator hands over a new Percept.
+// query requests from learners) through Percept_request_chan and Query_request_chan.
+// Shim of the channel API around a PerceptOracle that is never stopped.
+// @param processChan receives true once the package channels are initialized
 func Percept_Oracle(generator func(chan *Percept)(),windowLength int,processChan chan bool){
-	var slidingWindow []*Percept // history of percepts generated
-	var currentIndex, counter int // counts of percepts in history and pointer to latest percept
-	var windowLock sync.Mutex // lock for the sliding window
-	var perceptUpdateChan chan *Percept // channel throug which the generator delivers pointers to currently generated Percept structs
-
-	slidingWindow = make([]*Percept, windowLength, windowLength)
-	currentIndex = 0
-	counter = 0
-	perceptUpdateChan = make(chan *Percept)
-
-	// init package variables
-	Percept_request_chan = make(chan chan *Percept)
-	Query_request_chan = make(chan *dataRequest)
-	//processChan <- true
-
-	// start percept generator routine;
-	// generator ensures only valid percepts are send through perceptUpdateChan
-	go generator(perceptUpdateChan)
-
-	// spawn sliding window update routine;
-	// receives percepts and updates sliding window securely
-	go func()(){
-		var currentPercept *Percept
-		for {
-			// wait until a the generator function hands over a new percept pointer
-			currentPercept = <-perceptUpdateChan
-			if currentPercept != nil && currentPercept.IsValid() {
-				windowLock.Lock()
-				//@debug deadlock bug fmt.Print("Locked by Update generator...")
-				updateSlidingWindow(&slidingWindow,currentPercept,&currentIndex,&counter)
-				//@debug deadlock bug fmt.Print(" ...released by Update generator\n")
-				windowLock.Unlock()
-			}
-		}
-	}()
-
-	// spawn service routine;
-	// either receives update or query request and
-	go func()(){
-		for {
-			//@debug deadlock bug fmt.Println("Waiting for a query signal")
-			select {
-			case percept_chan := <-Percept_request_chan:
-				// a request for a percept pointer is received through request channel --> hand over current percept pointer
-				//@debug deadlock bug fmt.Print("Percept Request processor tries to lock...")
-					var currentPercept *Percept
-					for currentPercept == nil {
-						windowLock.Lock()
-						currentPercept = slidingWindow[currentIndex]
-						//@debug deadlock bug fmt.Print(" ...released by Percept Request processor\n")
-						windowLock.Unlock()
-						if currentPercept == nil {
-							// sleep and hope for sliding window updates
-							fmt.Println("Percept request could not be handled since sliding window is empty")
-							<-time.After(time.Second * 5)
-						}
-					}
-					percept_chan <- currentPercept
-			case result_chan := <-Query_request_chan:
-				// a dataRequest was received, open the request and answer back to the endpoint
-				resp := make([]DataResponse, 0, 0)
-				for _,req := range result_chan.request {
-					for _, j := range result_chan.calc_info {
-						if j.Sec > 0 {
-							var data func(i int) (int, int64, error)
-							var meanAlgorithm meanCalc
-							switch req {
-							case WATER_BUFFER_DELTA:
-								data = func(i int) (tempData int, dataTimestamp int64, err error) {
-									if slidingWindow[i] == nil {
-										err = errors.New("No data item in slot found")
-										return
-									}
-									err = nil
-									tempData = slidingWindow[i].BoilerTopTemp.GetValue()
-									dataTimestamp = slidingWindow[i].CurrentTime.Unix()
-									return
-								}
-								meanAlgorithm = totalDeltaInterval
-							case BOILER_DELTA:
-								data = func(i int) (int, int64, error) {
-									return slidingWindow[i].BoilerMidTemp.GetValue(), slidingWindow[i].CurrentTime.Unix(), nil
-								}
-								meanAlgorithm = expWeightedMovingAverage
-							case REVERSE_DELTA:
-								data = func(i int) (int, int64, error) {
-									return slidingWindow[i].HReverseRunTemp.GetValue(), slidingWindow[i].CurrentTime.Unix(), nil
-								}
-							default:
-								data = func(i int) (int, int64, error) {
-									return slidingWindow[i].HReverseRunTemp.GetValue(), slidingWindow[i].CurrentTime.Unix(), nil
-								}
-								meanAlgorithm = naiveMean
-							}
-							//@debug deadlock bug fmt.Print("Data Request processor tries to lock...")
-							windowLock.Lock()
-							resp = append(
-								resp,
-								calculateTempDelta(
-									&slidingWindow,
-									j.Weight,
-									j.Sec,
-									currentIndex,
-									req,
-									data,
-									meanAlgorithm,
-								),
-							)
-							//@debug deadlock bug fmt.Print(" ...released by Data Request processor\n")
-							windowLock.Unlock()
-						}
-					}
-				}
-				// send response back to the endpoint associated with dataRequest
-				result_chan.Endpoint <- resp
-			}
-			//@debug deadlock bug fmt.Println("Query signal processed")
-		}
-	}()
-
-	defer func(){
-		fmt.Println("When you see this line, all oracle routines have been started!")
-		// @important: send back signal after package variabels are initialized in order to signal callee that processing is possible
-		processChan <- true
-	}()
+	o := NewPerceptOracle(func(ctx context.Context, updates chan *Percept)(){
+		generator(updates)
+	},windowLength)
+	o.Start(context.Background())
+	o.ServeChannels(context.Background())
+	fmt.Println("When you see this line, all oracle routines have been started!")
+	processChan <- true
 }
 
-// Generates a configuration lookup map
-func generate_Configuration(path string)(configuration map[int][]int,ok bool){
-	// open file at path and build table
-	configuration = make(map[int][]int)
-	ok = false
-	file, err := os.Open(path)
-	if err != nil {
-		return
-	}
-	fileinfo,_ := file.Stat()
-	if fileinfo.IsDir() {
-		return
-	}
-	r := csv.NewReader(bufio.NewReader(file))
-	records, err_read := r.ReadAll()
-	if err_read == nil{
-		//parse records
-		for row_id,record := range records {
-			var key_tmp int
-			if row_id > 0 {
-				for col_id,temp := range record {
-					temp_value,convert_err := strconv.Atoi(temp)
-					hour := col_id-1
-					if convert_err != nil {
-						fmt.Println("conversion failed")
-					}
-					if col_id == 0 {
-						key_tmp = temp_value
-						_,ok := configuration[key_tmp]
-						if !ok {
-							configuration[key_tmp] = make([]int, 24, 24)
-						}
-					} else {
-						configuration[key_tmp][hour] = temp_value
-					}
-				}
-			}
-		}
+// Shim of the channel API around a ConfigurationOracle that is never stopped, requests
+// are served through Configuration_request_chan (see MakeConfigRequest).
+// @param processChan receives whether the configuration could be read
+func Configuration_Oracle(path string,processChan chan bool,default_target int)(){
+	o := NewConfigurationOracle(path,default_target)
+	err := o.Start(context.Background())
+	if err == nil {
+		o.ServeChannels(context.Background())
+	} else {
+		Configuration_request_chan = make(chan *configRequest)
 	}
-	ok = true
-	return
+	fmt.Printf("[OK: %v] When you see this line, all configuration oracle has been started!\n",err == nil)
+	processChan <- err == nil
 }
 
-func Configuration_Oracle(path string,processChan chan bool,default_target int)(){
-	//var windowLock sync.Mutex // lock for the sliding window
-	var target int
-	var configurationLock sync.Mutex // lock for the sliding window
-	var configuration map[int][]int
-	var ok bool
-	configurationLock.Lock()
-	configuration,ok = generate_Configuration(path)
-	configurationLock.Unlock()
-	Configuration_request_chan = make(chan *configRequest)
-
-	defer func(){
-		fmt.Printf("[OK: %v] When you see this line, all configuration oracle has been started!\n",ok)
-		// @important: send back signal after package variabels are initialized in order to signal callee that processing is possible
-		processChan <- ok
-	}()
-
-	if ok {
-		go func()(){
-			for {
-				select {
-					case config_request := <-Configuration_request_chan:
-						if config_request.percept.IsValid(){
-							temp_key := config_request.percept.OutsideTemp.GetValue()
-							temp_key = int(math.Round(float64(temp_key)/1000.0))
-							hour_index := config_request.percept.CurrentTime.Hour()
-							configurationLock.Lock()
-							if _,key_exist := configuration[temp_key]; key_exist {
-								target = configuration[temp_key][hour_index]
-							} else {
-								target = default_target
-							}
-							configurationLock.Unlock()
-						}
-						config_request.Endpoint <- target
-				}
-			}
-		}()
-		go func()(){
-			for {
-				<-time.After(time.Minute * 5)
-				update_configuration,valid := generate_Configuration(path)
-				if valid {
-					configurationLock.Lock()
-					configuration = update_configuration
-					configurationLock.Unlock()
-				}
-			}
-		}()
+// Returns a temperature of the percept in slot i of the window and the percept's timestamp.
+// @param logic logical name of the temperature reading
+// @return error if the slot is empty or the temperature was not measured for the percept
+func windowTemperature(window []*Percept, i int, logic string)(int, int64, error){
+	if window[i] == nil {
+		return 0, 0, errors.New("No data item in slot found")
+	}
+	if t := window[i].Temperature(logic); Measured(t) {
+		return t.GetValue(), window[i].CurrentTime.Unix(), nil
 	}
+	return 0, 0, errors.New("No measured temperature in slot found")
 }
 
 // Ensures sec value is in the bounds of the window
@@ -533,8 +344,8 @@ func naiveMean(i int, j int64, k *int, l *int64, meanValue *float64, w float64,
 func updateBoilerMean(window *([]*Percept), i int, lastBoilerTemp *int, lastTimeStamp *int64, meanValue *float64) () {
 	var currentBoilerTemp int
 	var currentTimeStamp int64
-	if (*window)[i] != nil {
-		currentBoilerTemp = (*window)[i].BoilerMidTemp.GetValue()
+	if (*window)[i] != nil && Usable((*window)[i].Temperature(QueryLogic[BOILER_DELTA])) {
+		currentBoilerTemp = (*window)[i].Temperature(QueryLogic[BOILER_DELTA]).GetValue()
 		currentTimeStamp = (*window)[i].CurrentTime.Unix()
 		if *lastBoilerTemp > math.MinInt32 {
 			// case: this is not the first element
@@ -0,0 +1,242 @@
+package system
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hansen1101/go_heating/system/w1"
+)
+
+const (
+	ORACLE_SNAPSHOT_KIND = "ORCL"
+)
+
+// Returns the time span the oracle remembers: the span of its coarsest tier or the length
+// of the sliding window without history
+func (o *PerceptOracle) horizon()(horizon time.Duration){
+	horizon = time.Second * time.Duration(len(o.window))
+	if o.history != nil {
+		for _,t := range o.history.Tiers() {
+			if t.Span() > horizon {
+				horizon = t.Span()
+			}
+		}
+	}
+	return
+}
+
+/**
+ * Writes the sliding window and the aggregated history to a snapshot file (see WriteSnapshot),
+ * may be called while the oracle is running
+ */
+func (o *PerceptOracle) Snapshot(path string)(error){
+	var e SnapshotEncoder
+	o.windowLock.Lock()
+	percepts := make([]*Percept,0,o.counter)
+	for _,p := range o.window {
+		if p != nil {
+			percepts = append(percepts,p)
+		}
+	}
+	history := o.history
+	o.windowLock.Unlock()
+
+	e.PutUint32(uint32(len(percepts)))
+	for _,p := range percepts {
+		encodePercept(&e,p)
+	}
+	e.PutBool(history != nil)
+	if history != nil {
+		history.encode(&e)
+	}
+	return WriteSnapshot(path,ORACLE_SNAPSHOT_KIND,time.Now(),e.Bytes())
+}
+
+/**
+ * Restores the sliding window and the history from a snapshot file. Percepts that left the
+ * sliding window since the snapshot was taken are dropped. The history is only restored if
+ * its tiers match those of the oracle. Restored percepts answer queries only, Current waits
+ * for the first percept of the source.
+ * @return ErrOracleRunning if the oracle was started, an error of ReadSnapshot if the file
+ * is corrupted or older than the span of the coarsest tier
+ */
+func (o *PerceptOracle) Restore(path string)(error){
+	if _,err := o.running(); err == nil {
+		return ErrOracleRunning
+	}
+	o.windowLock.Lock()
+	defer o.windowLock.Unlock()
+	payload,_,err := ReadSnapshot(path,ORACLE_SNAPSHOT_KIND,o.horizon())
+	if err != nil {
+		return err
+	}
+
+	d := NewSnapshotDecoder(payload)
+	percepts := make([]*Percept,d.Count(8))
+	for i := range percepts {
+		percepts[i] = decodePercept(d)
+	}
+	// the history is the last part of the payload, it is skipped if the oracle keeps none
+	var history *History
+	if d.Bool() && o.history != nil {
+		history = NewHistory(o.history.readings,o.history.Tiers()...)
+		if err = history.decode(d); err != nil && d.err == nil {
+			fmt.Printf("[WARNING]\t%v, the history is not restored\n",err)
+			history,err = nil,nil
+		} else if err == nil {
+			err = d.Err()
+		}
+	} else {
+		err = d.err
+	}
+	if err != nil {
+		return err
+	}
+
+	// replay the percepts in order of time, the window is cleared like on a regular update
+	window := make([]*Percept,len(o.window),len(o.window))
+	currentIndex,counter := 0,0
+	horizon := time.Now().Add(-time.Second * time.Duration(len(o.window)))
+	sort.Slice(percepts,func(i, j int)(bool){ return percepts[i].CurrentTime.Before(percepts[j].CurrentTime) })
+	for _,p := range percepts {
+		if p.CurrentTime.After(horizon) {
+			updateSlidingWindow(&window,p,&currentIndex,&counter)
+		}
+	}
+	o.window,o.currentIndex,o.counter = window,currentIndex,counter
+	if history != nil {
+		o.history = history
+	}
+	return nil
+}
+
+// Encodes time, validity and readings of the percept, violations are not kept
+func encodePercept(e *SnapshotEncoder, p *Percept)(){
+	e.PutInt64(p.CurrentTime.UnixNano())
+	e.PutBool(p.Valid)
+	logics := p.Logics()
+	e.PutUint32(uint32(len(logics)))
+	for _,logic := range logics {
+		r := p.Readings[logic]
+		e.PutText(logic)
+		e.PutUint8(uint8(r.GetKind()))
+		e.PutFloat64(r.GetNumeric())
+		e.PutBool(r.IsValid())
+		e.PutBool(isSubstitute(r))
+		sensor := ""
+		if t,ok := r.(TemperatureReading); ok {
+			sensor = t.GetSensorId()
+		}
+		e.PutText(sensor)
+	}
+}
+
+func decodePercept(d *SnapshotDecoder)(p *Percept){
+	p = NewPercept(time.Unix(0,d.Int64()))
+	p.Valid = d.Bool()
+	for i,n := 0,d.Count(16); i < n; i++ {
+		logic := d.Text()
+		kind := ReadingKind(d.Uint8())
+		value := d.Float64()
+		valid,substitute := d.Bool(),d.Bool()
+		sensor := d.Text()
+		switch kind {
+		case TEMPERATURE:
+			t := w1.NewTemperature(sensor,logic)
+			if valid {
+				t = w1.NewMeasuredTemperature(sensor,logic,int(value),p.CurrentTime)
+			}
+			if substitute {
+				t = t.Substitute()
+			}
+			p.SetTemperature(t)
+		case BINARY:
+			p.Set(StateReading{Logic:logic,State:value != 0,Valid:valid})
+		case FREQUENCY:
+			p.Set(FrequencyReading{Logic:logic,Hertz:value,Valid:valid})
+		case FLOW:
+			p.Set(FlowReading{Logic:logic,LitersPerHour:value,Valid:valid})
+		default:
+			fmt.Printf("[WARNING]\tsnapshot reading %s of unknown kind %d is dropped\n",logic,kind)
+		}
+	}
+	return
+}
+
+// Encodes the tracked readings and the filled buckets of all rings
+func (h *History) encode(e *SnapshotEncoder)(){
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	logics := make([]string,len(h.logics))
+	for logic,i := range h.logics {
+		logics[i] = logic
+	}
+	e.PutUint32(uint32(len(logics)))
+	for _,logic := range logics {
+		e.PutText(logic)
+	}
+	e.PutUint32(uint32(len(h.rings)))
+	for _,r := range h.rings {
+		e.PutInt64(int64(r.Resolution))
+		e.PutUint32(uint32(r.Length))
+		filled := 0
+		for _,start := range r.starts {
+			if start != 0 {
+				filled++
+			}
+		}
+		e.PutUint32(uint32(filled))
+		for bucket,start := range r.starts {
+			if start == 0 {
+				continue
+			}
+			e.PutUint32(uint32(bucket))
+			e.PutInt64(start)
+			for i := range logics {
+				a := r.aggregates[bucket*h.readings+i]
+				e.PutFloat32(a.min)
+				e.PutFloat32(a.mean)
+				e.PutFloat32(a.max)
+				e.PutUint16(a.count)
+			}
+		}
+	}
+}
+
+// Decodes the history into the empty history h
+// @return error if the tiers or the number of readings differ from h
+func (h *History) decode(d *SnapshotDecoder)(error){
+	logics := make([]string,d.Count(4))
+	for i := range logics {
+		logics[i] = d.Text()
+	}
+	if len(logics) > h.readings {
+		return fmt.Errorf("snapshot history tracks %d readings, %d are allowed",len(logics),h.readings)
+	}
+	for i,logic := range logics {
+		h.logics[logic] = i
+	}
+	if n := d.Count(12); n != len(h.rings) && d.err == nil {
+		return fmt.Errorf("snapshot history has %d tiers instead of %d",n,len(h.rings))
+	}
+	for n := range h.rings {
+		r := &h.rings[n]
+		resolution,length := time.Duration(d.Int64()),int(d.Uint32())
+		if d.err == nil && (resolution != r.Resolution || length != r.Length) {
+			return fmt.Errorf("snapshot history tier %s x %d does not match %s x %d",resolution,length,r.Resolution,r.Length)
+		}
+		for i,filled := 0,d.Count(12); i < filled; i++ {
+			bucket,start := int(d.Uint32()),d.Int64()
+			if bucket >= r.Length {
+				return fmt.Errorf("%v: bucket %d exceeds the tier",ErrSnapshotCorrupt,bucket)
+			}
+			r.starts[bucket] = start
+			for l := range logics {
+				a := &r.aggregates[bucket*h.readings+l]
+				a.min,a.mean,a.max,a.count = d.Float32(),d.Float32(),d.Float32(),d.Uint16()
+			}
+		}
+	}
+	return d.err
+}
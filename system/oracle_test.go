@@ -0,0 +1,165 @@
+package system
+
+import(
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+	"github.com/hansen1101/go_heating/system/hardware"
+	"github.com/hansen1101/go_heating/system/w1"
+)
+
+const (
+	TEST_CONFIGURATION = "../filesystem/heating_config/config.csv"
+)
+
+// Source that hands over a percept per second of the given time span, the boiler
+// heats up by 100 millidegree per second
+func testSource(t *testing.T, dir string, start time.Time, seconds int)(PerceptSource){
+	percepts := make([]*Percept,seconds)
+	for i := range percepts {
+		percepts[i] = NewPercept(start.Add(time.Second * time.Duration(i)))
+		percepts[i].SetTemperature(readTemperature(t,dir,"28-000000000001",hardware.ROLE_TPO,40000+i*100))
+	}
+	return func(ctx context.Context, updates chan *Percept)(){
+		for _,p := range percepts {
+			select {
+			case updates <- p:
+			case <-ctx.Done():
+				return
+			}
+		}
+		<-ctx.Done()
+	}
+}
+
+func TestPerceptOracle(t *testing.T){
+	dir,err := ioutil.TempDir("","oracle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(prefix string){ w1.SENSOR_PATH_PREFIX = prefix }(w1.SENSOR_PATH_PREFIX)
+	w1.SENSOR_PATH_PREFIX = dir+"/"
+
+	start := time.Now().Truncate(time.Second)
+	first := NewPerceptOracle(testSource(t,dir,start,10),60)
+	second := NewPerceptOracle(testSource(t,dir,start.Add(time.Minute),5),60)
+
+	if _,err := first.Current(context.Background()); err != ErrOracleStopped {
+		t.Error("For","request before start","expected",ErrOracleStopped,"got",err)
+	}
+	ctx,cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for _,o := range []*PerceptOracle{first,second} {
+		if err := o.Start(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := first.Start(ctx); err != ErrOracleRunning {
+		t.Error("For","second start","expected",ErrOracleRunning,"got",err)
+	}
+
+	// both oracles maintain their own window
+	wait := func(o *PerceptOracle, last time.Time)(){
+		deadline := time.Now().Add(time.Second * 5)
+		for time.Now().Before(deadline) {
+			if p,err := o.Current(context.Background()); err == nil && p.CurrentTime.Equal(last) {
+				return
+			}
+			time.Sleep(time.Millisecond * 10)
+		}
+		t.Fatal("For","oracle","expected","percept at",last,"got","timeout")
+	}
+	wait(first,start.Add(time.Second * 9))
+	wait(second,start.Add(time.Minute + time.Second * 4))
+
+	responses,err := first.Query([]DataQuery{WATER_BUFFER_DELTA,BOILER_DELTA},Calculation_info{Sec:30,Weight:1})
+	if err != nil || len(responses) != 2 {
+		t.Fatal("For","query","expected","2 responses","got",responses,err)
+	}
+	if responses[0].Id != WATER_BUFFER_DELTA || responses[0].Considered_data != 0 {
+		t.Error("For","water buffer delta without boiler top","expected","no data","got",responses[0])
+	}
+	if responses[1].Considered_data != 10 || responses[1].Result < 99 || responses[1].Result > 101 {
+		t.Error("For","boiler delta","expected","100 millidegree/s of 10 percepts","got",responses[1])
+	}
+
+	// stopped oracles refuse requests, others keep running
+	first.Stop()
+	if _,err := first.Current(context.Background()); err != ErrOracleStopped {
+		t.Error("For","request after stop","expected",ErrOracleStopped,"got",err)
+	}
+	if _,err := first.Query([]DataQuery{BOILER_DELTA}); err != ErrOracleStopped {
+		t.Error("For","query after stop","expected",ErrOracleStopped,"got",err)
+	}
+	if _,err := second.Current(context.Background()); err != nil {
+		t.Error("For","second oracle","expected",nil,"got",err)
+	}
+
+	// a request on an empty window waits for the context
+	empty := NewPerceptOracle(func(ctx context.Context, updates chan *Percept)(){ <-ctx.Done() },60)
+	empty.Start(ctx)
+	defer empty.Stop()
+	timeout,cancelTimeout := context.WithTimeout(context.Background(),time.Millisecond * 50)
+	defer cancelTimeout()
+	if _,err := empty.Current(timeout); err != context.DeadlineExceeded {
+		t.Error("For","empty window","expected",context.DeadlineExceeded,"got",err)
+	}
+
+	// cancelling the parent context stops the oracle as well
+	cancel()
+	second.Stop()
+	if _,err := second.Current(context.Background()); err != ErrOracleStopped {
+		t.Error("For","cancelled context","expected",ErrOracleStopped,"got",err)
+	}
+}
+
+func TestConfigurationOracle(t *testing.T){
+	dir,err := ioutil.TempDir("","oracle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(prefix string){ w1.SENSOR_PATH_PREFIX = prefix }(w1.SENSOR_PATH_PREFIX)
+	w1.SENSOR_PATH_PREFIX = dir+"/"
+
+	if err := NewConfigurationOracle(dir+"/missing.csv",40000).Start(context.Background()); err == nil {
+		t.Error("For","missing configuration","expected","error","got",nil)
+	}
+
+	o := NewConfigurationOracle(TEST_CONFIGURATION,40000)
+	if _,err := o.Target(context.Background(),nil); err != ErrOracleStopped {
+		t.Error("For","request before start","expected",ErrOracleStopped,"got",err)
+	}
+	if err := o.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer o.Stop()
+
+	night := time.Date(2026,1,1,2,0,0,0,time.Local)
+	p := NewPercept(night)
+	p.SetTemperature(readTemperature(t,dir,"28-000000000001",hardware.ROLE_OUTSIDE,-14800))
+	if target,err := o.Target(context.Background(),p); err != nil || target != 59800 {
+		t.Error("For","outside -14.8 at 2am","expected","59800 between -15 and -14","got",target,err)
+	}
+	p = NewPercept(night)
+	if target,_ := o.Target(context.Background(),p); target != 60000 {
+		t.Error("For","missing outside temperature","expected","target of the coldest temperature","got",target)
+	}
+	p = NewPercept(night.Add(time.Hour * 4))
+	p.SetTemperature(readTemperature(t,dir,"28-000000000001",hardware.ROLE_OUTSIDE,99000))
+	if target,_ := o.Target(context.Background(),p); target != 0 {
+		t.Error("For","outside temperature above the table","expected","0 of the warmest row","got",target)
+	}
+	p.Valid = false
+	if target,_ := o.Target(context.Background(),p); target != 0 {
+		t.Error("For","invalid percept","expected","last target","got",target)
+	}
+
+	o.Stop()
+	if _,err := o.Target(context.Background(),p); err != ErrOracleStopped {
+		t.Error("For","request after stop","expected",ErrOracleStopped,"got",err)
+	}
+}
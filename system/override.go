@@ -0,0 +1,309 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"github.com/hansen1101/go_heating/auxiliary/toml"
+	"github.com/hansen1101/go_heating/system/logger"
+)
+
+// Kinds of manual overrides
+const (
+	OVERRIDE_BOILER = "boiler"			// fixed boiler target, hot water is heated
+	OVERRIDE_HEATING_OFF = "heating_off"		// the radiators only protect against frost
+	OVERRIDE_HOT_WATER_BOOST = "hot_water_boost"	// hot water is heated once up to the target
+)
+
+const (
+	OVERRIDE_TABLE = "percept_overrides"
+	OVERRIDE_TIME_LAYOUT = time.RFC3339
+	HOT_WATER_BOOST_TARGET = 55000			// default target of a boost in m°C
+	HOT_WATER_BOOST_DURATION = time.Hour * 2	// a boost that does not reach its target expires
+)
+
+var (
+	ErrOverrideUnknown = errors.New("override unknown")
+)
+
+/**
+ * A manual override of the configuration, e.g. "boiler target 55 °C for the next 2 h",
+ * "heating off until tomorrow 06:00" or "one-off hot water boost now". Overrides apply on top
+ * of the setpoint of the configuration until they expire, a boost ends as soon as the boiler
+ * reached its target.
+ */
+type Override struct {
+	Id int
+	Kind string
+	Target int		// boiler target in m°C of OVERRIDE_BOILER and OVERRIDE_HOT_WATER_BOOST
+	Created time.Time
+	Expires time.Time
+}
+
+func (o Override) String()(string){
+	until := o.Expires.Local().Format("2006-01-02 15:04")
+	switch o.Kind {
+	case OVERRIDE_BOILER:
+		return fmt.Sprintf("#%d %s %d until %s",o.Id,o.Kind,o.Target,until)
+	case OVERRIDE_HOT_WATER_BOOST:
+		return fmt.Sprintf("#%d %s to %d until %s",o.Id,o.Kind,o.Target,until)
+	}
+	return fmt.Sprintf("#%d %s until %s",o.Id,o.Kind,until)
+}
+
+// Returns true if the override applies at the given time
+func (o Override) Active(at time.Time)(bool){
+	return at.Before(o.Expires)
+}
+
+// Checks the kind, the expiry and the target against the safety limits
+func (o Override) validate(limits SafetyLimits)(err error){
+	switch o.Kind {
+	case OVERRIDE_BOILER, OVERRIDE_HOT_WATER_BOOST:
+		if o.Target <= 0 || o.Target > limits.MaxTarget {
+			return fmt.Errorf("override %s: target %d exceeds 1..%d",o.Kind,o.Target,limits.MaxTarget)
+		}
+	case OVERRIDE_HEATING_OFF:
+	default:
+		return fmt.Errorf("override %q: unknown kind, expected %s, %s or %s",o.Kind,OVERRIDE_BOILER,OVERRIDE_HEATING_OFF,OVERRIDE_HOT_WATER_BOOST)
+	}
+	if !o.Expires.After(o.Created) {
+		return fmt.Errorf("override %s: expires %s before it is created",o.Kind,o.Expires.Format(OVERRIDE_TIME_LAYOUT))
+	}
+	return
+}
+
+// Returns the setpoint modified by the override
+func (o Override) apply(setpoint Setpoint)(Setpoint){
+	switch o.Kind {
+	case OVERRIDE_BOILER:
+		setpoint.Boiler,setpoint.HotWater = o.Target,true
+	case OVERRIDE_HEATING_OFF:
+		setpoint.HeatingOff = true
+	case OVERRIDE_HOT_WATER_BOOST:
+		setpoint.HotWater = true
+		if setpoint.Boiler < o.Target {
+			setpoint.Boiler = o.Target
+		}
+	}
+	return setpoint
+}
+
+// Returns true if a boost reached its target with the percept
+func (o Override) completed(percept *Percept)(bool){
+	return o.Kind == OVERRIDE_HOT_WATER_BOOST && Usable(percept.BoilerMidTemp) && percept.BoilerMidTemp.GetValue() >= o.Target
+}
+
+// Overrides ordered by Id, later overrides win
+type Overrides []Override
+
+// Returns the overrides that apply at the given time
+func (l Overrides) Active(at time.Time)(active Overrides){
+	for _,o := range l {
+		if o.Active(at) {
+			active = append(active,o)
+		}
+	}
+	return
+}
+
+/**
+ * Adds the override with the next free Id, expired overrides are dropped
+ * @param o override, Created is set to now if zero
+ * @return error if the override is invalid or exceeds the safety limits
+ */
+func (l Overrides) Add(o Override, limits SafetyLimits)(overrides Overrides, added Override, err error){
+	if o.Created.IsZero() {
+		o.Created = time.Now()
+	}
+	if err = o.validate(limits); err != nil {
+		return l, Override{}, err
+	}
+	o.Id = 1
+	for _,existing := range l {
+		if existing.Id >= o.Id {
+			o.Id = existing.Id + 1
+		}
+	}
+	return append(l.Active(o.Created),o), o, nil
+}
+
+// Removes the override with the given Id
+// @return ErrOverrideUnknown if there is no such override
+func (l Overrides) Remove(id int)(overrides Overrides, err error){
+	for i,o := range l {
+		if o.Id == id {
+			return append(append(Overrides{},l[:i]...),l[i+1:]...), nil
+		}
+	}
+	return l, ErrOverrideUnknown
+}
+
+// Returns the setpoint modified by the overrides that apply at the given time
+func (l Overrides) Apply(setpoint Setpoint, at time.Time)(Setpoint){
+	for _,o := range l.Active(at) {
+		setpoint = o.apply(setpoint)
+	}
+	return setpoint
+}
+
+// Returns the descriptions of the overrides
+func (l Overrides) Strings()(descriptions []string){
+	for _,o := range l {
+		descriptions = append(descriptions,o.String())
+	}
+	return
+}
+
+// representation of the overrides in the file
+type overrideEntry struct {
+	Id int `toml:"id"`
+	Kind string `toml:"kind"`
+	Target int `toml:"target,omitempty"`
+	Created string `toml:"created"`
+	Expires string `toml:"expires"`
+}
+
+type overrideFile struct {
+	Overrides []overrideEntry `toml:"override"`
+}
+
+/**
+ * Reads the overrides saved by SaveOverrides, expired overrides are kept
+ * @return no overrides and no error if the file does not exist, error if it can not be read
+ */
+func LoadOverrides(path string)(overrides Overrides, err error){
+	data,err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return
+	}
+	var file overrideFile
+	if err = toml.Unmarshal(data,&file); err != nil {
+		return nil, fmt.Errorf("%s: %v",path,err)
+	}
+	for _,entry := range file.Overrides {
+		o := Override{Id:entry.Id,Kind:entry.Kind,Target:entry.Target}
+		if o.Created,err = time.Parse(OVERRIDE_TIME_LAYOUT,entry.Created); err == nil {
+			o.Expires,err = time.Parse(OVERRIDE_TIME_LAYOUT,entry.Expires)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: override %d: %v",path,entry.Id,err)
+		}
+		overrides = append(overrides,o)
+	}
+	sort.SliceStable(overrides,func(i, j int)(bool){ return overrides[i].Id < overrides[j].Id })
+	return
+}
+
+// Writes the overrides that did not expire yet to path, the file is replaced at once
+func SaveOverrides(path string, overrides Overrides)(err error){
+	var file overrideFile
+	for _,o := range overrides.Active(time.Now()) {
+		file.Overrides = append(file.Overrides,overrideEntry{
+			Id:o.Id,
+			Kind:o.Kind,
+			Target:o.Target,
+			Created:o.Created.Format(OVERRIDE_TIME_LAYOUT),
+			Expires:o.Expires.Format(OVERRIDE_TIME_LAYOUT),
+		})
+	}
+	data,err := toml.Marshal(&file)
+	if err != nil {
+		return
+	}
+	if err = os.MkdirAll(filepath.Dir(path),0755); err != nil {
+		return
+	}
+	tmp := path + ".tmp"
+	if err = ioutil.WriteFile(tmp,data,0644); err != nil {
+		return
+	}
+	return os.Rename(tmp,path)
+}
+
+/**
+ * Parses the expiry of an override relative to now: a duration ("2h30m"), a time of the day
+ * ("06:00", the next occurrence) or a time ("2006-01-02 15:04") in local time
+ * @return error if the value can not be parsed or is not in the future
+ */
+func ParseExpiry(value string, now time.Time)(expires time.Time, err error){
+	value = strings.TrimSpace(value)
+	if d,derr := time.ParseDuration(value); derr == nil {
+		expires = now.Add(d)
+	} else if clock,cerr := time.ParseInLocation("15:04",value,time.Local); cerr == nil {
+		local := now.Local()
+		expires = time.Date(local.Year(),local.Month(),local.Day(),clock.Hour(),clock.Minute(),0,0,time.Local)
+		if !expires.After(now) {
+			expires = expires.AddDate(0,0,1)
+		}
+	} else if expires,err = parseScheduleTime(value,false); err != nil {
+		return expires, fmt.Errorf("%q is neither a duration (2h), a time of the day (06:00) nor a time (2006-01-02 15:04)",value)
+	}
+	if !expires.After(now) {
+		return expires, fmt.Errorf("%q is not in the future",value)
+	}
+	return
+}
+
+// Logs the overrides that apply to percepts (see Percept.Overrides) in long format.
+// implements logger.Logable interface
+type OverrideLog struct{}
+
+func (l *OverrideLog) GetRelationName()(string){
+	return OVERRIDE_TABLE
+}
+func (l *OverrideLog) CreateRelation()(){
+	var stmnt_string string
+
+	stmnt_string = fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s(" +
+			"po_id INT NOT NULL AUTO_INCREMENT," +
+			"time INT NOT NULL DEFAULT 0," +
+			"override_id INT NOT NULL," +
+			"kind VARCHAR(32) NOT NULL," +
+			"target INT NULL," +
+			"expires INT NOT NULL DEFAULT 0," +
+			"PRIMARY KEY(po_id)," +
+			"UNIQUE percept_override (time,override_id)" +
+			")ENGINE=InnoDB DEFAULT CHARSET=latin1",
+		l.GetRelationName())
+
+	logger.StatementExecute(stmnt_string)
+}
+
+// Inserts the overrides of the given percepts
+// @param val *Percept values
+func (l *OverrideLog) Insert(val ...interface{})(){
+	for _,v := range val {
+		p,ok := v.(*Percept)
+		if !ok || p == nil {
+			continue
+		}
+		for _,o := range p.Overrides {
+			target := "NULL"
+			if o.Target > 0 {
+				target = fmt.Sprint(o.Target)
+			}
+			stmnt_string := fmt.Sprintf(
+				"INSERT IGNORE INTO %s" +
+					"(time,override_id,kind,target,expires)" +
+					" VALUES " +
+					"(%d,%d,'%s',%s,%d)",
+				l.GetRelationName(),p.CurrentTime.Unix(),o.Id,o.Kind,target,o.Expires.Unix())
+			go logger.StatementExecute(stmnt_string)
+		}
+	}
+}
+func (l *OverrideLog) Delete(val ...interface{})(){
+	//@todo
+}
+func (l *OverrideLog) Update(val ...interface{})(){
+	//@todo
+}
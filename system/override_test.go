@@ -0,0 +1,208 @@
+package system
+
+import(
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"github.com/hansen1101/go_heating/system/hardware"
+	"github.com/hansen1101/go_heating/system/w1"
+)
+
+func TestOverrides(t *testing.T){
+	now := time.Date(2026,10,17,16,0,0,0,time.Local)
+	limits := DefaultConfiguration().Limits
+	var overrides Overrides
+	overrides,boiler,err := overrides.Add(Override{Kind:OVERRIDE_BOILER,Target:55000,Created:now,Expires:now.Add(time.Hour * 2)},limits)
+	if err != nil || boiler.Id != 1 {
+		t.Error("For","boiler override","expected","id 1","got",boiler,err)
+	}
+	overrides,off,err := overrides.Add(Override{Kind:OVERRIDE_HEATING_OFF,Created:now,Expires:now.Add(time.Hour * 14)},limits)
+	if err != nil || off.Id != 2 {
+		t.Error("For","heating off","expected","id 2","got",off,err)
+	}
+	invalid := []Override{
+		{Kind:OVERRIDE_BOILER,Target:limits.MaxTarget + 1,Created:now,Expires:now.Add(time.Hour)},
+		{Kind:OVERRIDE_HOT_WATER_BOOST,Created:now,Expires:now.Add(time.Hour)},
+		{Kind:OVERRIDE_HEATING_OFF,Created:now,Expires:now},
+		{Kind:"cooling",Created:now,Expires:now.Add(time.Hour)},
+	}
+	for _,o := range invalid {
+		if _,_,err := overrides.Add(o,limits); err == nil {
+			t.Error("For",o,"expected","error","got",nil)
+		}
+	}
+
+	scheduled := Setpoint{Boiler:40000,Frost:true}
+	expected := Setpoint{Boiler:55000,HotWater:true,Frost:true,HeatingOff:true}
+	if setpoint := overrides.Apply(scheduled,now.Add(time.Hour)); setpoint != expected {
+		t.Error("For","both overrides","expected",expected,"got",setpoint)
+	}
+	expected = Setpoint{Boiler:40000,Frost:true,HeatingOff:true}
+	if setpoint := overrides.Apply(scheduled,now.Add(time.Hour * 3)); setpoint != expected {
+		t.Error("For","expired boiler override","expected",expected,"got",setpoint)
+	}
+	boost := Override{Kind:OVERRIDE_HOT_WATER_BOOST,Target:50000}
+	if setpoint := boost.apply(Setpoint{Boiler:60000}); setpoint.Boiler != 60000 || !setpoint.HotWater {
+		t.Error("For","boost below the scheduled target","expected",60000,"got",setpoint)
+	}
+
+	// adding drops expired overrides
+	later := now.Add(time.Hour * 3)
+	overrides,_,_ = overrides.Add(Override{Kind:OVERRIDE_HOT_WATER_BOOST,Target:50000,Created:later,Expires:later.Add(time.Hour)},limits)
+	if len(overrides) != 2 || overrides[1].Id != 3 {
+		t.Error("For","add after expiry","expected","overrides 2 and 3","got",overrides)
+	}
+	if overrides,err = overrides.Remove(2); err != nil || len(overrides) != 1 {
+		t.Error("For","remove","expected","override 3","got",overrides,err)
+	}
+	if _,err = overrides.Remove(2); err != ErrOverrideUnknown {
+		t.Error("For","remove twice","expected",ErrOverrideUnknown,"got",err)
+	}
+}
+
+func TestSaveOverrides(t *testing.T){
+	dir,err := ioutil.TempDir("","overrides")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir,"lib","overrides.toml")
+	if overrides,err := LoadOverrides(path); err != nil || len(overrides) != 0 {
+		t.Error("For","missing file","expected","no overrides","got",overrides,err)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	overrides := Overrides{
+		{Id:1,Kind:OVERRIDE_BOILER,Target:55000,Created:now.Add(-time.Hour * 3),Expires:now.Add(-time.Hour)},
+		{Id:2,Kind:OVERRIDE_HEATING_OFF,Created:now,Expires:now.Add(time.Hour * 14)},
+		{Id:3,Kind:OVERRIDE_HOT_WATER_BOOST,Target:50000,Created:now,Expires:now.Add(time.Hour)},
+	}
+	if err = SaveOverrides(path,overrides); err != nil {
+		t.Fatal(err)
+	}
+	loaded,err := LoadOverrides(path)
+	if err != nil || len(loaded) != 2 {
+		t.Fatal("For","saved overrides","expected",2,"got",loaded,err)
+	}
+	for i,o := range loaded {
+		if o.Id != overrides[i+1].Id || o.Kind != overrides[i+1].Kind || o.Target != overrides[i+1].Target || !o.Created.Equal(now) || !o.Expires.Equal(overrides[i+1].Expires) {
+			t.Error("For","loaded override","expected",overrides[i+1],"got",o)
+		}
+	}
+
+	ioutil.WriteFile(path,[]byte("[[override]]\nid = 1\nkind = \"boiler\"\ncreated = \"now\"\nexpires = \"later\"\n"),0644)
+	if _,err = LoadOverrides(path); err == nil {
+		t.Error("For","invalid time","expected","error","got",nil)
+	}
+}
+
+func TestParseExpiry(t *testing.T){
+	now := time.Date(2026,10,17,16,0,0,0,time.Local)
+	expected := map[string]time.Time{
+		"2h":now.Add(time.Hour * 2),
+		"06:00":time.Date(2026,10,18,6,0,0,0,time.Local),
+		"18:30":time.Date(2026,10,17,18,30,0,0,time.Local),
+		"2026-10-20 07:15":time.Date(2026,10,20,7,15,0,0,time.Local),
+		"2026-10-20":time.Date(2026,10,20,0,0,0,0,time.Local),
+	}
+	for value,e := range expected {
+		if expires,err := ParseExpiry(value,now); err != nil || !expires.Equal(e) {
+			t.Error("For",value,"expected",e,"got",expires,err)
+		}
+	}
+	for _,value := range []string{"-1h","2026-10-01 06:00","tomorrow"} {
+		if _,err := ParseExpiry(value,now); err == nil {
+			t.Error("For",value,"expected","error","got",nil)
+		}
+	}
+}
+
+// Waits until the oracle holds the given number of overrides
+func awaitOverrides(t *testing.T, o *ConfigurationOracle, count int)(Overrides){
+	for deadline := time.Now().Add(time.Second * 5); time.Now().Before(deadline); time.Sleep(time.Millisecond * 10) {
+		if overrides := o.Overrides(time.Now()); len(overrides) == count {
+			return overrides
+		}
+	}
+	t.Fatal("For","overrides","expected",count,"got",o.Overrides(time.Now()))
+	return nil
+}
+
+func TestConfigurationOracleOverrides(t *testing.T){
+	dir,err := ioutil.TempDir("","overrides")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir,"config","config.csv")
+	os.MkdirAll(filepath.Dir(path),0755)
+	replaceFile(t,path,"Temp/h\n"+curveRow(0,func(hour int)(int){ return 40000 })+"\n"+curveRow(10,func(hour int)(int){ return 30000 })+"\n")
+	overridePath := filepath.Join(dir,"lib","overrides.toml")
+
+	o := NewConfigurationOracle(path,30000)
+	o.SetOverridePath(overridePath)
+	if err := o.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	percept := testPercept(time.Now(),nil)
+	percept.SetTemperature(w1.NewMeasuredTemperature("28-000000000001",hardware.ROLE_OUTSIDE,0,percept.CurrentTime))
+	percept.Valid = true
+	setpoint := func()(Setpoint){
+		setpoint,err := o.Setpoint(context.Background(),percept)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return setpoint
+	}
+
+	boiler,err := o.AddOverride(Override{Kind:OVERRIDE_BOILER,Target:90000,Expires:time.Now().Add(time.Hour)})
+	if err == nil {
+		t.Error("For","override above the safety limit","expected","error","got",boiler)
+	}
+	if boiler,err = o.AddOverride(Override{Kind:OVERRIDE_BOILER,Target:55000,Expires:time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if s := setpoint(); s.Boiler != 55000 || !s.HotWater || s.HeatingOff {
+		t.Error("For","boiler override","expected",55000,"got",s)
+	}
+	if err = o.RemoveOverride(boiler.Id); err != nil || setpoint().Boiler != 40000 {
+		t.Error("For","removed override","expected",40000,"got",setpoint(),err)
+	}
+
+	// a boost ends once the boiler reached its target
+	if _,err = o.AddOverride(Override{Kind:OVERRIDE_HOT_WATER_BOOST,Target:50000,Expires:time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	percept.SetTemperature(w1.NewMeasuredTemperature("28-000000000002",hardware.ROLE_TPO,45000,percept.CurrentTime))
+	if s := setpoint(); s.Boiler != 50000 {
+		t.Error("For","boost","expected",50000,"got",s)
+	}
+	percept.SetTemperature(w1.NewMeasuredTemperature("28-000000000002",hardware.ROLE_TPO,50000,percept.CurrentTime))
+	if s := setpoint(); s.Boiler != 40000 || len(o.Overrides(time.Now())) != 0 {
+		t.Error("For","completed boost","expected",40000,"got",s,o.Overrides(time.Now()))
+	}
+
+	// overrides written by another process are picked up at once
+	now := time.Now()
+	if err = SaveOverrides(overridePath,Overrides{{Id:7,Kind:OVERRIDE_HEATING_OFF,Created:now,Expires:now.Add(time.Hour)}}); err != nil {
+		t.Fatal(err)
+	}
+	if overrides := awaitOverrides(t,o,1); overrides[0].Id != 7 || !setpoint().HeatingOff {
+		t.Error("For","edited file","expected","heating off","got",overrides,setpoint())
+	}
+	o.Stop()
+
+	// and survive a restart
+	restarted := NewConfigurationOracle(path,30000)
+	restarted.SetOverridePath(overridePath)
+	if err := restarted.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer restarted.Stop()
+	if overrides := restarted.Overrides(time.Now()); len(overrides) != 1 || overrides[0].Kind != OVERRIDE_HEATING_OFF {
+		t.Error("For","restart","expected","heating off","got",overrides)
+	}
+}
@@ -3,6 +3,8 @@ package system
 import (
 	"bytes"
 	"fmt"
+	"sort"
+	"github.com/hansen1101/go_heating/system/hardware"
 	"github.com/hansen1101/go_heating/system/logger"
 	"time"
 	"github.com/hansen1101/go_heating/system/w1"
@@ -15,24 +17,106 @@ const (
 type Percept struct {
 	//logger.Relation
 	CurrentTime time.Time
+	// typed accessors of the known sensor roles, kept in sync with Readings by SetTemperature
 	OutsideTemp, BoilerMidTemp, BoilerTopTemp, KettleTemp, HForeRunTemp, HReverseRunTemp, WForeRunTemp, WReverseRunTemp, WIntakeTemp *w1.Temperature
+	// all readings of the percept by logical name
+	Readings map[string]Reading
+	// plausibility rules violated by the percept, see Validate
+	Violations []Violation
+	// manual overrides of the configuration that apply to the percept
+	Overrides Overrides
 	Valid bool
 }
 
 // A PerceptGenerator is a function that generates a Percept for a given time
 type PerceptGenerator func(timestamp *time.Time) (percept *Percept)
 
+// maps the known sensor roles to the typed accessors of a percept
+var roleFields = map[string]func(*Percept)(**w1.Temperature){
+	hardware.ROLE_OUTSIDE:func(p *Percept)(**w1.Temperature){ return &p.OutsideTemp },
+	hardware.ROLE_TPO:func(p *Percept)(**w1.Temperature){ return &p.BoilerMidTemp },
+	hardware.ROLE_TWO:func(p *Percept)(**w1.Temperature){ return &p.BoilerTopTemp },
+	hardware.ROLE_KETTLE:func(p *Percept)(**w1.Temperature){ return &p.KettleTemp },
+	hardware.ROLE_H_FOR:func(p *Percept)(**w1.Temperature){ return &p.HForeRunTemp },
+	hardware.ROLE_H_REV:func(p *Percept)(**w1.Temperature){ return &p.HReverseRunTemp },
+	hardware.ROLE_TPU:func(p *Percept)(**w1.Temperature){ return &p.WForeRunTemp },
+	hardware.ROLE_W_REV:func(p *Percept)(**w1.Temperature){ return &p.WReverseRunTemp },
+	hardware.ROLE_ROOM:func(p *Percept)(**w1.Temperature){ return &p.WIntakeTemp },
+}
+
+// Generates an empty percept for the given time. The typed accessors of the known roles
+// hold invalid temperatures until a reading is set, thus they are never nil.
+func NewPercept(t time.Time)(p *Percept){
+	p = &Percept{CurrentTime:t,Readings:make(map[string]Reading),Valid:true}
+	for role,field := range roleFields {
+		*field(p) = w1.NewTemperature("",role)
+	}
+	return
+}
+
+// Adds the reading to the percept, replaces an earlier reading of the same logical name
+func (p *Percept) Set(r Reading)(){
+	if p.Readings == nil {
+		p.Readings = make(map[string]Reading)
+	}
+	p.Readings[r.GetLogic()] = r
+	if t,ok := r.(TemperatureReading); ok {
+		if field,known := roleFields[r.GetLogic()]; known {
+			*field(p) = t.Temperature
+		}
+	}
+}
+
+// Adds the temperature to the percept under its sensor logic
+// @return the temperature
+func (p *Percept) SetTemperature(t *w1.Temperature)(*w1.Temperature){
+	p.Set(TemperatureReading{t})
+	return t
+}
+
+// Returns the reading of the logical name, nil if the percept has no such reading
+func (p *Percept) Get(logic string)(Reading){
+	return p.Readings[logic]
+}
+
+// Returns the temperature of the logical name, nil if the percept has no such temperature
+func (p *Percept) Temperature(logic string)(*w1.Temperature){
+	if t,ok := p.Readings[logic].(TemperatureReading); ok {
+		return t.Temperature
+	}
+	return nil
+}
+
+// Returns the sorted logical names of all readings
+func (p *Percept) Logics()(logic []string){
+	logic = make([]string,0,len(p.Readings))
+	for l := range p.Readings {
+		logic = append(logic,l)
+	}
+	sort.Strings(logic)
+	return
+}
+
 func (p *Percept) String()(string){
 	var buffer bytes.Buffer
 	buffer.WriteString(fmt.Sprintf("\nPercept at:\t%s\n",p.CurrentTime.String()))
-	buffer.WriteString(fmt.Sprintf("%s Temperature Value:\t%d\t(%v)\n",p.OutsideTemp.GetSensorLogic(),p.OutsideTemp.GetValue(),p.OutsideTemp.IsValid()))
-	buffer.WriteString(fmt.Sprintf("%s Temperature Value:\t%d\t(%v)\n",p.BoilerTopTemp.GetSensorLogic(),p.BoilerTopTemp.GetValue(),p.BoilerTopTemp.IsValid()))
-	buffer.WriteString(fmt.Sprintf("BoilerMidTemp Temperature Value:\t%d\t(%v)\n",p.BoilerMidTemp.GetValue(),p.BoilerMidTemp.IsValid()))
-	buffer.WriteString(fmt.Sprintf("KettleTemp Temperature Value:\t%d\t(%v)\n",p.KettleTemp.GetValue(),p.KettleTemp.IsValid()))
-	buffer.WriteString(fmt.Sprintf("HForeRun Temperature Value:\t%d\t(%v)\n",p.HForeRunTemp.GetValue(),p.HForeRunTemp.IsValid()))
-	buffer.WriteString(fmt.Sprintf("HReverse Temperature Value:\t%d\t(%v)\n",p.HReverseRunTemp.GetValue(),p.HReverseRunTemp.IsValid()))
-	buffer.WriteString(fmt.Sprintf("WForeRun Temperature Value:\t%d\t(%v)\n",p.WForeRunTemp.GetValue(),p.WForeRunTemp.IsValid()))
-	buffer.WriteString(fmt.Sprintf("WReverse Temperature Value:\t%d\t(%v)\n",p.WReverseRunTemp.GetValue(),p.WReverseRunTemp.IsValid()))
+	for _,logic := range p.Logics() {
+		switch r := p.Readings[logic].(type) {
+		case TemperatureReading:
+			buffer.WriteString(fmt.Sprintf("%s Temperature Value:\t%d\traw %d\t(%v)\n",logic,r.GetValue(),r.GetRawValue(),r.IsValid()))
+		default:
+			buffer.WriteString(fmt.Sprintf("%s %s Value:\t%g\t(%v)\n",logic,r.GetKind(),r.GetNumeric(),r.IsValid()))
+		}
+	}
+	if missing,substitutes := p.Missing(),p.Substitutes(); len(missing) > 0 || len(substitutes) > 0 {
+		buffer.WriteString(fmt.Sprintf("Missing:\t%v\tSubstituted:\t%v\n",missing,substitutes))
+	}
+	for _,v := range p.Violations {
+		buffer.WriteString(fmt.Sprintf("Violation:\t%s\t(rejected %v)\n",v,v.Rejected))
+	}
+	for _,o := range p.Overrides {
+		buffer.WriteString(fmt.Sprintf("Override:\t%s\n",o))
+	}
 	buffer.WriteString(fmt.Sprintln())
 	return buffer.String()
 }
@@ -72,8 +156,22 @@ func (p *Percept) CreateRelation()() {
 
 	logger.StatementExecute(stmnt_string)
 }
+// Inserts the percept into the wide relation of the known roles and its readings into the
+// long format relation (see ReadingLog), the overrides that apply as well (see OverrideLog).
+// Missing or invalid temperatures are stored as NULL.
 func (p *Percept) Insert(val ...interface{})() {
-	var stmnt_string string
+	go logger.StatementExecute(p.insertStatement())
+	(&ReadingLog{}).Insert(p)
+	(&OverrideLog{}).Insert(p)
+}
+// Returns the statement that inserts the percept into the percepts relation
+func (p *Percept) insertStatement()(stmnt_string string){
+	column := func(role string)(string){
+		if t := p.Temperature(role); Usable(t) {
+			return fmt.Sprintf("%d",t.GetValue())
+		}
+		return "NULL"
+	}
 
 	stmnt_string = fmt.Sprintf(
 		"INSERT IGNORE INTO %s" +
@@ -89,21 +187,20 @@ func (p *Percept) Insert(val ...interface{})() {
 			"WForeRunTemp," +
 			"WReverseRunTemp)" +
 			" VALUES " +
-			"(%d,%d,%d,%d,%d,%d,%d,%d,%d,%d)",
+			"(%d,%s,%s,%s,%s,%s,%s,%s,%s,%s)",
 		p.GetRelationName(),
 		p.CurrentTime.Unix(),
-		p.OutsideTemp.GetValue(),
-		p.BoilerMidTemp.GetValue(),
-		p.BoilerTopTemp.GetValue(),
-		p.KettleTemp.GetValue(),
-		p.HForeRunTemp.GetValue(),
-		p.HReverseRunTemp.GetValue(),
-		p.WIntakeTemp.GetValue(),
-		p.WForeRunTemp.GetValue(),
-		p.WReverseRunTemp.GetValue(),
+		column(hardware.ROLE_OUTSIDE),
+		column(hardware.ROLE_TPO),
+		column(hardware.ROLE_TWO),
+		column(hardware.ROLE_KETTLE),
+		column(hardware.ROLE_H_FOR),
+		column(hardware.ROLE_H_REV),
+		column(hardware.ROLE_ROOM),
+		column(hardware.ROLE_TPU),
+		column(hardware.ROLE_W_REV),
 	)
-
-	go logger.StatementExecute(stmnt_string)
+	return
 }
 func (p *Percept) Delete(val ...interface{})() {
 	//@todo
@@ -129,10 +226,72 @@ func (p *Percept) GetKettleDelta(successor *Percept)(tempDelta int,recordingDura
 	}
 	return
 }
+// Returns all temperatures of the percept ordered by logical name
+func (p *Percept) Temperatures()(temperatures []*w1.Temperature){
+	for _,logic := range p.Logics() {
+		if t := p.Temperature(logic); t != nil {
+			temperatures = append(temperatures,t)
+		}
+	}
+	return
+}
+
+// Returns the logical names of the readings without a valid value
+// (readings that are not set at all are not listed)
+func (p *Percept) Missing()(logic []string){
+	for _,l := range p.Logics() {
+		if !p.Readings[l].IsValid() {
+			logic = append(logic,l)
+		}
+	}
+	return
+}
+
+// Returns the logical names of the readings that were taken from an earlier percept
+func (p *Percept) Substitutes()(logic []string){
+	for _,l := range p.Logics() {
+		if r := p.Readings[l]; r.IsValid() && isSubstitute(r) {
+			logic = append(logic,l)
+		}
+	}
+	return
+}
+
+// Checks whether all readings of the percept were measured for this percept
+func (p *Percept) IsComplete()(bool){
+	for _,r := range p.Readings {
+		if !r.IsValid() || isSubstitute(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Checks whether the temperature holds a value (measured or substituted)
+func Usable(t *w1.Temperature)(bool){
+	return t != nil && t.IsValid()
+}
+
+// Checks whether the temperature holds a value that was measured for the percept
+func Measured(t *w1.Temperature)(bool){
+	return Usable(t) && !t.IsSubstitute()
+}
+
+// Checks the percept against the plausibility rules (see SetPlausibility), the percept
+// is invalid if a rule that is not warn_only is violated
+// @return true if the percept is valid
 func (p *Percept) Validate()(bool){
-	//@todo needs to be implemented
+	p.Violations = nil
 	p.Valid = true
-	return true
+	if engine := GetPlausibility(); engine != nil {
+		p.Violations = engine.Check(p)
+	}
+	for _,v := range p.Violations {
+		if v.Rejected {
+			p.Valid = false
+		}
+	}
+	return p.Valid
 }
 func (p *Percept) SetTime(t time.Time)(){
 	p.CurrentTime = t
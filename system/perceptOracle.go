@@ -0,0 +1,271 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	ErrOracleRunning = errors.New("oracle is already running")
+	ErrOracleStopped = errors.New("oracle is not running")
+)
+
+// A PerceptSource generates percepts and hands them over through updates until ctx is done
+type PerceptSource func(ctx context.Context, updates chan *Percept)()
+
+// The PerceptOracle maintains a sliding window of the valid percepts of its source and
+// answers requests for the current percept and data queries on the window.
+type PerceptOracle struct {
+	source PerceptSource
+	window []*Percept		// history of percepts generated
+	currentIndex, counter int	// pointer to latest percept and counts of percepts in history
+	windowLock sync.Mutex
+	updated chan struct{}		// closed and replaced whenever the window is updated
+	fresh bool			// a percept of the source entered the window since Start
+	history *History		// aggregated tiers beyond the window
+
+	ctx context.Context		// done when the oracle is stopped
+	cancel context.CancelFunc
+	routines sync.WaitGroup
+	stateLock sync.Mutex
+}
+
+// Generates an oracle for the given source with a sliding window of windowLength seconds
+func NewPerceptOracle(source PerceptSource, windowLength int)(o *PerceptOracle){
+	o = &PerceptOracle{
+		source:source,
+		window:make([]*Percept,windowLength,windowLength),
+		updated:make(chan struct{}),
+		history:NewHistory(HISTORY_READINGS,DefaultTiers...),
+	}
+	return
+}
+
+// Replaces the aggregated history of the oracle, must be called before Start
+func (o *PerceptOracle) SetHistory(h *History)(){
+	o.windowLock.Lock()
+	o.history = h
+	o.windowLock.Unlock()
+}
+
+// Returns the aggregated history of the oracle
+func (o *PerceptOracle) GetHistory()(*History){
+	o.windowLock.Lock()
+	defer o.windowLock.Unlock()
+	return o.history
+}
+
+// Starts the source and the sliding window update routine. Both run until ctx is done
+// or Stop is called.
+// @return ErrOracleRunning if the oracle was already started
+func (o *PerceptOracle) Start(ctx context.Context)(error){
+	o.stateLock.Lock()
+	defer o.stateLock.Unlock()
+	if o.ctx != nil && o.ctx.Err() == nil {
+		return ErrOracleRunning
+	}
+	o.ctx,o.cancel = context.WithCancel(ctx)
+	updates := make(chan *Percept)
+	// percepts of an earlier run or a snapshot are history, not the current state
+	o.windowLock.Lock()
+	o.fresh = false
+	o.windowLock.Unlock()
+
+	o.routines.Add(2)
+	go func(ctx context.Context)(){
+		defer o.routines.Done()
+		o.source(ctx,updates)
+	}(o.ctx)
+
+	// receives percepts and updates the sliding window, the source ensures only valid
+	// percepts enter the window
+	go func(ctx context.Context)(){
+		defer o.routines.Done()
+		for {
+			select {
+			case p := <-updates:
+				if p != nil && p.IsValid() {
+					o.windowLock.Lock()
+					updateSlidingWindow(&o.window,p,&o.currentIndex,&o.counter)
+					o.fresh = true
+					if o.history != nil {
+						o.history.Add(p)
+					}
+					close(o.updated)
+					o.updated = make(chan struct{})
+					o.windowLock.Unlock()
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}(o.ctx)
+	return nil
+}
+
+// Stops the oracle and waits until its routines terminated
+func (o *PerceptOracle) Stop()(){
+	o.stateLock.Lock()
+	ctx,cancel := o.ctx,o.cancel
+	o.stateLock.Unlock()
+	if ctx == nil {
+		return
+	}
+	cancel()
+	o.routines.Wait()
+}
+
+// Returns the context of the running oracle
+func (o *PerceptOracle) running()(context.Context, error){
+	o.stateLock.Lock()
+	defer o.stateLock.Unlock()
+	if o.ctx == nil || o.ctx.Err() != nil {
+		return nil, ErrOracleStopped
+	}
+	return o.ctx, nil
+}
+
+// Returns the latest percept of the sliding window, waits for the first percept of the
+// source after Start, thus restored percepts are never returned
+// @return error if ctx is done or the oracle is stopped before a percept is available
+func (o *PerceptOracle) Current(ctx context.Context)(*Percept, error){
+	for {
+		running,err := o.running()
+		if err != nil {
+			return nil, err
+		}
+		o.windowLock.Lock()
+		current,fresh,updated := o.window[o.currentIndex],o.fresh,o.updated
+		o.windowLock.Unlock()
+		if current != nil && fresh {
+			return current, nil
+		}
+		select {
+		case <-updated:
+		case <-running.Done():
+			return nil, ErrOracleStopped
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Calculates the queries on the sliding window, one response for each query and Calculation_info
+// with positive Sec
+// @return ErrOracleStopped if the oracle is not running
+func (o *PerceptOracle) Query(queries []DataQuery, info ...Calculation_info)(responses []DataResponse, err error){
+	if _,err = o.running(); err != nil {
+		return nil, err
+	}
+	responses = make([]DataResponse,0,len(queries)*len(info))
+	o.windowLock.Lock()
+	defer o.windowLock.Unlock()
+	for _,req := range queries {
+		logic,known := QueryLogic[req]
+		if !known {
+			return nil, ErrUnknownQuery
+		}
+		data := func(i int)(int, int64, error){
+			return windowTemperature(o.window,i,logic)
+		}
+		var meanAlgorithm meanCalc
+		switch req {
+		case WATER_BUFFER_DELTA:
+			meanAlgorithm = totalDeltaInterval
+		case BOILER_DELTA, REVERSE_DELTA:
+			meanAlgorithm = expWeightedMovingAverage
+		default:
+			meanAlgorithm = naiveMean
+		}
+		for _,j := range info {
+			if j.Sec > 0 {
+				responses = append(responses,calculateTempDelta(&o.window,j.Weight,j.Sec,o.currentIndex,req,data,meanAlgorithm))
+			}
+		}
+	}
+	return
+}
+
+// Answers the queries on the history of the readings, the range of the queries ends at
+// the latest percept. Queries are answered by the sliding window (resolution 1 s) or by
+// the aggregated tier of the requested resolution, by default by the finest that covers
+// the range.
+// @return one result per query or the first error (ErrInvalidQuery, ErrNoSamples or
+// ErrOracleStopped); results of queries without error are returned anyway
+func (o *PerceptOracle) Ask(queries ...Query)(results []Result, err error){
+	if _,err = o.running(); err != nil {
+		return nil, err
+	}
+	// keeps the first error
+	fail := func(e error)(){
+		if err == nil {
+			err = e
+		}
+	}
+	results = make([]Result,len(queries))
+	o.windowLock.Lock()
+	defer o.windowLock.Unlock()
+	latest := o.window[o.currentIndex]
+	for i,q := range queries {
+		results[i].Query = q
+		if q_err := q.Validate(); q_err != nil {
+			fail(q_err)
+			continue
+		}
+		var samples []Sample
+		switch {
+		case q.Resolution == time.Second || q.Resolution == 0 && (q.Range <= time.Second * time.Duration(len(o.window)) || o.history == nil):
+			q.Resolution = time.Second
+			if latest != nil {
+				samples = windowSamples(o.window,q.Logic,latest.CurrentTime,q.Range)
+			}
+		case o.history == nil:
+			fail(fmt.Errorf("%v: no history with resolution %s",ErrInvalidQuery,q.Resolution))
+			continue
+		default:
+			tier,t_err := o.history.tier(q.Range,q.Resolution)
+			if t_err != nil {
+				fail(t_err)
+				continue
+			}
+			q.Resolution = tier.Resolution
+			if latest != nil {
+				samples = o.history.samples(tier,q,latest.CurrentTime)
+			}
+		}
+		var q_err error
+		if results[i],q_err = Aggregate(q,samples); q_err != nil {
+			fail(fmt.Errorf("%s: %v",q,q_err))
+		}
+	}
+	return
+}
+
+// Serves the package channels Percept_request_chan and Query_request_chan by the oracle
+// until ctx is done, thus clients of the channel API keep working.
+func (o *PerceptOracle) ServeChannels(ctx context.Context)(){
+	Percept_request_chan = make(chan chan *Percept)
+	Query_request_chan = make(chan *dataRequest)
+	go func(percepts chan chan *Percept, queries chan *dataRequest)(){
+		for {
+			select {
+			case percept_chan := <-percepts:
+				// the channel API has no way to report errors, the client gets nil
+				p,_ := o.Current(ctx)
+				percept_chan <- p
+			case request := <-queries:
+				info := make([]Calculation_info,len(request.calc_info))
+				for i,j := range request.calc_info {
+					info[i] = Calculation_info(j)
+				}
+				resp,_ := o.Query(request.request,info...)
+				request.Endpoint <- resp
+			case <-ctx.Done():
+				return
+			}
+		}
+	}(Percept_request_chan,Query_request_chan)
+}
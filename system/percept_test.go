@@ -0,0 +1,132 @@
+package system
+
+import(
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"github.com/hansen1101/go_heating/system/hardware"
+	"github.com/hansen1101/go_heating/system/w1"
+)
+
+// Reads a temperature of the given value from a sensor file in dir
+func readTemperature(t *testing.T, dir, id, logic string, value int)(*w1.Temperature){
+	if err := os.MkdirAll(filepath.Join(dir,id),0755); err != nil {
+		t.Fatal(err)
+	}
+	data := fmt.Sprintf("5f 01 4b 46 7f ff 01 10 9b : crc=9b YES\n5f 01 4b 46 7f ff 01 10 9b t=%d\n",value)
+	if err := ioutil.WriteFile(filepath.Join(dir,id,"w1_slave"),[]byte(data),0644); err != nil {
+		t.Fatal(err)
+	}
+	var logDestination io.Writer = ioutil.Discard
+	var logMutex sync.Mutex
+	temp := w1.SensorTemperaturGenerator(id,logic,&logDestination,&logMutex)
+	return &temp
+}
+
+func TestPerceptReadings(t *testing.T){
+	dir,err := ioutil.TempDir("","percept")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(prefix string){ w1.SENSOR_PATH_PREFIX = prefix }(w1.SENSOR_PATH_PREFIX)
+	w1.SENSOR_PATH_PREFIX = dir+"/"
+
+	p := NewPercept(time.Now())
+	if p.KettleTemp == nil || p.KettleTemp.IsValid() {
+		t.Error("For","typed accessor of an empty percept","expected","invalid temperature","got",p.KettleTemp)
+	}
+
+	kettle := p.SetTemperature(readTemperature(t,dir,"28-000000000001",hardware.ROLE_KETTLE,62000))
+	p.SetTemperature(readTemperature(t,dir,"28-000000000002","Garage",8000))
+	p.Set(FlowReading{Logic:"solar_flow",LitersPerHour:120,Valid:true})
+	p.Set(StateReading{Logic:"door",Valid:false})
+
+	if p.KettleTemp != kettle || p.Temperature(hardware.ROLE_KETTLE) != kettle {
+		t.Error("For","typed accessor","expected",kettle,"got",p.KettleTemp)
+	}
+	if garage := p.Temperature("Garage"); garage == nil || garage.GetValue() != 8000 {
+		t.Error("For","custom sensor","expected",8000,"got",garage)
+	}
+	if r := p.Get("solar_flow"); r == nil || r.GetKind() != FLOW || r.GetNumeric() != 120 {
+		t.Error("For","flow reading","expected",120,"got",r)
+	}
+	if p.Temperature("solar_flow") != nil {
+		t.Error("For","temperature of a flow reading","expected",nil,"got",p.Temperature("solar_flow"))
+	}
+	if logics := p.Logics(); !reflect.DeepEqual(logics,[]string{"Garage",hardware.ROLE_KETTLE,"door","solar_flow"}) {
+		t.Error("For","Logics","expected","sorted names","got",logics)
+	}
+	if missing := p.Missing(); !reflect.DeepEqual(missing,[]string{"door"}) {
+		t.Error("For","Missing","expected",[]string{"door"},"got",missing)
+	}
+	if p.IsComplete() {
+		t.Error("For","IsComplete","expected",false,"got",true)
+	}
+
+	p.SetTemperature(kettle.Substitute())
+	if substitutes := p.Substitutes(); !reflect.DeepEqual(substitutes,[]string{hardware.ROLE_KETTLE}) {
+		t.Error("For","Substitutes","expected",[]string{hardware.ROLE_KETTLE},"got",substitutes)
+	}
+	if s := p.String(); !strings.Contains(s,"Garage Temperature Value:\t8000") || !strings.Contains(s,"solar_flow flow Value:\t120") {
+		t.Error("For","String","expected","all readings","got",s)
+	}
+}
+
+func TestWindowTemperature(t *testing.T){
+	dir,err := ioutil.TempDir("","percept")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(prefix string){ w1.SENSOR_PATH_PREFIX = prefix }(w1.SENSOR_PATH_PREFIX)
+	w1.SENSOR_PATH_PREFIX = dir+"/"
+
+	now := time.Now()
+	window := make([]*Percept,3)
+	window[1] = NewPercept(now)
+	window[1].SetTemperature(readTemperature(t,dir,"28-000000000001",hardware.ROLE_TPO,45000))
+
+	if _,_,err := windowTemperature(window,0,hardware.ROLE_TPO); err == nil {
+		t.Error("For","empty slot","expected","error","got",nil)
+	}
+	if value,timestamp,err := windowTemperature(window,1,QueryLogic[BOILER_DELTA]); err != nil || value != 45000 || timestamp != now.Unix() {
+		t.Error("For","boiler delta","expected",45000,"got",value,timestamp,err)
+	}
+	if _,_,err := windowTemperature(window,1,QueryLogic[REVERSE_DELTA]); err == nil {
+		t.Error("For","missing reverse run","expected","error","got",nil)
+	}
+}
+
+func TestPerceptStatements(t *testing.T){
+	dir,err := ioutil.TempDir("","percept")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(prefix string){ w1.SENSOR_PATH_PREFIX = prefix }(w1.SENSOR_PATH_PREFIX)
+	w1.SENSOR_PATH_PREFIX = dir+"/"
+
+	now := time.Now()
+	p := NewPercept(now)
+	p.SetTemperature(readTemperature(t,dir,"28-000000000001",hardware.ROLE_ROOM,21000))
+	p.SetTemperature(readTemperature(t,dir,"28-000000000002",hardware.ROLE_TPU,40000))
+	p.SetTemperature(readTemperature(t,dir,"28-000000000003","Attic's \\",15000))
+
+	// the room temperature keeps its legacy column H2ForeRunTemp
+	expected := fmt.Sprintf("(%d,NULL,NULL,NULL,NULL,NULL,NULL,21000,40000,NULL)",now.Unix())
+	if statement := p.insertStatement(); !strings.HasSuffix(statement,expected) || !strings.Contains(statement,"H2ForeRunTemp,WForeRunTemp,WReverseRunTemp)") {
+		t.Error("For","percept statement","expected",expected,"got",statement)
+	}
+	statements := (&ReadingLog{}).insertStatements(p)
+	if len(statements) != 3 || !strings.Contains(statements[0],"'Attic''s \\\\'") {
+		t.Error("For","reading statements","expected","escaped logic","got",statements)
+	}
+}
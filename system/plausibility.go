@@ -0,0 +1,348 @@
+package system
+
+import(
+	"fmt"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"github.com/hansen1101/go_heating/auxiliary/toml"
+	"github.com/hansen1101/go_heating/system/logger"
+)
+
+const (
+	RULE_ORDER = "order"	// reading a must not fall below reading b by more than tolerance
+	RULE_RATE = "rate"	// reading a must not change faster than max_rate per second
+	RULE_RANGE = "range"	// reading a must be within min and max
+
+	VIOLATION_TABLE = "plausibility_violations"
+	MAX_RULE_NAME_LENGTH = 64	// rule names are logged to the violation table
+)
+
+var (
+	plausibility *PlausibilityEngine	// engine used by Percept.Validate, nil accepts every percept
+	plausibilityMutex sync.RWMutex
+)
+
+// A plausibility rule as declared in the rule file. Values are given in the unit of the
+// readings, thus millidegree for temperatures.
+type Rule struct {
+	Name string `toml:"name"`
+	Type string `toml:"type"`
+	A string `toml:"a"`				// logical name of the checked reading
+	B string `toml:"b,omitempty"`			// logical name of the reference reading (order)
+	Tolerance float64 `toml:"tolerance,omitempty"`	// allowed deviation (order)
+	MaxRate float64 `toml:"max_rate,omitempty"`	// max change per second (rate)
+	Min float64 `toml:"min,omitempty"`		// lower bound (range)
+	Max float64 `toml:"max,omitempty"`		// upper bound (range)
+	While string `toml:"while,omitempty"`		// binary reading, the rule is only checked while it is true
+	For time.Duration `toml:"for,omitempty"`	// the violation has to persist this long
+	WarnOnly bool `toml:"warn_only,omitempty"`	// violations are reported but the percept is not rejected
+}
+
+// The rule file
+type RuleSet struct {
+	Rules []Rule `toml:"rule"`
+}
+
+// A failed rule of a percept
+type Violation struct {
+	Rule string
+	Message string
+	Rejected bool	// the violation rejected the percept
+}
+
+func (v Violation) String()(string){
+	return fmt.Sprintf("%s: %s",v.Rule,v.Message)
+}
+
+// Check and violation counters of a rule
+type RuleStat struct {
+	Checks int	// percepts the rule could be checked on
+	Skipped int	// percepts without the readings of the rule
+	Violations int	// percepts that violated the rule
+	LastViolation time.Time
+}
+
+func (s RuleStat) String()(string){
+	return fmt.Sprintf("checks %d\tskipped %d\tviolations %d",s.Checks,s.Skipped,s.Violations)
+}
+
+type ruleState struct {
+	since time.Time	// begin of the current violation, zero if the rule holds
+	stat RuleStat
+}
+
+type lastReading struct {
+	value float64
+	time time.Time
+}
+
+// Checks percepts against a set of plausibility rules. The engine keeps the readings of
+// the last checked percept (rate rules) and the begin of persisting violations.
+type PlausibilityEngine struct {
+	rules []Rule
+	states map[string]*ruleState
+	last map[string]lastReading
+	mutex sync.Mutex
+}
+
+// Reads the rule file at path
+// @return the rules or an error if the file can not be read or contains invalid rules
+func LoadRules(path string)(rules []Rule, err error){
+	var data []byte
+	if data,err = ioutil.ReadFile(path); err != nil {
+		return nil, err
+	}
+	set := RuleSet{}
+	if err = toml.Unmarshal(data,&set); err != nil {
+		return nil, fmt.Errorf("%s: %v",path,err)
+	}
+	if err = ValidateRules(set.Rules); err != nil {
+		return nil, fmt.Errorf("%s: %v",path,err)
+	}
+	return set.Rules, nil
+}
+
+// Checks the rules for unique names, known types and the parameters required by their type
+func ValidateRules(rules []Rule)(error){
+	problems := make([]string,0)
+	report := func(format string, a ...interface{}){
+		problems = append(problems,fmt.Sprintf(format,a...))
+	}
+	names := make(map[string]bool)
+	for _,r := range rules {
+		if r.Name == "" || names[r.Name] {
+			report("rule %q: name is empty or used twice",r.Name)
+		} else if len(r.Name) > MAX_RULE_NAME_LENGTH {
+			report("rule %q: name is longer than %d characters",r.Name,MAX_RULE_NAME_LENGTH)
+		}
+		names[r.Name] = true
+		if r.A == "" {
+			report("rule %s: reading a is missing",r.Name)
+		}
+		if r.For < 0 {
+			report("rule %s: for must not be negative",r.Name)
+		}
+		switch r.Type {
+		case RULE_ORDER:
+			if r.B == "" {
+				report("rule %s: reading b is missing",r.Name)
+			}
+			if r.Tolerance < 0 {
+				report("rule %s: tolerance must not be negative",r.Name)
+			}
+		case RULE_RATE:
+			if r.MaxRate <= 0 {
+				report("rule %s: max_rate must be positive",r.Name)
+			}
+		case RULE_RANGE:
+			if r.Min > r.Max {
+				report("rule %s: min %g is above max %g",r.Name,r.Min,r.Max)
+			}
+		default:
+			report("rule %s: unknown type %q (%s, %s, %s)",r.Name,r.Type,RULE_ORDER,RULE_RATE,RULE_RANGE)
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid plausibility rules:\n\t%s",strings.Join(problems,"\n\t"))
+	}
+	return nil
+}
+
+// Generates an engine for the given rules
+func NewPlausibilityEngine(rules []Rule)(e *PlausibilityEngine){
+	e = &PlausibilityEngine{rules:rules,states:make(map[string]*ruleState),last:make(map[string]lastReading)}
+	for _,r := range rules {
+		e.states[r.Name] = &ruleState{}
+	}
+	return
+}
+
+// Sets the engine that is used by Percept.Validate, nil disables the plausibility check
+func SetPlausibility(e *PlausibilityEngine)(){
+	plausibilityMutex.Lock()
+	plausibility = e
+	plausibilityMutex.Unlock()
+}
+
+// Returns the engine that is used by Percept.Validate
+func GetPlausibility()(*PlausibilityEngine){
+	plausibilityMutex.RLock()
+	defer plausibilityMutex.RUnlock()
+	return plausibility
+}
+
+// Checks the percept against all rules. Rules whose readings are missing or invalid are
+// skipped. Afterwards the readings of the percept are the reference of the rate rules,
+// except readings of rules that rejected the percept, e.g. a spike is not the reference
+// of the next reading.
+// @return the violated rules
+func (e *PlausibilityEngine) Check(p *Percept)(violations []Violation){
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	rejected := make(map[string]bool)
+	for _,r := range e.rules {
+		state := e.states[r.Name]
+		message,checked := e.check(r,p)
+		if !checked {
+			state.stat.Skipped++
+			continue
+		}
+		state.stat.Checks++
+		if message == "" {
+			state.since = time.Time{}
+			continue
+		}
+		if state.since.IsZero() {
+			state.since = p.CurrentTime
+		}
+		if p.CurrentTime.Sub(state.since) < r.For {
+			// violation does not persist long enough yet
+			continue
+		}
+		state.stat.Violations++
+		state.stat.LastViolation = p.CurrentTime
+		violations = append(violations,Violation{Rule:r.Name,Message:message,Rejected:!r.WarnOnly})
+		if !r.WarnOnly {
+			rejected[r.A] = true
+			if r.Type == RULE_ORDER {
+				rejected[r.B] = true
+			}
+		}
+	}
+
+	for logic,reading := range p.Readings {
+		if reading.IsValid() && !isSubstitute(reading) && !rejected[logic] {
+			e.last[logic] = lastReading{reading.GetNumeric(),p.CurrentTime}
+		}
+	}
+	return
+}
+
+// Checks a single rule
+// @return a description of the violation, empty if the rule holds, and whether the rule could be checked
+func (e *PlausibilityEngine) check(r Rule, p *Percept)(message string, checked bool){
+	if r.While != "" {
+		if w := p.Get(r.While); w == nil || !w.IsValid() || w.GetNumeric() == 0 {
+			return "", false
+		}
+	}
+	a := p.Get(r.A)
+	if a == nil || !a.IsValid() || isSubstitute(a) {
+		return "", false
+	}
+	value := a.GetNumeric()
+
+	switch r.Type {
+	case RULE_ORDER:
+		b := p.Get(r.B)
+		if b == nil || !b.IsValid() || isSubstitute(b) {
+			return "", false
+		}
+		if reference := b.GetNumeric(); value < reference - r.Tolerance {
+			message = fmt.Sprintf("%s %g is below %s %g by more than %g",r.A,value,r.B,reference,r.Tolerance)
+		}
+	case RULE_RATE:
+		last,ok := e.last[r.A]
+		seconds := p.CurrentTime.Sub(last.time).Seconds()
+		if !ok || seconds <= 0 {
+			return "", false
+		}
+		if rate := math.Abs(value - last.value) / seconds; rate > r.MaxRate {
+			message = fmt.Sprintf("%s changed by %g/s (max %g/s)",r.A,rate,r.MaxRate)
+		}
+	case RULE_RANGE:
+		if value < r.Min || value > r.Max {
+			message = fmt.Sprintf("%s %g is not within %g and %g",r.A,value,r.Min,r.Max)
+		}
+	}
+	return message, true
+}
+
+// Returns the counters of all rules by rule name
+func (e *PlausibilityEngine) GetRuleStats()(stats map[string]RuleStat){
+	e.mutex.Lock()
+	stats = make(map[string]RuleStat,len(e.states))
+	for name,state := range e.states {
+		stats[name] = state.stat
+	}
+	e.mutex.Unlock()
+	return
+}
+
+// Returns the sorted names of all rules
+func (e *PlausibilityEngine) RuleNames()(names []string){
+	for _,r := range e.rules {
+		names = append(names,r.Name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// Logs the rule violations of percepts together with the violation counter of the rule.
+// implements logger.Logable interface
+type ViolationLog struct{
+	Engine *PlausibilityEngine
+}
+
+func (l *ViolationLog) GetRelationName()(string){
+	return VIOLATION_TABLE
+}
+func (l *ViolationLog) CreateRelation()(){
+	var stmnt_string string
+
+	stmnt_string = fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s(" +
+			"v_id INT NOT NULL AUTO_INCREMENT," +
+			"time INT NOT NULL DEFAULT 0," +
+			"rule VARCHAR(64) NOT NULL," +
+			"message VARCHAR(255) NOT NULL DEFAULT ''," +
+			"rejected BIT(1) NOT NULL DEFAULT b'1'," +
+			"violations INT NOT NULL DEFAULT 0," +
+			"checks INT NOT NULL DEFAULT 0," +
+			"PRIMARY KEY(v_id)" +
+			")ENGINE=InnoDB DEFAULT CHARSET=latin1",
+		l.GetRelationName())
+
+	logger.StatementExecute(stmnt_string)
+}
+
+// Inserts the violations of the given percepts
+// @param val *Percept values
+func (l *ViolationLog) Insert(val ...interface{})(){
+	var stats map[string]RuleStat
+	if l.Engine != nil {
+		stats = l.Engine.GetRuleStats()
+	}
+	for _,v := range val {
+		p,ok := v.(*Percept)
+		if !ok || p == nil {
+			continue
+		}
+		escape := func(s string)(string){ return strings.Replace(strings.Replace(s,"\\","\\\\",-1),"'","''",-1) }
+		for _,violation := range p.Violations {
+			rejected := 0
+			if violation.Rejected {
+				rejected = 1
+			}
+			stat := stats[violation.Rule]
+			stmnt_string := fmt.Sprintf(
+				"INSERT INTO %s" +
+					"(time,rule,message,rejected,violations,checks)" +
+					" VALUES " +
+					"(%d,'%s','%s',b'%d',%d,%d)",
+				l.GetRelationName(),p.CurrentTime.Unix(),escape(violation.Rule),escape(violation.Message),rejected,stat.Violations,stat.Checks)
+			go logger.StatementExecute(stmnt_string)
+		}
+	}
+}
+func (l *ViolationLog) Delete(val ...interface{})(){
+	//@todo
+}
+func (l *ViolationLog) Update(val ...interface{})(){
+	//@todo
+}
@@ -0,0 +1,121 @@
+package system
+
+import(
+	"strings"
+	"testing"
+	"time"
+	"github.com/hansen1101/go_heating/system/hardware"
+)
+
+const (
+	TEST_RULES = "../filesystem/heating_config/plausibility.toml"
+)
+
+// Generates a percept at the given time with the given readings as flows, the
+// reading kind does not matter for the rules
+func testPercept(t time.Time, readings map[string]float64)(p *Percept){
+	p = &Percept{CurrentTime:t}
+	for logic,value := range readings {
+		p.Set(FlowReading{Logic:logic,LitersPerHour:value,Valid:true})
+	}
+	return
+}
+
+func TestLoadRules(t *testing.T){
+	rules,err := LoadRules(TEST_RULES)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 5 {
+		t.Error("For","number of rules","expected",5,"got",len(rules))
+	}
+	for _,r := range rules {
+		for _,logic := range []string{r.A,r.B} {
+			if logic != "" && !hardware.IsRole(logic) {
+				t.Error("For",r.Name,"expected","known role","got",logic)
+			}
+		}
+	}
+	if rules[1].For != time.Minute * 20 || rules[0].While != "radiator" {
+		t.Error("For","rule options","expected","20m and radiator","got",rules[1].For,rules[0].While)
+	}
+}
+
+func TestValidateRules(t *testing.T){
+	invalid := []Rule{
+		{Name:"a",Type:RULE_ORDER,A:"x"},
+		{Name:"a",Type:RULE_RATE,A:"x"},
+		{Name:"b",Type:RULE_RANGE,A:"x",Min:2,Max:1},
+		{Name:"c",Type:"faster",A:"x"},
+		{Name:strings.Repeat("d",MAX_RULE_NAME_LENGTH+1),Type:RULE_RANGE,A:"x"},
+	}
+	err := ValidateRules(invalid)
+	if err == nil {
+		t.Fatal("For","invalid rules","expected","error","got",nil)
+	}
+	for _,problem := range []string{"reading b is missing","used twice","max_rate","min 2 is above max 1","unknown type","longer than 64 characters"} {
+		if !strings.Contains(err.Error(),problem) {
+			t.Error("For","invalid rules","expected",problem,"got",err)
+		}
+	}
+}
+
+func TestPlausibilityRules(t *testing.T){
+	e := NewPlausibilityEngine([]Rule{
+		{Name:"order",Type:RULE_ORDER,A:"for",B:"rev",Tolerance:3000,While:"pump"},
+		{Name:"persisting",Type:RULE_ORDER,A:"top",B:"bottom",Tolerance:5000,For:time.Minute * 20},
+		{Name:"rate",Type:RULE_RATE,A:"kettle",MaxRate:500},
+		{Name:"range",Type:RULE_RANGE,A:"outside",Min:-40000,Max:50000,WarnOnly:true},
+	})
+	now := time.Now()
+	check := func(p *Percept, expected ...string)(){
+		violations := e.Check(p)
+		names := make([]string,0)
+		for _,v := range violations {
+			names = append(names,v.Rule)
+		}
+		if strings.Join(names,",") != strings.Join(expected,",") {
+			t.Error("For","percept at",p.CurrentTime.Sub(now),"expected",expected,"got",violations)
+		}
+	}
+
+	// fore run below reverse run only counts while the pump runs
+	check(testPercept(now,map[string]float64{"for":30000,"rev":40000,"pump":0,"kettle":60000,"top":50000,"bottom":40000}))
+	check(testPercept(now.Add(time.Second * 10),map[string]float64{"for":30000,"rev":40000,"pump":1,"kettle":61000,"top":50000,"bottom":40000}),"order")
+
+	// kettle jumps by 2 K/s, the spike is not the reference of the next percept
+	check(testPercept(now.Add(time.Second * 20),map[string]float64{"kettle":81000}),"rate")
+	check(testPercept(now.Add(time.Second * 30),map[string]float64{"kettle":61500}))
+
+	// a value that stays wrong is rejected until it is reached at a plausible rate
+	check(testPercept(now.Add(time.Second * 40),map[string]float64{"kettle":81000}),"rate")
+	check(testPercept(now.Add(time.Second * 50),map[string]float64{"kettle":81000}),"rate")
+	check(testPercept(now.Add(time.Second * 70),map[string]float64{"kettle":81000}))
+
+	// boiler stratification has to be violated for 20 minutes
+	check(testPercept(now.Add(time.Minute),map[string]float64{"top":30000,"bottom":40000}))
+	check(testPercept(now.Add(time.Minute * 15),map[string]float64{"top":30000,"bottom":40000}))
+	check(testPercept(now.Add(time.Minute * 21),map[string]float64{"top":30000,"bottom":40000}),"persisting")
+	check(testPercept(now.Add(time.Minute * 22),map[string]float64{"top":40000,"bottom":40000}))
+	check(testPercept(now.Add(time.Minute * 23),map[string]float64{"top":30000,"bottom":40000}))
+
+	stats := e.GetRuleStats()
+	if s := stats["order"]; s.Checks != 1 || s.Violations != 1 || s.Skipped != 11 {
+		t.Error("For","order stats","expected","1 check, 1 violation, 11 skipped","got",s)
+	}
+	if s := stats["persisting"]; s.Checks != 7 || s.Violations != 1 {
+		t.Error("For","persisting stats","expected","7 checks, 1 violation","got",s)
+	}
+
+	// warn only violations do not reject the percept
+	SetPlausibility(e)
+	defer SetPlausibility(nil)
+	p := testPercept(now.Add(time.Hour),map[string]float64{"outside":60000})
+	if !p.Validate() || len(p.Violations) != 1 || p.Violations[0].Rejected {
+		t.Error("For","warn only violation","expected","valid percept","got",p.Valid,p.Violations)
+	}
+	p = testPercept(now.Add(time.Hour + time.Second),map[string]float64{"for":30000,"rev":40000,"pump":1})
+	if p.Validate() || !strings.Contains(p.String(),"Violation:\torder") {
+		t.Error("For","violated rule","expected","invalid percept","got",p)
+	}
+}
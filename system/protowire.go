@@ -0,0 +1,237 @@
+package system
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Wire types of the protocol buffer encoding
+const (
+	WIRE_VARINT = 0
+	WIRE_FIXED64 = 1
+	WIRE_BYTES = 2
+	WIRE_FIXED32 = 5
+)
+
+/**
+ * Minimal encoder of the protocol buffer wire format for the messages of configuration.proto.
+ * Scalars are written even if 0, thus they override the defaults of the decoder. Empty strings
+ * are not written, repeated sint32 are packed.
+ */
+type protoEncoder struct {
+	buffer []byte
+}
+
+func (e *protoEncoder) key(field int, wire int)(){
+	e.buffer = binary.AppendUvarint(e.buffer,uint64(field) << 3 | uint64(wire))
+}
+
+func (e *protoEncoder) putUint(field int, v uint64)(){
+	e.key(field,WIRE_VARINT)
+	e.buffer = binary.AppendUvarint(e.buffer,v)
+}
+
+// zigzag encoded as sint32/sint64
+func (e *protoEncoder) putSint(field int, v int64)(){
+	e.key(field,WIRE_VARINT)
+	e.buffer = binary.AppendVarint(e.buffer,v)
+}
+
+func (e *protoEncoder) putDouble(field int, v float64)(){
+	e.key(field,WIRE_FIXED64)
+	e.buffer = binary.LittleEndian.AppendUint64(e.buffer,math.Float64bits(v))
+}
+
+func (e *protoEncoder) putBytes(field int, v []byte)(){
+	e.key(field,WIRE_BYTES)
+	e.buffer = binary.AppendUvarint(e.buffer,uint64(len(v)))
+	e.buffer = append(e.buffer,v...)
+}
+
+func (e *protoEncoder) putString(field int, v string)(){
+	if v == "" {
+		return
+	}
+	e.putBytes(field,[]byte(v))
+}
+
+// Writes the message encoded by encode as field
+func (e *protoEncoder) putMessage(field int, encode func(*protoEncoder))(){
+	var m protoEncoder
+	encode(&m)
+	e.putBytes(field,m.buffer)
+}
+
+func (e *protoEncoder) putPackedSint(field int, values []int)(){
+	if len(values) == 0 {
+		return
+	}
+	var packed []byte
+	for _,v := range values {
+		packed = binary.AppendVarint(packed,int64(v))
+	}
+	e.putBytes(field,packed)
+}
+
+/**
+ * Decoder of the protocol buffer wire format, counterpart of protoEncoder. Unknown fields are
+ * skipped, thus older programs read messages of newer schemas. The first error is kept.
+ */
+type protoDecoder struct {
+	data []byte
+	err error
+}
+
+// Returns false if the message is read completely or an error occurred
+func (d *protoDecoder) more()(bool){
+	return d.err == nil && len(d.data) > 0
+}
+
+func (d *protoDecoder) fail(format string, a ...interface{})(){
+	if d.err == nil {
+		d.err = fmt.Errorf("protobuf: "+format,a...)
+	}
+}
+
+func (d *protoDecoder) uvarint()(uint64){
+	if d.err != nil {
+		return 0
+	}
+	v,n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.fail("invalid varint")
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+// Reads the key of the next field
+func (d *protoDecoder) next()(field int, wire int){
+	key := d.uvarint()
+	field,wire = int(key >> 3),int(key & 7)
+	if d.err == nil && field == 0 {
+		d.fail("invalid field number 0")
+	}
+	return
+}
+
+// Reads a value of wire type WIRE_VARINT
+func (d *protoDecoder) uint(wire int)(uint64){
+	if wire != WIRE_VARINT {
+		d.fail("wire type %d is not a varint",wire)
+		return 0
+	}
+	return d.uvarint()
+}
+
+// Reads a zigzag encoded sint32/sint64
+func (d *protoDecoder) sint(wire int)(int64){
+	v := d.uint(wire)
+	return int64(v >> 1) ^ -int64(v & 1)
+}
+
+// Reads a sint32 and checks its range
+func (d *protoDecoder) sint32(wire int)(int){
+	v := d.sint(wire)
+	if v < math.MinInt32 || v > math.MaxInt32 {
+		d.fail("%d exceeds sint32",v)
+		return 0
+	}
+	return int(v)
+}
+
+func (d *protoDecoder) double(wire int)(float64){
+	if wire != WIRE_FIXED64 {
+		d.fail("wire type %d is not fixed64",wire)
+		return 0
+	}
+	if len(d.data) < 8 {
+		d.fail("truncated fixed64")
+		return 0
+	}
+	v := math.Float64frombits(binary.LittleEndian.Uint64(d.data))
+	d.data = d.data[8:]
+	return v
+}
+
+// Reads a value of wire type WIRE_BYTES
+func (d *protoDecoder) bytes(wire int)([]byte){
+	if wire != WIRE_BYTES {
+		d.fail("wire type %d is not length-delimited",wire)
+		return nil
+	}
+	n := d.uvarint()
+	if d.err != nil {
+		return nil
+	}
+	if n > uint64(len(d.data)) {
+		d.fail("length %d exceeds the message",n)
+		return nil
+	}
+	v := d.data[:n]
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *protoDecoder) string(wire int)(string){
+	return string(d.bytes(wire))
+}
+
+// Returns a decoder of the embedded message
+func (d *protoDecoder) message(wire int)(*protoDecoder){
+	return &protoDecoder{data:d.bytes(wire)}
+}
+
+// Reads repeated sint32 values, packed or a single element
+func (d *protoDecoder) appendSint32(values []int, wire int)([]int){
+	if wire == WIRE_VARINT {
+		return append(values,d.sint32(wire))
+	}
+	packed := d.message(wire)
+	for packed.more() {
+		values = append(values,packed.sint32(WIRE_VARINT))
+	}
+	d.adopt(packed)
+	return values
+}
+
+// Skips the value of an unknown field
+func (d *protoDecoder) skip(wire int)(){
+	switch wire {
+	case WIRE_VARINT:
+		d.uvarint()
+	case WIRE_FIXED64, WIRE_FIXED32:
+		size := 8
+		if wire == WIRE_FIXED32 {
+			size = 4
+		}
+		if len(d.data) < size {
+			d.fail("truncated fixed%d",size * 8)
+			return
+		}
+		d.data = d.data[size:]
+	case WIRE_BYTES:
+		d.bytes(wire)
+	default:
+		d.fail("unsupported wire type %d",wire)
+	}
+}
+
+// Decodes the embedded message of the field by decode
+func (d *protoDecoder) embedded(wire int, decode func(*protoDecoder))(){
+	m := d.message(wire)
+	if d.err != nil {
+		return
+	}
+	decode(m)
+	d.adopt(m)
+}
+
+// Adopts the error of an embedded message
+func (d *protoDecoder) adopt(m *protoDecoder)(){
+	if m.err != nil && d.err == nil {
+		d.err = m.err
+	}
+}
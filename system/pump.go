@@ -2,6 +2,10 @@ package system
 
 import (
 	"fmt"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 	"github.com/hansen1101/go_heating/system/gpio"
 )
@@ -9,15 +13,70 @@ import (
 const (
 	OFF_FREQ = 0.0
 	ACC_BASIS = 50.0
+	PUMP_PULSE_UNCERTAINTY = 0.05			// share of a frequency change that is uncertain (missed or stretched pulses)
+	PUMP_MAX_UNCERTAINTY = 5.0			// Hz, the pump is recalibrated beyond
+	PUMP_RECALIBRATION_INTERVAL = time.Hour * 24	// a running pump is recalibrated at least this often
+	PUMP_FEEDBACK_TOLERANCE = 2.0			// Hz, default of the max deviation of the measured frequency
 )
 
+// A FrequencyFeedback measures the real frequency of a pump
+type FrequencyFeedback interface {
+	Frequency()(float64, error)
+}
+
+// Frequency of an analog output of the converter read by an ADC, e.g. the raw value of an
+// IIO channel (/sys/bus/iio/devices/iio:device0/in_voltage0_raw): Scale * raw + Offset Hz
+// implements FrequencyFeedback interface
+type AnalogFeedback struct {
+	Path string
+	Scale, Offset float64
+}
+
+func (f *AnalogFeedback) Frequency()(float64, error){
+	data,err := ioutil.ReadFile(f.Path)
+	if err != nil {
+		return 0, err
+	}
+	raw,err := strconv.ParseFloat(strings.TrimSpace(string(data)),64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v",f.Path,err)
+	}
+	return f.Scale * raw + f.Offset, nil
+}
+
+// Deviation of the measured from the estimated frequency of a pump
+type PumpFault struct {
+	Time time.Time
+	Target, Estimated, Measured float64
+}
+
+func (f *PumpFault) Error()(string){
+	return fmt.Sprintf("pump fault: measured %.2f Hz, estimated %.2f Hz (target %.2f Hz)",f.Measured,f.Estimated,f.Target)
+}
+
+/**
+ * A pump behind a frequency converter, the frequency is changed by pulses of the inc/dec
+ * relays and estimated from their duration. Every pulse adds to the uncertainty of the
+ * estimation, the pump is recalibrated by driving it to its min or max frequency (a known
+ * endstop) once the uncertainty exceeds PUMP_MAX_UNCERTAINTY or after
+ * PUMP_RECALIBRATION_INTERVAL. Switching the pump on calibrates it as well since the
+ * converter starts at min_freq. With a feedback (see SetFeedback) the estimation is
+ * checked against the measured frequency after every change.
+ * implements Actuator interface
+ */
 type Pump struct {
 	state bool
 	current, max_freq, min_freq, acceleration, delta float64
-	power_gpio, inc_gpio, dec_gpio *gpio.Pin
+	power_gpio, inc_gpio, dec_gpio gpio.Pin
+
+	uncertainty float64		// Hz the estimation may be off
+	calibrated time.Time		// last time the frequency was known
+	feedback FrequencyFeedback
+	tolerance float64
+	faults int
 }
 
-func NewPump(max,min,acc,delta float64, power,inc,dec *gpio.Pin)(p *Pump){
+func NewPump(max,min,acc,delta float64, power,inc,dec gpio.Pin)(p *Pump){
 	p = &Pump{
 		state:false,
 		current:OFF_FREQ,
@@ -51,6 +110,7 @@ func (p *Pump) Activate() {
 	if !p.state {
 		p.state = p.toggle()
 		p.current = p.min_freq
+		p.uncertainty,p.calibrated = 0,time.Now()
 	}
 	return
 }
@@ -70,9 +130,6 @@ func (p *Pump) UpdateFrequencyTo(target float64) {
 }
 
 func (p *Pump) updateFrequencyBy(steps float64) {
-	// relais to use depends on case
-	var relais *gpio.Pin
-
 	// update current field of struct depending on parameter
 	p.current += steps
 	if p.current > p.max_freq {
@@ -82,20 +139,30 @@ func (p *Pump) updateFrequencyBy(steps float64) {
 		steps += p.min_freq - p.current
 		p.current = p.min_freq
 	}
+	p.uncertainty += math.Abs(steps) * PUMP_PULSE_UNCERTAINTY
+	p.pulse(steps)
+	return
+}
 
+// Triggers the increase (steps > 0) or decrease relais long enough to change the frequency
+// by steps
+func (p *Pump) pulse(steps float64)(){
+	// relais to use depends on case
+	var relais gpio.Pin
 	switch {
 		case steps < 0.0:
 			// trigger decrease relais
 			relais = p.dec_gpio
 			steps *= -1
-		//	break
 		case steps > 0.0:
 			// trigger increase relais
 			relais = p.inc_gpio
-		//	break
 		default:
 			return
 	}
+	if relais == nil {
+		return
+	}
 
 	// activate relais
 	relais.SetValue(true)
@@ -103,19 +170,71 @@ func (p *Pump) updateFrequencyBy(steps float64) {
 	defer relais.SetValue(false)
 
 	<-time.After(time.Duration(int((steps+p.delta)*1000*p.acceleration/ACC_BASIS)) * time.Millisecond)
+}
+
+/**
+ * Recalibrates the frequency of a running pump: the pump is driven beyond the endstop
+ * (min_freq or max_freq) nearest to target by the full range and the uncertainty, thus it
+ * runs at the endstop afterwards whatever the estimation was.
+ */
+func (p *Pump) Recalibrate(target float64)(){
+	if !p.state {
+		return
+	}
+	overrun := p.max_freq - p.min_freq + p.uncertainty
+	if target - p.min_freq <= p.max_freq - target {
+		p.pulse(-overrun)
+		p.current = p.min_freq
+	} else {
+		p.pulse(overrun)
+		p.current = p.max_freq
+	}
+	p.uncertainty,p.calibrated = 0,time.Now()
+}
+
+// Returns true if the estimation of the frequency must be recalibrated
+func (p *Pump) needsRecalibration(now time.Time)(bool){
+	return p.uncertainty > PUMP_MAX_UNCERTAINTY || now.Sub(p.calibrated) > PUMP_RECALIBRATION_INTERVAL
+}
 
-	/*
-    	select {
-		// wait until pump reached target frequency
-		case <-time.After(time.Duration(int((steps+p.delta)*1000*p.acceleration/ACC_BASIS)) * time.Millisecond):
-			// deactivate relais
-			relais.SetValue(false)
-			break
-    	}*/
+// Sets the feedback the estimated frequency is checked against
+// @param tolerance max deviation in Hz, PUMP_FEEDBACK_TOLERANCE if 0
+func (p *Pump) SetFeedback(feedback FrequencyFeedback, tolerance float64)(){
+	if tolerance <= 0 {
+		tolerance = PUMP_FEEDBACK_TOLERANCE
+	}
+	p.feedback,p.tolerance = feedback,tolerance
+}
 
+// Checks the estimated against the measured frequency. A deviation beyond the tolerance is a
+// fault, the pump is driven to target again starting at the measured frequency.
+// @return *PumpFault on a deviation, error if the frequency can not be measured
+func (p *Pump) track(target float64)(err error){
+	measured,err := p.feedback.Frequency()
+	if err != nil {
+		return fmt.Errorf("frequency feedback: %v",err)
+	}
+	if math.Abs(measured - p.current) > p.tolerance {
+		fault := &PumpFault{Time:time.Now(),Target:target,Estimated:p.current,Measured:measured}
+		p.faults++
+		p.current = measured
+		p.UpdateFrequencyTo(target)
+		err = fault
+	}
+	p.uncertainty,p.calibrated = 0,time.Now()
 	return
 }
 
+// Returns the uncertainty of the estimated frequency in Hz
+func (p *Pump) Uncertainty()(float64){
+	return p.uncertainty
+}
+
+// Returns the number of deviations of the measured from the estimated frequency
+func (p *Pump) Faults()(int){
+	return p.faults
+}
+
 func (p *Pump) GetState()(bool,float64){
 	return p.state,p.current
 }
@@ -136,10 +255,73 @@ func (p *Pump) GetMaxFreq()(float64) {
 	return p.max_freq
 }
 
+func (p *Pump) Kind()(string){
+	return ACTUATOR_PUMP
+}
+
+func (p *Pump) State()(Setting){
+	return Setting{On:p.state,Frequency:p.current}
+}
+
+// Switches the pump and drives it to the frequency of the setting, clamped to the range
+// min_freq..max_freq. A frequency of 0 runs the pump at min_freq. The pump is recalibrated
+// before if necessary and checked against its feedback afterwards.
+// @return *PumpFault if the measured frequency deviates from the estimation
+func (p *Pump) Set(setting Setting)(error){
+	if !setting.On {
+		p.Deactivate()
+		return nil
+	}
+	p.Activate()
+	target := p.clamp(setting.Frequency)
+	if p.needsRecalibration(time.Now()) {
+		p.Recalibrate(target)
+	}
+	p.UpdateFrequencyTo(target)
+	if p.feedback != nil {
+		return p.track(target)
+	}
+	return nil
+}
+
+// Returns the frequency limited to min_freq..max_freq
+func (p *Pump) clamp(frequency float64)(float64){
+	return clampFrequency(frequency,p.min_freq,p.max_freq)
+}
+
+// Returns the frequency limited to min..max, min if the frequency is not set (0 or NaN)
+func clampFrequency(frequency, min, max float64)(float64){
+	if frequency < min || math.IsNaN(frequency) {
+		return min
+	} else if frequency > max {
+		return max
+	}
+	return frequency
+}
+
+// Reads the power relay and the frequency of the feedback, without feedback the frequency is
+// the estimation
+func (p *Pump) ReadBack()(setting Setting, err error){
+	if p.power_gpio == nil {
+		return Setting{}, ErrNoFeedback
+	}
+	setting = Setting{On:p.power_gpio.GetValue(),Frequency:p.current}
+	if p.feedback != nil && setting.On {
+		setting.Frequency,err = p.feedback.Frequency()
+	}
+	return
+}
+
+func (p *Pump) Constraints()(Constraints){
+	return Constraints{MinFrequency:p.min_freq,MaxFrequency:p.max_freq}
+}
+
 func (p *Pump) String()(string){
-	return fmt.Sprintf("Pump State [active:%v frequency:%.2f]\tGPIO fingerprint [%v:%v %v:%v %v:%v]\n",
+	return fmt.Sprintf("Pump State [active:%v frequency:%.2f ±%.2f faults:%d]\tGPIO fingerprint [%v:%v %v:%v %v:%v]\n",
 		p.state,
 		p.current,
+		p.uncertainty,
+		p.faults,
 		p.power_gpio.GetGpioId(),
 		p.power_gpio.GetValue(),
 		p.inc_gpio.GetGpioId(),
@@ -0,0 +1,196 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+type Aggregation int
+
+const (
+	MIN Aggregation = iota
+	MAX
+	MEAN
+	EWMA		// exponentially weighted moving average of the values, Weight of the newest value
+	SLOPE		// change per second by linear regression
+	TOTAL_DELTA	// last - first value
+	PERCENTILE	// Percentile (0-100) of the values
+	TIME_ABOVE	// seconds the value was above Threshold (value held until the next sample)
+)
+
+var (
+	ErrInvalidQuery = errors.New("invalid query")
+	ErrNoSamples = errors.New("no samples in range")
+	ErrUnknownQuery = errors.New("unknown data query")
+)
+
+// A query on the history of a reading. The range ends at the latest percept.
+type Query struct {
+	Logic string		// logical name of the reading
+	Range time.Duration	// time span before the latest percept
+	Aggregation Aggregation
+	Weight float64		// EWMA: weight of the newest value within (0,1]
+	Percentile float64	// PERCENTILE: within [0,100]
+	Threshold float64	// TIME_ABOVE: in the unit of the reading
+	Resolution time.Duration	// resolution of the history to query, 0 selects the finest that covers the range
+}
+
+// Result of a Query. Value is given in the unit of the reading, per second for SLOPE and
+// in seconds for TIME_ABOVE.
+type Result struct {
+	Query Query
+	Value float64
+	Samples int		// measured readings within the range
+	From, To time.Time	// time of the first and last sample
+	Coverage float64	// share of the range spanned by the samples within [0,1]
+	Resolution time.Duration	// resolution of the history that answered the query
+}
+
+// A measured value of a reading at a point in time
+type Sample struct {
+	Time time.Time
+	Value float64
+}
+
+func (a Aggregation) String()(string){
+	switch a {
+	case MIN:
+		return "min"
+	case MAX:
+		return "max"
+	case MEAN:
+		return "mean"
+	case EWMA:
+		return "ewma"
+	case SLOPE:
+		return "slope"
+	case TOTAL_DELTA:
+		return "total delta"
+	case PERCENTILE:
+		return "percentile"
+	case TIME_ABOVE:
+		return "time above"
+	default:
+		return "unknown"
+	}
+}
+
+func (q Query) String()(string){
+	return fmt.Sprintf("%s of %s over %s",q.Aggregation,q.Logic,q.Range)
+}
+
+func (r Result) String()(string){
+	return fmt.Sprintf("%s: %g (%d samples, coverage %.2f)",r.Query,r.Value,r.Samples,r.Coverage)
+}
+
+// Checks the parameters of the query
+func (q Query) Validate()(error){
+	switch {
+	case q.Logic == "":
+		return fmt.Errorf("%v: reading is missing",ErrInvalidQuery)
+	case q.Range <= 0:
+		return fmt.Errorf("%v: range must be positive",ErrInvalidQuery)
+	case q.Resolution < 0 || q.Resolution % time.Second != 0:
+		return fmt.Errorf("%v: resolution must be a positive number of seconds",ErrInvalidQuery)
+	case q.Aggregation < MIN || q.Aggregation > TIME_ABOVE:
+		return fmt.Errorf("%v: unknown aggregation %d",ErrInvalidQuery,q.Aggregation)
+	case q.Aggregation == EWMA && (q.Weight <= 0 || q.Weight > 1):
+		return fmt.Errorf("%v: weight %g is not within (0,1]",ErrInvalidQuery,q.Weight)
+	case q.Aggregation == PERCENTILE && (q.Percentile < 0 || q.Percentile > 100):
+		return fmt.Errorf("%v: percentile %g is not within [0,100]",ErrInvalidQuery,q.Percentile)
+	}
+	return nil
+}
+
+// Returns the samples of the reading within (to-span,to] of the window ordered by time,
+// readings that are invalid or substituted are skipped
+func windowSamples(window []*Percept, logic string, to time.Time, span time.Duration)(samples []Sample){
+	from := to.Add(-span)
+	for _,p := range window {
+		if p == nil || !p.CurrentTime.After(from) || p.CurrentTime.After(to) {
+			continue
+		}
+		if r := p.Get(logic); r != nil && r.IsValid() && !isSubstitute(r) {
+			samples = append(samples,Sample{p.CurrentTime,r.GetNumeric()})
+		}
+	}
+	sort.Slice(samples,func(i, j int)(bool){ return samples[i].Time.Before(samples[j].Time) })
+	return
+}
+
+/**
+ * Aggregates the samples according to the query
+ * @param samples ordered by time
+ * @return the result or ErrNoSamples if there are no samples
+ */
+func Aggregate(q Query, samples []Sample)(r Result, err error){
+	r.Query = q
+	r.Resolution = q.Resolution
+	r.Samples = len(samples)
+	if len(samples) == 0 {
+		return r, ErrNoSamples
+	}
+	first,last := samples[0],samples[len(samples)-1]
+	r.From,r.To = first.Time,last.Time
+	r.Coverage = math.Min(1,(last.Time.Sub(first.Time) + q.Resolution).Seconds()/q.Range.Seconds())
+
+	switch q.Aggregation {
+	case MIN:
+		r.Value = first.Value
+		for _,s := range samples {
+			r.Value = math.Min(r.Value,s.Value)
+		}
+	case MAX:
+		r.Value = first.Value
+		for _,s := range samples {
+			r.Value = math.Max(r.Value,s.Value)
+		}
+	case MEAN:
+		for _,s := range samples {
+			r.Value += s.Value
+		}
+		r.Value /= float64(len(samples))
+	case EWMA:
+		r.Value = first.Value
+		for _,s := range samples[1:] {
+			r.Value = q.Weight * s.Value + (1 - q.Weight) * r.Value
+		}
+	case SLOPE:
+		// least squares fit of value = a + slope * seconds
+		var sumX, sumY, sumXY, sumXX float64
+		n := float64(len(samples))
+		for _,s := range samples {
+			x := s.Time.Sub(first.Time).Seconds()
+			sumX += x
+			sumY += s.Value
+			sumXY += x * s.Value
+			sumXX += x * x
+		}
+		if d := n * sumXX - sumX * sumX; d != 0 {
+			r.Value = (n * sumXY - sumX * sumY) / d
+		}
+	case TOTAL_DELTA:
+		r.Value = last.Value - first.Value
+	case PERCENTILE:
+		values := make([]float64,len(samples))
+		for i,s := range samples {
+			values[i] = s.Value
+		}
+		sort.Float64s(values)
+		// linear interpolation between the closest ranks
+		rank := q.Percentile / 100 * float64(len(values)-1)
+		lower := int(math.Floor(rank))
+		upper := int(math.Ceil(rank))
+		r.Value = values[lower] + (rank - float64(lower)) * (values[upper] - values[lower])
+	case TIME_ABOVE:
+		for i,s := range samples[:len(samples)-1] {
+			if s.Value > q.Threshold {
+				r.Value += samples[i+1].Time.Sub(s.Time).Seconds()
+			}
+		}
+	}
+	return
+}
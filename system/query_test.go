@@ -0,0 +1,116 @@
+package system
+
+import(
+	"context"
+	"io/ioutil"
+	"math"
+	"os"
+	"testing"
+	"time"
+	"github.com/hansen1101/go_heating/system/hardware"
+	"github.com/hansen1101/go_heating/system/w1"
+)
+
+func TestAggregate(t *testing.T){
+	start := time.Now()
+	// 10, 20, 40, 30 at 0s, 10s, 20s, 30s
+	samples := []Sample{
+		{start,10},
+		{start.Add(time.Second * 10),20},
+		{start.Add(time.Second * 20),40},
+		{start.Add(time.Second * 30),30},
+	}
+	tests := []struct{
+		query Query
+		expected float64
+	}{
+		{Query{Aggregation:MIN},10},
+		{Query{Aggregation:MAX},40},
+		{Query{Aggregation:MEAN},25},
+		{Query{Aggregation:EWMA,Weight:0.5},28.75},
+		{Query{Aggregation:SLOPE},0.8},
+		{Query{Aggregation:TOTAL_DELTA},20},
+		{Query{Aggregation:PERCENTILE,Percentile:50},25},
+		{Query{Aggregation:PERCENTILE,Percentile:100},40},
+		{Query{Aggregation:TIME_ABOVE,Threshold:15},20},
+	}
+	for _,test := range tests {
+		test.query.Logic = "x"
+		test.query.Range = time.Minute
+		r,err := Aggregate(test.query,samples)
+		if err != nil || math.Abs(r.Value - test.expected) > 1e-9 {
+			t.Error("For",test.query,"expected",test.expected,"got",r.Value,err)
+		}
+		if r.Samples != 4 || r.Coverage != 0.5 || !r.From.Equal(start) {
+			t.Error("For",test.query,"expected","4 samples covering half the range","got",r)
+		}
+	}
+	if _,err := Aggregate(Query{Logic:"x",Range:time.Minute},nil); err != ErrNoSamples {
+		t.Error("For","no samples","expected",ErrNoSamples,"got",err)
+	}
+}
+
+func TestValidateQuery(t *testing.T){
+	invalid := []Query{
+		{Range:time.Minute},
+		{Logic:"x"},
+		{Logic:"x",Range:time.Minute,Aggregation:Aggregation(42)},
+		{Logic:"x",Range:time.Minute,Aggregation:EWMA},
+		{Logic:"x",Range:time.Minute,Aggregation:PERCENTILE,Percentile:101},
+	}
+	for _,q := range invalid {
+		if q.Validate() == nil {
+			t.Error("For",q,"expected","error","got",nil)
+		}
+	}
+	if err := (Query{Logic:"x",Range:time.Minute,Aggregation:SLOPE}).Validate(); err != nil {
+		t.Error("For","slope","expected",nil,"got",err)
+	}
+}
+
+func TestAsk(t *testing.T){
+	dir,err := ioutil.TempDir("","query")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(prefix string){ w1.SENSOR_PATH_PREFIX = prefix }(w1.SENSOR_PATH_PREFIX)
+	w1.SENSOR_PATH_PREFIX = dir+"/"
+
+	start := time.Now().Truncate(time.Second)
+	o := NewPerceptOracle(testSource(t,dir,start,10),60)
+	if _,err := o.Ask(Query{Logic:hardware.ROLE_TPO,Range:time.Minute,Aggregation:MEAN}); err != ErrOracleStopped {
+		t.Error("For","query before start","expected",ErrOracleStopped,"got",err)
+	}
+	o.Start(context.Background())
+	defer o.Stop()
+	for p,_ := o.Current(context.Background()); !p.CurrentTime.Equal(start.Add(time.Second * 9)); p,_ = o.Current(context.Background()) {
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	results,err := o.Ask(
+		Query{Logic:hardware.ROLE_TPO,Range:time.Second * 5,Aggregation:SLOPE},
+		Query{Logic:hardware.ROLE_TPO,Range:time.Minute,Aggregation:MAX},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(results[0].Value - 100) > 1e-9 || results[0].Samples != 5 {
+		t.Error("For","boiler slope over 5s","expected","100 of 5 samples","got",results[0])
+	}
+	if results[1].Value != 40900 || results[1].Samples != 10 {
+		t.Error("For","boiler max","expected",40900,"got",results[1])
+	}
+
+	results,err = o.Ask(
+		Query{Logic:hardware.ROLE_KETTLE,Range:time.Minute,Aggregation:MEAN},
+		Query{Logic:hardware.ROLE_TPO,Range:time.Minute,Aggregation:MIN},
+	)
+	if err == nil || results[0].Samples != 0 || results[1].Value != 40000 {
+		t.Error("For","missing reading","expected","error and the remaining results","got",results,err)
+	}
+
+	if _,err := o.Query([]DataQuery{DataQuery(42)},Calculation_info{Sec:10,Weight:1}); err != ErrUnknownQuery {
+		t.Error("For","unknown data query","expected",ErrUnknownQuery,"got",err)
+	}
+}
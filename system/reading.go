@@ -0,0 +1,199 @@
+package system
+
+import (
+	"fmt"
+	"strings"
+	"github.com/hansen1101/go_heating/system/logger"
+	"github.com/hansen1101/go_heating/system/w1"
+)
+
+const (
+	READING_TABLE = "percept_readings"
+)
+
+type ReadingKind int
+
+const (
+	TEMPERATURE ReadingKind = iota	// millidegree
+	BINARY				// 0 or 1
+	FREQUENCY			// Hz
+	FLOW				// l/h
+)
+
+// A Reading is a single typed value of a percept, addressed by the logical name of its source
+type Reading interface {
+	GetKind()(ReadingKind)
+	GetLogic()(string)
+	IsValid()(bool)
+	GetNumeric()(float64)
+}
+
+func (k ReadingKind) String()(string){
+	switch k {
+	case TEMPERATURE:
+		return "temperature"
+	case BINARY:
+		return "binary"
+	case FREQUENCY:
+		return "frequency"
+	case FLOW:
+		return "flow"
+	default:
+		return "unknown"
+	}
+}
+
+// Reading of a w1 temperature sensor
+type TemperatureReading struct {
+	*w1.Temperature
+}
+
+func (r TemperatureReading) GetKind()(ReadingKind){
+	return TEMPERATURE
+}
+func (r TemperatureReading) GetLogic()(string){
+	return r.GetSensorLogic()
+}
+func (r TemperatureReading) IsValid()(bool){
+	return Usable(r.Temperature)
+}
+func (r TemperatureReading) GetNumeric()(float64){
+	return float64(r.GetValue())
+}
+
+// Reading of a binary state like a switch or a contact
+type StateReading struct {
+	Logic string
+	State bool
+	Valid bool
+}
+
+func (r StateReading) GetKind()(ReadingKind){
+	return BINARY
+}
+func (r StateReading) GetLogic()(string){
+	return r.Logic
+}
+func (r StateReading) IsValid()(bool){
+	return r.Valid
+}
+func (r StateReading) GetNumeric()(float64){
+	if r.State {
+		return 1
+	}
+	return 0
+}
+
+// Reading of a frequency in Hz, e.g. of a pump's frequency converter
+type FrequencyReading struct {
+	Logic string
+	Hertz float64
+	Valid bool
+}
+
+func (r FrequencyReading) GetKind()(ReadingKind){
+	return FREQUENCY
+}
+func (r FrequencyReading) GetLogic()(string){
+	return r.Logic
+}
+func (r FrequencyReading) IsValid()(bool){
+	return r.Valid
+}
+func (r FrequencyReading) GetNumeric()(float64){
+	return r.Hertz
+}
+
+// Reading of a flow meter in l/h
+type FlowReading struct {
+	Logic string
+	LitersPerHour float64
+	Valid bool
+}
+
+func (r FlowReading) GetKind()(ReadingKind){
+	return FLOW
+}
+func (r FlowReading) GetLogic()(string){
+	return r.Logic
+}
+func (r FlowReading) IsValid()(bool){
+	return r.Valid
+}
+func (r FlowReading) GetNumeric()(float64){
+	return r.LitersPerHour
+}
+
+// Checks whether the reading is a temperature that was taken from an earlier percept
+func isSubstitute(r Reading)(bool){
+	t,ok := r.(TemperatureReading)
+	return ok && t.Temperature != nil && t.IsSubstitute()
+}
+
+// Logs the readings of percepts in long format (one row per reading), thus the relation
+// does not depend on the configured sensor set.
+// implements logger.Logable interface
+type ReadingLog struct{}
+
+func (l *ReadingLog) GetRelationName()(string){
+	return READING_TABLE
+}
+func (l *ReadingLog) CreateRelation()(){
+	var stmnt_string string
+
+	stmnt_string = fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s(" +
+			"r_id INT NOT NULL AUTO_INCREMENT," +
+			"time INT NOT NULL DEFAULT 0," +
+			"logic VARCHAR(32) NOT NULL," +
+			"kind TINYINT NOT NULL DEFAULT 0," +
+			"value DOUBLE NULL DEFAULT NULL," +
+			"substitute BIT(1) NOT NULL DEFAULT b'0'," +
+			"PRIMARY KEY(r_id)," +
+			"UNIQUE value_key (time,logic)" +
+			")ENGINE=InnoDB DEFAULT CHARSET=latin1",
+		l.GetRelationName())
+
+	logger.StatementExecute(stmnt_string)
+}
+
+// Inserts all readings of the given percepts
+// @param val *Percept values
+func (l *ReadingLog) Insert(val ...interface{})(){
+	for _,v := range val {
+		if p,ok := v.(*Percept); ok && p != nil {
+			for _,stmnt_string := range l.insertStatements(p) {
+				go logger.StatementExecute(stmnt_string)
+			}
+		}
+	}
+}
+
+// Returns one insert statement per reading of the percept
+func (l *ReadingLog) insertStatements(p *Percept)(statements []string){
+	escape := func(s string)(string){ return strings.Replace(strings.Replace(s,"\\","\\\\",-1),"'","''",-1) }
+	for _,logic := range p.Logics() {
+		r := p.Readings[logic]
+		value := "NULL"
+		if r.IsValid() {
+			value = fmt.Sprintf("%f",r.GetNumeric())
+		}
+		substitute := 0
+		if isSubstitute(r) {
+			substitute = 1
+		}
+		statements = append(statements,fmt.Sprintf(
+			"INSERT IGNORE INTO %s" +
+				"(time,logic,kind,value,substitute)" +
+				" VALUES " +
+				"(%d,'%s',%d,%s,b'%d')",
+			l.GetRelationName(),p.CurrentTime.Unix(),escape(logic),int(r.GetKind()),value,substitute))
+	}
+	return
+}
+func (l *ReadingLog) Delete(val ...interface{})(){
+	//@todo
+}
+func (l *ReadingLog) Update(val ...interface{})(){
+	//@todo
+}
@@ -0,0 +1,264 @@
+package system
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"github.com/hansen1101/go_heating/auxiliary/toml"
+)
+
+const (
+	VACATION_PROFILE = "vacation"
+)
+
+var (
+	scheduleTimeLayouts = []string{"2006-01-02 15:04","2006-01-02"}
+	weekdayKeys = []string{"sunday","monday","tuesday","wednesday","thursday","friday","saturday"}
+)
+
+// Answer of the configuration for a point in time: the boiler target and whether hot water
+// is heated and the radiators only protect against frost
+type Setpoint struct {
+	Boiler int	// boiler target in m°C, 0 switches the heating off
+	HotWater bool	// hot water is heated
+	Frost bool	// frost protection, the radiators run only if it is freezing outside
+	HeatingOff bool	// manual override, the radiators only protect against frost
+	Profile string	// name of the profile that applies
+}
+
+// A heating curve of the schedule, Shift is added to all targets above 0 of the curve
+type Profile struct {
+	Curve string `toml:"curve"`	// configuration table (.csv) or parametric curve (.toml), relative to the schedule
+	Shift int `toml:"shift"`
+	curve HeatingCurve `toml:"-"`
+	path string `toml:"-"`	// file of the curve
+}
+
+// Profiles by day of the week, days that are not set use Default
+type Week struct {
+	Default string `toml:"default"`
+	Monday string `toml:"monday"`
+	Tuesday string `toml:"tuesday"`
+	Wednesday string `toml:"wednesday"`
+	Thursday string `toml:"thursday"`
+	Friday string `toml:"friday"`
+	Saturday string `toml:"saturday"`
+	Sunday string `toml:"sunday"`
+}
+
+// Returns the profile of the day
+func (w Week) profile(day time.Weekday)(string){
+	profiles := []string{w.Sunday,w.Monday,w.Tuesday,w.Wednesday,w.Thursday,w.Friday,w.Saturday}
+	if profiles[day] != "" {
+		return profiles[day]
+	}
+	return w.Default
+}
+
+// A period that uses another profile than the week, From and To are dates (inclusive) or
+// times ("2006-01-02 15:04") in local time
+type Holiday struct {
+	Name string `toml:"name"`
+	From string `toml:"from"`
+	To string `toml:"to"`
+	Profile string `toml:"profile"`
+	from, to time.Time `toml:"-"`
+}
+
+// While on vacation the boiler is held at FrostTarget, hot water is not heated and the
+// radiators only protect against frost. The profile of the week is resumed Preheat ahead of
+// the return (Until, a date means the beginning of the day).
+type Vacation struct {
+	From string `toml:"from"`	// empty: at once
+	Until string `toml:"until"`
+	FrostTarget int `toml:"frost_target"`
+	Preheat time.Duration `toml:"preheat"`
+	from, until time.Time `toml:"-"`
+}
+
+/**
+ * A Schedule selects the profile (heating curve) by day of the week, holiday periods and
+ * vacation mode. It is read from the structured configuration (toml):
+ * [profiles.<name>], [week], [[holiday]] and [vacation].
+ * implements HeatingCurve interface
+ */
+type Schedule struct {
+	Profiles map[string]*Profile `toml:"profiles"`
+	Week Week `toml:"week"`
+	Holidays []Holiday `toml:"holiday"`
+	Vacation *Vacation `toml:"vacation"`
+	report CurveReport `toml:"-"`
+}
+
+// Parses a date or time of the schedule, a date ends at midnight of the next day if end is set
+func parseScheduleTime(value string, end bool)(t time.Time, err error){
+	for _,layout := range scheduleTimeLayouts {
+		if t,err = time.ParseInLocation(layout,strings.TrimSpace(value),time.Local); err == nil {
+			if end && len(layout) == len("2006-01-02") {
+				t = t.AddDate(0,0,1)
+			}
+			return
+		}
+	}
+	return t, fmt.Errorf("%q is neither a date (2006-01-02) nor a time (2006-01-02 15:04)",value)
+}
+
+/**
+ * Reads a schedule and the heating curves of its profiles
+ * @return error if the file or a curve can not be read, a referenced profile is not defined,
+ * a day of the week has no profile or a period is invalid
+ */
+func LoadSchedule(path string)(s *Schedule, err error){
+	doc,err := toml.ParseFile(path)
+	if err != nil {
+		return
+	}
+	return decodeSchedule(doc,path)
+}
+
+// Decodes the schedule of the document read from path
+func decodeSchedule(doc toml.Table, path string)(s *Schedule, err error){
+	s = &Schedule{}
+	if err = toml.Decode(doc,s); err != nil {
+		return nil, fmt.Errorf("%s: %v",path,err)
+	}
+	if err = s.validate(filepath.Dir(path)); err != nil {
+		return nil, fmt.Errorf("%s: %v",path,err)
+	}
+	return
+}
+
+// Loads the curves relative to dir and checks the references and periods
+func (s *Schedule) validate(dir string)(err error){
+	if len(s.Profiles) == 0 {
+		return fmt.Errorf("no profile defined")
+	}
+	names := make([]string,0,len(s.Profiles))
+	for name := range s.Profiles {
+		names = append(names,name)
+	}
+	sort.Strings(names)
+	for _,name := range names {
+		p := s.Profiles[name]
+		if p == nil || p.Curve == "" {
+			return fmt.Errorf("profile %s has no curve",name)
+		}
+		path := p.Curve
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir,path)
+		}
+		var report CurveReport
+		p.path = path
+		if p.curve,report,err = LoadHeatingCurve(path); err != nil {
+			return fmt.Errorf("profile %s: %v",name,err)
+		}
+		if _,nested := p.curve.(*Schedule); nested {
+			return fmt.Errorf("profile %s: curve %s is a schedule",name,p.Curve)
+		}
+		s.addReport(name,report)
+	}
+	return s.check()
+}
+
+// Adds the issues of the curve of a profile to the report of the schedule
+func (s *Schedule) addReport(name string, report CurveReport)(){
+	s.report.Missing = append(s.report.Missing,report.Missing...)
+	for _,issue := range report.NonMonotonic {
+		s.report.NonMonotonic = append(s.report.NonMonotonic,name+": "+issue)
+	}
+	for _,issue := range report.Invalid {
+		s.report.Invalid = append(s.report.Invalid,name+": "+issue)
+	}
+}
+
+// Checks the profiles of the week and the holiday and vacation periods
+func (s *Schedule) check()(err error){
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if profile := s.Week.profile(day); profile == "" {
+			return fmt.Errorf("week: %s has no profile and there is no default",weekdayKeys[day])
+		} else if _,ok := s.Profiles[profile]; !ok {
+			return fmt.Errorf("week: %s uses the undefined profile %s",weekdayKeys[day],profile)
+		}
+	}
+	for i := range s.Holidays {
+		h := &s.Holidays[i]
+		if _,ok := s.Profiles[h.Profile]; !ok {
+			return fmt.Errorf("holiday %s uses the undefined profile %q",h.Name,h.Profile)
+		}
+		if h.from,err = parseScheduleTime(h.From,false); err != nil {
+			return fmt.Errorf("holiday %s: from %v",h.Name,err)
+		}
+		if h.to,err = parseScheduleTime(h.To,true); err != nil {
+			return fmt.Errorf("holiday %s: to %v",h.Name,err)
+		}
+		if !h.to.After(h.from) {
+			return fmt.Errorf("holiday %s ends before it starts",h.Name)
+		}
+	}
+	if v := s.Vacation; v != nil {
+		if v.Until == "" {
+			return fmt.Errorf("vacation: until is missing")
+		}
+		if v.until,err = parseScheduleTime(v.Until,false); err != nil {
+			return fmt.Errorf("vacation: until %v",err)
+		}
+		if v.From != "" {
+			if v.from,err = parseScheduleTime(v.From,false); err != nil {
+				return fmt.Errorf("vacation: from %v",err)
+			}
+		}
+		if v.Preheat < 0 {
+			return fmt.Errorf("vacation: preheat %s is negative",v.Preheat)
+		}
+	}
+	return nil
+}
+
+// Returns the files of the curves of the profiles
+func (s *Schedule) Files()(files []string){
+	for _,p := range s.Profiles {
+		if p.path != "" {
+			files = append(files,p.path)
+		}
+	}
+	sort.Strings(files)
+	return
+}
+
+// Returns the issues of the configuration tables of the profiles
+func (s *Schedule) Report()(CurveReport){
+	return s.report
+}
+
+// Returns the profile that applies at the given time and whether the vacation mode holds
+func (s *Schedule) Profile(at time.Time)(name string, vacation bool){
+	if v := s.Vacation; v != nil && !at.Before(v.from) && at.Before(v.until.Add(-v.Preheat)) {
+		return VACATION_PROFILE, true
+	}
+	for _,h := range s.Holidays {
+		if !at.Before(h.from) && at.Before(h.to) {
+			return h.Profile, false
+		}
+	}
+	return s.Week.profile(at.Weekday()), false
+}
+
+// Returns the setpoint for the outside temperature (m°C) at the given time
+func (s *Schedule) Setpoint(outside int, at time.Time)(Setpoint){
+	name,vacation := s.Profile(at)
+	if vacation {
+		return Setpoint{Boiler:s.Vacation.FrostTarget,Frost:true,Profile:name}
+	}
+	p := s.Profiles[name]
+	target := p.curve.Target(outside,at)
+	if target > 0 {
+		target += p.Shift
+	}
+	return Setpoint{Boiler:target,HotWater:true,Profile:name}
+}
+
+func (s *Schedule) Target(outside int, at time.Time)(int){
+	return s.Setpoint(outside,at).Boiler
+}
@@ -0,0 +1,128 @@
+package system
+
+import(
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"github.com/hansen1101/go_heating/system/hardware"
+	"github.com/hansen1101/go_heating/system/w1"
+)
+
+const testSchedule = `
+[profiles.workday]
+curve = "curve.toml"
+
+[profiles.weekend]
+curve = "curve.toml"
+shift = 2000
+
+[week]
+default = "workday"
+saturday = "weekend"
+sunday = "weekend"
+
+[[holiday]]
+name = "christmas"
+from = "2026-12-24"
+to = "2026-12-26"
+profile = "weekend"
+
+[vacation]
+from = "2027-02-01"
+until = "2027-02-14 18:00"
+frost_target = 30000
+preheat = "6h"
+`
+
+// Writes the test schedule and its curve to dir
+func writeSchedule(t *testing.T, dir string, schedule string)(path string){
+	curve := "[curve]\nroom = 20000\ndesign_outside = -15000\ndesign_target = 55000\nmax = 65000\n"
+	if err := ioutil.WriteFile(filepath.Join(dir,"curve.toml"),[]byte(curve),0644); err != nil {
+		t.Fatal(err)
+	}
+	path = filepath.Join(dir,"schedule.toml")
+	if err := ioutil.WriteFile(path,[]byte(schedule),0644); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestSchedule(t *testing.T){
+	dir,err := ioutil.TempDir("","schedule")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	schedule,err := LoadSchedule(writeSchedule(t,dir,testSchedule))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(value string)(time.Time){
+		t,_ := time.ParseInLocation("2006-01-02 15:04",value,time.Local)
+		return t
+	}
+	tests := []struct{
+		at time.Time
+		expected Setpoint
+	}{
+		{at("2026-12-22 08:00"),Setpoint{Boiler:40000,HotWater:true,Profile:"workday"}},	// tuesday
+		{at("2026-12-19 08:00"),Setpoint{Boiler:42000,HotWater:true,Profile:"weekend"}},	// saturday
+		{at("2026-12-24 00:00"),Setpoint{Boiler:42000,HotWater:true,Profile:"weekend"}},	// holiday begins
+		{at("2026-12-26 23:59"),Setpoint{Boiler:42000,HotWater:true,Profile:"weekend"}},	// last day of the holiday
+		{at("2026-12-28 00:00"),Setpoint{Boiler:40000,HotWater:true,Profile:"workday"}},
+		{at("2027-01-31 23:59"),Setpoint{Boiler:42000,HotWater:true,Profile:"weekend"}},	// before the vacation
+		{at("2027-02-01 00:00"),Setpoint{Boiler:30000,Frost:true,Profile:VACATION_PROFILE}},
+		{at("2027-02-14 11:59"),Setpoint{Boiler:30000,Frost:true,Profile:VACATION_PROFILE}},
+		{at("2027-02-14 12:00"),Setpoint{Boiler:42000,HotWater:true,Profile:"weekend"}},	// preheat
+	}
+	for _,test := range tests {
+		if setpoint := schedule.Setpoint(0,test.at); setpoint != test.expected {
+			t.Error("For",test.at,"expected",test.expected,"got",setpoint)
+		}
+	}
+
+	invalid := map[string]string{
+		"no profiles":"[week]\ndefault = \"workday\"\n",
+		"undefined profile":"[profiles.workday]\ncurve = \"curve.toml\"\n[week]\ndefault = \"weekend\"\n",
+		"day without profile":"[profiles.workday]\ncurve = \"curve.toml\"\n[week]\nmonday = \"workday\"\n",
+		"missing curve":"[profiles.workday]\ncurve = \"missing.csv\"\n[week]\ndefault = \"workday\"\n",
+		"holiday ends before":"[profiles.workday]\ncurve = \"curve.toml\"\n[week]\ndefault = \"workday\"\n[[holiday]]\nname = \"x\"\nfrom = \"2026-12-24\"\nto = \"2026-12-23\"\nprofile = \"workday\"\n",
+		"vacation date":"[profiles.workday]\ncurve = \"curve.toml\"\n[week]\ndefault = \"workday\"\n[vacation]\nuntil = \"14.02.2027\"\n",
+		"unknown key":"[profiles.workday]\ncurve = \"curve.toml\"\n[week]\ndefault = \"workday\"\nholiday = \"x\"\n",
+	}
+	for name,content := range invalid {
+		if _,err := LoadSchedule(writeSchedule(t,dir,content)); err == nil {
+			t.Error("For",name,"expected","error","got",nil)
+		}
+	}
+}
+
+func TestConfigurationOracleSchedule(t *testing.T){
+	dir,err := ioutil.TempDir("","schedule")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := writeSchedule(t,dir,testSchedule)
+
+	o := NewConfigurationOracle(path,50000)
+	if err := o.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer o.Stop()
+
+	percept := testPercept(time.Date(2027,2,10,8,0,0,0,time.Local),nil)
+	percept.SetTemperature(w1.NewMeasuredTemperature("28-000000000001",hardware.ROLE_OUTSIDE,0,percept.CurrentTime))
+	percept.Valid = true
+	setpoint,err := o.Setpoint(context.Background(),percept)
+	if err != nil || setpoint.Boiler != 30000 || setpoint.HotWater || !setpoint.Frost {
+		t.Error("For","vacation","expected","frost target without hot water","got",setpoint,err)
+	}
+	if target,err := o.Target(context.Background(),percept); err != nil || target != 30000 {
+		t.Error("For","target on vacation","expected",30000,"got",target,err)
+	}
+}
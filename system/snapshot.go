@@ -0,0 +1,191 @@
+package system
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	SNAPSHOT_VERSION = 1
+	SNAPSHOT_MAGIC = "GHSN"
+	snapshotHeaderSize = 4 + 2 + 4 + 8 + 4	// magic, version, kind, created, payload length
+)
+
+var (
+	ErrSnapshotCorrupt = errors.New("snapshot is corrupted")
+	ErrSnapshotVersion = errors.New("unsupported snapshot version")
+	ErrSnapshotStale = errors.New("snapshot is older than its horizon")
+)
+
+/**
+ * Writes the payload to a snapshot file. The file holds a header (magic, version, kind of
+ * the payload, creation time and payload length), the payload and a CRC32 checksum of both
+ * in little endian byte order. The file is written to a temporary file first and renamed,
+ * thus an interrupted write never replaces a previous snapshot.
+ * @param kind 4 characters that identify the payload, e.g. "ORCL"
+ */
+func WriteSnapshot(path, kind string, created time.Time, payload []byte)(err error){
+	if len(kind) != 4 {
+		return fmt.Errorf("snapshot kind %q must have 4 characters",kind)
+	}
+	var buf bytes.Buffer
+	buf.WriteString(SNAPSHOT_MAGIC)
+	binary.Write(&buf,binary.LittleEndian,uint16(SNAPSHOT_VERSION))
+	buf.WriteString(kind)
+	binary.Write(&buf,binary.LittleEndian,created.UnixNano())
+	binary.Write(&buf,binary.LittleEndian,uint32(len(payload)))
+	buf.Write(payload)
+	binary.Write(&buf,binary.LittleEndian,crc32.ChecksumIEEE(buf.Bytes()))
+
+	if err = os.MkdirAll(filepath.Dir(path),0755); err != nil {
+		return
+	}
+	tmp := path + ".tmp"
+	if err = ioutil.WriteFile(tmp,buf.Bytes(),0644); err != nil {
+		return
+	}
+	return os.Rename(tmp,path)
+}
+
+/**
+ * Reads the payload of a snapshot file written by WriteSnapshot
+ * @param horizon max age of the snapshot, 0 accepts any age
+ * @return ErrSnapshotCorrupt if the file is truncated, of another kind or fails the checksum,
+ * ErrSnapshotVersion if it was written by another version and ErrSnapshotStale if it is
+ * older than horizon
+ */
+func ReadSnapshot(path, kind string, horizon time.Duration)(payload []byte, created time.Time, err error){
+	data,err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+	if len(data) < snapshotHeaderSize + 4 || string(data[:4]) != SNAPSHOT_MAGIC {
+		return nil, created, fmt.Errorf("%v: %s has no snapshot header",ErrSnapshotCorrupt,path)
+	}
+	content,checksum := data[:len(data)-4],binary.LittleEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(content) != checksum {
+		return nil, created, fmt.Errorf("%v: checksum of %s does not match",ErrSnapshotCorrupt,path)
+	}
+	if version := binary.LittleEndian.Uint16(data[4:6]); version != SNAPSHOT_VERSION {
+		return nil, created, fmt.Errorf("%v: %s has version %d",ErrSnapshotVersion,path,version)
+	}
+	if string(data[6:10]) != kind {
+		return nil, created, fmt.Errorf("%v: %s holds %q instead of %q",ErrSnapshotCorrupt,path,data[6:10],kind)
+	}
+	created = time.Unix(0,int64(binary.LittleEndian.Uint64(data[10:18])))
+	if length := binary.LittleEndian.Uint32(data[18:22]); int(length) != len(content) - snapshotHeaderSize {
+		return nil, created, fmt.Errorf("%v: payload length of %s does not match",ErrSnapshotCorrupt,path)
+	}
+	if horizon > 0 && time.Since(created) > horizon {
+		return nil, created, fmt.Errorf("%v: %s was taken %s ago",ErrSnapshotStale,path,time.Since(created).Truncate(time.Second))
+	}
+	return content[snapshotHeaderSize:], created, nil
+}
+
+// Encodes the payload of a snapshot in little endian byte order
+type SnapshotEncoder struct {
+	buf bytes.Buffer
+}
+
+func (e *SnapshotEncoder) PutUint8(v uint8)(){ e.buf.WriteByte(v) }
+func (e *SnapshotEncoder) PutUint16(v uint16)(){ binary.Write(&e.buf,binary.LittleEndian,v) }
+func (e *SnapshotEncoder) PutUint32(v uint32)(){ binary.Write(&e.buf,binary.LittleEndian,v) }
+func (e *SnapshotEncoder) PutInt64(v int64)(){ binary.Write(&e.buf,binary.LittleEndian,v) }
+func (e *SnapshotEncoder) PutFloat32(v float32)(){ e.PutUint32(math.Float32bits(v)) }
+func (e *SnapshotEncoder) PutFloat64(v float64)(){ e.PutInt64(int64(math.Float64bits(v))) }
+func (e *SnapshotEncoder) PutBool(v bool)(){
+	if v {
+		e.PutUint8(1)
+	} else {
+		e.PutUint8(0)
+	}
+}
+func (e *SnapshotEncoder) PutText(v string)(){
+	e.PutUint32(uint32(len(v)))
+	e.buf.WriteString(v)
+}
+
+// Returns the encoded payload
+func (e *SnapshotEncoder) Bytes()([]byte){ return e.buf.Bytes() }
+
+// Decodes a payload written by SnapshotEncoder. After the first failure all reads return
+// zero values and Err reports ErrSnapshotCorrupt.
+type SnapshotDecoder struct {
+	data []byte
+	err error
+}
+
+func NewSnapshotDecoder(payload []byte)(*SnapshotDecoder){
+	return &SnapshotDecoder{data:payload}
+}
+
+func (d *SnapshotDecoder) next(n int)([]byte){
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.data) {
+		d.err = fmt.Errorf("%v: payload is truncated",ErrSnapshotCorrupt)
+		return nil
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *SnapshotDecoder) Uint8()(uint8){
+	if b := d.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+func (d *SnapshotDecoder) Uint16()(uint16){
+	if b := d.next(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+func (d *SnapshotDecoder) Uint32()(uint32){
+	if b := d.next(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+func (d *SnapshotDecoder) Int64()(int64){
+	if b := d.next(8); b != nil {
+		return int64(binary.LittleEndian.Uint64(b))
+	}
+	return 0
+}
+func (d *SnapshotDecoder) Float32()(float32){ return math.Float32frombits(d.Uint32()) }
+func (d *SnapshotDecoder) Float64()(float64){ return math.Float64frombits(uint64(d.Int64())) }
+func (d *SnapshotDecoder) Bool()(bool){ return d.Uint8() != 0 }
+func (d *SnapshotDecoder) Text()(string){
+	return string(d.next(int(d.Uint32())))
+}
+
+// Reads a count of elements of at least size bytes each, counts that exceed the remaining
+// payload are reported as corruption
+func (d *SnapshotDecoder) Count(size int)(int){
+	n := int(d.Uint32())
+	if d.err == nil && n * size > len(d.data) {
+		d.err = fmt.Errorf("%v: %d elements exceed the payload",ErrSnapshotCorrupt,n)
+		return 0
+	}
+	return n
+}
+
+// Returns the first decoding error, ErrSnapshotCorrupt is also reported for trailing data
+func (d *SnapshotDecoder) Err()(error){
+	if d.err == nil && len(d.data) > 0 {
+		return fmt.Errorf("%v: %d trailing bytes",ErrSnapshotCorrupt,len(d.data))
+	}
+	return d.err
+}
@@ -0,0 +1,184 @@
+package system
+
+import(
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"github.com/hansen1101/go_heating/system/hardware"
+	"github.com/hansen1101/go_heating/system/w1"
+)
+
+func TestSnapshotFile(t *testing.T){
+	dir,err := ioutil.TempDir("","snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir,"state","test.snapshot")
+
+	var e SnapshotEncoder
+	e.PutText("kettle")
+	e.PutInt64(-42)
+	e.PutFloat32(1.5)
+	e.PutBool(true)
+	if err := WriteSnapshot(path,"TEST",time.Now(),e.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	payload,_,err := ReadSnapshot(path,"TEST",time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := NewSnapshotDecoder(payload)
+	if s,i,f,b := d.Text(),d.Int64(),d.Float32(),d.Bool(); s != "kettle" || i != -42 || f != 1.5 || !b || d.Err() != nil {
+		t.Error("For","payload","expected","kettle -42 1.5 true","got",s,i,f,b,d.Err())
+	}
+	if d.Uint32(); d.Err() == nil {
+		t.Error("For","read beyond the payload","expected","error","got",nil)
+	}
+
+	data,_ := ioutil.ReadFile(path)
+	tests := []struct{
+		name string
+		modify func(data []byte)([]byte)
+		expected error
+	}{
+		{"flipped bit",func(d []byte)([]byte){ d[len(d)-6] ^= 1; return d },ErrSnapshotCorrupt},
+		{"truncated",func(d []byte)([]byte){ return d[:len(d)-3] },ErrSnapshotCorrupt},
+		{"no header",func(d []byte)([]byte){ return []byte("GHS") },ErrSnapshotCorrupt},
+		{"version",func(d []byte)([]byte){
+			binary.LittleEndian.PutUint16(d[4:6],SNAPSHOT_VERSION+1)
+			binary.LittleEndian.PutUint32(d[len(d)-4:],crc32.ChecksumIEEE(d[:len(d)-4]))
+			return d
+		},ErrSnapshotVersion},
+	}
+	for _,test := range tests {
+		modified := test.modify(append([]byte(nil),data...))
+		ioutil.WriteFile(path,modified,0644)
+		if _,_,err := ReadSnapshot(path,"TEST",time.Minute); err == nil || !strings.HasPrefix(err.Error(),test.expected.Error()) {
+			t.Error("For",test.name,"expected",test.expected,"got",err)
+		}
+	}
+
+	WriteSnapshot(path,"TEST",time.Now().Add(-time.Hour),e.Bytes())
+	if _,_,err := ReadSnapshot(path,"TEST",time.Minute); err == nil || !strings.HasPrefix(err.Error(),ErrSnapshotStale.Error()) {
+		t.Error("For","stale snapshot","expected",ErrSnapshotStale,"got",err)
+	}
+	if _,_,err := ReadSnapshot(path,"ORCL",0); err == nil {
+		t.Error("For","snapshot of another kind","expected","error","got",nil)
+	}
+}
+
+func TestOracleSnapshot(t *testing.T){
+	dir,err := ioutil.TempDir("","snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir,"oracle.snapshot")
+
+	// the kettle heats up by 1 K per second for the last 20 seconds
+	start := time.Now().Truncate(time.Second).Add(-time.Second * 20)
+	percepts := make([]*Percept,0)
+	for i := 0; i < 20; i++ {
+		p := testPercept(start.Add(time.Second * time.Duration(i)),map[string]float64{"Kettle":float64(40000 + i*1000)})
+		p.Set(StateReading{Logic:"burner",State:i > 10,Valid:true})
+		p.SetTemperature(w1.NewMeasuredTemperature("28-000000000001",hardware.ROLE_OUTSIDE,5000,p.CurrentTime))
+		p.Valid = true
+		percepts = append(percepts,p)
+	}
+	tiers := []Tier{{time.Second * 10,60}}
+	source := func(percepts []*Percept)(PerceptSource){
+		return func(ctx context.Context, updates chan *Percept)(){
+			for _,p := range percepts {
+				updates <- p
+			}
+			<-ctx.Done()
+		}
+	}
+	o := NewPerceptOracle(source(percepts),60)
+	o.SetHistory(NewHistory(4,tiers...))
+	o.Start(context.Background())
+	for p,_ := o.Current(context.Background()); !p.CurrentTime.Equal(percepts[19].CurrentTime); p,_ = o.Current(context.Background()) {
+		time.Sleep(time.Millisecond * 10)
+	}
+	if err := o.Snapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	o.Stop()
+
+	// the source of the restored oracle delivers its first percept on release
+	release := make(chan struct{})
+	live := testPercept(time.Now(),map[string]float64{"Kettle":60000})
+	live.Valid = true
+	restored := NewPerceptOracle(func(ctx context.Context, updates chan *Percept)(){
+		select {
+		case <-release:
+			updates <- live
+		case <-ctx.Done():
+		}
+		<-ctx.Done()
+	},60)
+	restored.SetHistory(NewHistory(4,tiers...))
+	if err := restored.Restore(path); err != nil {
+		t.Fatal(err)
+	}
+	current := restored.window[restored.currentIndex]
+	if !current.CurrentTime.Equal(percepts[19].CurrentTime) || current.Get("Kettle").GetNumeric() != 59000 || current.Get("burner").GetNumeric() != 1 {
+		t.Error("For","restored window","expected",percepts[19],"got",current)
+	}
+	restored.Start(context.Background())
+	defer restored.Stop()
+	if err := restored.Restore(path); err != ErrOracleRunning {
+		t.Error("For","restore of a running oracle","expected",ErrOracleRunning,"got",err)
+	}
+
+	// restored percepts are not current, the request waits for the source
+	ctx,cancel := context.WithTimeout(context.Background(),time.Millisecond * 50)
+	if p,err := restored.Current(ctx); err != context.DeadlineExceeded {
+		t.Error("For","current before the first live percept","expected",context.DeadlineExceeded,"got",p,err)
+	}
+	cancel()
+	close(release)
+	if p,err := restored.Current(context.Background()); p != live {
+		t.Error("For","current after the first live percept","expected",live,"got",p,err)
+	}
+
+	if outside := current.Temperature(hardware.ROLE_OUTSIDE); outside == nil || outside.GetValue() != 5000 || outside.GetSensorId() != "28-000000000001" || current.OutsideTemp != outside {
+		t.Error("For","restored outside temperature","expected",5000,"got",outside)
+	}
+	results,err := restored.Ask(
+		Query{Logic:"Kettle",Range:time.Second * 30,Aggregation:MAX},
+		Query{Logic:"Kettle",Range:time.Minute * 5,Aggregation:MIN},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := results[0]; r.Resolution != time.Second || r.Samples != 21 || r.Value != 60000 {
+		t.Error("For","max of the restored window and the live percept","expected",60000,"got",r)
+	}
+	if r := results[1]; r.Resolution != time.Second * 10 || r.Value != 40000 {
+		t.Error("For","min of the restored history","expected",40000,"got",r)
+	}
+
+	// the window is restored without history of different tiers
+	other := NewPerceptOracle(source(nil),60)
+	other.SetHistory(NewHistory(4,Tier{time.Minute,60}))
+	if err := other.Restore(path); err != nil || other.counter != 20 || len(other.GetHistory().logics) != 0 {
+		t.Error("For","other tiers","expected","window only","got",err,other.counter)
+	}
+
+	// a corrupted snapshot is ignored
+	data,_ := ioutil.ReadFile(path)
+	data[len(data)/2] ^= 0xff
+	ioutil.WriteFile(path,data,0644)
+	empty := NewPerceptOracle(source(nil),60)
+	if err := empty.Restore(path); err == nil || empty.counter != 0 {
+		t.Error("For","corrupted snapshot","expected","error and an empty window","got",err,empty.counter)
+	}
+}
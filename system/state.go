@@ -3,6 +3,8 @@ package system
 import (
 	"time"
 	"fmt"
+	"math"
+	"strings"
 	"github.com/hansen1101/go_heating/system/logger"
 )
 
@@ -51,11 +53,27 @@ type ActorState struct{
 	time.Time
 	wPumpState, hPumpState, burnerState, triangleState bool
 	wPumpFreq, hPumpFreq int
+	overrides Overrides	// manual overrides the state was reached with, not compared
 }
 
 // ActorState implements the Stringer interface.
 func (s *ActorState) String() string {
-	return fmt.Sprintf("[STATE]\t[Time: %v]\t[B:%v] - [W:%v (%d)] - [H:%v (%d)]",s.Time,s.burnerState,s.wPumpState,s.wPumpFreq,s.hPumpState,s.hPumpFreq)
+	state := fmt.Sprintf("[STATE]\t[Time: %v]\t[B:%v] - [W:%v (%d)] - [H:%v (%d)]",s.Time,s.burnerState,s.wPumpState,s.wPumpFreq,s.hPumpState,s.hPumpFreq)
+	if len(s.overrides) > 0 {
+		state += fmt.Sprintf(" - [Overrides: %s]",strings.Join(s.overrides.Strings(),", "))
+	}
+	return state
+}
+
+// Sets the frequencies the pumps achieved during the rollout, they replace the throttles of
+// the action (see Successor)
+func (s *ActorState) SetPumpFrequencies(wFreq, hFreq float64)(){
+	s.wPumpFreq,s.hPumpFreq = int(math.Round(wFreq)),int(math.Round(hFreq))
+}
+
+// Sets the manual overrides that apply to the state
+func (s *ActorState) SetOverrides(overrides Overrides)(){
+	s.overrides = overrides
 }
 
 func (s *ActorState) SetTimeStamp(t time.Time)(){
@@ -197,10 +215,10 @@ func (s *ActorState) Insert(val ...interface{})(){
 
 	query_string = fmt.Sprintf(
 		"INSERT IGNORE INTO %s" +
-		"(time,burnerState,triangleState,wPumpState,hPumpState,hPumpFreq)" +
+		"(time,burnerState,triangleState,wPumpState,wPumpFreq,hPumpState,hPumpFreq)" +
 		" VALUES " +
-		"(%d,b'%d',b'%d',b'%d',b'%d',%d)",
-		s.GetRelationName(),s.Time.Unix(),burner,triangle,wPump,hPump,s.hPumpFreq)
+		"(%d,b'%d',b'%d',b'%d',%d,b'%d',%d)",
+		s.GetRelationName(),s.Time.Unix(),burner,triangle,wPump,s.wPumpFreq,hPump,s.hPumpFreq)
 
 	logger.StatementExecute(query_string)
 }
@@ -0,0 +1,77 @@
+package w1
+
+import(
+	"errors"
+	"math"
+	"sync"
+)
+
+var (
+	calibrations = make(map[string]Calibration)	// map: sensor id -> calibration of the sensor
+	calibrationMutex sync.RWMutex
+)
+
+/**
+ * Linear correction of the raw sensor value: corrected = gain * raw + offset.
+ * Offset is given in millidegree, a gain of 0 is treated as 1.
+ */
+type Calibration struct {
+	Offset int
+	Gain float64
+}
+
+/**
+ * Creates a calibration from two reference points, e.g. ice water and boiling water.
+ * @param raw1,raw2 raw sensor values at the reference points
+ * @param ref1,ref2 true temperatures at the reference points
+ * @return calibration mapping raw1 to ref1 and raw2 to ref2
+ */
+func TwoPointCalibration(raw1, ref1, raw2, ref2 int)(c Calibration, err error){
+	if raw1 == raw2 {
+		err = errors.New("Two point calibration requires different raw values.")
+		return
+	}
+	c.Gain = float64(ref2-ref1) / float64(raw2-raw1)
+	c.Offset = int(math.Floor(float64(ref1) - c.Gain*float64(raw1) + 0.5))
+	return
+}
+
+/**
+ * Applies the calibration to a raw sensor value
+ * @return the corrected value in millidegree
+ */
+func (c Calibration) Apply(raw int)(int){
+	gain := c.Gain
+	if gain == 0 {
+		gain = 1
+	}
+	return int(math.Floor(gain*float64(raw) + 0.5)) + c.Offset
+}
+
+// Sets the calibration that is applied to all lookups of the sensor
+func SetCalibration(sensorId string, c Calibration)(){
+	calibrationMutex.Lock()
+	calibrations[sensorId] = c
+	calibrationMutex.Unlock()
+}
+
+// Returns the calibration of the sensor, the identity if none is set
+func GetCalibration(sensorId string)(c Calibration){
+	calibrationMutex.RLock()
+	c = calibrations[sensorId]
+	calibrationMutex.RUnlock()
+	return
+}
+
+// Removes the calibrations of all sensors
+func ResetCalibrations()(){
+	calibrationMutex.Lock()
+	calibrations = make(map[string]Calibration)
+	calibrationMutex.Unlock()
+}
+
+// Stores the raw value of a lookup and replaces the value by the calibrated one
+func (t *Temperature) calibrate()(){
+	t.raw = t.value
+	t.value = GetCalibration(t.sensor).Apply(t.raw)
+}
@@ -0,0 +1,71 @@
+package w1
+
+import(
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestCalibrationApply(t *testing.T){
+	for _,c := range []struct{
+		calibration Calibration
+		raw, expected int
+	}{
+		{Calibration{},21500,21500},
+		{Calibration{Offset:-300},21500,21200},
+		{Calibration{Offset:100,Gain:1.01},20000,20300},
+	} {
+		if v := c.calibration.Apply(c.raw); v != c.expected {
+			t.Error("For",c.calibration,"expected",c.expected,"got",v)
+		}
+	}
+}
+
+func TestTwoPointCalibration(t *testing.T){
+	c,err := TwoPointCalibration(500,0,99000,100000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := c.Apply(500); v != 0 {
+		t.Error("For","ice water","expected",0,"got",v)
+	}
+	if v := c.Apply(99000); v != 100000 {
+		t.Error("For","boiling water","expected",100000,"got",v)
+	}
+	if _,err = TwoPointCalibration(500,0,500,100000); err == nil {
+		t.Error("For","equal raw values","expected","error","got",nil)
+	}
+}
+
+func TestCalibratedLookup(t *testing.T){
+	dir,err := ioutil.TempDir("","w1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(prefix string){ SENSOR_PATH_PREFIX = prefix }(SENSOR_PATH_PREFIX)
+	SENSOR_PATH_PREFIX = dir+"/"
+	defer ResetCalibrations()
+
+	id := "28-000000000001"
+	os.Mkdir(filepath.Join(dir,id),0755)
+	data := "5f 01 4b 46 7f ff 01 10 9b : crc=9b YES\n5f 01 4b 46 7f ff 01 10 9b t=45000\n"
+	if err = ioutil.WriteFile(filepath.Join(dir,id,"w1_slave"),[]byte(data),0644); err != nil {
+		t.Fatal(err)
+	}
+
+	SetCalibration(id,Calibration{Offset:-250})
+	var logDestination io.Writer = ioutil.Discard
+	var logMutex sync.Mutex
+	for _,temp := range []Temperature{
+		SensorTemperaturGenerator(id,"Kettle",&logDestination,&logMutex),
+		Init_Sensor(id,&logDestination,&logMutex)(),
+	} {
+		if !temp.IsValid() || temp.GetValue() != 44750 || temp.GetRawValue() != 45000 {
+			t.Error("For","calibrated lookup","expected","44750 (raw 45000)","got",temp.GetValue(),temp.GetRawValue())
+		}
+	}
+}
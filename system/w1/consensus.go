@@ -0,0 +1,178 @@
+package w1
+
+import(
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ConsensusTolerance = 500	// readings within this distance (millidegree) of the median agree
+	consensusStats = make(map[string]*ConsensusStat)
+	consensusMutex sync.RWMutex
+)
+
+// Disagreement statistics of the replicated lookups of a sensor
+type ConsensusStat struct {
+	Rounds int		// number of consensus decisions
+	NoQuorum int		// decisions without quorum
+	Readings int		// valid replica readings
+	Failed int		// invalid replica readings
+	Outliers int		// valid readings rejected as outlier
+	LastSpread int		// max - min of the valid readings of the last round
+	MaxSpread int		// largest spread seen so far
+}
+
+func (s ConsensusStat) String()(string){
+	return fmt.Sprintf("rounds %d\tno quorum %d\treadings %d\tfailed %d\toutliers %d\tspread %d (max %d)",
+		s.Rounds,s.NoQuorum,s.Readings,s.Failed,s.Outliers,s.LastSpread,s.MaxSpread)
+}
+
+// Returns the (lower) median of the values, thus always one of the values.
+// The values are sorted in place.
+func median(values []int)(int){
+	sort.Ints(values)
+	return values[(len(values)-1)/2]
+}
+
+func abs(i int)(int){
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+/**
+ * Decides on the temperature of a sensor from replicated readings. Readings that deviate
+ * from the median by more than ConsensusTolerance are rejected as outliers. The temperature
+ * is the mean of the agreeing readings and is only valid if at least quorum readings agree.
+ * @param readings replica readings of the same sensor
+ * @param quorum minimal number of agreeing readings
+ * @return pointer to the agreed Temperature, marked invalid if no quorum is reached
+ */
+func Agree(readings []Temperature, quorum int)(t *Temperature){
+	t = &Temperature{}
+	if len(readings) == 0 {
+		return
+	}
+	t.sensor = readings[0].sensor
+	t.system_logic = readings[0].system_logic
+
+	values := make([]int,0,len(readings))
+	for _,r := range readings {
+		if r.valid {
+			values = append(values,r.value)
+		}
+	}
+	stat := ConsensusStat{Rounds:1,Readings:len(values),Failed:len(readings)-len(values)}
+
+	if len(values) > 0 {
+		m := median(values)
+
+		var value, raw int
+		for _,r := range readings {
+			if !r.valid {
+				continue
+			}
+			if abs(r.value-m) > ConsensusTolerance {
+				stat.Outliers++
+				continue
+			}
+			t.quorum++
+			value += r.value
+			raw += r.raw
+			if r.time.After(t.time) {
+				t.time = r.time
+			}
+		}
+		t.value = int(math.Floor(float64(value)/float64(t.quorum) + 0.5))
+		t.raw = int(math.Floor(float64(raw)/float64(t.quorum) + 0.5))
+		stat.LastSpread = values[len(values)-1] - values[0]
+	}
+	t.valid = t.quorum > 0 && t.quorum >= quorum
+	if !t.valid {
+		stat.NoQuorum++
+	}
+	recordConsensus(t.sensor,stat)
+	return
+}
+
+/**
+ * The consensus TemperatureLookup spawns the replicated TemperatureLookups, collects their
+ * readings until all replicas answered or the window elapsed and decides on the temperature
+ * by Agree.
+ * @param quorum minimal number of agreeing readings
+ * @param window time to wait for the replicas
+ * @param replicas slice of TemperaturLookup functions of the same sensor
+ * @return pointer to the agreed Temperature, marked invalid if no quorum is reached
+ */
+func Consensus(quorum int, window time.Duration, replicas ...TemperatureLookup)(*Temperature){
+	c := make(chan Temperature,len(replicas))
+	for _,replica := range replicas {
+		go func(lookup TemperatureLookup){
+			c <- lookup()
+		}(replica)
+	}
+
+	readings := make([]Temperature,0,len(replicas))
+	timeout := time.After(window)
+	collect:
+	for len(readings) < len(replicas) {
+		select {
+		case temperature := <-c:
+			readings = append(readings,temperature)
+		case <-timeout:
+			break collect
+		}
+	}
+	return Agree(readings,quorum)
+}
+
+func recordConsensus(sensorId string, round ConsensusStat)(){
+	consensusMutex.Lock()
+	stat,ok := consensusStats[sensorId]
+	if !ok {
+		stat = &ConsensusStat{}
+		consensusStats[sensorId] = stat
+	}
+	stat.Rounds += round.Rounds
+	stat.NoQuorum += round.NoQuorum
+	stat.Readings += round.Readings
+	stat.Failed += round.Failed
+	stat.Outliers += round.Outliers
+	stat.LastSpread = round.LastSpread
+	if round.LastSpread > stat.MaxSpread {
+		stat.MaxSpread = round.LastSpread
+	}
+	consensusMutex.Unlock()
+}
+
+// Returns the disagreement statistics of all sensors by sensor id
+func GetConsensusStats()(stats map[string]ConsensusStat){
+	consensusMutex.RLock()
+	stats = make(map[string]ConsensusStat,len(consensusStats))
+	for id,stat := range consensusStats {
+		stats[id] = *stat
+	}
+	consensusMutex.RUnlock()
+	return
+}
+
+// Returns the disagreement statistics of the sensor
+func GetConsensusStat(sensorId string)(stat ConsensusStat){
+	consensusMutex.RLock()
+	if s,ok := consensusStats[sensorId]; ok {
+		stat = *s
+	}
+	consensusMutex.RUnlock()
+	return
+}
+
+// Removes the statistics of all sensors
+func ResetConsensusStats()(){
+	consensusMutex.Lock()
+	consensusStats = make(map[string]*ConsensusStat)
+	consensusMutex.Unlock()
+}
@@ -0,0 +1,72 @@
+package w1
+
+import(
+	"testing"
+	"time"
+)
+
+func reading(value int)(TemperatureLookup){
+	return func()(Temperature){
+		return Temperature{sensor:"28-000000000001",system_logic:"Kettle",value:value,raw:value,valid:true}
+	}
+}
+
+func failing()(Temperature){
+	return Temperature{sensor:"28-000000000001",system_logic:"Kettle"}
+}
+
+func TestAgree(t *testing.T){
+	ResetConsensusStats()
+	defer ResetConsensusStats()
+
+	// one corrupted replica is rejected as outlier
+	temp := Consensus(2,time.Second,reading(45000),reading(45200),reading(85000))
+	if !temp.IsValid() || temp.GetQuorum() != 2 || temp.GetValue() != 45100 || temp.GetSensorLogic() != "Kettle" {
+		t.Error("For","outlier","expected","45100 with quorum 2","got",temp.GetValue(),temp.GetQuorum(),temp.IsValid())
+	}
+
+	// replicas disagree, no quorum
+	temp = Consensus(2,time.Second,reading(20000),reading(45000),failing)
+	if temp.IsValid() {
+		t.Error("For","disagreement","expected","invalid","got",temp.GetValue(),temp.GetQuorum())
+	}
+
+	stat := GetConsensusStat("28-000000000001")
+	expected := ConsensusStat{Rounds:2,NoQuorum:1,Readings:5,Failed:1,Outliers:2,LastSpread:25000,MaxSpread:40000}
+	if stat != expected {
+		t.Error("For","stats","expected",expected,"got",stat)
+	}
+}
+
+func TestConsensusWindow(t *testing.T){
+	slow := func()(Temperature){
+		<-time.After(time.Second)
+		return reading(30000)()
+	}
+	start := time.Now()
+	temp := Consensus(2,20*time.Millisecond,reading(21000),reading(21100),slow)
+	if !temp.IsValid() || temp.GetQuorum() != 2 || time.Since(start) > 500*time.Millisecond {
+		t.Error("For","window","expected","quorum 2 without slow replica","got",temp.GetQuorum(),time.Since(start))
+	}
+	if temp = Consensus(2,time.Second); temp.IsValid() {
+		t.Error("For","no replicas","expected","invalid","got",temp)
+	}
+}
+
+func TestSubstitute(t *testing.T){
+	measured := time.Now().Add(-time.Minute)
+	temp := Agree([]Temperature{
+		{sensor:"28-000000000001",value:21000,valid:true,time:measured.Add(-time.Second)},
+		{sensor:"28-000000000001",value:21000,valid:true,time:measured},
+	},2)
+	if !temp.GetTime().Equal(measured) || temp.IsSubstitute() {
+		t.Fatal("For","agreed time","expected",measured,"got",temp.GetTime())
+	}
+	s := temp.Substitute()
+	if !s.IsSubstitute() || !s.IsValid() || s.GetValue() != 21000 || temp.IsSubstitute() {
+		t.Error("For","substitute","expected","marked copy","got",s)
+	}
+	if age := s.Age(measured.Add(time.Minute)); age != time.Minute {
+		t.Error("For","age","expected",time.Minute,"got",age)
+	}
+}
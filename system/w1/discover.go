@@ -0,0 +1,27 @@
+package w1
+
+import(
+	"path/filepath"
+	"sort"
+)
+
+const (
+	DS18B20_FAMILY = "28-"
+)
+
+/**
+ * Scans the w1 device directory for DS18B20 temperature sensors.
+ * @return the ids of all sensors found on the bus in ascending order
+ */
+func DiscoverSensors()(ids []string, err error){
+	var paths []string
+	if paths,err = filepath.Glob(SENSOR_PATH_PREFIX+DS18B20_FAMILY+"*"); err != nil {
+		return
+	}
+	ids = make([]string,0,len(paths))
+	for _,path := range paths {
+		ids = append(ids,filepath.Base(path))
+	}
+	sort.Strings(ids)
+	return
+}
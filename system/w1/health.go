@@ -0,0 +1,182 @@
+package w1
+
+import(
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	HEALTH_RATE_WEIGHT = 0.1	// weight of a single lookup in the moving failure rates
+	POWER_ON_VALUE = 85000		// value reported by a DS18B20 after a power-on reset
+)
+
+type HealthStatus int
+
+const (
+	HEALTHY HealthStatus = iota
+	DEGRADED	// failures occur but valid values are still delivered
+	UNHEALTHY	// sensor is disconnected, stuck or fails permanently
+)
+
+var (
+	MaxFailureRate = 0.5			// moving failure rate above which a sensor is unhealthy
+	DegradedFailureRate = 0.1		// moving failure rate above which a sensor is degraded
+	MaxSilence = time.Minute * 2		// max time without a valid value
+	StuckTimeout = time.Minute * 30		// max time of an unchanged value while related sensors change
+	healthRecords = make(map[string]*SensorHealth)
+	relatedSensors = make(map[string][]string)
+	healthMutex sync.RWMutex
+)
+
+// Health record of a single sensor
+type SensorHealth struct {
+	SensorId string
+	Lookups int			// number of lookups
+	Failures int			// number of lookups without valid value
+	CRCFailures int			// number of lookups that failed the CRC check
+	PowerOnValues int		// number of lookups that returned the power-on reset value
+	FailureRate float64		// moving rate of failed lookups
+	CRCFailureRate float64		// moving rate of lookups that failed the CRC check
+	LastLatency time.Duration	// duration of the last lookup
+	MeanLatency time.Duration	// moving average of the lookup durations
+	LastValid time.Time		// time of the last valid value
+	LastValue int			// last valid value
+	LastChange time.Time		// time the value changed the last time
+	Changes int			// number of value changes
+	Stuck bool			// value did not change for StuckTimeout while related sensors changed
+}
+
+func (s HealthStatus) String()(string){
+	switch s {
+	case HEALTHY:
+		return "healthy"
+	case DEGRADED:
+		return "degraded"
+	default:
+		return "unhealthy"
+	}
+}
+
+func (h SensorHealth) String()(string){
+	return fmt.Sprintf("%s\tfailure rate %.2f\tcrc %.2f\tlatency %s\tlast valid %s\tstuck %v\t%s",
+		h.SensorId,h.FailureRate,h.CRCFailureRate,h.MeanLatency,h.LastValid.Format(time.RFC3339),h.Stuck,h.Status(time.Now()))
+}
+
+/**
+ * Evaluates the health of the sensor at the given time
+ * @return UNHEALTHY if the sensor is stuck, delivered no valid value for MaxSilence or fails
+ * more often than MaxFailureRate, DEGRADED if it fails more often than DegradedFailureRate
+ */
+func (h SensorHealth) Status(now time.Time)(HealthStatus){
+	switch {
+	case h.Lookups == 0:
+		return HEALTHY
+	case h.Stuck || h.FailureRate > MaxFailureRate || now.Sub(h.LastValid) > MaxSilence:
+		return UNHEALTHY
+	case h.FailureRate > DegradedFailureRate || h.CRCFailureRate > DegradedFailureRate:
+		return DEGRADED
+	}
+	return HEALTHY
+}
+
+// Sets the sensors whose changes are expected to change the value of the sensor as well.
+// A sensor without related sensors is never stuck, e.g. an idle kettle keeps its value
+// while the outside temperature changes.
+func SetRelatedSensors(sensorId string, related ...string)(){
+	healthMutex.Lock()
+	relatedSensors[sensorId] = related
+	healthMutex.Unlock()
+}
+
+func movingRate(rate float64, event bool)(float64){
+	if event {
+		return rate*(1-HEALTH_RATE_WEIGHT) + HEALTH_RATE_WEIGHT
+	}
+	return rate*(1-HEALTH_RATE_WEIGHT)
+}
+
+// Records the outcome of a lookup in the health record of the sensor
+func recordLookup(data *Temperature, err error, latency time.Duration)(){
+	now := time.Now()
+	healthMutex.Lock()
+	defer healthMutex.Unlock()
+
+	h,ok := healthRecords[data.sensor]
+	if !ok {
+		h = &SensorHealth{SensorId:data.sensor,LastValid:now,LastChange:now}
+		healthRecords[data.sensor] = h
+	}
+	h.Lookups++
+	h.LastLatency = latency
+	if h.Lookups == 1 {
+		h.MeanLatency = latency
+	} else {
+		h.MeanLatency = time.Duration(float64(h.MeanLatency)*(1-HEALTH_RATE_WEIGHT) + float64(latency)*HEALTH_RATE_WEIGHT)
+	}
+	h.FailureRate = movingRate(h.FailureRate,!data.valid)
+	h.CRCFailureRate = movingRate(h.CRCFailureRate,err == ErrCRC)
+	if err == ErrCRC {
+		h.CRCFailures++
+	}
+	if !data.valid {
+		h.Failures++
+		return
+	}
+	if data.raw == POWER_ON_VALUE {
+		h.PowerOnValues++
+	}
+	if h.Lookups - h.Failures > 1 && data.raw != h.LastValue {
+		h.LastChange = now
+		h.Changes++
+	}
+	h.LastValid = now
+	h.LastValue = data.raw
+
+	// stuck if the value did not change for a long time although related sensors changed recently
+	h.Stuck = false
+	if now.Sub(h.LastChange) > StuckTimeout {
+		for _,id := range relatedSensors[h.SensorId] {
+			if r,ok := healthRecords[id]; ok && id != h.SensorId && r.Changes > 0 && now.Sub(r.LastChange) < StuckTimeout {
+				h.Stuck = true
+				break
+			}
+		}
+	}
+}
+
+// Returns the health record of the sensor
+func GetSensorHealth(sensorId string)(h SensorHealth){
+	healthMutex.RLock()
+	if record,ok := healthRecords[sensorId]; ok {
+		h = *record
+	} else {
+		h.SensorId = sensorId
+	}
+	healthMutex.RUnlock()
+	return
+}
+
+// Returns the health records of all sensors by sensor id
+func GetHealthReport()(report map[string]SensorHealth){
+	healthMutex.RLock()
+	report = make(map[string]SensorHealth,len(healthRecords))
+	for id,record := range healthRecords {
+		report[id] = *record
+	}
+	healthMutex.RUnlock()
+	return
+}
+
+// Checks whether the sensor is not flagged as UNHEALTHY
+func IsHealthy(sensorId string)(bool){
+	return GetSensorHealth(sensorId).Status(time.Now()) != UNHEALTHY
+}
+
+// Removes the health records of all sensors
+func ResetHealth()(){
+	healthMutex.Lock()
+	healthRecords = make(map[string]*SensorHealth)
+	relatedSensors = make(map[string][]string)
+	healthMutex.Unlock()
+}
@@ -0,0 +1,102 @@
+package w1
+
+import(
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func writeSlave(t *testing.T, dir, id, data string)(){
+	os.MkdirAll(filepath.Join(dir,id),0755)
+	if err := ioutil.WriteFile(filepath.Join(dir,id,"w1_slave"),[]byte(data),0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSensorHealth(t *testing.T){
+	dir,err := ioutil.TempDir("","w1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(prefix string){ SENSOR_PATH_PREFIX = prefix }(SENSOR_PATH_PREFIX)
+	SENSOR_PATH_PREFIX = dir+"/"
+	ResetHealth()
+	defer ResetHealth()
+
+	var logDestination io.Writer = ioutil.Discard
+	var logMutex sync.Mutex
+	lookup := func(id string)(){
+		SensorTemperaturGenerator(id,"",&logDestination,&logMutex)
+	}
+
+	good,broken,missing := "28-000000000001","28-000000000002","28-000000000003"
+	writeSlave(t,dir,good,"5f 01 4b 46 7f ff 01 10 9b : crc=9b YES\n5f 01 4b 46 7f ff 01 10 9b t=21000\n")
+	writeSlave(t,dir,broken,"5f 01 4b 46 7f ff 01 10 9b : crc=9b NO\n5f 01 4b 46 7f ff 01 10 9b t=21000\n")
+	for i := 0; i < 10; i++ {
+		lookup(good)
+		lookup(broken)
+		lookup(missing)
+	}
+
+	if h := GetSensorHealth(good); !IsHealthy(good) || h.Lookups != 10 || h.Failures != 0 || h.LastValue != 21000 {
+		t.Error("For","good sensor","expected","healthy","got",h)
+	}
+	if h := GetSensorHealth(broken); IsHealthy(broken) || h.CRCFailures != 10 {
+		t.Error("For","crc failures","expected","unhealthy","got",h)
+	}
+	if h := GetSensorHealth(missing); IsHealthy(missing) || h.Failures != 10 || h.CRCFailures != 0 {
+		t.Error("For","missing sensor","expected","unhealthy","got",h)
+	}
+	if len(GetHealthReport()) != 3 {
+		t.Error("For","report","expected",3,"got",len(GetHealthReport()))
+	}
+}
+
+func TestStuckSensor(t *testing.T){
+	ResetHealth()
+	defer ResetHealth()
+	defer func(timeout time.Duration){ StuckTimeout = timeout }(StuckTimeout)
+	StuckTimeout = 20 * time.Millisecond
+
+	stuck,moving,idle := "28-000000000001","28-000000000002","28-000000000003"
+	record := func(id string, value int)(){
+		recordLookup(&Temperature{sensor:id,value:value,raw:value,valid:true},nil,time.Millisecond)
+	}
+	record(stuck,21000)
+	record(moving,21000)
+	record(idle,21000)
+	<-time.After(2*StuckTimeout)
+	record(idle,21000)
+	record(stuck,21000)
+	if !IsHealthy(stuck) {
+		t.Fatal("For","no related change","expected","healthy","got",GetSensorHealth(stuck))
+	}
+
+	// an idle kettle keeps its value while the outside temperature changes, without
+	// related sensors it is not checked
+	record(moving,22000)
+	record(stuck,21000)
+	if h := GetSensorHealth(stuck); h.Stuck || !IsHealthy(stuck) {
+		t.Error("For","sensor without related sensors","expected","not stuck","got",h)
+	}
+
+	SetRelatedSensors(stuck,moving)
+	record(stuck,21000)
+	if h := GetSensorHealth(stuck); !h.Stuck || IsHealthy(stuck) {
+		t.Error("For","related sensor changed","expected","stuck","got",h)
+	}
+	SetRelatedSensors(stuck,idle)
+	record(stuck,21000)
+	if h := GetSensorHealth(stuck); h.Stuck {
+		t.Error("For","unrelated sensor changed","expected","not stuck","got",h)
+	}
+	record(stuck,21100)
+	if !IsHealthy(stuck) {
+		t.Error("For","changed value","expected","healthy","got",GetSensorHealth(stuck))
+	}
+}
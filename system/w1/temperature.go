@@ -21,6 +21,7 @@ var (
 	replica_timeout_seconds time.Duration = 15		// timeout in seconds for the replicated TemperatureLookup
 	successGenerations, failGenerations int
 	statsMutex sync.RWMutex
+	ErrCRC = errors.New("CRC check failed, YES flag could not be found.")
 )
 
 type TemperatureLookupJob struct {
@@ -39,7 +40,11 @@ type Temperature struct {
 	sensor       string
 	system_logic string
 	value        int	// tempreature value
+	raw          int	// temperature value before calibration
 	valid        bool	// validation flag
+	quorum       int	// number of agreeing replica readings, see Agree
+	time         time.Time	// time of the measurement
+	substitute   bool	// value was not measured for the current percept but taken from an earlier one
 }
 
 /**
@@ -56,10 +61,55 @@ func NewTemperature(sensorId, logic string)(*Temperature){
 	}
 }
 
+// Returns a valid temperature that was measured before, e.g. restored from a snapshot
+func NewMeasuredTemperature(sensorId, logic string, value int, measured time.Time)(*Temperature){
+	return &Temperature{
+		sensor:sensorId,
+		system_logic:logic,
+		value:value,
+		raw:value,
+		valid:true,
+		quorum:1,
+		time:measured,
+	}
+}
+
 func (t *Temperature) GetValue()(int){
 	return t.value
 }
 
+// Returns the value as read from the sensor before calibration was applied
+func (t *Temperature) GetRawValue()(int){
+	return t.raw
+}
+
+// Returns the time the value was measured
+func (t *Temperature) GetTime()(time.Time){
+	return t.time
+}
+
+// Returns the age of the value at the given time
+func (t *Temperature) Age(now time.Time)(time.Duration){
+	return now.Sub(t.time)
+}
+
+// Checks whether the value is a substitute for a missing measurement
+func (t *Temperature) IsSubstitute()(bool){
+	return t.substitute
+}
+
+// Returns a copy of the temperature that is marked as substitute for a missing measurement
+func (t *Temperature) Substitute()(*Temperature){
+	s := *t
+	s.substitute = true
+	return &s
+}
+
+// Returns the number of agreeing replica readings the value is based on
+func (t *Temperature) GetQuorum()(int){
+	return t.quorum
+}
+
 func (t *Temperature) AddValue(delta int)(){
 	t.value += delta
 	return
@@ -139,7 +189,7 @@ func extractTempDataFromFile(sensorId *string, file *os.File, logDestination *io
 	crc_valid,_ := regexp.Match("[^:]+( : crc=)[a-z0-9]{2}( YES\n)",*buf)
 	//crc_check := strings.Contains(string(*buf), "YES")
 	if !crc_valid {
-		err = errors.New("CRC check failed, YES flag could not be found.")
+		err = ErrCRC
 		return
 	}
 
@@ -198,6 +248,7 @@ func Init_Sensor(sensorId string,logDestination *io.Writer, logMutex *sync.Mutex
 		var file *os.File
 		var temp_value int
 		buf := make([]byte, 128, 128) //@todo evaluate whether this can be placed globally
+		start := time.Now()
 
 		// opens the sensor's data file in read only mode
 		sensorpath := SENSOR_PATH_PREFIX+sensorId+SENSOR_PATH_SUFFIX
@@ -208,6 +259,7 @@ func Init_Sensor(sensorId string,logDestination *io.Writer, logMutex *sync.Mutex
 			//fmt.Printf("Close sensor file at %s\n",sensorpath)
 			buf = nil
 			file.Close()
+			recordLookup(&data,err,time.Since(start))
 		}()
 
 		if err != nil {
@@ -224,13 +276,15 @@ func Init_Sensor(sensorId string,logDestination *io.Writer, logMutex *sync.Mutex
 			if temp_value > 120000 || temp_value < -60000 {
 				err = errors.New("Temperature sensor data corrupted due to electromagnetic interference.")
 			} else {
-				if temp_value == 85000 {
+				if temp_value == POWER_ON_VALUE {
 					// warning, sensor maybe not working correctly
 					warning := "Sensor might not be working properly."
 					logWarning(&sensorId, &warning, logDestination,logMutex)
 				}
 				data.valid = true
 				data.value = temp_value
+				data.time = time.Now()
+				data.calibrate()
 				return
 			}
 		}
@@ -289,6 +343,7 @@ func SensorTemperaturGenerator(sensorId, sensorLogic string,logDestination *io.W
 	var file *os.File
 	var temp_value int
 	buf := make([]byte, 128, 128) //@todo evaluate whether this can be placed globally
+	start := time.Now()
 
 	// opens the sensor's data file in read only mode
 	sensorpath := SENSOR_PATH_PREFIX+sensorId+SENSOR_PATH_SUFFIX
@@ -299,6 +354,7 @@ func SensorTemperaturGenerator(sensorId, sensorLogic string,logDestination *io.W
 		//fmt.Printf("Close sensor file at %s\n",sensorpath)
 		buf = nil
 		file.Close()
+		recordLookup(&data,err,time.Since(start))
 	}()
 
 	if err != nil {
@@ -315,13 +371,15 @@ func SensorTemperaturGenerator(sensorId, sensorLogic string,logDestination *io.W
 		if temp_value > 120000 || temp_value < -60000 {
 			err = errors.New("Temperature sensor data corrupted due to electromagnetic interference.")
 		} else {
-			if temp_value == 85000 {
+			if temp_value == POWER_ON_VALUE {
 				// warning, sensor maybe not working correctly
 				warning := "Sensor might not be working properly."
 				logWarning(&sensorId, &warning, logDestination,logMutex)
 			}
 			data.valid = true
 			data.value = temp_value
+			data.time = time.Now()
+			data.calibrate()
 			return
 		}
 	}
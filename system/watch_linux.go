@@ -0,0 +1,69 @@
+//go:build linux
+// +build linux
+
+package system
+
+import (
+	"context"
+	"os"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	WATCH_EVENTS = syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_MOVED_FROM | syscall.IN_CREATE | syscall.IN_DELETE
+)
+
+/**
+ * Watches the directory by inotify and sends the names of the files that were written,
+ * created, removed or moved (editors and atomic writes replace files by renaming them). An
+ * empty name is sent if events were lost. The channel is closed when ctx is done.
+ * @return error if the directory can not be watched
+ */
+func watchDirectory(ctx context.Context, dir string)(<-chan string, error){
+	fd,err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	if _,err = syscall.InotifyAddWatch(fd,dir,WATCH_EVENTS); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	// a non-blocking descriptor is served by the runtime poller, thus Close interrupts Read
+	file := os.NewFile(uintptr(fd),"inotify:"+dir)
+	go func(){
+		<-ctx.Done()
+		file.Close()
+	}()
+
+	names := make(chan string)
+	go func(){
+		defer close(names)
+		buffer := make([]byte,64 * 1024)
+		for {
+			n,err := file.Read(buffer)
+			if err != nil {
+				return
+			}
+			for offset := 0; offset + syscall.SizeofInotifyEvent <= n; {
+				event := (*syscall.InotifyEvent)(unsafe.Pointer(&buffer[offset]))
+				start := offset + syscall.SizeofInotifyEvent
+				offset = start + int(event.Len)
+				if offset > n {
+					break
+				}
+				name := strings.TrimRight(string(buffer[start:offset]),"\x00")
+				if event.Mask & syscall.IN_Q_OVERFLOW != 0 {
+					name = ""
+				}
+				select {
+				case names <- name:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return names, nil
+}
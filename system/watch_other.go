@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package system
+
+import (
+	"context"
+	"errors"
+)
+
+// File notifications are only available on linux, the configuration oracle polls instead.
+func watchDirectory(ctx context.Context, dir string)(<-chan string, error){
+	return nil, errors.New("file notifications are only supported on linux")
+}